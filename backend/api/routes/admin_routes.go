@@ -1 +1,29 @@
 package routes
+
+import (
+	"github.com/go-chi/chi/v5"
+	audithandlers "github.com/infortech07/cubert/internal/audit/handlers"
+	"github.com/infortech07/cubert/internal/auth/handlers"
+)
+
+func RegisterAdminRoutes(r chi.Router, authHandler *handlers.AuthHandler, aclHandler *handlers.ACLHandler, auditHandler *audithandlers.AuditHandler) {
+	r.Route("/api/v1/admin", func(r chi.Router) {
+		r.Use(authHandler.RequireAuth)
+		r.Use(authHandler.RequireAdmin)
+
+		r.Get("/users", authHandler.ListUsers)
+		r.Post("/users", authHandler.CreateUser)
+		r.Put("/users/{id}", authHandler.UpdateUser)
+		r.Delete("/users/{id}", authHandler.DeleteUser)
+		r.Delete("/users/{id}/totp", authHandler.ResetUserTOTP)
+
+		r.Get("/security", authHandler.GetSecurityPolicy)
+		r.Put("/security", authHandler.UpdateSecurityPolicy)
+
+		r.Get("/acl", aclHandler.GetACL)
+		r.Put("/acl/groups/{group}", aclHandler.SetGroupRules)
+		r.Delete("/acl/groups/{group}", aclHandler.DeleteGroupRules)
+
+		r.Get("/audit", auditHandler.QueryEvents)
+	})
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/archive/handlers"
+)
+
+func RegisterArchiveRoutes(r chi.Router, handler *handlers.ArchiveHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Route("/api/v1/archive", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Post("/extract", handler.Extract)
+		r.Post("/create", handler.Create)
+	})
+
+	r.Route("/api/v1/download", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Post("/bundle", handler.Bundle)
+	})
+}
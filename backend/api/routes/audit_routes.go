@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/audit/handlers"
+)
+
+func RegisterActivityRoutes(r chi.Router, handler *handlers.AuditHandler, requireAuth func(http.Handler) http.Handler) {
+	r.Route("/api/v1/activity", func(r chi.Router) {
+		r.Use(requireAuth)
+
+		r.Get("/recent", handler.RecentActivity)
+	})
+}
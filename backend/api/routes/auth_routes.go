@@ -1 +1,44 @@
 package routes
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/auth/handlers"
+)
+
+func RegisterAuthRoutes(r chi.Router, handler *handlers.AuthHandler, oidcHandler *handlers.OIDCHandler, aclHandler *handlers.ACLHandler) {
+	r.Route("/api/v1/auth", func(r chi.Router) {
+		r.Get("/providers", oidcHandler.Providers)
+		r.Post("/login", handler.Login)
+		r.Post("/login/verify", handler.VerifyLogin)
+		r.Post("/logout", handler.Logout)
+
+		// Single sign-on (OpenID Connect)
+		r.Get("/oidc/login", oidcHandler.Login)
+		r.Get("/oidc/callback", oidcHandler.Callback)
+
+		// Las sesiones pendientes de inscribir 2FA solo llegan hasta aquí
+		r.Group(func(r chi.Router) {
+			r.Use(handler.RequireSession)
+			r.Get("/me", handler.Me)
+			r.Post("/totp/enroll", handler.BeginTOTPEnrollment)
+			r.Post("/totp/confirm", handler.ConfirmTOTPEnrollment)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(handler.RequireAuth)
+			r.Post("/totp/disable", handler.DisableTOTP)
+			r.Post("/totp/recovery-codes", handler.RegenerateRecoveryCodes)
+			r.Get("/acl", aclHandler.MyRules)
+
+			// Claves para la API compatible con S3
+			r.Get("/api-keys", handler.ListAPIKeys)
+			r.Post("/api-keys", handler.CreateAPIKey)
+			r.Delete("/api-keys/{id}", handler.DeleteAPIKey)
+
+			// Claves públicas para el servidor SFTP
+			r.Get("/ssh-keys", handler.ListSSHKeys)
+			r.Post("/ssh-keys", handler.AddSSHKey)
+			r.Delete("/ssh-keys/{id}", handler.DeleteSSHKey)
+		})
+	})
+}
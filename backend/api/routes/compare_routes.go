@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/compare/handlers"
+)
+
+func RegisterCompareRoutes(r chi.Router, handler *handlers.CompareHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Route("/api/v1/compare", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Post("/", handler.Compare)
+		r.Post("/sync", handler.Sync)
+	})
+}
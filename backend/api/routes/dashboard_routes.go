@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/dashboard/handlers"
+)
+
+func RegisterDashboardRoutes(r chi.Router, handler *handlers.DashboardHandler, requireAuth func(http.Handler) http.Handler) {
+	r.Route("/api/v1/dashboard", func(r chi.Router) {
+		r.Use(requireAuth)
+
+		r.Get("/", handler.Overview)
+
+		r.Get("/recent", handler.GetRecent)
+		r.Post("/recent", handler.RecordRecent)
+
+		r.Get("/favorites", handler.GetFavorites)
+		r.Put("/favorites", handler.AddFavorite)
+		r.Delete("/favorites", handler.RemoveFavorite)
+		r.Delete("/favorites/{id}", handler.RemoveFavorite)
+
+		r.Get("/quick-access", handler.GetQuickAccess)
+		r.Post("/quick-access", handler.PinFolder)
+		r.Put("/quick-access/order", handler.ReorderQuickAccess)
+		r.Delete("/quick-access/{id}", handler.UnpinFolder)
+	})
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/duplicates/handlers"
+)
+
+func RegisterDuplicateRoutes(r chi.Router, handler *handlers.DuplicateHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Route("/api/v1/duplicates", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		// El estado, la cancelación y el borrado de los escaneos se gestionan en /api/v1/jobs
+		r.Post("/scans", handler.StartScan)
+		r.Post("/scans/{id}/resolve", handler.Resolve)
+	})
+}
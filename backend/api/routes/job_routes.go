@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/jobs/handlers"
+)
+
+func RegisterJobRoutes(r chi.Router, handler *handlers.JobHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Route("/api/v1/jobs", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Get("/", handler.ListJobs)
+		r.Get("/events", handler.Events)
+		r.Get("/{id}", handler.GetJob)
+		r.Get("/{id}/events", handler.Events)
+		r.Post("/{id}/cancel", handler.CancelJob)
+		r.Delete("/{id}", handler.DeleteJob)
+	})
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/quota/handlers"
+)
+
+func RegisterQuotaRoutes(r chi.Router, handler *handlers.QuotaHandler, requireAuth, requireAdmin, audit func(http.Handler) http.Handler) {
+	r.With(requireAuth).Get("/api/v1/quota", handler.GetQuota)
+
+	r.Route("/api/v1/admin/quotas", func(r chi.Router) {
+		r.Use(requireAuth, requireAdmin)
+
+		r.Get("/", handler.Overview)
+		r.With(audit).Put("/users/{id}", handler.SetUserLimits)
+		r.With(audit).Delete("/users/{id}", handler.DeleteUserLimits)
+		r.With(audit).Put("/roots", handler.SetRootLimits)
+		r.With(audit).Delete("/roots", handler.DeleteRootLimits)
+	})
+}
@@ -1,18 +1,70 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/infortech07/cubert/internal/filesystem/handlers"
 )
 
-func RegisterFilesystemRoutes(r chi.Router, handler *handlers.FilesystemHandler) {
+func RegisterFilesystemRoutes(r chi.Router, handler *handlers.FilesystemHandler, middlewares ...func(http.Handler) http.Handler) {
 	r.Route("/api/v1/filesystem", func(r chi.Router) {
+		r.Use(middlewares...)
+
 		r.Get("/scan", handler.ScanDirectory)
 		r.Get("/list", handler.ListDirectory)
 		r.Get("/info", handler.GetFileInfo)
+		r.Get("/download", handler.DownloadFile)
 		r.Get("/stats", handler.GetDirectoryStats)
+		r.Get("/usage", handler.GetDiskUsage)
+		r.Get("/usage/largest", handler.GetLargestFiles)
 		r.Get("/search", handler.SearchFiles)
 		r.Get("/roots", handler.GetSystemRoots)
 		r.Post("/validate", handler.ValidatePath)
+
+		r.Put("/file", handler.UploadFile)
+		r.Delete("/file", handler.DeleteFile)
+		r.Post("/mkdir", handler.CreateDirectory)
+		r.Post("/rename", handler.Rename)
+	})
+}
+
+// RegisterContentRoutes adds the editor and preview endpoints below /api/v1/filesystem
+func RegisterContentRoutes(r chi.Router, handler *handlers.ContentHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Get("/api/v1/filesystem/content", handler.GetContent)
+		r.Put("/api/v1/filesystem/content", handler.PutContent)
+		r.Get("/api/v1/filesystem/preview", handler.GetPreview)
+	})
+}
+
+func RegisterTrashRoutes(r chi.Router, handler *handlers.TrashHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Route("/api/v1/trash", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Get("/", handler.ListTrash)
+		r.Post("/{id}/restore", handler.RestoreTrash)
+		r.Delete("/{id}", handler.PurgeTrash)
+	})
+}
+
+func RegisterVersionRoutes(r chi.Router, handler *handlers.VersionHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Route("/api/v1/versions", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Get("/", handler.ListVersions)
+		r.Get("/{id}", handler.GetVersion)
+		r.Get("/{id}/content", handler.GetVersionContent)
+		r.Post("/{id}/restore", handler.RestoreVersion)
+	})
+}
+
+func RegisterDiffRoutes(r chi.Router, handler *handlers.DiffHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Route("/api/v1/diff", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Get("/", handler.Diff)
 	})
 }
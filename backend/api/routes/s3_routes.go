@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/s3/domain"
+	"github.com/infortech07/cubert/internal/s3/handlers"
+)
+
+// RegisterS3Routes mounts the S3-compatible API; the handler dispatches on bucket, key and query
+func RegisterS3Routes(r chi.Router, handler *handlers.S3Handler, middlewares ...func(http.Handler) http.Handler) {
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Handle(domain.Prefix, handler)
+		r.Handle(domain.Prefix+"/*", handler)
+	})
+}
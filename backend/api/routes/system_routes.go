@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/system/handlers"
+)
+
+func RegisterSystemRoutes(r chi.Router, handler *handlers.SystemHandler, requireAuth, requireAdmin func(http.Handler) http.Handler) {
+	r.Route("/api/v1/system", func(r chi.Router) {
+		r.Use(requireAuth)
+
+		r.Get("/stats", handler.GetStats)
+		r.With(requireAdmin).Post("/stats/reindex", handler.Reindex)
+	})
+}
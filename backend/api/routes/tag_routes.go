@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/tags/handlers"
+)
+
+func RegisterTagRoutes(r chi.Router, handler *handlers.TagHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Route("/api/v1/tags", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Get("/", handler.Get)
+		r.Put("/", handler.Set)
+		r.Patch("/", handler.Update)
+		r.Delete("/", handler.Delete)
+		r.Get("/list", handler.List)
+		r.Get("/search", handler.Search)
+	})
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/vault/handlers"
+)
+
+func RegisterVaultRoutes(r chi.Router, handler *handlers.VaultHandler, middlewares ...func(http.Handler) http.Handler) {
+	r.Route("/api/v1/vaults", func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Get("/", handler.List)
+		r.Post("/", handler.Create)
+		r.Post("/unlock", handler.Unlock)
+		r.Post("/lock", handler.Lock)
+	})
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/infortech07/cubert/internal/webdav/domain"
+	"github.com/infortech07/cubert/internal/webdav/handlers"
+)
+
+// RegisterWebDAVRoutes mounts the WebDAV server, which handles every method below its prefix
+func RegisterWebDAVRoutes(r chi.Router, handler *handlers.WebDAVHandler, middlewares ...func(http.Handler) http.Handler) {
+	for _, method := range handlers.Methods {
+		chi.RegisterMethod(method)
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Handle(domain.Prefix, handler)
+		r.Handle(domain.Prefix+"/*", handler)
+	})
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,9 +20,44 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"github.com/infortech07/cubert/api/routes"
+	archivedomain "github.com/infortech07/cubert/internal/archive/domain"
+	archivehandlers "github.com/infortech07/cubert/internal/archive/handlers"
+	archiveservices "github.com/infortech07/cubert/internal/archive/services"
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	audithandlers "github.com/infortech07/cubert/internal/audit/handlers"
+	auditservices "github.com/infortech07/cubert/internal/audit/services"
+	authhandlers "github.com/infortech07/cubert/internal/auth/handlers"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	comparehandlers "github.com/infortech07/cubert/internal/compare/handlers"
+	compareservices "github.com/infortech07/cubert/internal/compare/services"
+	dashboardhandlers "github.com/infortech07/cubert/internal/dashboard/handlers"
+	dashboardservices "github.com/infortech07/cubert/internal/dashboard/services"
+	duplicatehandlers "github.com/infortech07/cubert/internal/duplicates/handlers"
+	duplicateservices "github.com/infortech07/cubert/internal/duplicates/services"
+	fsdomain "github.com/infortech07/cubert/internal/filesystem/domain"
 	"github.com/infortech07/cubert/internal/filesystem/handlers"
 	"github.com/infortech07/cubert/internal/filesystem/services"
+	jobhandlers "github.com/infortech07/cubert/internal/jobs/handlers"
+	jobservices "github.com/infortech07/cubert/internal/jobs/services"
+	quotadomain "github.com/infortech07/cubert/internal/quota/domain"
+	quotahandlers "github.com/infortech07/cubert/internal/quota/handlers"
+	quotaservices "github.com/infortech07/cubert/internal/quota/services"
+	s3handlers "github.com/infortech07/cubert/internal/s3/handlers"
+	s3services "github.com/infortech07/cubert/internal/s3/services"
+	sftpdomain "github.com/infortech07/cubert/internal/sftp/domain"
+	sftphandlers "github.com/infortech07/cubert/internal/sftp/handlers"
+	sftpservices "github.com/infortech07/cubert/internal/sftp/services"
+	"github.com/infortech07/cubert/internal/shared/config"
 	"github.com/infortech07/cubert/internal/shared/utils"
+	storageservices "github.com/infortech07/cubert/internal/storage/services"
+	systemhandlers "github.com/infortech07/cubert/internal/system/handlers"
+	systemservices "github.com/infortech07/cubert/internal/system/services"
+	taghandlers "github.com/infortech07/cubert/internal/tags/handlers"
+	tagservices "github.com/infortech07/cubert/internal/tags/services"
+	vaulthandlers "github.com/infortech07/cubert/internal/vault/handlers"
+	vaultservices "github.com/infortech07/cubert/internal/vault/services"
+	webdavhandlers "github.com/infortech07/cubert/internal/webdav/handlers"
+	webdavservices "github.com/infortech07/cubert/internal/webdav/services"
 )
 
 //go:embed static
@@ -29,23 +67,186 @@ func main() {
 	// Cargar variables de entorno
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
-	} // Puerto del servidor
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
 	}
 
+	cfg := config.Load()
+	port := cfg.Port
+
 	// Configurar servicios
-	scannerService := services.NewScannerService()
-	explorerService := services.NewExplorerService(scannerService)
+	// Las bibliotecas remotas se montan sobre el disco local
+	storage, err := storageservices.LoadMounts(context.Background(), cfg.RemoteRootsFile, storageservices.NewLocalStorage())
+	if err != nil {
+		log.Fatalf("Failed to open remote roots: %v", err)
+	}
+	// Las bóvedas cifran nombres y contenido por encima de cualquier almacenamiento
+	vaultService, err := vaultservices.NewVaultService(cfg.DataDir, storage)
+	if err != nil {
+		log.Fatalf("Failed to open vault registry: %v", err)
+	}
+	vaultStorage := vaultservices.NewVaultStorage(storage, vaultService)
+	scannerService := services.NewScannerService(vaultStorage)
+	archiveService := services.NewArchiveService(vaultStorage)
+	explorerService := services.NewExplorerService(vaultStorage, scannerService, archiveService)
+	previewService := services.NewPreviewService(explorerService)
+	diskUsageService := services.NewDiskUsageService()
+	trashService, err := services.NewTrashService(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open trash: %v", err)
+	}
+	versionService, err := services.NewVersionService(cfg.DataDir, cfg.LibraryRoots, fsdomain.VersionRetention{
+		KeepLast:      cfg.VersionsKeepLast,
+		KeepDailyDays: cfg.VersionsKeepDailyDays,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open version history: %v", err)
+	}
+	// Las cuotas parten del índice de las bibliotecas y se actualizan con cada cambio
+	quotaService, err := quotaservices.NewQuotaService(quotaservices.QuotaConfig{
+		UserLimits: quotadomain.Limits{
+			MaxBytes: int64(cfg.QuotaUserMaxMB) << 20,
+			MaxFiles: int64(cfg.QuotaUserMaxFiles),
+		},
+		RootLimits: quotadomain.Limits{
+			MaxBytes: int64(cfg.QuotaRootMaxMB) << 20,
+			MaxFiles: int64(cfg.QuotaRootMaxFiles),
+		},
+		SoftPercent: cfg.QuotaSoftPercent,
+	}, cfg.DataDir, cfg.LibraryRoots, versionService)
+	if err != nil {
+		log.Fatalf("Failed to open quotas: %v", err)
+	}
+	contentService := services.NewContentService(explorerService, versionService, quotaService)
+	diffService := services.NewDiffService(explorerService, versionService)
+
+	// Los trabajos largos se ejecutan fuera de la petición HTTP y sobreviven a reinicios
+	jobService, err := jobservices.NewJobService(jobservices.JobConfig{
+		Dir:       cfg.JobsDir,
+		Workers:   cfg.JobWorkers,
+		Retention: cfg.JobRetention,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open job store: %v", err)
+	}
+	duplicateService := duplicateservices.NewDuplicateService(jobService, trashService)
+	archiveJobService := archiveservices.NewArchiveService(jobService, archivedomain.Limits{
+		MaxBytes:   int64(cfg.ExtractMaxSizeMB) << 20,
+		MaxEntries: int64(cfg.ExtractMaxEntries),
+	}, quotaService, vaultStorage)
+	compareService := compareservices.NewCompareService(scannerService, trashService, jobService, quotaService, vaultStorage)
+
+	userStore, err := authservices.NewUserStore(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to load users: %v", err)
+	}
+	sessionStore := authservices.NewSessionStore()
+	totpService := authservices.NewTOTPService(cfg.TOTPIssuer)
+	authService := authservices.NewAuthService(userStore, sessionStore, totpService, cfg.SessionTTL)
+	aclService := authservices.NewACLService(userStore)
+	oidcService := authservices.NewOIDCService(authservices.OIDCConfig{
+		Issuer:        cfg.OIDCIssuer,
+		ClientID:      cfg.OIDCClientID,
+		ClientSecret:  cfg.OIDCClientSecret,
+		RedirectURL:   cfg.OIDCRedirectURL,
+		Scopes:        cfg.OIDCScopes,
+		UsernameClaim: cfg.OIDCUsernameClaim,
+		GroupsClaim:   cfg.OIDCGroupsClaim,
+		RolesClaim:    cfg.OIDCRolesClaim,
+		AdminGroups:   cfg.OIDCAdminGroups,
+		ProviderName:  cfg.OIDCProviderName,
+	}, userStore, authService)
+
+	if err := authService.EnsureAdmin(cfg.AdminUsername, cfg.AdminPassword); err != nil {
+		log.Fatalf("Failed to create initial admin user: %v", err)
+	}
+	if err := authService.ApplyDefaultPolicy(cfg.Require2FARoles); err != nil {
+		log.Fatalf("Failed to apply 2FA policy: %v", err)
+	}
+
+	auditService, err := auditservices.NewAuditService(auditservices.AuditConfig{
+		Dir:           cfg.AuditDir,
+		MaxSizeBytes:  int64(cfg.AuditMaxSizeMB) << 20,
+		MaxFiles:      cfg.AuditMaxFiles,
+		RetentionDays: cfg.AuditRetentionDays,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer auditService.Close()
+
+	// Los dashboards se actualizan a partir de los eventos de auditoría
+	dashboardService, err := dashboardservices.NewDashboardService(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open dashboard store: %v", err)
+	}
+	auditService.Subscribe(dashboardService.HandleAuditEvent)
+
+	// Las etiquetas siguen a los archivos renombrados, movidos o borrados desde Cubert
+	tagService, err := tagservices.NewTagService(cfg.DataDir, cfg.TagsXattr)
+	if err != nil {
+		log.Fatalf("Failed to open tag store: %v", err)
+	}
+	auditService.Subscribe(tagService.HandleAuditEvent)
+
+	// Las modificaciones invalidan los análisis de uso de disco que las contienen
+	auditService.Subscribe(func(event auditdomain.Event) {
+		if event.Method == http.MethodGet || event.Result != auditdomain.ResultSuccess {
+			return
+		}
+		for _, path := range event.Paths {
+			diskUsageService.Invalidate(path)
+		}
+	})
+
+	// Indexar las bibliotecas en segundo plano para las estadísticas del sistema
+	indexCtx, stopIndexer := context.WithCancel(context.Background())
+	defer stopIndexer()
+	libraryIndexer := systemservices.NewLibraryIndexer(cfg.LibraryRoots, cfg.StatsIndexInterval)
+	libraryIndexer.Subscribe(quotaService.HandleIndex)
+	libraryIndexer.Start(indexCtx)
+	quotaService.Start(indexCtx)
+	auditService.Subscribe(quotaService.HandleAuditEvent)
+	statsService := systemservices.NewStatsService(cfg.LibraryRoots, libraryIndexer, jobService)
+	webdavFS := webdavservices.NewFileSystem(cfg.LibraryRoots, aclService, trashService, versionService, quotaService, vaultStorage)
+	s3Gateway, err := s3services.NewGatewayService(cfg.DataDir, cfg.LibraryRoots, aclService, trashService, versionService, quotaService, vaultStorage)
+	if err != nil {
+		log.Fatalf("Failed to start S3 gateway: %v", err)
+	}
+
+	// Todos los tipos de trabajo están registrados: reanudar los pendientes
+	jobService.Start()
 
 	// Configurar handlers
-	filesystemHandler := handlers.NewFilesystemHandler(scannerService, explorerService)
+	authHandler := authhandlers.NewAuthHandler(authService)
+	appHandlers := &serverHandlers{
+		filesystem: handlers.NewFilesystemHandler(scannerService, explorerService, diskUsageService, trashService, versionService, quotaService, tagService, aclService),
+		auth:       authHandler,
+		oidc:       authhandlers.NewOIDCHandler(oidcService, authHandler, cfg.OIDCPostLoginURL),
+		acl:        authhandlers.NewACLHandler(aclService),
+		audit:      audithandlers.NewAuditHandler(auditService),
+		dashboard:  dashboardhandlers.NewDashboardHandler(dashboardService, aclService),
+		system:     systemhandlers.NewSystemHandler(statsService),
+		content:    handlers.NewContentHandler(contentService, previewService, aclService),
+		trash:      handlers.NewTrashHandler(trashService, aclService),
+		versions:   handlers.NewVersionHandler(versionService, aclService),
+		diff:       handlers.NewDiffHandler(diffService, versionService, aclService),
+		duplicates: duplicatehandlers.NewDuplicateHandler(duplicateService, aclService),
+		jobs:       jobhandlers.NewJobHandler(jobService),
+		archive:    archivehandlers.NewArchiveHandler(archiveJobService, vaultStorage, aclService),
+		compare:    comparehandlers.NewCompareHandler(compareService, aclService),
+		webdav:     webdavhandlers.NewWebDAVHandler(webdavFS),
+		s3:         s3handlers.NewS3Handler(s3Gateway, s3services.NewSignatureService(authService)),
+		vaults:     vaulthandlers.NewVaultHandler(vaultService, aclService),
+		quotas:     quotahandlers.NewQuotaHandler(quotaService, aclService, userStore),
+		tags:       taghandlers.NewTagHandler(tagService, aclService),
+	}
 
 	// Configurar router
-	router := setupRouter(filesystemHandler, port)
+	router := setupRouter(appHandlers, port)
 
-	// Crear servidor HTTP
+	// Crear servidor HTTP. Las peticiones heredan baseCtx, que se cancela al apagar para
+	// que los flujos largos (eventos SSE de trabajos) no retengan el cierre
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 	server := &http.Server{
 		Addr:           ":" + port,
 		Handler:        router,
@@ -53,7 +254,9 @@ func main() {
 		WriteTimeout:   30 * time.Second,
 		IdleTimeout:    60 * time.Second,
 		MaxHeaderBytes: 1 << 20, // 1MB
+		BaseContext:    func(net.Listener) context.Context { return baseCtx },
 	}
+	server.RegisterOnShutdown(cancelRequests)
 
 	// Iniciar servidor en goroutine
 	go func() {
@@ -66,6 +269,34 @@ func main() {
 		}
 	}()
 
+	// Servidor SFTP opcional con los mismos usuarios, ACL y auditoría
+	var sftpServer *sftpservices.Server
+	if cfg.SFTPAddr != "" {
+		sftpHandler := sftphandlers.NewSFTPHandler(webdavFS, auditService)
+		sftpServer, err = sftpservices.NewServer(sftpservices.ServerConfig{
+			Addr:        cfg.SFTPAddr,
+			HostKeyFile: cfg.SFTPHostKeyFile,
+		}, authService, sftpHandler.Handlers)
+		if err != nil {
+			log.Fatalf("Failed to start SFTP server: %v", err)
+		}
+		go func() {
+			log.Printf("📂 SFTP server listening on %s", cfg.SFTPAddr)
+			if err := sftpServer.ListenAndServe(); err != nil && !errors.Is(err, sftpdomain.ErrServerClosed) {
+				log.Fatalf("SFTP server failed to start: %v", err)
+			}
+		}()
+	}
+
+	// Limpiar sesiones expiradas periódicamente
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			sessionStore.Cleanup()
+		}
+	}()
+
 	// Esperar señal de interrupción
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -77,13 +308,49 @@ func main() {
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		log.Printf("Server forced to shutdown: %v", err)
+		server.Close()
+	}
+	if sftpServer != nil {
+		if err := sftpServer.Shutdown(ctx); err != nil {
+			log.Printf("SFTP sessions closed before finishing: %v", err)
+		}
+	}
+	if err := jobService.Shutdown(ctx); err != nil {
+		log.Printf("Jobs did not stop in time: %v", err)
+	}
+	if err := quotaService.Flush(); err != nil {
+		log.Printf("Quota ledger not saved: %v", err)
 	}
 
 	log.Println("✅ Server exited")
 }
 
-func setupRouter(filesystemHandler *handlers.FilesystemHandler, port string) chi.Router {
+// serverHandlers groups the HTTP handlers of every module
+type serverHandlers struct {
+	filesystem *handlers.FilesystemHandler
+	auth       *authhandlers.AuthHandler
+	oidc       *authhandlers.OIDCHandler
+	acl        *authhandlers.ACLHandler
+	audit      *audithandlers.AuditHandler
+	dashboard  *dashboardhandlers.DashboardHandler
+	system     *systemhandlers.SystemHandler
+	content    *handlers.ContentHandler
+	trash      *handlers.TrashHandler
+	versions   *handlers.VersionHandler
+	diff       *handlers.DiffHandler
+	duplicates *duplicatehandlers.DuplicateHandler
+	jobs       *jobhandlers.JobHandler
+	archive    *archivehandlers.ArchiveHandler
+	compare    *comparehandlers.CompareHandler
+	webdav     *webdavhandlers.WebDAVHandler
+	s3         *s3handlers.S3Handler
+	vaults     *vaulthandlers.VaultHandler
+	quotas     *quotahandlers.QuotaHandler
+	tags       *taghandlers.TagHandler
+}
+
+func setupRouter(h *serverHandlers, port string) chi.Router {
 	r := chi.NewRouter()
 
 	// Middleware
@@ -149,20 +416,64 @@ func setupRouter(filesystemHandler *handlers.FilesystemHandler, port string) chi
 			"description": "Local filesystem exploration and management API with embedded frontend",
 			"embedded":    true,
 			"endpoints": map[string]string{
-				"scan":     "/api/v1/filesystem/scan?path=/your/path",
-				"list":     "/api/v1/filesystem/list?path=/your/path",
-				"info":     "/api/v1/filesystem/info?path=/your/path",
-				"stats":    "/api/v1/filesystem/stats?path=/your/path",
-				"search":   "/api/v1/filesystem/search?path=/your/path&q=query",
-				"roots":    "/api/v1/filesystem/roots",
-				"validate": "/api/v1/filesystem/validate",
+				"scan":       "/api/v1/filesystem/scan?path=/your/path",
+				"list":       "/api/v1/filesystem/list?path=/your/path",
+				"info":       "/api/v1/filesystem/info?path=/your/path",
+				"download":   "/api/v1/filesystem/download?path=/your/file",
+				"content":    "/api/v1/filesystem/content?path=/your/file",
+				"preview":    "/api/v1/filesystem/preview?path=/your/file",
+				"versions":   "/api/v1/versions?path=/your/file",
+				"diff":       "/api/v1/diff?a=/file/one&b=/file/two",
+				"stats":      "/api/v1/filesystem/stats?path=/your/path",
+				"search":     "/api/v1/filesystem/search?path=/your/path&q=query",
+				"roots":      "/api/v1/filesystem/roots",
+				"validate":   "/api/v1/filesystem/validate",
+				"login":      "/api/v1/auth/login",
+				"totp":       "/api/v1/auth/totp/enroll",
+				"sso":        "/api/v1/auth/oidc/login",
+				"dashboard":  "/api/v1/dashboard",
+				"system":     "/api/v1/system/stats",
+				"duplicates": "/api/v1/duplicates/scans",
+				"trash":      "/api/v1/trash",
+				"jobs":       "/api/v1/jobs",
+				"archive":    "/api/v1/archive/extract",
+				"compare":    "/api/v1/compare",
+				"vaults":     "/api/v1/vaults",
+				"quota":      "/api/v1/quota",
+				"tags":       "/api/v1/tags",
+				"webdav":     "/dav/",
+				"s3":         "/s3",
 			},
 		}
 		utils.WriteJSONResponse(w, http.StatusOK, info)
 	})
 
-	// Registrar rutas del filesystem
-	routes.RegisterFilesystemRoutes(r, filesystemHandler)
+	// Registrar rutas de autenticación y administración
+	routes.RegisterAuthRoutes(r, h.auth, h.oidc, h.acl)
+	routes.RegisterAdminRoutes(r, h.auth, h.acl, h.audit)
+	routes.RegisterActivityRoutes(r, h.audit, h.auth.RequireAuth)
+	routes.RegisterDashboardRoutes(r, h.dashboard, h.auth.RequireAuth)
+	routes.RegisterSystemRoutes(r, h.system, h.auth.RequireAuth, h.auth.RequireAdmin)
+	routes.RegisterJobRoutes(r, h.jobs, h.auth.RequireAuth)
+
+	// Registrar rutas del filesystem (auditadas)
+	routes.RegisterFilesystemRoutes(r, h.filesystem, h.auth.RequireAuth, h.audit.Middleware)
+	routes.RegisterContentRoutes(r, h.content, h.auth.RequireAuth, h.audit.Middleware)
+	routes.RegisterTrashRoutes(r, h.trash, h.auth.RequireAuth, h.audit.Middleware)
+	routes.RegisterVersionRoutes(r, h.versions, h.auth.RequireAuth, h.audit.Middleware)
+	routes.RegisterDiffRoutes(r, h.diff, h.auth.RequireAuth, h.audit.Middleware)
+	routes.RegisterDuplicateRoutes(r, h.duplicates, h.auth.RequireAuth, h.audit.Middleware)
+	routes.RegisterArchiveRoutes(r, h.archive, h.auth.RequireAuth, h.audit.Middleware)
+	routes.RegisterCompareRoutes(r, h.compare, h.auth.RequireAuth, h.audit.Middleware)
+	routes.RegisterVaultRoutes(r, h.vaults, h.auth.RequireAuth, h.audit.Middleware)
+	routes.RegisterQuotaRoutes(r, h.quotas, h.auth.RequireAuth, h.auth.RequireAdmin, h.audit.Middleware)
+	routes.RegisterTagRoutes(r, h.tags, h.auth.RequireAuth, h.audit.Middleware)
+
+	// WebDAV para montar las raíces como unidad de red
+	routes.RegisterWebDAVRoutes(r, h.webdav, h.auth.RequireBasicAuth, h.audit.Middleware)
+
+	// API compatible con S3 firmada con las claves de API de los usuarios
+	routes.RegisterS3Routes(r, h.s3, h.s3.Authenticate, h.audit.Middleware)
 
 	// Swagger documentation
 	r.Get("/docs/swagger.yaml", func(w http.ResponseWriter, r *http.Request) {
@@ -179,7 +490,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
-		if r.Method == "OPTIONS" {
+		// Los clientes WebDAV usan OPTIONS para descubrir las clases soportadas
+		if r.Method == "OPTIONS" && !strings.HasPrefix(r.URL.Path, "/dav") {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
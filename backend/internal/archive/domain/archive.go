@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"errors"
+)
+
+// Job types of the archive module
+const (
+	JobTypeExtract = "archive.extract"
+	JobTypeCreate  = "archive.create"
+)
+
+type Format string
+
+const (
+	FormatZip     Format = "zip"
+	FormatTar     Format = "tar"
+	FormatTarGzip Format = "tar.gz"
+)
+
+// ConflictPolicy decides what happens when a target file already exists
+type ConflictPolicy string
+
+const (
+	ConflictFail      ConflictPolicy = "fail"
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictRename    ConflictPolicy = "rename"
+)
+
+func (p ConflictPolicy) IsValid() bool {
+	switch p {
+	case ConflictFail, ConflictSkip, ConflictOverwrite, ConflictRename:
+		return true
+	}
+	return false
+}
+
+type ExtractRequest struct {
+	Archive     string         `json:"archive"`
+	Destination string         `json:"destination"`
+	Conflict    ConflictPolicy `json:"conflict"`
+}
+
+type ExtractResult struct {
+	Destination string `json:"destination"`
+	Files       int64  `json:"files"`
+	Directories int64  `json:"directories"`
+	Bytes       int64  `json:"bytes"`
+	// Skipped lists entries not written: existing files with the skip policy, links and devices
+	Skipped []string `json:"skipped,omitempty"`
+	// Renamed maps entry names to the path they were written to with the rename policy
+	Renamed map[string]string `json:"renamed,omitempty"`
+}
+
+type CreateRequest struct {
+	Paths       []string       `json:"paths"`
+	Destination string         `json:"destination"`
+	Format      Format         `json:"format"`
+	Conflict    ConflictPolicy `json:"conflict"`
+}
+
+type CreateResult struct {
+	Archive string `json:"archive"`
+	Format  Format `json:"format"`
+	Files   int64  `json:"files"`
+	Bytes   int64  `json:"bytes"`
+	Size    int64  `json:"size"`
+}
+
+// BundleRequest streams a selection as a single download
+type BundleRequest struct {
+	Paths  []string `json:"paths"`
+	Format Format   `json:"format"`
+	// Compression is "deflate" (default) or "store" for zip bundles
+	Compression string `json:"compression"`
+	// Name of the downloaded file without extension; defaults to the single path or "download"
+	Name string `json:"name"`
+}
+
+// Limits protect extraction against archive bombs
+type Limits struct {
+	MaxBytes   int64
+	MaxEntries int64
+}
+
+var (
+	ErrUnsafeEntry      = errors.New("archive entry escapes the destination")
+	ErrLimitExceeded    = errors.New("archive exceeds the extraction limits")
+	ErrTargetExists     = errors.New("target already exists")
+	ErrInvalidConflict  = errors.New("invalid conflict policy")
+	ErrInvalidFormat    = errors.New("invalid archive format")
+	ErrNotAnArchive     = errors.New("file is not a supported archive")
+	ErrNothingToArchive = errors.New("no paths to archive")
+)
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/infortech07/cubert/internal/archive/domain"
+	"github.com/infortech07/cubert/internal/archive/services"
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	jobdomain "github.com/infortech07/cubert/internal/jobs/domain"
+	jobhandlers "github.com/infortech07/cubert/internal/jobs/handlers"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	vaultdomain "github.com/infortech07/cubert/internal/vault/domain"
+)
+
+type ArchiveHandler struct {
+	archiveService *services.ArchiveService
+	storage        storagedomain.Storage
+	aclService     *authservices.ACLService
+}
+
+// NewArchiveHandler serves bundle downloads from storage, the same view of the libraries
+// the explorer has (remote roots, unlocked vaults)
+func NewArchiveHandler(archiveService *services.ArchiveService, storage storagedomain.Storage, aclService *authservices.ACLService) *ArchiveHandler {
+	return &ArchiveHandler{
+		archiveService: archiveService,
+		storage:        storage,
+		aclService:     aclService,
+	}
+}
+
+// Extract queues the extraction of an archive into a folder
+func (h *ArchiveHandler) Extract(w http.ResponseWriter, r *http.Request) {
+	var request domain.ExtractRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if request.Archive == "" || request.Destination == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Archive and destination are required", nil)
+		return
+	}
+
+	if !h.authorize(w, r, request.Archive, authdomain.AccessRead) ||
+		!h.authorize(w, r, request.Destination, authdomain.AccessWrite) {
+		return
+	}
+	auditdomain.AddPaths(r.Context(), request.Archive, request.Destination)
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	job, err := h.archiveService.StartExtract(r.Context(), user.ID, request)
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+
+	jobhandlers.WriteJobAccepted(w, job)
+}
+
+// Create queues a job that packs the selected paths into a new archive
+func (h *ArchiveHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request domain.CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if len(request.Paths) == 0 || request.Destination == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Paths and destination are required", nil)
+		return
+	}
+
+	for _, path := range request.Paths {
+		if !h.authorize(w, r, path, authdomain.AccessRead) {
+			return
+		}
+	}
+	if !h.authorize(w, r, request.Destination, authdomain.AccessWrite) {
+		return
+	}
+	auditdomain.AddPaths(r.Context(), request.Destination)
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	job, err := h.archiveService.StartCreate(r.Context(), user.ID, request)
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+
+	jobhandlers.WriteJobAccepted(w, job)
+}
+
+// Bundle streams the selected files and folders as a zip, tar or tar.gz without creating
+// a temporary archive. A client disconnect cancels the walk through the request context.
+func (h *ArchiveHandler) Bundle(w http.ResponseWriter, r *http.Request) {
+	var request domain.BundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if len(request.Paths) == 0 {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Paths are required", nil)
+		return
+	}
+	if request.Format == "" {
+		request.Format = domain.FormatZip
+	}
+	if request.Compression != "" && request.Compression != "deflate" && request.Compression != "store" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Compression must be deflate or store", nil)
+		return
+	}
+
+	extension, contentType := "", ""
+	switch request.Format {
+	case domain.FormatZip:
+		extension, contentType = ".zip", "application/zip"
+	case domain.FormatTar:
+		extension, contentType = ".tar", "application/x-tar"
+	case domain.FormatTarGzip:
+		extension, contentType = ".tar.gz", "application/gzip"
+	default:
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request", domain.ErrInvalidFormat)
+		return
+	}
+
+	for i, path := range request.Paths {
+		if !h.authorize(w, r, path, authdomain.AccessRead) {
+			return
+		}
+		request.Paths[i] = filepath.Clean(path)
+		if locks, ok := h.storage.(storagedomain.LockChecker); ok && locks.Locked(r.Context(), request.Paths[i]) {
+			utils.WriteErrorResponse(w, http.StatusLocked, "Vault is locked", vaultdomain.ErrVaultLocked)
+			return
+		}
+		if _, err := h.storage.Stat(r.Context(), request.Paths[i]); err != nil {
+			utils.WriteErrorResponse(w, http.StatusNotFound, "File not found", err)
+			return
+		}
+	}
+	auditdomain.AddPaths(r.Context(), request.Paths...)
+
+	name := request.Name
+	if name == "" {
+		name = "download"
+		if len(request.Paths) == 1 {
+			name = filepath.Base(request.Paths[0])
+		}
+	}
+
+	// La descarga puede durar más que el WriteTimeout del servidor
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + extension}))
+	w.WriteHeader(http.StatusOK)
+
+	stats, err := services.WriteArchive(r.Context(), w, request.Format, request.Paths, services.WriteOptions{
+		Store:          request.Compression == "store",
+		SkipUnreadable: true,
+		Storage:        h.storage,
+	})
+	if err != nil {
+		// Las cabeceras ya se enviaron: el archivo queda truncado y el cliente lo detecta
+		auditdomain.SetError(r.Context(), err)
+		if r.Context().Err() == nil {
+			log.Printf("archive: bundle download aborted: %v", err)
+		}
+		return
+	}
+	if len(stats.Skipped) > 0 {
+		auditdomain.SetError(r.Context(), fmt.Errorf("%d unreadable paths skipped", len(stats.Skipped)))
+	}
+}
+
+func (h *ArchiveHandler) authorize(w http.ResponseWriter, r *http.Request, path string, access authdomain.Access) bool {
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, path, access); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return false
+	}
+	return true
+}
+
+func writeArchiveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobdomain.ErrQueueFull):
+		jobhandlers.WriteJobError(w, err)
+	case errors.Is(err, domain.ErrInvalidConflict), errors.Is(err, domain.ErrInvalidFormat),
+		errors.Is(err, domain.ErrNotAnArchive), errors.Is(err, domain.ErrNothingToArchive):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request", err)
+	case errors.Is(err, domain.ErrTargetExists):
+		utils.WriteErrorResponse(w, http.StatusConflict, "Destination already exists", err)
+	case errors.Is(err, vaultdomain.ErrVaultLocked):
+		utils.WriteErrorResponse(w, http.StatusLocked, "Vault is locked", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Failed to start archive job", err)
+	}
+}
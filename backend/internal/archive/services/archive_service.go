@@ -0,0 +1,564 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/infortech07/cubert/internal/archive/domain"
+	fsdomain "github.com/infortech07/cubert/internal/filesystem/domain"
+	fsservices "github.com/infortech07/cubert/internal/filesystem/services"
+	jobdomain "github.com/infortech07/cubert/internal/jobs/domain"
+	jobservices "github.com/infortech07/cubert/internal/jobs/services"
+	quotaservices "github.com/infortech07/cubert/internal/quota/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	vaultservices "github.com/infortech07/cubert/internal/vault/services"
+)
+
+// ArchiveService extracts and creates archives as background jobs. Extraction works on
+// the local disk, so the encrypted vaults of storage are refused; archives are packed
+// from storage, which leaves locked vaults out.
+type ArchiveService struct {
+	jobs    *jobservices.JobService
+	limits  domain.Limits
+	quotas  *quotaservices.QuotaService
+	storage storagedomain.Storage
+}
+
+func NewArchiveService(jobs *jobservices.JobService, limits domain.Limits, quotas *quotaservices.QuotaService, storage storagedomain.Storage) *ArchiveService {
+	s := &ArchiveService{
+		jobs:    jobs,
+		limits:  limits,
+		quotas:  quotas,
+		storage: storage,
+	}
+	// Una extracción o compresión interrumpida deja archivos a medias: no se reanuda
+	jobs.Register(domain.JobTypeExtract, s.runExtract, jobservices.RunnerOptions{})
+	jobs.Register(domain.JobTypeCreate, s.runCreate, jobservices.RunnerOptions{})
+	return s
+}
+
+// StartExtract validates the request and queues an extraction job
+func (s *ArchiveService) StartExtract(ctx context.Context, userID string, req domain.ExtractRequest) (*jobdomain.Job, error) {
+	req.Archive = filepath.Clean(req.Archive)
+	req.Destination = filepath.Clean(req.Destination)
+	if req.Conflict == "" {
+		req.Conflict = domain.ConflictFail
+	}
+	if !req.Conflict.IsValid() {
+		return nil, fmt.Errorf("%w: %s", domain.ErrInvalidConflict, req.Conflict)
+	}
+	if _, ok := fsdomain.ArchiveFormatOf(req.Archive); !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrNotAnArchive, req.Archive)
+	}
+	for _, path := range []string{req.Archive, req.Destination} {
+		if err := vaultservices.CheckDiskPath(ctx, s.storage, path); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := os.Stat(req.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access archive %s: %w", req.Archive, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", domain.ErrNotAnArchive, req.Archive)
+	}
+	if info, err := os.Stat(req.Destination); err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("destination %s is not a directory", req.Destination)
+	}
+
+	return s.jobs.Submit(ctx, domain.JobTypeExtract, userID, req)
+}
+
+// StartCreate validates the request and queues a job that writes the archive
+func (s *ArchiveService) StartCreate(ctx context.Context, userID string, req domain.CreateRequest) (*jobdomain.Job, error) {
+	if len(req.Paths) == 0 {
+		return nil, domain.ErrNothingToArchive
+	}
+	if req.Format == "" {
+		req.Format = domain.FormatZip
+	}
+	if req.Format != domain.FormatZip && req.Format != domain.FormatTar && req.Format != domain.FormatTarGzip {
+		return nil, fmt.Errorf("%w: %s", domain.ErrInvalidFormat, req.Format)
+	}
+	if req.Conflict == "" {
+		req.Conflict = domain.ConflictFail
+	}
+	if !req.Conflict.IsValid() || req.Conflict == domain.ConflictSkip {
+		return nil, fmt.Errorf("%w: %s", domain.ErrInvalidConflict, req.Conflict)
+	}
+
+	req.Destination = filepath.Clean(req.Destination)
+	for i, path := range req.Paths {
+		req.Paths[i] = filepath.Clean(path)
+		if err := vaultservices.CheckDiskPath(ctx, s.storage, req.Paths[i]); err != nil {
+			return nil, err
+		}
+		if _, err := os.Lstat(req.Paths[i]); err != nil {
+			return nil, fmt.Errorf("failed to access %s: %w", path, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Dir(req.Destination)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("destination folder %s does not exist", filepath.Dir(req.Destination))
+	}
+	if err := vaultservices.CheckDiskPath(ctx, s.storage, req.Destination); err != nil {
+		return nil, err
+	}
+	// Se vuelve a comprobar al terminar, pero así no se comprime en balde
+	if _, err := os.Lstat(req.Destination); err == nil && req.Conflict == domain.ConflictFail {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTargetExists, req.Destination)
+	}
+
+	return s.jobs.Submit(ctx, domain.JobTypeCreate, userID, req)
+}
+
+func (s *ArchiveService) runExtract(ctx context.Context, job *jobdomain.Job, progress *jobservices.Progress) (interface{}, error) {
+	var req domain.ExtractRequest
+	if err := json.Unmarshal(job.Params, &req); err != nil {
+		return nil, fmt.Errorf("invalid extract parameters: %w", err)
+	}
+	format, _ := fsdomain.ArchiveFormatOf(req.Archive)
+
+	x := &extractor{
+		ctx:       ctx,
+		storage:   s.storage,
+		policy:    req.Conflict,
+		limits:    s.limits,
+		allowance: s.quotas.Allowance(job.UserID, req.Destination),
+		progress:  progress,
+		result:    &domain.ExtractResult{Destination: req.Destination},
+	}
+	// Las entradas conservan la fecha del archivo: se asignan al usuario explícitamente
+	started := time.Now()
+	defer func() {
+		s.quotas.Claim(job.UserID, x.written...)
+		s.quotas.Track(job.UserID, req.Destination, started)
+	}()
+	if err := vaultservices.CheckDiskPath(ctx, s.storage, req.Archive); err != nil {
+		return nil, err
+	}
+	if err := x.prepare(req.Destination); err != nil {
+		x.rollback()
+		return nil, err
+	}
+
+	var err error
+	if format == fsdomain.ArchiveZip {
+		err = x.extractZip(req.Archive)
+	} else {
+		err = x.extractTar(req.Archive, format)
+	}
+	if err != nil {
+		x.rollback()
+		return nil, err
+	}
+
+	progress.Flush()
+	return x.result, nil
+}
+
+func (s *ArchiveService) runCreate(ctx context.Context, job *jobdomain.Job, progress *jobservices.Progress) (interface{}, error) {
+	var req domain.CreateRequest
+	if err := json.Unmarshal(job.Params, &req); err != nil {
+		return nil, fmt.Errorf("invalid create parameters: %w", err)
+	}
+
+	started := time.Now()
+	defer s.quotas.Track(job.UserID, req.Destination, started)
+
+	progress.SetMessage("measuring")
+	total, err := MeasurePaths(ctx, req.Paths)
+	if err != nil {
+		return nil, err
+	}
+	progress.SetTotal(total.Bytes, "bytes")
+	progress.SetMessage("writing")
+
+	if err := vaultservices.CheckDiskPath(ctx, s.storage, req.Destination); err != nil {
+		return nil, err
+	}
+	// Se escribe en un temporal junto al destino y se renombra al terminar
+	temp, err := os.CreateTemp(filepath.Dir(req.Destination), "."+filepath.Base(req.Destination)+".partial-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	stats, err := WriteArchive(ctx, temp, req.Format, req.Paths, WriteOptions{
+		Exclude: temp.Name(),
+		OnWrite: progress.Add,
+		Storage: s.storage,
+	})
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := resolveConflict(req.Destination, req.Conflict)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(temp.Name(), target); err != nil {
+		return nil, fmt.Errorf("failed to move archive into place: %w", err)
+	}
+	os.Chmod(target, 0644)
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+	progress.Flush()
+	return &domain.CreateResult{
+		Archive: target,
+		Format:  req.Format,
+		Files:   stats.Files,
+		Bytes:   stats.Bytes,
+		Size:    info.Size(),
+	}, nil
+}
+
+// extractor writes archive entries below a destination, enforcing the limits and the
+// conflict policy. Everything it creates is removed again if the job fails.
+type extractor struct {
+	ctx       context.Context
+	storage   storagedomain.Storage
+	policy    domain.ConflictPolicy
+	limits    domain.Limits
+	allowance *quotaservices.Allowance
+	progress  *jobservices.Progress
+	result    *domain.ExtractResult
+
+	destination string
+	realDest    string
+	entries     int64
+	created     []string
+	// Ficheros escritos, para asignarlos a la cuota del usuario
+	written []string
+	// countBytes reports progress per extracted byte (zip) instead of per byte read (tar)
+	countBytes bool
+}
+
+func (x *extractor) prepare(destination string) error {
+	if err := vaultservices.CheckDiskPath(x.ctx, x.storage, destination); err != nil {
+		return err
+	}
+	if _, err := os.Stat(destination); os.IsNotExist(err) {
+		if err := x.mkdirAll(destination); err != nil {
+			return fmt.Errorf("failed to create destination %s: %w", destination, err)
+		}
+	}
+	realDest, err := filepath.EvalSymlinks(destination)
+	if err != nil {
+		return fmt.Errorf("failed to resolve destination %s: %w", destination, err)
+	}
+	x.destination, x.realDest = destination, realDest
+	return nil
+}
+
+func (x *extractor) extractZip(archive string) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to read zip %s: %w", archive, err)
+	}
+	defer reader.Close()
+
+	// El directorio central declara los tamaños: se rechaza antes de escribir nada
+	var declared int64
+	for _, f := range reader.File {
+		declared += int64(f.UncompressedSize64)
+	}
+	if x.limits.MaxEntries > 0 && int64(len(reader.File)) > x.limits.MaxEntries {
+		return fmt.Errorf("%w: %d entries", domain.ErrLimitExceeded, len(reader.File))
+	}
+	if x.limits.MaxBytes > 0 && declared > x.limits.MaxBytes {
+		return fmt.Errorf("%w: %d bytes", domain.ErrLimitExceeded, declared)
+	}
+	var files int64
+	for _, f := range reader.File {
+		if !strings.HasSuffix(f.Name, "/") && f.Mode().IsRegular() {
+			files++
+		}
+	}
+	if err := x.allowance.Fits(declared, files); err != nil {
+		return err
+	}
+	x.progress.SetTotal(declared, "bytes")
+	x.countBytes = true
+
+	for _, f := range reader.File {
+		isDir := strings.HasSuffix(f.Name, "/") || f.Mode().IsDir()
+		err := x.entry(f.Name, f.Mode(), f.Modified, isDir, int64(f.UncompressedSize64), func() (io.ReadCloser, error) {
+			return f.Open()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) extractTar(archive string, format fsdomain.ArchiveFormat) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s: %w", archive, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	// El progreso de un tar comprimido se mide sobre los bytes comprimidos leídos
+	counter := &countingReader{reader: file}
+	x.progress.SetTotal(info.Size(), "bytes")
+
+	stream, closers, err := fsservices.OpenTarStream(counter, format)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i].Close()
+		}
+	}()
+
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar %s: %w", archive, err)
+		}
+
+		mode := header.FileInfo().Mode()
+		isDir := header.Typeflag == tar.TypeDir
+		err = x.entry(header.Name, mode, header.ModTime, isDir, header.Size, func() (io.ReadCloser, error) {
+			return io.NopCloser(reader), nil
+		})
+		if err != nil {
+			return err
+		}
+		x.progress.SetCurrent(counter.count.Load())
+	}
+}
+
+func (x *extractor) entry(name string, mode fs.FileMode, modTime time.Time, isDir bool, size int64, open func() (io.ReadCloser, error)) error {
+	if err := x.ctx.Err(); err != nil {
+		return err
+	}
+	x.entries++
+	if x.limits.MaxEntries > 0 && x.entries > x.limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", domain.ErrLimitExceeded, x.limits.MaxEntries)
+	}
+
+	// Protección zip-slip: la entrada debe quedar dentro del destino
+	local := filepath.FromSlash(strings.TrimLeft(strings.ReplaceAll(name, "\\", "/"), "/"))
+	if local == "" || local == "." {
+		return nil
+	}
+	if !filepath.IsLocal(local) {
+		return fmt.Errorf("%w: %s", domain.ErrUnsafeEntry, name)
+	}
+	target := filepath.Join(x.destination, local)
+	// Una carpeta del archivo puede coincidir con una bóveda ya existente en el destino
+	if err := vaultservices.CheckDiskPath(x.ctx, x.storage, target); err != nil {
+		return err
+	}
+
+	if isDir {
+		if err := x.mkdirAll(target); err != nil {
+			return err
+		}
+		if err := x.checkInside(target); err != nil {
+			return err
+		}
+		x.result.Directories++
+		return nil
+	}
+	if !mode.IsRegular() {
+		// Enlaces y dispositivos no se extraen: un enlace podría apuntar fuera del destino
+		x.result.Skipped = append(x.result.Skipped, name)
+		return nil
+	}
+
+	if err := x.mkdirAll(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if err := x.checkInside(filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	existing, err := os.Lstat(target)
+	if err == nil {
+		if existing.IsDir() && x.policy == domain.ConflictOverwrite {
+			return fmt.Errorf("%w: %s is a directory", domain.ErrTargetExists, target)
+		}
+		if x.policy == domain.ConflictSkip {
+			x.result.Skipped = append(x.result.Skipped, name)
+			return nil
+		}
+	}
+	finalTarget, err := resolveConflict(target, x.policy)
+	if err != nil {
+		return err
+	}
+	if finalTarget != target {
+		if x.result.Renamed == nil {
+			x.result.Renamed = make(map[string]string)
+		}
+		x.result.Renamed[name] = finalTarget
+	}
+
+	// Se reserva el tamaño declarado: si miente, el límite de abajo corta la copia
+	growth, files := size, int64(1)
+	if existing != nil && finalTarget == target {
+		growth, files = size-existing.Size(), 0
+	}
+	if err := x.allowance.Take(growth, files); err != nil {
+		return err
+	}
+
+	in, err := open()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer in.Close()
+
+	temp, err := os.CreateTemp(filepath.Dir(target), ".cubert-extract-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	// Límite global y límite por entrada: un encabezado que miente sobre el tamaño no pasa
+	allowed := size
+	if x.limits.MaxBytes > 0 && x.limits.MaxBytes-x.result.Bytes < allowed {
+		allowed = x.limits.MaxBytes - x.result.Bytes
+	}
+	written, err := io.Copy(temp, io.LimitReader(&contextReader{ctx: x.ctx, reader: in}, allowed+1))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	if written > allowed {
+		return fmt.Errorf("%w: %s is larger than declared or the size limit", domain.ErrLimitExceeded, name)
+	}
+
+	os.Chmod(temp.Name(), mode.Perm()|0600)
+	os.Chtimes(temp.Name(), modTime, modTime)
+	if err := os.Rename(temp.Name(), finalTarget); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	if existing == nil || finalTarget != target {
+		x.created = append(x.created, finalTarget)
+	}
+	x.written = append(x.written, finalTarget)
+
+	x.result.Files++
+	x.result.Bytes += written
+	if x.countBytes {
+		x.progress.Add(written)
+	}
+	return nil
+}
+
+// mkdirAll creates missing directories, remembering them for the rollback
+func (x *extractor) mkdirAll(dir string) error {
+	if info, err := os.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%w: %s is not a directory", domain.ErrTargetExists, dir)
+		}
+		return nil
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := x.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	x.created = append(x.created, dir)
+	return nil
+}
+
+// checkInside rejects directories that resolve outside the destination through symlinks
+func (x *extractor) checkInside(dir string) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(x.realDest, real)
+	if err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return fmt.Errorf("%w: %s", domain.ErrUnsafeEntry, dir)
+	}
+	return nil
+}
+
+// rollback removes what the failed extraction created; overwritten files are not restored
+func (x *extractor) rollback() {
+	for i := len(x.created) - 1; i >= 0; i-- {
+		os.Remove(x.created[i])
+	}
+}
+
+// resolveConflict returns the path to write to according to the policy
+func resolveConflict(target string, policy domain.ConflictPolicy) (string, error) {
+	if _, err := os.Lstat(target); os.IsNotExist(err) {
+		return target, nil
+	}
+
+	switch policy {
+	case domain.ConflictOverwrite:
+		return target, nil
+	case domain.ConflictRename:
+		for counter := 1; ; counter++ {
+			candidate := utils.GenerateUniquePath(target, counter)
+			if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+				return candidate, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", domain.ErrTargetExists, target)
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
+
+// contextReader stops long copies as soon as the job is cancelled
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/infortech07/cubert/internal/archive/domain"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	storageservices "github.com/infortech07/cubert/internal/storage/services"
+)
+
+// WriteOptions tunes how an archive is written
+type WriteOptions struct {
+	// Store writes zip entries without compression, for already compressed media
+	Store bool
+	// Exclude is skipped while walking, e.g. the archive being written
+	Exclude string
+	// OnWrite receives the number of input bytes added after each chunk
+	OnWrite func(n int64)
+	// SkipUnreadable leaves out files and folders that cannot be opened instead of failing
+	SkipUnreadable bool
+	// Storage is walked and read instead of the local disk; locked vaults in it are skipped
+	Storage storagedomain.Storage
+}
+
+// ArchiveStats counts what was written to an archive
+type ArchiveStats struct {
+	Files   int64
+	Bytes   int64
+	Skipped []string
+}
+
+// archiveSink abstracts the zip and tar writers
+type archiveSink interface {
+	addDir(name string, info fs.FileInfo) error
+	addFile(name string, info fs.FileInfo) (io.Writer, error)
+	close() error
+}
+
+// WriteArchive streams the selected paths into w. Each path is stored under its base
+// name; symlinks and special files are skipped. It stops as soon as ctx is done.
+func WriteArchive(ctx context.Context, w io.Writer, format domain.Format, paths []string, opts WriteOptions) (ArchiveStats, error) {
+	var stats ArchiveStats
+
+	store := opts.Storage
+	if store == nil {
+		store = storageservices.NewLocalStorage()
+	}
+	locks, _ := store.(storagedomain.LockChecker)
+
+	sink, err := newSink(w, format, opts.Store)
+	if err != nil {
+		return stats, err
+	}
+
+	buffer := make([]byte, 256*1024)
+	used := make(map[string]bool)
+	for _, root := range paths {
+		root = filepath.Clean(root)
+		base := uniqueEntryName(filepath.Base(root), used)
+
+		err := store.Walk(ctx, root, func(current string, info fs.FileInfo, err error) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err != nil {
+				if opts.SkipUnreadable && current != root {
+					stats.Skipped = append(stats.Skipped, current)
+					return nil
+				}
+				return err
+			}
+			if opts.Exclude != "" && current == opts.Exclude {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, current)
+			if err != nil {
+				return err
+			}
+			name := path.Join(base, filepath.ToSlash(rel))
+
+			switch {
+			case info.IsDir() && locks != nil && locks.Locked(ctx, current):
+				// Sin la clave solo habría nombres cifrados
+				stats.Skipped = append(stats.Skipped, current)
+				return filepath.SkipDir
+			case info.IsDir():
+				return sink.addDir(name, info)
+			case !info.Mode().IsRegular():
+				return nil
+			}
+
+			// Se abre antes de escribir la cabecera para poder omitir el archivo
+			in, err := store.Open(ctx, current)
+			if err != nil {
+				if opts.SkipUnreadable {
+					stats.Skipped = append(stats.Skipped, current)
+					return nil
+				}
+				return err
+			}
+			defer in.Close()
+			out, err := sink.addFile(name, info)
+			if err != nil {
+				return err
+			}
+
+			for {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				n, readErr := in.Read(buffer)
+				if n > 0 {
+					if _, err := out.Write(buffer[:n]); err != nil {
+						return err
+					}
+					stats.Bytes += int64(n)
+					if opts.OnWrite != nil {
+						opts.OnWrite(int64(n))
+					}
+				}
+				if readErr == io.EOF {
+					break
+				}
+				if readErr != nil {
+					return readErr
+				}
+			}
+			stats.Files++
+			return nil
+		})
+		if err != nil {
+			return stats, fmt.Errorf("failed to archive %s: %w", root, err)
+		}
+	}
+
+	return stats, sink.close()
+}
+
+// MeasurePaths returns the number of regular files and their total size below paths
+func MeasurePaths(ctx context.Context, paths []string) (ArchiveStats, error) {
+	var stats ArchiveStats
+	for _, root := range paths {
+		err := filepath.WalkDir(filepath.Clean(root), func(current string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			stats.Files++
+			stats.Bytes += info.Size()
+			return nil
+		})
+		if err != nil {
+			return stats, fmt.Errorf("failed to read %s: %w", root, err)
+		}
+	}
+	return stats, nil
+}
+
+// uniqueEntryName avoids two selected paths with the same base name overwriting each other
+func uniqueEntryName(name string, used map[string]bool) string {
+	candidate := name
+	ext := path.Ext(name)
+	for i := 1; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+func newSink(w io.Writer, format domain.Format, store bool) (archiveSink, error) {
+	switch format {
+	case domain.FormatZip:
+		method := zip.Deflate
+		if store {
+			method = zip.Store
+		}
+		return &zipSink{writer: zip.NewWriter(w), method: method}, nil
+	case domain.FormatTar:
+		return &tarSink{writer: tar.NewWriter(w)}, nil
+	case domain.FormatTarGzip:
+		gz := gzip.NewWriter(w)
+		return &tarSink{writer: tar.NewWriter(gz), gzip: gz}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", domain.ErrInvalidFormat, format)
+}
+
+type zipSink struct {
+	writer *zip.Writer
+	method uint16
+}
+
+func (s *zipSink) addDir(name string, info fs.FileInfo) error {
+	header := &zip.FileHeader{Name: name + "/", Modified: info.ModTime()}
+	header.SetMode(info.Mode())
+	_, err := s.writer.CreateHeader(header)
+	return err
+}
+
+func (s *zipSink) addFile(name string, info fs.FileInfo) (io.Writer, error) {
+	// Los tamaños los calcula el writer; a partir de 4 GiB usa zip64 automáticamente
+	header := &zip.FileHeader{Name: name, Method: s.method, Modified: info.ModTime()}
+	header.SetMode(info.Mode())
+	return s.writer.CreateHeader(header)
+}
+
+func (s *zipSink) close() error {
+	return s.writer.Close()
+}
+
+type tarSink struct {
+	writer *tar.Writer
+	gzip   *gzip.Writer
+}
+
+func (s *tarSink) addDir(name string, info fs.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name + "/"
+	return s.writer.WriteHeader(header)
+}
+
+func (s *tarSink) addFile(name string, info fs.FileInfo) (io.Writer, error) {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return nil, err
+	}
+	header.Name = name
+	if err := s.writer.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	return s.writer, nil
+}
+
+func (s *tarSink) close() error {
+	if err := s.writer.Close(); err != nil {
+		return err
+	}
+	if s.gzip != nil {
+		return s.gzip.Close()
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ActivityType string
+
+const (
+	ActivityCreate   ActivityType = "create"
+	ActivityModify   ActivityType = "modify"
+	ActivityDelete   ActivityType = "delete"
+	ActivityShare    ActivityType = "share"
+	ActivityDownload ActivityType = "download"
+)
+
+// Activity matches the shape consumed by the dashboard RecentActivity widget
+type Activity struct {
+	ID        string       `json:"id"`
+	Type      ActivityType `json:"type"`
+	FileName  string       `json:"fileName"`
+	Path      string       `json:"path"`
+	Timestamp time.Time    `json:"timestamp"`
+	User      string       `json:"user,omitempty"`
+}
+
+// ToActivity converts a successful mutation or download into a dashboard activity.
+// Navigation events (list, stats, search...) return false.
+func (e *Event) ToActivity() (Activity, bool) {
+	if e.Result != ResultSuccess || len(e.Paths) == 0 {
+		return Activity{}, false
+	}
+
+	var activityType ActivityType
+	switch {
+	case e.Method == http.MethodDelete || strings.HasSuffix(e.Action, ".delete") || strings.HasSuffix(e.Action, ".trash"):
+		activityType = ActivityDelete
+	case strings.Contains(e.Action, "share"):
+		activityType = ActivityShare
+	case e.Method == http.MethodPut || e.Method == http.MethodPatch:
+		activityType = ActivityModify
+	case e.Method == http.MethodPost && !strings.HasSuffix(e.Action, ".validate"):
+		activityType = ActivityCreate
+	case strings.Contains(e.Action, "download"):
+		activityType = ActivityDownload
+	default:
+		return Activity{}, false
+	}
+
+	path := e.Paths[len(e.Paths)-1]
+	return Activity{
+		ID:        e.ID,
+		Type:      activityType,
+		FileName:  filepath.Base(path),
+		Path:      path,
+		Timestamp: e.Timestamp,
+		User:      e.Username,
+	}, true
+}
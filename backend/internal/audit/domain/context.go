@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+type contextKey int
+
+const recorderContextKey contextKey = iota
+
+// Recorder collects details about the request being audited that only the handler knows
+type Recorder struct {
+	mu     sync.Mutex
+	paths  []string
+	action string
+	source string
+	err    string
+}
+
+func (r *Recorder) Paths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.paths...)
+}
+
+func (r *Recorder) Action() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.action
+}
+
+func (r *Recorder) Source() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.source
+}
+
+func (r *Recorder) Error() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func ContextWithRecorder(ctx context.Context, recorder *Recorder) context.Context {
+	return context.WithValue(ctx, recorderContextKey, recorder)
+}
+
+// AddPaths records the paths touched by the current request; paths already recorded,
+// like the ?path= the middleware takes, are not added twice
+func AddPaths(ctx context.Context, paths ...string) {
+	if recorder, ok := ctx.Value(recorderContextKey).(*Recorder); ok {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		for _, path := range paths {
+			if path != "" && !slices.Contains(recorder.paths, path) {
+				recorder.paths = append(recorder.paths, path)
+			}
+		}
+	}
+}
+
+// SetAction overrides the action name derived from the route
+func SetAction(ctx context.Context, action string) {
+	if recorder, ok := ctx.Value(recorderContextKey).(*Recorder); ok {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		recorder.action = action
+	}
+}
+
+// SetSource overrides the protocol reported for the request, "rest" by default
+func SetSource(ctx context.Context, source string) {
+	if recorder, ok := ctx.Value(recorderContextKey).(*Recorder); ok {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		recorder.source = source
+	}
+}
+
+// SetError records why the request failed
+func SetError(ctx context.Context, err error) {
+	if recorder, ok := ctx.Value(recorderContextKey).(*Recorder); ok && err != nil {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		recorder.err = err.Error()
+	}
+}
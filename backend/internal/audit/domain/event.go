@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+type Result string
+
+const (
+	ResultSuccess Result = "success"
+	ResultFailure Result = "failure"
+	ResultDenied  Result = "denied"
+)
+
+type Event struct {
+	ID         string    `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	UserID     string    `json:"user_id,omitempty"`
+	Username   string    `json:"username,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	Source     string    `json:"source"`
+	Method     string    `json:"method,omitempty"`
+	Action     string    `json:"action"`
+	Paths      []string  `json:"paths,omitempty"`
+	Result     Result    `json:"result"`
+	Status     int       `json:"status,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Query filters audit events; zero values are ignored
+type Query struct {
+	UserID   string
+	Username string
+	Action   string
+	Path     string
+	Result   Result
+	Source   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+func (q *Query) Matches(event *Event) bool {
+	if q.UserID != "" && event.UserID != q.UserID {
+		return false
+	}
+	if q.Username != "" && !strings.EqualFold(event.Username, q.Username) {
+		return false
+	}
+	if q.Action != "" && !strings.HasPrefix(event.Action, q.Action) {
+		return false
+	}
+	if q.Result != "" && event.Result != q.Result {
+		return false
+	}
+	if q.Source != "" && event.Source != q.Source {
+		return false
+	}
+	if !q.From.IsZero() && event.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && event.Timestamp.After(q.To) {
+		return false
+	}
+	if q.Path != "" {
+		found := false
+		for _, path := range event.Paths {
+			if path == q.Path || strings.HasPrefix(path, strings.TrimSuffix(q.Path, "/")+"/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/infortech07/cubert/internal/audit/domain"
+	"github.com/infortech07/cubert/internal/audit/services"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const maxExportEvents = 100000
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// QueryEvents serves GET /admin/audit. With format=json|csv the full result is exported as a download.
+func (h *AuditHandler) QueryEvents(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid audit query", err)
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" {
+		query.Offset = 0
+		query.Limit = maxExportEvents
+	}
+
+	events, total, err := h.auditService.Query(r.Context(), query)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to query audit log", err)
+		return
+	}
+
+	filename := fmt.Sprintf("cubert-audit-%s", time.Now().UTC().Format("20060102-150405"))
+	switch format {
+	case "":
+		utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+			"events": events,
+			"count":  len(events),
+			"total":  total,
+			"limit":  query.Limit,
+			"offset": query.Offset,
+		})
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		json.NewEncoder(w).Encode(events)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		writeCSV(w, events)
+	default:
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Unsupported export format (use json or csv)", nil)
+	}
+}
+
+// RecentActivity returns the current user's latest file activity for the dashboard widget
+func (h *AuditHandler) RecentActivity(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	limit := 20
+	if value, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && value > 0 && value <= 200 {
+		limit = value
+	}
+
+	query := domain.Query{UserID: user.ID, Result: domain.ResultSuccess, Limit: limit * 20}
+	if user.IsAdmin() && r.URL.Query().Get("scope") == "all" {
+		query.UserID = ""
+	}
+
+	activities := make([]domain.Activity, 0, limit)
+	for _, event := range h.auditService.Recent(query) {
+		if activity, ok := event.ToActivity(); ok {
+			activities = append(activities, activity)
+			if len(activities) == limit {
+				break
+			}
+		}
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"activities": activities,
+		"count":      len(activities),
+	})
+}
+
+func parseQuery(r *http.Request) (domain.Query, error) {
+	values := r.URL.Query()
+
+	query := domain.Query{
+		UserID:   values.Get("user_id"),
+		Username: values.Get("user"),
+		Action:   values.Get("action"),
+		Path:     values.Get("path"),
+		Result:   domain.Result(values.Get("result")),
+		Source:   values.Get("source"),
+	}
+
+	if from := values.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return query, fmt.Errorf("invalid from date: %w", err)
+		}
+		query.From = t
+	}
+	if to := values.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return query, fmt.Errorf("invalid to date: %w", err)
+		}
+		query.To = t
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return query, fmt.Errorf("invalid limit: %s", limit)
+		}
+		query.Limit = n
+	}
+	if offset := values.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return query, fmt.Errorf("invalid offset: %s", offset)
+		}
+		query.Offset = n
+	}
+
+	return query, nil
+}
+
+func writeCSV(w http.ResponseWriter, events []domain.Event) {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "timestamp", "user_id", "username", "ip", "source", "method", "action", "paths", "result", "status", "duration_ms", "error"})
+
+	for _, event := range events {
+		writer.Write([]string{
+			event.ID,
+			event.Timestamp.Format(time.RFC3339Nano),
+			event.UserID,
+			event.Username,
+			event.IP,
+			event.Source,
+			event.Method,
+			event.Action,
+			strings.Join(event.Paths, "|"),
+			string(event.Result),
+			strconv.Itoa(event.Status),
+			strconv.FormatInt(event.DurationMs, 10),
+			event.Error,
+		})
+	}
+	writer.Flush()
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+)
+
+const maxCapturedErrorBody = 4096
+
+// Middleware records an audit event for every request it wraps. It must run after
+// authentication so the user is known; handlers can add paths through domain.AddPaths.
+func (h *AuditHandler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		recorder := &domain.Recorder{}
+		ctx := domain.ContextWithRecorder(r.Context(), recorder)
+		domain.AddPaths(ctx, r.URL.Query().Get("path"))
+
+		rw := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		event := domain.Event{
+			Source:     "rest",
+			Method:     r.Method,
+			Action:     recorder.Action(),
+			Paths:      recorder.Paths(),
+			Status:     rw.status,
+			IP:         remoteIP(r),
+			DurationMs: time.Since(start).Milliseconds(),
+			Error:      recorder.Error(),
+		}
+		if event.Action == "" {
+			event.Action = actionFromRoute(r)
+		}
+		if source := recorder.Source(); source != "" {
+			event.Source = source
+		}
+		if user, ok := authdomain.UserFromContext(r.Context()); ok {
+			event.UserID = user.ID
+			event.Username = user.Username
+		}
+
+		switch {
+		case rw.status == http.StatusUnauthorized || rw.status == http.StatusForbidden:
+			event.Result = domain.ResultDenied
+		case rw.status >= http.StatusBadRequest:
+			event.Result = domain.ResultFailure
+		default:
+			event.Result = domain.ResultSuccess
+		}
+		if event.Error == "" && rw.status >= http.StatusBadRequest {
+			event.Error = rw.errorMessage()
+		}
+
+		h.auditService.Log(event)
+	})
+}
+
+// actionFromRoute turns "/api/v1/filesystem/list" into "filesystem.list"
+func actionFromRoute(r *http.Request) string {
+	pattern := r.URL.Path
+	if routeCtx := chi.RouteContext(r.Context()); routeCtx != nil {
+		if routePattern := routeCtx.RoutePattern(); routePattern != "" {
+			pattern = routePattern
+		}
+	}
+
+	pattern = strings.TrimPrefix(pattern, "/api/v1/")
+	var parts []string
+	for _, part := range strings.Split(pattern, "/") {
+		if part == "" || part == "*" || strings.HasPrefix(part, "{") {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ".")
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	errorBody   bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status >= http.StatusBadRequest && w.errorBody.Len() < maxCapturedErrorBody {
+		remaining := maxCapturedErrorBody - w.errorBody.Len()
+		if len(data) < remaining {
+			remaining = len(data)
+		}
+		w.errorBody.Write(data[:remaining])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *auditResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// errorMessage extracts the "error" (or "message") field written by utils.WriteErrorResponse
+func (w *auditResponseWriter) errorMessage() string {
+	var body struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.errorBody.Bytes(), &body); err != nil {
+		return http.StatusText(w.status)
+	}
+	if body.Error != "" {
+		return body.Error
+	}
+	return body.Message
+}
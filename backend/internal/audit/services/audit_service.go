@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/infortech07/cubert/internal/audit/domain"
+)
+
+const (
+	currentLogName   = "audit.log"
+	rotatedLogPrefix = "audit-"
+	recentBufferSize = 1000
+	defaultPageSize  = 100
+	maxPageSize      = 5000
+	maxLineSize      = 1 << 20
+)
+
+type AuditConfig struct {
+	Dir           string
+	MaxSizeBytes  int64
+	MaxFiles      int
+	RetentionDays int
+}
+
+// AuditService appends events to a JSON-lines log with size-based rotation and age/count retention.
+// Events are never modified once written.
+type AuditService struct {
+	cfg AuditConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	recent []domain.Event
+
+	listeners []func(domain.Event)
+}
+
+func NewAuditService(cfg AuditConfig) (*AuditService, error) {
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory %s: %w", cfg.Dir, err)
+	}
+
+	s := &AuditService{cfg: cfg}
+	if err := s.openCurrent(); err != nil {
+		return nil, err
+	}
+
+	// Precargar el búfer de eventos recientes desde el log actual
+	events, err := readLogFile(s.currentPath())
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > recentBufferSize {
+		events = events[len(events)-recentBufferSize:]
+	}
+	s.recent = events
+
+	s.applyRetention()
+	return s, nil
+}
+
+// Log appends an event, filling in its ID and timestamp when missing
+func (s *AuditService) Log(event domain.Event) {
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	if event.Result == "" {
+		event.Result = domain.ResultSuccess
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("audit: failed to encode event: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	if s.append(event, line) {
+		s.notify(event)
+	}
+}
+
+// Subscribe registers a listener called after each event is persisted
+func (s *AuditService) Subscribe(listener func(domain.Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// notify runs outside the lock so listeners can take their time or query the service
+func (s *AuditService) notify(event domain.Event) {
+	s.mu.Lock()
+	listeners := append([]func(domain.Event){}, s.listeners...)
+	s.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+func (s *AuditService) append(event domain.Event, line []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cfg.MaxSizeBytes > 0 && s.size+int64(len(line)) > s.cfg.MaxSizeBytes && s.size > 0 {
+		if err := s.rotateLocked(); err != nil {
+			log.Printf("audit: rotation failed: %v", err)
+		}
+	}
+
+	if s.file == nil {
+		log.Printf("audit: log file is not open, dropping event %s", event.ID)
+		return false
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		log.Printf("audit: failed to write event: %v", err)
+		return false
+	}
+
+	s.recent = append(s.recent, event)
+	if len(s.recent) > recentBufferSize {
+		s.recent = s.recent[len(s.recent)-recentBufferSize:]
+	}
+	return true
+}
+
+// Query scans the current and rotated logs, newest first
+func (s *AuditService) Query(ctx context.Context, q domain.Query) ([]domain.Event, int, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultPageSize
+	}
+	if q.Limit > maxPageSize {
+		q.Limit = maxPageSize
+	}
+
+	files, err := s.logFiles()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var matched []domain.Event
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		events, err := readLogFile(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		for i := len(events) - 1; i >= 0; i-- {
+			if q.Matches(&events[i]) {
+				matched = append(matched, events[i])
+			}
+		}
+	}
+
+	total := len(matched)
+	if q.Offset >= total {
+		return []domain.Event{}, total, nil
+	}
+	end := q.Offset + q.Limit
+	if end > total {
+		end = total
+	}
+	return matched[q.Offset:end], total, nil
+}
+
+// Recent returns the latest buffered events matching the query, newest first
+func (s *AuditService) Recent(q domain.Query) []domain.Event {
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]domain.Event, 0, q.Limit)
+	for i := len(s.recent) - 1; i >= 0 && len(events) < q.Limit; i-- {
+		if q.Matches(&s.recent[i]) {
+			events = append(events, s.recent[i])
+		}
+	}
+	return events
+}
+
+func (s *AuditService) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *AuditService) currentPath() string {
+	return filepath.Join(s.cfg.Dir, currentLogName)
+}
+
+func (s *AuditService) openCurrent() error {
+	file, err := os.OpenFile(s.currentPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *AuditService) rotateLocked() error {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+
+	rotated := filepath.Join(s.cfg.Dir, rotatedLogPrefix+time.Now().UTC().Format("20060102T150405.000000000")+".log")
+	if err := os.Rename(s.currentPath(), rotated); err != nil {
+		// Reabrir el log actual aunque falle el renombrado para no perder eventos
+		if openErr := s.openCurrent(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	if err := s.openCurrent(); err != nil {
+		return err
+	}
+
+	go s.applyRetention()
+	return nil
+}
+
+// applyRetention removes rotated files older than the retention window or beyond the file limit
+func (s *AuditService) applyRetention() {
+	rotated, err := s.rotatedFiles()
+	if err != nil {
+		log.Printf("audit: retention failed: %v", err)
+		return
+	}
+
+	cutoff := time.Time{}
+	if s.cfg.RetentionDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -s.cfg.RetentionDays)
+	}
+
+	// rotated está ordenado del más reciente al más antiguo
+	for i, path := range rotated {
+		expired := false
+		if s.cfg.MaxFiles > 0 && i >= s.cfg.MaxFiles {
+			expired = true
+		}
+		if !cutoff.IsZero() {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				expired = true
+			}
+		}
+		if expired {
+			if err := os.Remove(path); err != nil {
+				log.Printf("audit: failed to remove %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// rotatedFiles lists rotated logs, newest first
+func (s *AuditService) rotatedFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, rotatedLogPrefix) && strings.HasSuffix(name, ".log") {
+			files = append(files, filepath.Join(s.cfg.Dir, name))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+// logFiles lists the current log followed by rotated logs, newest first
+func (s *AuditService) logFiles() ([]string, error) {
+	rotated, err := s.rotatedFiles()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{s.currentPath()}, rotated...), nil
+}
+
+func readLogFile(path string) ([]domain.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var events []domain.Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var event domain.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue // Línea truncada o corrupta
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return events, fmt.Errorf("failed to read audit log %s: %w", path, err)
+	}
+	return events, nil
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type Access string
+
+const (
+	AccessRead  Access = "read"
+	AccessWrite Access = "write"
+)
+
+func (a Access) IsValid() bool {
+	return a == AccessRead || a == AccessWrite
+}
+
+// Allows reports whether a rule granting a also grants the requested access
+func (a Access) Allows(requested Access) bool {
+	if a == AccessWrite {
+		return true
+	}
+	return a == requested
+}
+
+// EveryoneGroup is the pseudo-group whose rules apply to every user
+const EveryoneGroup = "*"
+
+type ACLRule struct {
+	Path   string `json:"path"`
+	Access Access `json:"access"`
+}
+
+// Covers reports whether the rule's path is the given path or one of its ancestors
+func (r ACLRule) Covers(path string) bool {
+	root := filepath.Clean(r.Path)
+	if root == "/" {
+		return true
+	}
+	return path == root || strings.HasPrefix(path, root+"/")
+}
+
+// GroupACL maps a group name (local or from the identity provider) to its path rules
+type GroupACL map[string][]ACLRule
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxAPIKeysPerUser limits how many API keys a user can hold at once
+const MaxAPIKeysPerUser = 10
+
+// APIKey lets non-interactive clients such as S3 tools sign requests on behalf of a user.
+// The secret is stored because request signatures (SigV4) are verified with it.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKeyInfo is the public view of an API key; the secret is never listed
+type APIKeyInfo struct {
+	AccessKeyID string    `json:"access_key_id"`
+	Name        string    `json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewAPIKey is returned once, when the key is created
+type NewAPIKey struct {
+	APIKeyInfo
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+func (k APIKey) Info() APIKeyInfo {
+	return APIKeyInfo{
+		AccessKeyID: k.ID,
+		Name:        k.Name,
+		CreatedAt:   k.CreatedAt,
+	}
+}
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrTooManyAPIKeys = errors.New("too many API keys")
+)
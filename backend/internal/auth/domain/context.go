@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+)
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	sessionContextKey
+)
+
+// ContextWithUser attaches the authenticated user and session to a context
+func ContextWithUser(ctx context.Context, user *User, session *Session) context.Context {
+	ctx = context.WithValue(ctx, userContextKey, user)
+	return context.WithValue(ctx, sessionContextKey, session)
+}
+
+// UserFromContext returns the authenticated user stored in the context, if any
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userContextKey).(*User)
+	return user, ok && user != nil
+}
+
+// SessionFromContext returns the session stored in the context, if any
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(*Session)
+	return session, ok && session != nil
+}
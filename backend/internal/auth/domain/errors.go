@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"errors"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserDisabled       = errors.New("user is disabled")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("username already exists")
+	ErrInvalidUserData    = errors.New("invalid user data")
+	ErrInvalidSession     = errors.New("invalid or expired session")
+	ErrInvalidChallenge   = errors.New("invalid or expired login challenge")
+	ErrInvalidTOTPCode    = errors.New("invalid verification code")
+	ErrTOTPNotPending     = errors.New("no TOTP enrollment in progress")
+	ErrTOTPNotEnabled     = errors.New("TOTP is not enabled for this user")
+	ErrTOTPAlreadyEnabled = errors.New("TOTP is already enabled for this user")
+	ErrTOTPRequired       = errors.New("two-factor authentication is required for this role")
+	ErrEnrollmentRequired = errors.New("two-factor enrollment is required before accessing this resource")
+	ErrAccessDenied       = errors.New("access denied")
+	ErrOIDCDisabled       = errors.New("single sign-on is not configured")
+	ErrOIDCState          = errors.New("invalid or expired single sign-on state")
+)
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"time"
+)
+
+type Session struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"user_id"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// Restricted sessions belong to users whose role requires 2FA but who
+	// have not enrolled yet; they may only reach the enrollment endpoints.
+	Restricted bool `json:"restricted"`
+}
+
+func (s *Session) IsExpired(now time.Time) bool {
+	return now.After(s.ExpiresAt)
+}
+
+// LoginChallenge is issued after a correct password when a second factor is still required
+type LoginChallenge struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"user_id"`
+	IP        string    `json:"ip,omitempty"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type LoginResult struct {
+	Session      *Session     `json:"session,omitempty"`
+	User         *UserProfile `json:"user,omitempty"`
+	TOTPRequired bool         `json:"totp_required"`
+	Challenge    string       `json:"challenge,omitempty"`
+	MustEnroll   bool         `json:"must_enroll_totp"`
+}
+
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	URI        string `json:"uri"`
+	QRCodePNG  string `json:"qr_code_png"`
+	Issuer     string `json:"issuer"`
+	Account    string `json:"account"`
+	Digits     int    `json:"digits"`
+	PeriodSecs int    `json:"period"`
+}
+
+type SecurityPolicy struct {
+	Require2FARoles []Role `json:"require_2fa_roles"`
+}
+
+func (p SecurityPolicy) Requires2FA(role Role) bool {
+	for _, r := range p.Require2FARoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
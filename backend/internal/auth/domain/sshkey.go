@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxSSHKeysPerUser limits how many SSH public keys a user can register
+const MaxSSHKeysPerUser = 10
+
+// SSHKey is a public key that lets the user log in to the built-in SFTP server
+type SSHKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// PublicKey está en formato authorized_keys, sin comentario
+	PublicKey   string    `json:"public_key"`
+	Fingerprint string    `json:"fingerprint"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+var (
+	ErrSSHKeyNotFound = errors.New("SSH key not found")
+	ErrTooManySSHKeys = errors.New("too many SSH keys")
+	ErrSSHKeyExists   = errors.New("SSH key already registered")
+)
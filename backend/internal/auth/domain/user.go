@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"time"
+)
+
+type Role string
+
+const (
+	RoleAdmin Role = "admin"
+	RoleUser  Role = "user"
+)
+
+func (r Role) IsValid() bool {
+	return r == RoleAdmin || r == RoleUser
+}
+
+type AuthSource string
+
+const (
+	AuthSourceLocal AuthSource = "local"
+	AuthSourceOIDC  AuthSource = "oidc"
+)
+
+type User struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username"`
+	Email        string     `json:"email,omitempty"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	Role         Role       `json:"role"`
+	Groups       []string   `json:"groups,omitempty"`
+	Disabled     bool       `json:"disabled"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	LastLoginAt  time.Time  `json:"last_login_at,omitempty"`
+	AuthSource   AuthSource `json:"auth_source,omitempty"`
+
+	// Identidad en el proveedor externo ("issuer|subject")
+	ExternalID string `json:"external_id,omitempty"`
+
+	// Segundo factor (TOTP)
+	TOTPEnabled       bool     `json:"totp_enabled"`
+	TOTPSecret        string   `json:"totp_secret,omitempty"`
+	TOTPPendingSecret string   `json:"totp_pending_secret,omitempty"`
+	TOTPLastCounter   int64    `json:"totp_last_counter,omitempty"`
+	RecoveryCodes     []string `json:"recovery_codes,omitempty"`
+
+	// Claves para clientes no interactivos (API S3)
+	APIKeys []APIKey `json:"api_keys,omitempty"`
+
+	// Claves públicas para el servidor SFTP
+	SSHKeys []SSHKey `json:"ssh_keys,omitempty"`
+}
+
+// UserUpdate holds the optional fields an administrator can change on a user
+type UserUpdate struct {
+	Role     *Role     `json:"role"`
+	Groups   *[]string `json:"groups"`
+	Disabled *bool     `json:"disabled"`
+	Password *string   `json:"password"`
+}
+
+// UserProfile is the public view of a user returned by the API
+type UserProfile struct {
+	ID                     string     `json:"id"`
+	Username               string     `json:"username"`
+	Email                  string     `json:"email,omitempty"`
+	Role                   Role       `json:"role"`
+	Groups                 []string   `json:"groups"`
+	AuthSource             AuthSource `json:"auth_source"`
+	Disabled               bool       `json:"disabled"`
+	TOTPEnabled            bool       `json:"totp_enabled"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+	CreatedAt              time.Time  `json:"created_at"`
+	LastLoginAt            time.Time  `json:"last_login_at,omitempty"`
+}
+
+func (u *User) Profile() UserProfile {
+	return UserProfile{
+		ID:                     u.ID,
+		Username:               u.Username,
+		Email:                  u.Email,
+		Role:                   u.Role,
+		Groups:                 u.Groups,
+		AuthSource:             u.Source(),
+		Disabled:               u.Disabled,
+		TOTPEnabled:            u.TOTPEnabled,
+		RecoveryCodesRemaining: len(u.RecoveryCodes),
+		CreatedAt:              u.CreatedAt,
+		LastLoginAt:            u.LastLoginAt,
+	}
+}
+
+func (u *User) Source() AuthSource {
+	if u.AuthSource == "" {
+		return AuthSourceLocal
+	}
+	return u.AuthSource
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
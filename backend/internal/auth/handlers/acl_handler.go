@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+type ACLHandler struct {
+	aclService *services.ACLService
+}
+
+func NewACLHandler(aclService *services.ACLService) *ACLHandler {
+	return &ACLHandler{
+		aclService: aclService,
+	}
+}
+
+func (h *ACLHandler) GetACL(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONResponse(w, http.StatusOK, h.aclService.GetACL())
+}
+
+func (h *ACLHandler) SetGroupRules(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Rules []domain.ACLRule `json:"rules"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	acl, err := h.aclService.SetGroupRules(chi.URLParam(r, "group"), request.Rules)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, acl)
+}
+
+func (h *ACLHandler) DeleteGroupRules(w http.ResponseWriter, r *http.Request) {
+	acl, err := h.aclService.SetGroupRules(chi.URLParam(r, "group"), nil)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, acl)
+}
+
+// MyRules returns the effective rules of the current user
+func (h *ACLHandler) MyRules(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"unrestricted": user.IsAdmin() || h.aclService.AllowedRoots(user) == nil,
+		"rules":        h.aclService.RulesFor(user),
+	})
+}
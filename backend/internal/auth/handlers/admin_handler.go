@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+func (h *AuthHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users := h.authService.ListUsers(r.Context())
+
+	profiles := make([]domain.UserProfile, 0, len(users))
+	for _, user := range users {
+		profiles = append(profiles, user.Profile())
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"users": profiles,
+		"count": len(profiles),
+	})
+}
+
+func (h *AuthHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username string      `json:"username"`
+		Email    string      `json:"email"`
+		Password string      `json:"password"`
+		Role     domain.Role `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if !utils.IsValidPassword(request.Password) {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Password does not meet the strength requirements", nil)
+		return
+	}
+	if request.Role == "" {
+		request.Role = domain.RoleUser
+	}
+
+	user, err := h.authService.CreateUser(r.Context(), request.Username, request.Email, request.Password, request.Role)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, user.Profile())
+}
+
+func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	var request domain.UserUpdate
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if request.Password != nil && !utils.IsValidPassword(*request.Password) {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Password does not meet the strength requirements", nil)
+		return
+	}
+
+	user, err := h.authService.UpdateUser(r.Context(), chi.URLParam(r, "id"), request)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, user.Profile())
+}
+
+func (h *AuthHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if current, ok := domain.UserFromContext(r.Context()); ok && current.ID == id {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Administrators cannot delete their own account", nil)
+		return
+	}
+
+	if err := h.authService.DeleteUser(r.Context(), id); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "User deleted")
+}
+
+func (h *AuthHandler) ResetUserTOTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.authService.ResetTOTP(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "Two-factor authentication reset")
+}
+
+func (h *AuthHandler) GetSecurityPolicy(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONResponse(w, http.StatusOK, h.authService.GetPolicy())
+}
+
+func (h *AuthHandler) UpdateSecurityPolicy(w http.ResponseWriter, r *http.Request) {
+	var request domain.SecurityPolicy
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	policy, err := h.authService.SetRequire2FARoles(request.Require2FARoles)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, policy)
+}
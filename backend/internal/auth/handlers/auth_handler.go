@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+type AuthHandler struct {
+	authService *services.AuthService
+}
+
+func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+	return &AuthHandler{
+		authService: authService,
+	}
+}
+
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	result, err := h.authService.Login(r.Context(), request.Username, request.Password, ClientIP(r))
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	h.writeLoginResult(w, result)
+}
+
+func (h *AuthHandler) VerifyLogin(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Challenge    string `json:"challenge"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	result, err := h.authService.VerifyLogin(r.Context(), request.Challenge, request.Code, request.RecoveryCode)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	h.writeLoginResult(w, result)
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	h.authService.Logout(SessionToken(r))
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	utils.WriteMessageResponse(w, http.StatusOK, "Logged out")
+}
+
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	user, ok := domain.UserFromContext(r.Context())
+	if !ok {
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Authentication required", nil)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"user":             user.Profile(),
+		"must_enroll_totp": h.authService.RequiresEnrollment(user),
+	})
+}
+
+func (h *AuthHandler) BeginTOTPEnrollment(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	enrollment, err := h.authService.BeginTOTPEnrollment(r.Context(), user.ID)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, enrollment)
+}
+
+func (h *AuthHandler) ConfirmTOTPEnrollment(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	codes, err := h.authService.ConfirmTOTPEnrollment(r.Context(), user.ID, request.Code)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"enabled":        true,
+		"recovery_codes": codes,
+	})
+}
+
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	var request struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if err := h.authService.DisableTOTP(r.Context(), user.ID, request.Code, request.RecoveryCode); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "Two-factor authentication disabled")
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(r.Context(), user.ID, request.Code)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *AuthHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	keys, err := h.authService.ListAPIKeys(r.Context(), user.ID)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"keys": keys,
+	})
+}
+
+// CreateAPIKey returns the new secret access key; it cannot be retrieved again
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	key, err := h.authService.CreateAPIKey(r.Context(), user.ID, request.Name)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, key)
+}
+
+func (h *AuthHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	if err := h.authService.DeleteAPIKey(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "API key deleted")
+}
+
+func (h *AuthHandler) ListSSHKeys(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	keys, err := h.authService.ListSSHKeys(r.Context(), user.ID)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"keys": keys,
+	})
+}
+
+func (h *AuthHandler) AddSSHKey(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	var request struct {
+		Name      string `json:"name"`
+		PublicKey string `json:"public_key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	key, err := h.authService.AddSSHKey(r.Context(), user.ID, request.Name, request.PublicKey)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, key)
+}
+
+func (h *AuthHandler) DeleteSSHKey(w http.ResponseWriter, r *http.Request) {
+	user, _ := domain.UserFromContext(r.Context())
+
+	if err := h.authService.DeleteSSHKey(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "SSH key deleted")
+}
+
+func (h *AuthHandler) writeLoginResult(w http.ResponseWriter, result *domain.LoginResult) {
+	if result.Session != nil {
+		h.setSessionCookie(w, result.Session)
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, result)
+}
+
+func (h *AuthHandler) setSessionCookie(w http.ResponseWriter, session *domain.Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func writeAuthError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidCredentials),
+		errors.Is(err, domain.ErrInvalidChallenge),
+		errors.Is(err, domain.ErrInvalidTOTPCode):
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Authentication failed", err)
+	case errors.Is(err, domain.ErrUserDisabled),
+		errors.Is(err, domain.ErrTOTPRequired),
+		errors.Is(err, domain.ErrAccessDenied):
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Operation not allowed", err)
+	case errors.Is(err, domain.ErrInvalidUserData):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid user data", err)
+	case errors.Is(err, domain.ErrUserNotFound):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "User not found", err)
+	case errors.Is(err, domain.ErrAPIKeyNotFound):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "API key not found", err)
+	case errors.Is(err, domain.ErrSSHKeyNotFound):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "SSH key not found", err)
+	case errors.Is(err, domain.ErrUserExists),
+		errors.Is(err, domain.ErrTOTPAlreadyEnabled),
+		errors.Is(err, domain.ErrTOTPNotEnabled),
+		errors.Is(err, domain.ErrTOTPNotPending),
+		errors.Is(err, domain.ErrTooManyAPIKeys),
+		errors.Is(err, domain.ErrTooManySSHKeys),
+		errors.Is(err, domain.ErrSSHKeyExists):
+		utils.WriteErrorResponse(w, http.StatusConflict, "Invalid state", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Authentication error", err)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const (
+	sessionCookieName = "cubert_session"
+	basicAuthRealm    = "Cubert"
+)
+
+// RequireAuth rejects requests without a valid, fully enrolled session
+func (h *AuthHandler) RequireAuth(next http.Handler) http.Handler {
+	return h.authenticate(next, false)
+}
+
+// RequireSession accepts restricted sessions too; used for the 2FA enrollment endpoints
+func (h *AuthHandler) RequireSession(next http.Handler) http.Handler {
+	return h.authenticate(next, true)
+}
+
+// RequireAdmin must be chained after RequireAuth
+func (h *AuthHandler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := domain.UserFromContext(r.Context())
+		if !ok || !user.IsAdmin() {
+			utils.WriteErrorResponse(w, http.StatusForbidden, "Administrator role required", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *AuthHandler) authenticate(next http.Handler, allowRestricted bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, session, err := h.authService.Authenticate(SessionToken(r))
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "Authentication required", err)
+			return
+		}
+
+		if !allowRestricted && h.authService.RequiresEnrollment(user) {
+			utils.WriteErrorResponse(w, http.StatusForbidden, "Two-factor enrollment required", domain.ErrEnrollmentRequired)
+			return
+		}
+
+		ctx := domain.ContextWithUser(r.Context(), user, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireBasicAuth authenticates clients such as WebDAV mounts through HTTP Basic credentials,
+// falling back to a bearer token or session cookie, and challenges them on failure
+func (h *AuthHandler) RequireBasicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			user    *domain.User
+			session *domain.Session
+			err     error
+		)
+		if username, secret, ok := r.BasicAuth(); ok {
+			user, session, err = h.authService.AuthenticateBasic(username, secret)
+		} else {
+			user, session, err = h.authService.Authenticate(SessionToken(r))
+			if err == nil && h.authService.RequiresEnrollment(user) {
+				err = domain.ErrEnrollmentRequired
+			}
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+basicAuthRealm+`", charset="UTF-8"`)
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "Authentication required", err)
+			return
+		}
+
+		ctx := domain.ContextWithUser(r.Context(), user, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// SessionToken extracts the session token from the Authorization header or the session cookie
+func SessionToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// ClientIP returns the remote address without the port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
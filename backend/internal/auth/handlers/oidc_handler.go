@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const oidcStateCookieName = "cubert_oidc_state"
+
+type OIDCHandler struct {
+	oidcService  *services.OIDCService
+	authHandler  *AuthHandler
+	postLoginURL string
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService, authHandler *AuthHandler, postLoginURL string) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:  oidcService,
+		authHandler:  authHandler,
+		postLoginURL: postLoginURL,
+	}
+}
+
+// Providers tells the login page which sign-in methods are available
+func (h *OIDCHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	providers := map[string]interface{}{
+		"local": true,
+		"oidc": map[string]interface{}{
+			"enabled": h.oidcService.Enabled(),
+			"name":    h.oidcService.ProviderName(),
+			"url":     "/api/v1/auth/oidc/login",
+		},
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, providers)
+}
+
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := h.oidcService.AuthCodeURL(r.Context())
+	if err != nil {
+		if errors.Is(err, domain.ErrOIDCDisabled) {
+			utils.WriteErrorResponse(w, http.StatusNotFound, "Single sign-on is not configured", err)
+			return
+		}
+		utils.WriteErrorResponse(w, http.StatusBadGateway, "Identity provider unavailable", err)
+		return
+	}
+
+	// El state queda ligado al navegador para evitar login CSRF
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    state,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   oidcStateCookieName,
+		Value:  "",
+		Path:   "/api/v1/auth/oidc",
+		MaxAge: -1,
+	})
+
+	if providerError := query.Get("error"); providerError != "" {
+		h.redirectWithError(w, r, providerError)
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if err != nil || state == "" || !utils.SecureCompare(cookie.Value, state) {
+		h.redirectWithError(w, r, "invalid_state")
+		return
+	}
+
+	result, err := h.oidcService.HandleCallback(r.Context(), state, query.Get("code"), ClientIP(r))
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		if errors.Is(err, domain.ErrUserDisabled) {
+			h.redirectWithError(w, r, "account_disabled")
+			return
+		}
+		h.redirectWithError(w, r, "sso_failed")
+		return
+	}
+
+	h.authHandler.setSessionCookie(w, result.Session)
+	http.Redirect(w, r, h.postLoginURL, http.StatusFound)
+}
+
+func (h *OIDCHandler) redirectWithError(w http.ResponseWriter, r *http.Request, reason string) {
+	http.Redirect(w, r, "/login?error="+url.QueryEscape(reason), http.StatusFound)
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+)
+
+// ACLService resolves the path rules granted to a user through their groups
+type ACLService struct {
+	users *UserStore
+}
+
+func NewACLService(users *UserStore) *ACLService {
+	return &ACLService{
+		users: users,
+	}
+}
+
+// Authorize returns domain.ErrAccessDenied unless one of the user's rules grants access to path
+func (s *ACLService) Authorize(ctx context.Context, user *domain.User, path string, access domain.Access) error {
+	if user == nil {
+		return domain.ErrAccessDenied
+	}
+	if user.IsAdmin() {
+		return nil
+	}
+
+	resolved := ResolvePath(path)
+	for _, rule := range s.RulesFor(user) {
+		rule.Path = ResolvePath(rule.Path)
+		if rule.Covers(resolved) && rule.Access.Allows(access) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", domain.ErrAccessDenied, path)
+}
+
+// RulesFor returns the rules that apply to the user, merged from every group they belong to
+func (s *ACLService) RulesFor(user *domain.User) []domain.ACLRule {
+	acl := s.users.ACL()
+
+	groups := append([]string{domain.EveryoneGroup}, user.Groups...)
+	var rules []domain.ACLRule
+	for _, group := range groups {
+		rules = append(rules, acl[group]...)
+	}
+	return rules
+}
+
+// AllowedRoots returns the top-level paths a user can browse, or nil when access is unrestricted
+func (s *ACLService) AllowedRoots(user *domain.User) []string {
+	if user.IsAdmin() {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var roots []string
+	for _, rule := range s.RulesFor(user) {
+		path := filepath.Clean(rule.Path)
+		if path == "/" {
+			return nil
+		}
+		if !seen[path] {
+			seen[path] = true
+			roots = append(roots, path)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+func (s *ACLService) GetACL() domain.GroupACL {
+	return s.users.ACL()
+}
+
+// SetGroupRules replaces the rules of a group; an empty list removes the group
+func (s *ACLService) SetGroupRules(group string, rules []domain.ACLRule) (domain.GroupACL, error) {
+	if group == "" {
+		return nil, fmt.Errorf("%w: group name is required", domain.ErrInvalidUserData)
+	}
+
+	for i, rule := range rules {
+		if !rule.Access.IsValid() {
+			return nil, fmt.Errorf("%w: invalid access %q", domain.ErrInvalidUserData, rule.Access)
+		}
+		if !filepath.IsAbs(rule.Path) {
+			return nil, fmt.Errorf("%w: ACL paths must be absolute: %s", domain.ErrInvalidUserData, rule.Path)
+		}
+		rules[i].Path = filepath.Clean(rule.Path)
+	}
+
+	acl := s.users.ACL()
+	if len(rules) == 0 {
+		delete(acl, group)
+	} else {
+		acl[group] = rules
+	}
+
+	if err := s.users.SetACL(acl); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// ResolvePath cleans a path and resolves symlinks so rules cannot be bypassed through links.
+// For paths that do not exist yet the closest existing ancestor is resolved.
+func ResolvePath(path string) string {
+	path = filepath.Clean(path)
+	if !filepath.IsAbs(path) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+
+	suffix := ""
+	current := path
+	for {
+		if resolved, err := filepath.EvalSymlinks(current); err == nil {
+			return filepath.Join(resolved, suffix)
+		} else if !os.IsNotExist(err) {
+			return path
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return path
+		}
+		suffix = filepath.Join(filepath.Base(current), suffix)
+		current = parent
+	}
+}
@@ -0,0 +1,682 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const (
+	sessionTokenBytes     = 32
+	challengeTTL          = 5 * time.Minute
+	maxChallengeAttempts  = 5
+	restrictedSessionTTL  = 15 * time.Minute
+	generatedPasswordSize = 20
+	// La contraseña generada se guarda aquí, dentro del directorio de datos, y nunca en el log
+	initialPasswordFile = "initial-admin-password"
+
+	// Las claves de acceso imitan el formato de AWS: prefijo y 18 caracteres
+	apiKeyIDPrefix    = "CK"
+	apiKeyIDBytes     = 9
+	apiKeySecretBytes = 20
+
+	sshKeyIDBytes = 8
+)
+
+type AuthService struct {
+	users      *UserStore
+	sessions   *SessionStore
+	totp       *TOTPService
+	sessionTTL time.Duration
+}
+
+func NewAuthService(users *UserStore, sessions *SessionStore, totp *TOTPService, sessionTTL time.Duration) *AuthService {
+	return &AuthService{
+		users:      users,
+		sessions:   sessions,
+		totp:       totp,
+		sessionTTL: sessionTTL,
+	}
+}
+
+// EnsureAdmin creates the initial administrator when the user store is empty.
+// If no password is supplied a random one is generated and written to a file
+// readable only by the server user.
+func (a *AuthService) EnsureAdmin(username, password string) error {
+	if a.users.Count() > 0 {
+		return nil
+	}
+
+	passwordFile := ""
+	if password == "" {
+		generated, err := utils.GenerateRandomString(generatedPasswordSize)
+		if err != nil {
+			return err
+		}
+		password = generated
+
+		passwordFile = filepath.Join(filepath.Dir(a.users.path), initialPasswordFile)
+		if err := utils.WriteFileAtomic(passwordFile, []byte(password+"\n"), 0600); err != nil {
+			return fmt.Errorf("failed to save initial admin password: %w", err)
+		}
+	}
+
+	if _, err := a.CreateUser(context.Background(), username, "", password, domain.RoleAdmin); err != nil {
+		return err
+	}
+	if passwordFile != "" {
+		log.Printf("🔑 Created initial admin user %q; its password is in %s", username, passwordFile)
+	}
+	return nil
+}
+
+func (a *AuthService) CreateUser(ctx context.Context, username, email, password string, role domain.Role) (*domain.User, error) {
+	username = utils.SanitizeInput(username)
+	if !utils.IsValidUsername(username) {
+		return nil, fmt.Errorf("%w: invalid username %q", domain.ErrInvalidUserData, username)
+	}
+	if email != "" && !utils.IsValidEmail(email) {
+		return nil, fmt.Errorf("%w: invalid email %q", domain.ErrInvalidUserData, email)
+	}
+	if !role.IsValid() {
+		return nil, fmt.Errorf("%w: invalid role %q", domain.ErrInvalidUserData, role)
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		Username:     username,
+		Email:        email,
+		PasswordHash: hash,
+		Role:         role,
+	}
+	if err := a.users.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (a *AuthService) Login(ctx context.Context, username, password, ip string) (*domain.LoginResult, error) {
+	user, err := a.users.GetByUsername(strings.TrimSpace(username))
+	if err != nil || !utils.VerifyPassword(password, user.PasswordHash) {
+		return nil, domain.ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return nil, domain.ErrUserDisabled
+	}
+
+	if user.TOTPEnabled {
+		token, err := utils.GenerateToken(sessionTokenBytes)
+		if err != nil {
+			return nil, err
+		}
+		a.sessions.SaveChallenge(&domain.LoginChallenge{
+			Token:     token,
+			UserID:    user.ID,
+			IP:        ip,
+			ExpiresAt: time.Now().Add(challengeTTL),
+		})
+		return &domain.LoginResult{TOTPRequired: true, Challenge: token}, nil
+	}
+
+	// El rol exige 2FA pero el usuario aún no lo configuró
+	return a.startSession(user, ip, a.RequiresEnrollment(user))
+}
+
+// VerifyLogin completes a login challenge with a TOTP code or a recovery code
+func (a *AuthService) VerifyLogin(ctx context.Context, challengeToken, code, recoveryCode string) (*domain.LoginResult, error) {
+	challenge, ok := a.sessions.GetChallenge(challengeToken)
+	if !ok {
+		return nil, domain.ErrInvalidChallenge
+	}
+
+	user, err := a.users.Get(challenge.UserID)
+	if err != nil {
+		return nil, domain.ErrInvalidChallenge
+	}
+	if user.Disabled {
+		return nil, domain.ErrUserDisabled
+	}
+
+	if err := a.verifySecondFactor(user, code, recoveryCode); err != nil {
+		a.sessions.RecordChallengeAttempt(challengeToken, maxChallengeAttempts)
+		return nil, err
+	}
+	a.sessions.DeleteChallenge(challengeToken)
+
+	user, err = a.users.Get(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return a.startSession(user, challenge.IP, false)
+}
+
+// RequiresEnrollment reports whether the user's role mandates 2FA that is not yet configured
+func (a *AuthService) RequiresEnrollment(user *domain.User) bool {
+	// Con SSO el segundo factor lo exige el proveedor de identidad
+	if user.TOTPEnabled || user.Source() == domain.AuthSourceOIDC {
+		return false
+	}
+	return a.users.Policy().Requires2FA(user.Role)
+}
+
+// StartExternalSession opens a session for a user already authenticated by an identity provider
+func (a *AuthService) StartExternalSession(user *domain.User, ip string) (*domain.LoginResult, error) {
+	return a.startSession(user, ip, false)
+}
+
+func (a *AuthService) Logout(token string) {
+	a.sessions.DeleteSession(token)
+}
+
+// Authenticate resolves a session token to its user
+func (a *AuthService) Authenticate(token string) (*domain.User, *domain.Session, error) {
+	if token == "" {
+		return nil, nil, domain.ErrInvalidSession
+	}
+
+	session, ok := a.sessions.GetSession(token)
+	if !ok {
+		return nil, nil, domain.ErrInvalidSession
+	}
+
+	user, err := a.users.Get(session.UserID)
+	if err != nil || user.Disabled {
+		a.sessions.DeleteSession(token)
+		return nil, nil, domain.ErrInvalidSession
+	}
+	return user, session, nil
+}
+
+// AuthenticateBasic resolves HTTP Basic credentials for clients that cannot log in interactively.
+// The secret is either a session token of the user or, when no second factor applies, the password.
+func (a *AuthService) AuthenticateBasic(username, secret string) (*domain.User, *domain.Session, error) {
+	if user, session, err := a.Authenticate(secret); err == nil {
+		if user.Username != strings.TrimSpace(username) {
+			return nil, nil, domain.ErrInvalidCredentials
+		}
+		if a.RequiresEnrollment(user) {
+			return nil, nil, domain.ErrEnrollmentRequired
+		}
+		return user, session, nil
+	}
+
+	user, err := a.users.GetByUsername(strings.TrimSpace(username))
+	if err != nil || !utils.VerifyPassword(secret, user.PasswordHash) {
+		return nil, nil, domain.ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return nil, nil, domain.ErrUserDisabled
+	}
+	// La contraseña sola no basta cuando el usuario tiene o debe tener 2FA
+	if user.TOTPEnabled || a.RequiresEnrollment(user) {
+		return nil, nil, domain.ErrTOTPRequired
+	}
+	return user, nil, nil
+}
+
+// BeginTOTPEnrollment generates a new pending secret for the user
+func (a *AuthService) BeginTOTPEnrollment(ctx context.Context, userID string) (*domain.TOTPEnrollment, error) {
+	secret, err := a.totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.users.Update(userID, func(user *domain.User) error {
+		if user.TOTPEnabled {
+			return domain.ErrTOTPAlreadyEnabled
+		}
+		user.TOTPPendingSecret = secret
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a.totp.NewEnrollment(user.Username, secret)
+}
+
+// ConfirmTOTPEnrollment activates the pending secret and returns fresh recovery codes
+func (a *AuthService) ConfirmTOTPEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	plain, hashed, err := a.totp.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = a.users.Update(userID, func(user *domain.User) error {
+		if user.TOTPEnabled {
+			return domain.ErrTOTPAlreadyEnabled
+		}
+		if user.TOTPPendingSecret == "" {
+			return domain.ErrTOTPNotPending
+		}
+
+		counter, ok := a.totp.Validate(user.TOTPPendingSecret, code, 0)
+		if !ok {
+			return domain.ErrInvalidTOTPCode
+		}
+
+		user.TOTPEnabled = true
+		user.TOTPSecret = user.TOTPPendingSecret
+		user.TOTPPendingSecret = ""
+		user.TOTPLastCounter = counter
+		user.RecoveryCodes = hashed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plain, nil
+}
+
+// DisableTOTP turns off the second factor after verifying a current code or recovery code
+func (a *AuthService) DisableTOTP(ctx context.Context, userID, code, recoveryCode string) error {
+	user, err := a.users.Get(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return domain.ErrTOTPNotEnabled
+	}
+	if a.users.Policy().Requires2FA(user.Role) {
+		return domain.ErrTOTPRequired
+	}
+	if err := a.verifySecondFactor(user, code, recoveryCode); err != nil {
+		return err
+	}
+
+	_, err = a.users.Update(userID, clearTOTP)
+	return err
+}
+
+// RegenerateRecoveryCodes replaces every recovery code after verifying a current TOTP code
+func (a *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := a.users.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, domain.ErrTOTPNotEnabled
+	}
+	if err := a.verifySecondFactor(user, code, ""); err != nil {
+		return nil, err
+	}
+
+	plain, hashed, err := a.totp.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = a.users.Update(userID, func(user *domain.User) error {
+		user.RecoveryCodes = hashed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+// ResetTOTP lets an administrator clear a user's second factor (e.g. lost device)
+func (a *AuthService) ResetTOTP(ctx context.Context, userID string) error {
+	if _, err := a.users.Update(userID, clearTOTP); err != nil {
+		return err
+	}
+	a.sessions.DeleteUserSessions(userID)
+	return nil
+}
+
+// CreateAPIKey generates an access key and secret for the user. The secret is only returned here.
+func (a *AuthService) CreateAPIKey(ctx context.Context, userID, name string) (*domain.NewAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: key name is required", domain.ErrInvalidUserData)
+	}
+
+	id, err := utils.GenerateToken(apiKeyIDBytes)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := utils.GenerateToken(apiKeySecretBytes)
+	if err != nil {
+		return nil, err
+	}
+	key := domain.APIKey{
+		ID:        apiKeyIDPrefix + strings.ToUpper(id),
+		Name:      name,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = a.users.Update(userID, func(user *domain.User) error {
+		if len(user.APIKeys) >= domain.MaxAPIKeysPerUser {
+			return domain.ErrTooManyAPIKeys
+		}
+		user.APIKeys = append(append([]domain.APIKey{}, user.APIKeys...), key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domain.NewAPIKey{APIKeyInfo: key.Info(), SecretAccessKey: secret}, nil
+}
+
+func (a *AuthService) ListAPIKeys(ctx context.Context, userID string) ([]domain.APIKeyInfo, error) {
+	user, err := a.users.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]domain.APIKeyInfo, 0, len(user.APIKeys))
+	for _, key := range user.APIKeys {
+		keys = append(keys, key.Info())
+	}
+	return keys, nil
+}
+
+func (a *AuthService) DeleteAPIKey(ctx context.Context, userID, keyID string) error {
+	_, err := a.users.Update(userID, func(user *domain.User) error {
+		keys := make([]domain.APIKey, 0, len(user.APIKeys))
+		for _, key := range user.APIKeys {
+			if key.ID != keyID {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == len(user.APIKeys) {
+			return domain.ErrAPIKeyNotFound
+		}
+		user.APIKeys = keys
+		return nil
+	})
+	return err
+}
+
+// LookupAPIKey returns the owner of an access key and its secret, for signature verification
+func (a *AuthService) LookupAPIKey(accessKeyID string) (*domain.User, string, error) {
+	for _, user := range a.users.List() {
+		for _, key := range user.APIKeys {
+			if key.ID != accessKeyID {
+				continue
+			}
+			if user.Disabled {
+				return nil, "", domain.ErrUserDisabled
+			}
+			if a.RequiresEnrollment(user) {
+				return nil, "", domain.ErrEnrollmentRequired
+			}
+			return user, key.Secret, nil
+		}
+	}
+	return nil, "", domain.ErrAPIKeyNotFound
+}
+
+// AddSSHKey registers a public key in authorized_keys format for SFTP logins
+func (a *AuthService) AddSSHKey(ctx context.Context, userID, name, publicKey string) (*domain.SSHKey, error) {
+	parsed, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid public key: %v", domain.ErrInvalidUserData, err)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		// Sin nombre se usa el comentario de la clave, p. ej. "ana@portatil"
+		name = comment
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: key name is required", domain.ErrInvalidUserData)
+	}
+
+	id, err := utils.GenerateToken(sshKeyIDBytes)
+	if err != nil {
+		return nil, err
+	}
+	key := domain.SSHKey{
+		ID:          id,
+		Name:        name,
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(parsed))),
+		Fingerprint: ssh.FingerprintSHA256(parsed),
+		CreatedAt:   time.Now(),
+	}
+
+	_, err = a.users.Update(userID, func(user *domain.User) error {
+		if len(user.SSHKeys) >= domain.MaxSSHKeysPerUser {
+			return domain.ErrTooManySSHKeys
+		}
+		for _, existing := range user.SSHKeys {
+			if existing.Fingerprint == key.Fingerprint {
+				return domain.ErrSSHKeyExists
+			}
+		}
+		user.SSHKeys = append(append([]domain.SSHKey{}, user.SSHKeys...), key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (a *AuthService) ListSSHKeys(ctx context.Context, userID string) ([]domain.SSHKey, error) {
+	user, err := a.users.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]domain.SSHKey{}, user.SSHKeys...), nil
+}
+
+func (a *AuthService) DeleteSSHKey(ctx context.Context, userID, keyID string) error {
+	_, err := a.users.Update(userID, func(user *domain.User) error {
+		keys := make([]domain.SSHKey, 0, len(user.SSHKeys))
+		for _, key := range user.SSHKeys {
+			if key.ID != keyID {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == len(user.SSHKeys) {
+			return domain.ErrSSHKeyNotFound
+		}
+		user.SSHKeys = keys
+		return nil
+	})
+	return err
+}
+
+// AuthenticateSSHKey resolves an SFTP public key login. Like API keys, a registered
+// key stands in for the second factor.
+func (a *AuthService) AuthenticateSSHKey(username string, key ssh.PublicKey) (*domain.User, error) {
+	user, err := a.users.GetByUsername(strings.TrimSpace(username))
+	if err != nil {
+		return nil, domain.ErrInvalidCredentials
+	}
+	fingerprint := ssh.FingerprintSHA256(key)
+	for _, registered := range user.SSHKeys {
+		if registered.Fingerprint != fingerprint {
+			continue
+		}
+		if user.Disabled {
+			return nil, domain.ErrUserDisabled
+		}
+		if a.RequiresEnrollment(user) {
+			return nil, domain.ErrEnrollmentRequired
+		}
+		return user, nil
+	}
+	return nil, domain.ErrInvalidCredentials
+}
+
+func (a *AuthService) ListUsers(ctx context.Context) []*domain.User {
+	return a.users.List()
+}
+
+func (a *AuthService) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	return a.users.Get(userID)
+}
+
+// UpdateUser applies an administrative update (role, groups, disabled flag or password)
+func (a *AuthService) UpdateUser(ctx context.Context, userID string, update domain.UserUpdate) (*domain.User, error) {
+	var passwordHash string
+	if update.Password != nil {
+		hash, err := utils.HashPassword(*update.Password)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = hash
+	}
+
+	user, err := a.users.Update(userID, func(user *domain.User) error {
+		if update.Role != nil {
+			if !update.Role.IsValid() {
+				return fmt.Errorf("%w: invalid role %q", domain.ErrInvalidUserData, *update.Role)
+			}
+			user.Role = *update.Role
+		}
+		if update.Groups != nil {
+			user.Groups = *update.Groups
+		}
+		if update.Disabled != nil {
+			user.Disabled = *update.Disabled
+		}
+		if passwordHash != "" {
+			if user.Source() != domain.AuthSourceLocal {
+				return fmt.Errorf("%w: cannot set a password on an external account", domain.ErrInvalidUserData)
+			}
+			user.PasswordHash = passwordHash
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Disabled != nil && *update.Disabled || update.Password != nil {
+		a.sessions.DeleteUserSessions(userID)
+	}
+	return user, nil
+}
+
+func (a *AuthService) DeleteUser(ctx context.Context, userID string) error {
+	if err := a.users.Delete(userID); err != nil {
+		return err
+	}
+	a.sessions.DeleteUserSessions(userID)
+	return nil
+}
+
+func (a *AuthService) GetPolicy() domain.SecurityPolicy {
+	return a.users.Policy()
+}
+
+func (a *AuthService) SetRequire2FARoles(roles []domain.Role) (domain.SecurityPolicy, error) {
+	for _, role := range roles {
+		if !role.IsValid() {
+			return domain.SecurityPolicy{}, fmt.Errorf("%w: invalid role %q", domain.ErrInvalidUserData, role)
+		}
+	}
+
+	policy := a.users.Policy()
+	policy.Require2FARoles = roles
+	if err := a.users.SetPolicy(policy); err != nil {
+		return domain.SecurityPolicy{}, err
+	}
+	return policy, nil
+}
+
+// ApplyDefaultPolicy seeds the 2FA role policy from configuration when none is stored
+func (a *AuthService) ApplyDefaultPolicy(roles []string) error {
+	if len(roles) == 0 || len(a.users.Policy().Require2FARoles) > 0 {
+		return nil
+	}
+
+	parsed := make([]domain.Role, 0, len(roles))
+	for _, role := range roles {
+		parsed = append(parsed, domain.Role(role))
+	}
+	_, err := a.SetRequire2FARoles(parsed)
+	return err
+}
+
+func (a *AuthService) verifySecondFactor(user *domain.User, code, recoveryCode string) error {
+	if code != "" {
+		// Se valida dentro de Update para que dos inicios con el mismo código no pasen ambos
+		_, err := a.users.Update(user.ID, func(u *domain.User) error {
+			counter, ok := a.totp.Validate(u.TOTPSecret, code, u.TOTPLastCounter)
+			if !ok {
+				return domain.ErrInvalidTOTPCode
+			}
+			u.TOTPLastCounter = counter
+			return nil
+		})
+		return err
+	}
+
+	if recoveryCode != "" {
+		_, err := a.users.Update(user.ID, func(u *domain.User) error {
+			remaining, ok := a.totp.ConsumeRecoveryCode(u.RecoveryCodes, recoveryCode)
+			if !ok {
+				return domain.ErrInvalidTOTPCode
+			}
+			u.RecoveryCodes = remaining
+			return nil
+		})
+		return err
+	}
+
+	return domain.ErrInvalidTOTPCode
+}
+
+func (a *AuthService) startSession(user *domain.User, ip string, restricted bool) (*domain.LoginResult, error) {
+	token, err := utils.GenerateToken(sessionTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ttl := a.sessionTTL
+	if restricted {
+		ttl = restrictedSessionTTL
+	}
+
+	session := &domain.Session{
+		Token:      token,
+		UserID:     user.ID,
+		IP:         ip,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+		Restricted: restricted,
+	}
+	a.sessions.SaveSession(session)
+
+	updated, err := a.users.Update(user.ID, func(u *domain.User) error {
+		u.LastLoginAt = now
+		return nil
+	})
+	if err != nil {
+		updated = user
+	}
+
+	profile := updated.Profile()
+	return &domain.LoginResult{
+		Session:    session,
+		User:       &profile,
+		MustEnroll: restricted,
+	}, nil
+}
+
+func clearTOTP(user *domain.User) error {
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPPendingSecret = ""
+	user.TOTPLastCounter = 0
+	user.RecoveryCodes = nil
+	return nil
+}
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const (
+	oidcStateTTL         = 10 * time.Minute
+	oidcStateBytes       = 24
+	maxUsernameLength    = 32
+	minUsernameLength    = 3
+	usernameSuffixDigits = 4
+)
+
+var invalidUsernameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+type OIDCConfig struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+	RolesClaim    string
+	AdminGroups   []string
+	ProviderName  string
+}
+
+type oidcPendingLogin struct {
+	nonce     string
+	verifier  string
+	expiresAt time.Time
+}
+
+// OIDCService implements the authorization-code flow with PKCE against an external provider
+// and maps the resulting identity onto a local user.
+type OIDCService struct {
+	cfg   OIDCConfig
+	users *UserStore
+	auth  *AuthService
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+	oauth    *oauth2.Config
+	pending  map[string]*oidcPendingLogin
+}
+
+func NewOIDCService(cfg OIDCConfig, users *UserStore, auth *AuthService) *OIDCService {
+	return &OIDCService{
+		cfg:     cfg,
+		users:   users,
+		auth:    auth,
+		pending: make(map[string]*oidcPendingLogin),
+	}
+}
+
+func (s *OIDCService) Enabled() bool {
+	return s.cfg.Issuer != "" && s.cfg.ClientID != "" && s.cfg.RedirectURL != ""
+}
+
+func (s *OIDCService) ProviderName() string {
+	return s.cfg.ProviderName
+}
+
+// AuthCodeURL starts a login and returns the provider URL and the state to bind to the browser
+func (s *OIDCService) AuthCodeURL(ctx context.Context) (string, string, error) {
+	if err := s.ensureProvider(ctx); err != nil {
+		return "", "", err
+	}
+
+	state, err := utils.GenerateToken(oidcStateBytes)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.GenerateToken(oidcStateBytes)
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	s.mu.Lock()
+	s.cleanupLocked()
+	s.pending[state] = &oidcPendingLogin{
+		nonce:     nonce,
+		verifier:  verifier,
+		expiresAt: time.Now().Add(oidcStateTTL),
+	}
+	oauthConfig := s.oauth
+	s.mu.Unlock()
+
+	url := oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	return url, state, nil
+}
+
+// HandleCallback exchanges the authorization code, validates the ID token and opens a session
+func (s *OIDCService) HandleCallback(ctx context.Context, state, code, ip string) (*domain.LoginResult, error) {
+	if err := s.ensureProvider(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	pending, ok := s.pending[state]
+	delete(s.pending, state)
+	oauthConfig, verifier, provider := s.oauth, s.verifier, s.provider
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(pending.expiresAt) {
+		return nil, domain.ErrOIDCState
+	}
+
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(pending.verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("provider response did not include an id_token")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id_token: %w", err)
+	}
+	if !utils.SecureCompare(idToken.Nonce, pending.nonce) {
+		return nil, fmt.Errorf("id_token nonce mismatch")
+	}
+
+	claims := make(map[string]interface{})
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode id_token claims: %w", err)
+	}
+
+	// Algunos proveedores solo exponen los grupos en userinfo
+	if _, found := lookupClaim(claims, s.cfg.GroupsClaim); !found {
+		if info, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token)); err == nil {
+			extra := make(map[string]interface{})
+			if err := info.Claims(&extra); err == nil {
+				for key, value := range extra {
+					if _, exists := claims[key]; !exists {
+						claims[key] = value
+					}
+				}
+			}
+		}
+	}
+
+	user, err := s.syncUser(idToken.Issuer, idToken.Subject, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.auth.StartExternalSession(user, ip)
+}
+
+// syncUser creates or updates the local user linked to the external identity
+func (s *OIDCService) syncUser(issuer, subject string, claims map[string]interface{}) (*domain.User, error) {
+	externalID := issuer + "|" + subject
+	email := claimString(claims, "email")
+	groups := normalizeGroups(claimStrings(claims, s.cfg.GroupsClaim))
+	roles := claimStrings(claims, s.cfg.RolesClaim)
+
+	role := domain.RoleUser
+	if s.isAdmin(groups, roles) {
+		role = domain.RoleAdmin
+	}
+
+	existing, err := s.users.GetByExternalID(externalID)
+	if err == nil {
+		if existing.Disabled {
+			return nil, domain.ErrUserDisabled
+		}
+		return s.users.Update(existing.ID, func(user *domain.User) error {
+			user.Email = email
+			user.Groups = groups
+			user.Role = role
+			return nil
+		})
+	}
+
+	username, err := s.availableUsername(claims, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		Username:   username,
+		Email:      email,
+		Role:       role,
+		Groups:     groups,
+		AuthSource: domain.AuthSourceOIDC,
+		ExternalID: externalID,
+	}
+	if err := s.users.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *OIDCService) isAdmin(groups, roles []string) bool {
+	for _, admin := range s.cfg.AdminGroups {
+		admin = strings.TrimPrefix(admin, "/")
+		for _, group := range groups {
+			if strings.EqualFold(group, admin) {
+				return true
+			}
+		}
+		for _, role := range roles {
+			if strings.EqualFold(role, admin) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// availableUsername derives a valid local username that does not collide with existing accounts
+func (s *OIDCService) availableUsername(claims map[string]interface{}, subject string) (string, error) {
+	candidate := claimString(claims, s.cfg.UsernameClaim)
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(claimString(claims, "email"), "@")
+	}
+	if candidate == "" {
+		candidate = subject
+	}
+
+	base := invalidUsernameChars.ReplaceAllString(candidate, "_")
+	if len(base) > maxUsernameLength-usernameSuffixDigits-1 {
+		base = base[:maxUsernameLength-usernameSuffixDigits-1]
+	}
+	for len(base) < minUsernameLength {
+		base += "_"
+	}
+
+	username := base
+	for i := 0; i < 10; i++ {
+		if _, err := s.users.GetByUsername(username); err != nil {
+			return username, nil
+		}
+		suffix, err := utils.GenerateRandomString(usernameSuffixDigits)
+		if err != nil {
+			return "", err
+		}
+		username = base + "-" + suffix
+	}
+	return "", fmt.Errorf("could not allocate a username for %s", candidate)
+}
+
+// ensureProvider performs discovery lazily so the server can start while the provider is unreachable
+func (s *OIDCService) ensureProvider(ctx context.Context) error {
+	if !s.Enabled() {
+		return domain.ErrOIDCDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider != nil {
+		return nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, s.cfg.Issuer)
+	if err != nil {
+		return fmt.Errorf("failed to discover OIDC provider %s: %w", s.cfg.Issuer, err)
+	}
+
+	scopes := s.cfg.Scopes
+	if !containsString(scopes, oidc.ScopeOpenID) {
+		scopes = append([]string{oidc.ScopeOpenID}, scopes...)
+	}
+
+	s.provider = provider
+	s.verifier = provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID})
+	s.oauth = &oauth2.Config{
+		ClientID:     s.cfg.ClientID,
+		ClientSecret: s.cfg.ClientSecret,
+		RedirectURL:  s.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+	return nil
+}
+
+func (s *OIDCService) cleanupLocked() {
+	now := time.Now()
+	for state, pending := range s.pending {
+		if now.After(pending.expiresAt) {
+			delete(s.pending, state)
+		}
+	}
+}
+
+// lookupClaim resolves dotted claim names such as "realm_access.roles"
+func lookupClaim(claims map[string]interface{}, name string) (interface{}, bool) {
+	if name == "" {
+		return nil, false
+	}
+	if value, ok := claims[name]; ok {
+		return value, true
+	}
+
+	var current interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := lookupClaim(claims, name)
+	str, _ := value.(string)
+	return strings.TrimSpace(str)
+}
+
+func claimStrings(claims map[string]interface{}, name string) []string {
+	value, ok := lookupClaim(claims, name)
+	if !ok {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(strings.ReplaceAll(v, ",", " "))
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok && str != "" {
+				items = append(items, str)
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+// normalizeGroups strips the leading slash Keycloak adds to group paths
+func normalizeGroups(groups []string) []string {
+	normalized := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if group = strings.TrimPrefix(strings.TrimSpace(group), "/"); group != "" {
+			normalized = append(normalized, group)
+		}
+	}
+	return normalized
+}
+
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+)
+
+const testOIDCClientID = "cubert"
+
+// fakeIdP is an OpenID provider serving discovery, JWKS, token and userinfo. The
+// browser step is replaced by authorize, which issues a code for an auth URL.
+type fakeIdP struct {
+	server *httptest.Server
+	// key se publica en el JWKS; signingKey firma los ID tokens
+	key        *rsa.PrivateKey
+	signingKey *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]fakeGrant
+	// userinfo se devuelve a cualquier token de acceso emitido
+	userinfo map[string]interface{}
+}
+
+type fakeGrant struct {
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating signing key: %v", err)
+	}
+	idp := &fakeIdP{key: key, signingKey: key, codes: make(map[string]fakeGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/userinfo", idp.userInfo)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (p *fakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := p.server.URL
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *fakeIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	encode := base64.RawURLEncoding.EncodeToString
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   encode(p.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token canjea un código comprobando el code_verifier contra el challenge de PKCE
+func (p *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	grant, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     p.sign(grant.claims),
+	})
+}
+
+func (p *fakeIdP) userInfo(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.userinfo == nil {
+		writeTestJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, p.userinfo)
+}
+
+// authorize plays the browser and the login page: it reads the nonce and the PKCE
+// challenge of authURL and returns a code for an ID token with those claims
+func (p *fakeIdP) authorize(t *testing.T, authURL string, claims map[string]interface{}) string {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parsing auth URL: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("auth URL without an S256 PKCE challenge: %s", authURL)
+	}
+
+	idClaims := map[string]interface{}{
+		"iss":   p.server.URL,
+		"aud":   testOIDCClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code := "code-" + query.Get("state")
+	p.mu.Lock()
+	p.codes[code] = fakeGrant{challenge: query.Get("code_challenge"), claims: idClaims}
+	p.mu.Unlock()
+	return code
+}
+
+func (p *fakeIdP) sign(claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"}) + "." + encode(claims)
+	hashed := sha256.Sum256([]byte(signed))
+	p.mu.Lock()
+	key := p.signingKey
+	p.mu.Unlock()
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newTestOIDCService configures single sign-on against idp with roles read from
+// realm_access.roles and groups from "groups", as Keycloak sends them
+func newTestOIDCService(t *testing.T, idp *fakeIdP) (*OIDCService, *UserStore) {
+	t.Helper()
+
+	users, err := NewUserStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewUserStore: %v", err)
+	}
+	auth := NewAuthService(users, NewSessionStore(), NewTOTPService("Cubert"), time.Hour)
+	service := NewOIDCService(OIDCConfig{
+		Issuer:        idp.server.URL,
+		ClientID:      testOIDCClientID,
+		ClientSecret:  "client-secret",
+		RedirectURL:   "http://cubert.test/api/v1/auth/oidc/callback",
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		RolesClaim:    "realm_access.roles",
+		AdminGroups:   []string{"/cubert-admins"},
+	}, users, auth)
+	return service, users
+}
+
+func startOIDCLogin(t *testing.T, service *OIDCService) (string, string) {
+	t.Helper()
+
+	authURL, state, err := service.AuthCodeURL(context.Background())
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if got := mustQuery(t, authURL).Get("state"); got != state {
+		t.Fatalf("auth URL state = %q, want %q", got, state)
+	}
+	return authURL, state
+}
+
+func mustQuery(t *testing.T, rawURL string) url.Values {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", rawURL, err)
+	}
+	return parsed.Query()
+}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	idp := newFakeIdP(t)
+	service, users := newTestOIDCService(t, idp)
+	ctx := context.Background()
+
+	authURL, state := startOIDCLogin(t, service)
+	if query := mustQuery(t, authURL); query.Get("client_id") != testOIDCClientID || !strings.Contains(query.Get("scope"), "openid") {
+		t.Errorf("unexpected auth URL: %s", authURL)
+	}
+	code := idp.authorize(t, authURL, map[string]interface{}{
+		"sub":                "user-1",
+		"preferred_username": "ana.garcia",
+		"email":              "ana@example.com",
+		"groups":             []string{"/staff", "/projects/alpha"},
+	})
+
+	result, err := service.HandleCallback(ctx, state, code, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	if result.Session == nil || result.Session.Token == "" {
+		t.Fatalf("no session opened: %+v", result)
+	}
+
+	user, err := users.GetByExternalID(idp.server.URL + "|user-1")
+	if err != nil {
+		t.Fatalf("user not linked to the identity: %v", err)
+	}
+	// Los caracteres no válidos del nombre se sustituyen y se quita la barra de Keycloak
+	if user.Username != "ana_garcia" || user.Email != "ana@example.com" || user.AuthSource != domain.AuthSourceOIDC {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	if strings.Join(user.Groups, ",") != "staff,projects/alpha" {
+		t.Errorf("groups = %v, want [staff projects/alpha]", user.Groups)
+	}
+	if user.Role != domain.RoleUser {
+		t.Errorf("role = %s, want %s", user.Role, domain.RoleUser)
+	}
+}
+
+func TestOIDCRejectsStateMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	service, users := newTestOIDCService(t, idp)
+	ctx := context.Background()
+
+	authURL, state := startOIDCLogin(t, service)
+	code := idp.authorize(t, authURL, map[string]interface{}{"sub": "user-1"})
+
+	if _, err := service.HandleCallback(ctx, "forged-state", code, "127.0.0.1"); !errors.Is(err, domain.ErrOIDCState) {
+		t.Errorf("unknown state error = %v, want ErrOIDCState", err)
+	}
+	if _, err := service.HandleCallback(ctx, state, code, "127.0.0.1"); err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	// Un state solo se puede usar una vez
+	if _, err := service.HandleCallback(ctx, state, code, "127.0.0.1"); !errors.Is(err, domain.ErrOIDCState) {
+		t.Errorf("reused state error = %v, want ErrOIDCState", err)
+	}
+	if users.Count() != 1 {
+		t.Errorf("%d users created, want 1", users.Count())
+	}
+}
+
+func TestOIDCRejectsWrongPKCEVerifier(t *testing.T) {
+	idp := newFakeIdP(t)
+	service, users := newTestOIDCService(t, idp)
+
+	// El código se emitió para el challenge del primer login, pero se canjea con el
+	// verifier del segundo
+	firstURL, _ := startOIDCLogin(t, service)
+	_, secondState := startOIDCLogin(t, service)
+	code := idp.authorize(t, firstURL, map[string]interface{}{"sub": "user-1"})
+
+	if _, err := service.HandleCallback(context.Background(), secondState, code, "127.0.0.1"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("exchange with another verifier error = %v, want invalid_grant", err)
+	}
+	if users.Count() != 0 {
+		t.Errorf("%d users created after a failed exchange", users.Count())
+	}
+}
+
+func TestOIDCRejectsNonceMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	service, users := newTestOIDCService(t, idp)
+
+	authURL, state := startOIDCLogin(t, service)
+	code := idp.authorize(t, authURL, map[string]interface{}{
+		"sub":   "user-1",
+		"nonce": "replayed-nonce",
+	})
+
+	if _, err := service.HandleCallback(context.Background(), state, code, "127.0.0.1"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("nonce mismatch error = %v, want a nonce error", err)
+	}
+	if users.Count() != 0 {
+		t.Errorf("%d users created with a replayed token", users.Count())
+	}
+}
+
+func TestOIDCRejectsTokenFromAnotherKey(t *testing.T) {
+	idp := newFakeIdP(t)
+	service, _ := newTestOIDCService(t, idp)
+
+	authURL, state := startOIDCLogin(t, service)
+	code := idp.authorize(t, authURL, map[string]interface{}{"sub": "user-1"})
+
+	// El proveedor firma con una clave que no publica en el JWKS
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	idp.mu.Lock()
+	idp.signingKey = other
+	idp.mu.Unlock()
+
+	if _, err := service.HandleCallback(context.Background(), state, code, "127.0.0.1"); err == nil || !strings.Contains(err.Error(), "verify") {
+		t.Errorf("token signed with an unknown key error = %v, want a verification error", err)
+	}
+}
+
+func TestOIDCMapsClaimsToRole(t *testing.T) {
+	idp := newFakeIdP(t)
+	service, users := newTestOIDCService(t, idp)
+	ctx := context.Background()
+
+	login := func(claims map[string]interface{}) *domain.User {
+		t.Helper()
+		authURL, state := startOIDCLogin(t, service)
+		code := idp.authorize(t, authURL, claims)
+		if _, err := service.HandleCallback(ctx, state, code, "127.0.0.1"); err != nil {
+			t.Fatalf("HandleCallback: %v", err)
+		}
+		user, err := users.GetByExternalID(idp.server.URL + "|" + claims["sub"].(string))
+		if err != nil {
+			t.Fatalf("GetByExternalID: %v", err)
+		}
+		return user
+	}
+
+	// Rol anidado en realm_access.roles
+	byRole := login(map[string]interface{}{
+		"sub":          "by-role",
+		"realm_access": map[string]interface{}{"roles": []string{"offline_access", "CUBERT-ADMINS"}},
+	})
+	if byRole.Role != domain.RoleAdmin {
+		t.Errorf("role from realm_access.roles = %s, want admin", byRole.Role)
+	}
+
+	byGroup := login(map[string]interface{}{"sub": "by-group", "groups": []string{"/cubert-admins"}})
+	if byGroup.Role != domain.RoleAdmin {
+		t.Errorf("role from groups = %s, want admin", byGroup.Role)
+	}
+
+	// Al salir del grupo se pierde el rol en el siguiente inicio de sesión
+	demoted := login(map[string]interface{}{"sub": "by-group", "groups": []string{"/staff"}})
+	if demoted.ID != byGroup.ID || demoted.Role != domain.RoleUser {
+		t.Errorf("after leaving the group: id %s role %s, want id %s role user", demoted.ID, demoted.Role, byGroup.ID)
+	}
+
+	// Sin grupos en el token se completan desde userinfo
+	idp.mu.Lock()
+	idp.userinfo = map[string]interface{}{"sub": "by-userinfo", "groups": "cubert-admins,staff"}
+	idp.mu.Unlock()
+	fromUserInfo := login(map[string]interface{}{"sub": "by-userinfo"})
+	if fromUserInfo.Role != domain.RoleAdmin || strings.Join(fromUserInfo.Groups, ",") != "cubert-admins,staff" {
+		t.Errorf("from userinfo: role %s groups %v", fromUserInfo.Role, fromUserInfo.Groups)
+	}
+}
+
+func TestOIDCGroupsGrantACLRules(t *testing.T) {
+	idp := newFakeIdP(t)
+	service, users := newTestOIDCService(t, idp)
+	acl := NewACLService(users)
+	ctx := context.Background()
+
+	library := t.TempDir()
+	shared, private := filepath.Join(library, "shared"), filepath.Join(library, "private")
+	// Sin la regla por defecto que da acceso a todo a cualquier usuario
+	if _, err := acl.SetGroupRules(domain.EveryoneGroup, nil); err != nil {
+		t.Fatalf("SetGroupRules: %v", err)
+	}
+	if _, err := acl.SetGroupRules("projects/alpha", []domain.ACLRule{{Path: shared, Access: domain.AccessWrite}}); err != nil {
+		t.Fatalf("SetGroupRules: %v", err)
+	}
+	if _, err := acl.SetGroupRules("staff", []domain.ACLRule{{Path: private, Access: domain.AccessRead}}); err != nil {
+		t.Fatalf("SetGroupRules: %v", err)
+	}
+
+	authURL, state := startOIDCLogin(t, service)
+	code := idp.authorize(t, authURL, map[string]interface{}{
+		"sub":    "user-1",
+		"groups": []string{"/projects/alpha"},
+	})
+	if _, err := service.HandleCallback(ctx, state, code, "127.0.0.1"); err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	user, err := users.GetByExternalID(idp.server.URL + "|user-1")
+	if err != nil {
+		t.Fatalf("GetByExternalID: %v", err)
+	}
+
+	// El grupo del proveedor, sin la barra inicial, recibe las reglas del grupo local
+	if err := acl.Authorize(ctx, user, filepath.Join(shared, "plan.txt"), domain.AccessWrite); err != nil {
+		t.Errorf("write to the group's folder denied: %v", err)
+	}
+	if err := acl.Authorize(ctx, user, filepath.Join(private, "salaries.txt"), domain.AccessRead); !errors.Is(err, domain.ErrAccessDenied) {
+		t.Errorf("read outside the user's groups error = %v, want ErrAccessDenied", err)
+	}
+	if roots := acl.AllowedRoots(user); len(roots) != 1 || roots[0] != shared {
+		t.Errorf("AllowedRoots = %v, want [%s]", roots, shared)
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+)
+
+// SessionStore keeps active sessions and pending login challenges in memory
+type SessionStore struct {
+	mu         sync.RWMutex
+	sessions   map[string]*domain.Session
+	challenges map[string]*domain.LoginChallenge
+}
+
+func NewSessionStore() *SessionStore {
+	return &SessionStore{
+		sessions:   make(map[string]*domain.Session),
+		challenges: make(map[string]*domain.LoginChallenge),
+	}
+}
+
+func (s *SessionStore) SaveSession(session *domain.Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.Token] = session
+}
+
+func (s *SessionStore) GetSession(token string) (*domain.Session, bool) {
+	s.mu.RLock()
+	session, ok := s.sessions[token]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+	if session.IsExpired(time.Now()) {
+		s.DeleteSession(token)
+		return nil, false
+	}
+	return session, true
+}
+
+func (s *SessionStore) DeleteSession(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// DeleteUserSessions removes every session belonging to a user
+func (s *SessionStore) DeleteUserSessions(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+func (s *SessionStore) SaveChallenge(challenge *domain.LoginChallenge) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[challenge.Token] = challenge
+}
+
+func (s *SessionStore) GetChallenge(token string) (*domain.LoginChallenge, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[token]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		delete(s.challenges, token)
+		return nil, false
+	}
+	return challenge, true
+}
+
+// RecordChallengeAttempt increments the failed attempt counter and drops the challenge when exhausted
+func (s *SessionStore) RecordChallengeAttempt(token string, maxAttempts int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[token]
+	if !ok {
+		return
+	}
+	challenge.Attempts++
+	if challenge.Attempts >= maxAttempts {
+		delete(s.challenges, token)
+	}
+}
+
+func (s *SessionStore) DeleteChallenge(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.challenges, token)
+}
+
+// Cleanup drops expired sessions and challenges
+func (s *SessionStore) Cleanup() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, session := range s.sessions {
+		if session.IsExpired(now) {
+			delete(s.sessions, token)
+		}
+	}
+	for token, challenge := range s.challenges {
+		if now.After(challenge.ExpiresAt) {
+			delete(s.challenges, token)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const (
+	totpSecretBytes    = 20
+	totpDigits         = 6
+	totpPeriod         = 30
+	totpSkew           = 1
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+// TOTPService implements RFC 6238 time-based one-time passwords (HMAC-SHA1, 6 digits, 30s)
+type TOTPService struct {
+	issuer string
+	now    func() time.Time
+}
+
+func NewTOTPService(issuer string) *TOTPService {
+	return &TOTPService{
+		issuer: issuer,
+		now:    time.Now,
+	}
+}
+
+func (t *TOTPService) GenerateSecret() (string, error) {
+	return utils.GenerateBase32Secret(totpSecretBytes)
+}
+
+// NewEnrollment builds the otpauth:// URI and QR code for a secret
+func (t *TOTPService) NewEnrollment(account, secret string) (*domain.TOTPEnrollment, error) {
+	uri := t.ProvisioningURI(account, secret)
+
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
+	}
+
+	return &domain.TOTPEnrollment{
+		Secret:     secret,
+		URI:        uri,
+		QRCodePNG:  "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		Issuer:     t.issuer,
+		Account:    account,
+		Digits:     totpDigits,
+		PeriodSecs: totpPeriod,
+	}, nil
+}
+
+func (t *TOTPService) ProvisioningURI(account, secret string) string {
+	label := url.PathEscape(t.issuer + ":" + account)
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", t.issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", totpDigits))
+	params.Set("period", fmt.Sprintf("%d", totpPeriod))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Validate checks a code against the secret allowing one step of clock skew.
+// It returns the matched time counter so callers can reject replays.
+func (t *TOTPService) Validate(secret, code string, lastCounter int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := t.now().Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		counter := current + offset
+		if counter <= lastCounter {
+			continue
+		}
+		if utils.SecureCompare(hotp(key, counter), code) {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateCode returns the code for the given instant
+func (t *TOTPService) GenerateCode(secret string, at time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, at.Unix()/totpPeriod), nil
+}
+
+// GenerateRecoveryCodes returns plain codes for the user and their SHA-256 hashes for storage
+func (t *TOTPService) GenerateRecoveryCodes() ([]string, []string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	hashed := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRandomString(recoveryCodeLength)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		formatted := code[:5] + "-" + code[5:]
+		plain = append(plain, formatted)
+		hashed = append(hashed, hashRecoveryCode(formatted))
+	}
+
+	return plain, hashed, nil
+}
+
+// ConsumeRecoveryCode returns the remaining hashes if code matches one of them
+func (t *TOTPService) ConsumeRecoveryCode(hashes []string, code string) ([]string, bool) {
+	candidate := hashRecoveryCode(code)
+	for i, stored := range hashes {
+		if utils.SecureCompare(stored, candidate) {
+			remaining := make([]string, 0, len(hashes)-1)
+			remaining = append(remaining, hashes[:i]...)
+			remaining = append(remaining, hashes[i+1:]...)
+			return remaining, true
+		}
+	}
+	return hashes, false
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return utils.HashSHA256(normalized)
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	return key, nil
+}
+
+func hotp(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
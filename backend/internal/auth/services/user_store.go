@@ -0,0 +1,236 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+type usersFile struct {
+	Users  []*domain.User        `json:"users"`
+	Policy domain.SecurityPolicy `json:"policy"`
+	ACL    domain.GroupACL       `json:"acl"`
+}
+
+// UserStore keeps users, the security policy and group ACLs in a JSON file inside the data directory
+type UserStore struct {
+	mu     sync.RWMutex
+	path   string
+	users  map[string]*domain.User
+	policy domain.SecurityPolicy
+	acl    domain.GroupACL
+}
+
+func NewUserStore(dataDir string) (*UserStore, error) {
+	store := &UserStore{
+		path:  filepath.Join(dataDir, "users.json"),
+		users: make(map[string]*domain.User),
+	}
+
+	var file usersFile
+	if err := utils.LoadJSONFile(store.path, &file); err != nil {
+		return nil, err
+	}
+	for _, user := range file.Users {
+		store.users[user.ID] = user
+	}
+	store.policy = file.Policy
+	store.acl = file.ACL
+
+	// Sin ACL configurada todos los usuarios conservan acceso completo
+	if store.acl == nil {
+		store.acl = domain.GroupACL{
+			domain.EveryoneGroup: {{Path: "/", Access: domain.AccessWrite}},
+		}
+	}
+
+	return store, nil
+}
+
+func (s *UserStore) Get(id string) (*domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	clone := *user
+	return &clone, nil
+}
+
+func (s *UserStore) GetByUsername(username string) (*domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if strings.EqualFold(user.Username, username) {
+			clone := *user
+			return &clone, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (s *UserStore) GetByExternalID(externalID string) (*domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.ExternalID != "" && user.ExternalID == externalID {
+			clone := *user
+			return &clone, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (s *UserStore) List() []*domain.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*domain.User, 0, len(s.users))
+	for _, user := range s.users {
+		clone := *user
+		users = append(users, &clone)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users
+}
+
+func (s *UserStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users)
+}
+
+func (s *UserStore) Create(user *domain.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if strings.EqualFold(existing.Username, user.Username) {
+			return domain.ErrUserExists
+		}
+	}
+
+	if user.ID == "" {
+		user.ID = uuid.NewString()
+	}
+	now := time.Now()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	clone := *user
+	s.users[user.ID] = &clone
+	return s.persistLocked()
+}
+
+// Update applies fn to the stored user and persists the result
+func (s *UserStore) Update(id string, fn func(user *domain.User) error) (*domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+
+	updated := *existing
+	if err := fn(&updated); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = time.Now()
+	s.users[id] = &updated
+
+	if err := s.persistLocked(); err != nil {
+		s.users[id] = existing
+		return nil, err
+	}
+
+	result := updated
+	return &result, nil
+}
+
+func (s *UserStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return domain.ErrUserNotFound
+	}
+	delete(s.users, id)
+	return s.persistLocked()
+}
+
+func (s *UserStore) Policy() domain.SecurityPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy
+}
+
+func (s *UserStore) SetPolicy(policy domain.SecurityPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.policy
+	s.policy = policy
+	if err := s.persistLocked(); err != nil {
+		s.policy = previous
+		return err
+	}
+	return nil
+}
+
+func (s *UserStore) ACL() domain.GroupACL {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	acl := make(domain.GroupACL, len(s.acl))
+	for group, rules := range s.acl {
+		acl[group] = append([]domain.ACLRule(nil), rules...)
+	}
+	return acl
+}
+
+func (s *UserStore) SetACL(acl domain.GroupACL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.acl
+	s.acl = acl
+	if err := s.persistLocked(); err != nil {
+		s.acl = previous
+		return err
+	}
+	return nil
+}
+
+func (s *UserStore) persistLocked() error {
+	file := usersFile{
+		Users:  make([]*domain.User, 0, len(s.users)),
+		Policy: s.policy,
+		ACL:    s.acl,
+	}
+	for _, user := range s.users {
+		file.Users = append(file.Users, user)
+	}
+	sort.Slice(file.Users, func(i, j int) bool {
+		return file.Users[i].CreatedAt.Before(file.Users[j].CreatedAt)
+	})
+
+	if err := utils.SaveJSONFile(s.path, file); err != nil {
+		return fmt.Errorf("failed to persist users: %w", err)
+	}
+	return nil
+}
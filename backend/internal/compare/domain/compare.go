@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// JobTypeSync makes a target directory match a source directory
+const JobTypeSync = "compare.sync"
+
+// Reasons two entries differ
+const (
+	ReasonType    = "type"
+	ReasonSize    = "size"
+	ReasonModTime = "mod_time"
+	ReasonContent = "content"
+)
+
+// ConflictPolicy decides what a sync does when the target file is newer than the source
+type ConflictPolicy string
+
+const (
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictFail      ConflictPolicy = "fail"
+)
+
+func (p ConflictPolicy) IsValid() bool {
+	switch p {
+	case ConflictOverwrite, ConflictSkip, ConflictFail:
+		return true
+	}
+	return false
+}
+
+type CompareRequest struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	// Hash compares the content of files with the same size instead of their mtime
+	Hash bool `json:"hash"`
+}
+
+// EntryInfo describes one side of a compared entry
+type EntryInfo struct {
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	IsDirectory bool      `json:"is_directory"`
+}
+
+// CompareEntry is a path relative to both roots. A directory present on one side only is
+// reported once, without its content.
+type CompareEntry struct {
+	Path   string     `json:"path"`
+	Left   *EntryInfo `json:"left,omitempty"`
+	Right  *EntryInfo `json:"right,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+type CompareResult struct {
+	Left      string         `json:"left"`
+	Right     string         `json:"right"`
+	OnlyLeft  []CompareEntry `json:"only_left"`
+	OnlyRight []CompareEntry `json:"only_right"`
+	Different []CompareEntry `json:"different"`
+	Identical []string       `json:"identical"`
+	// Errors lists folders that could not be read on either side
+	Errors []string `json:"errors,omitempty"`
+}
+
+type SyncRequest struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	DryRun bool   `json:"dry_run"`
+	// Delete moves files that only exist in the target to the trash
+	Delete   bool           `json:"delete"`
+	Conflict ConflictPolicy `json:"conflict"`
+	Hash     bool           `json:"hash"`
+}
+
+// Sync actions
+const (
+	ActionCopy     = "copy"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionConflict = "conflict"
+)
+
+type SyncAction struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type SyncResult struct {
+	Source  string       `json:"source"`
+	Target  string       `json:"target"`
+	DryRun  bool         `json:"dry_run"`
+	Copied  int64        `json:"copied"`
+	Updated int64        `json:"updated"`
+	Deleted int64        `json:"deleted"`
+	Skipped int64        `json:"skipped"`
+	Bytes   int64        `json:"bytes"`
+	Actions []SyncAction `json:"actions"`
+	// ActionsTruncated is set when only the first actions are listed
+	ActionsTruncated bool `json:"actions_truncated,omitempty"`
+}
+
+var (
+	ErrNotADirectory   = errors.New("path is not a directory")
+	ErrNestedPaths     = errors.New("source and target must not contain each other")
+	ErrInvalidConflict = errors.New("invalid conflict policy")
+	ErrSyncConflict    = errors.New("target file is newer than the source")
+	ErrIncompleteScan  = errors.New("some folders could not be read")
+)
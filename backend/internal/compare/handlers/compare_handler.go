@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/compare/domain"
+	"github.com/infortech07/cubert/internal/compare/services"
+	jobdomain "github.com/infortech07/cubert/internal/jobs/domain"
+	jobhandlers "github.com/infortech07/cubert/internal/jobs/handlers"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	vaultdomain "github.com/infortech07/cubert/internal/vault/domain"
+)
+
+type CompareHandler struct {
+	compareService *services.CompareService
+	aclService     *authservices.ACLService
+}
+
+func NewCompareHandler(compareService *services.CompareService, aclService *authservices.ACLService) *CompareHandler {
+	return &CompareHandler{
+		compareService: compareService,
+		aclService:     aclService,
+	}
+}
+
+// Compare reports files only on the left, only on the right, different and identical
+func (h *CompareHandler) Compare(w http.ResponseWriter, r *http.Request) {
+	var request domain.CompareRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if request.Left == "" || request.Right == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Left and right are required", nil)
+		return
+	}
+
+	if !h.authorize(w, r, request.Left, authdomain.AccessRead) ||
+		!h.authorize(w, r, request.Right, authdomain.AccessRead) {
+		return
+	}
+	auditdomain.AddPaths(r.Context(), request.Left, request.Right)
+
+	result, err := h.compareService.Compare(r.Context(), request)
+	if err != nil {
+		writeCompareError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, result)
+}
+
+// Sync queues a job that makes the target folder match the source
+func (h *CompareHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	var request domain.SyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if request.Source == "" || request.Target == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Source and target are required", nil)
+		return
+	}
+
+	if !h.authorize(w, r, request.Source, authdomain.AccessRead) ||
+		!h.authorize(w, r, request.Target, authdomain.AccessWrite) {
+		return
+	}
+	auditdomain.AddPaths(r.Context(), request.Target)
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	job, err := h.compareService.StartSync(r.Context(), user.ID, request)
+	if err != nil {
+		writeCompareError(w, err)
+		return
+	}
+
+	jobhandlers.WriteJobAccepted(w, job)
+}
+
+func (h *CompareHandler) authorize(w http.ResponseWriter, r *http.Request, path string, access authdomain.Access) bool {
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, path, access); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return false
+	}
+	return true
+}
+
+func writeCompareError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobdomain.ErrQueueFull):
+		jobhandlers.WriteJobError(w, err)
+	case errors.Is(err, vaultdomain.ErrVaultLocked):
+		utils.WriteErrorResponse(w, http.StatusLocked, "Vault is locked", err)
+	case errors.Is(err, os.ErrNotExist):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Folder not found", err)
+	case errors.Is(err, domain.ErrNotADirectory), errors.Is(err, domain.ErrNestedPaths),
+		errors.Is(err, domain.ErrInvalidConflict):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to compare folders", err)
+	}
+}
@@ -0,0 +1,611 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/infortech07/cubert/internal/compare/domain"
+	fsdomain "github.com/infortech07/cubert/internal/filesystem/domain"
+	fsservices "github.com/infortech07/cubert/internal/filesystem/services"
+	jobdomain "github.com/infortech07/cubert/internal/jobs/domain"
+	jobservices "github.com/infortech07/cubert/internal/jobs/services"
+	quotaservices "github.com/infortech07/cubert/internal/quota/services"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	vaultservices "github.com/infortech07/cubert/internal/vault/services"
+)
+
+const (
+	// modTimeTolerance absorbs the 2 s resolution of FAT and some network filesystems
+	modTimeTolerance = 2 * time.Second
+	// maxListedActions caps the actions returned in a sync result
+	maxListedActions = 5000
+	copyBufferSize   = 256 * 1024
+)
+
+// CompareService compares directory trees and syncs one onto another as a job. Files are
+// read and copied on the local disk, so the encrypted vaults of storage are refused.
+type CompareService struct {
+	scanner *fsservices.ScannerService
+	trash   *fsservices.TrashService
+	jobs    *jobservices.JobService
+	quotas  *quotaservices.QuotaService
+	storage storagedomain.Storage
+}
+
+func NewCompareService(scanner *fsservices.ScannerService, trash *fsservices.TrashService, jobs *jobservices.JobService, quotas *quotaservices.QuotaService, storage storagedomain.Storage) *CompareService {
+	s := &CompareService{
+		scanner: scanner,
+		trash:   trash,
+		jobs:    jobs,
+		quotas:  quotas,
+		storage: storage,
+	}
+	// Repetir una sincronización es seguro: se recalcula lo que falta
+	jobs.Register(domain.JobTypeSync, s.runSync, jobservices.RunnerOptions{Resumable: true})
+	return s
+}
+
+// Compare reports the differences between two directories
+func (s *CompareService) Compare(ctx context.Context, req domain.CompareRequest) (*domain.CompareResult, error) {
+	req.Left = filepath.Clean(req.Left)
+	req.Right = filepath.Clean(req.Right)
+	for _, dir := range []string{req.Left, req.Right} {
+		if err := vaultservices.CheckDiskPath(ctx, s.storage, dir); err != nil {
+			return nil, err
+		}
+		if err := checkDirectory(dir); err != nil {
+			return nil, err
+		}
+	}
+	return s.compare(ctx, req, false)
+}
+
+// StartSync validates the request and queues a job that makes target match source
+func (s *CompareService) StartSync(ctx context.Context, userID string, req domain.SyncRequest) (*jobdomain.Job, error) {
+	req.Source = filepath.Clean(req.Source)
+	req.Target = filepath.Clean(req.Target)
+	if req.Conflict == "" {
+		req.Conflict = domain.ConflictOverwrite
+	}
+	if !req.Conflict.IsValid() {
+		return nil, fmt.Errorf("%w: %s", domain.ErrInvalidConflict, req.Conflict)
+	}
+	for _, dir := range []string{req.Source, req.Target} {
+		if err := vaultservices.CheckDiskPath(ctx, s.storage, dir); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkDirectory(req.Source); err != nil {
+		return nil, err
+	}
+	if isWithin(req.Source, req.Target) || isWithin(req.Target, req.Source) {
+		return nil, domain.ErrNestedPaths
+	}
+	// El destino puede no existir todavía, pero su carpeta padre sí
+	if err := checkDirectory(req.Target); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err := checkDirectory(filepath.Dir(req.Target)); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.jobs.Submit(ctx, domain.JobTypeSync, userID, req)
+}
+
+// compare scans both trees with the scanner and matches them by relative path. A missing
+// right side is treated as empty when allowMissingRight is set.
+func (s *CompareService) compare(ctx context.Context, req domain.CompareRequest, allowMissingRight bool) (*domain.CompareResult, error) {
+	result := &domain.CompareResult{
+		Left:      req.Left,
+		Right:     req.Right,
+		OnlyLeft:  []domain.CompareEntry{},
+		OnlyRight: []domain.CompareEntry{},
+		Different: []domain.CompareEntry{},
+		Identical: []string{},
+	}
+
+	left, err := s.scan(ctx, req.Left, result)
+	if err != nil {
+		return nil, err
+	}
+	right := map[string]fsdomain.LocalFile{}
+	if _, err := os.Stat(req.Right); err == nil || !allowMissingRight {
+		if right, err = s.scan(ctx, req.Right, result); err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make([]string, 0, len(left)+len(right))
+	for rel := range left {
+		paths = append(paths, rel)
+	}
+	for rel := range right {
+		if _, ok := left[rel]; !ok {
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+
+	// Carpetas ya informadas como un todo: su contenido no se lista por separado
+	reported := make(map[string]bool)
+	for _, rel := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if hasReportedParent(rel, reported) {
+			continue
+		}
+
+		l, inLeft := left[rel]
+		r, inRight := right[rel]
+		entry := domain.CompareEntry{Path: rel}
+		if inLeft {
+			entry.Left = entryInfo(l)
+		}
+		if inRight {
+			entry.Right = entryInfo(r)
+		}
+
+		switch {
+		case !inRight:
+			result.OnlyLeft = append(result.OnlyLeft, entry)
+			reported[rel] = l.IsDirectory
+		case !inLeft:
+			result.OnlyRight = append(result.OnlyRight, entry)
+			reported[rel] = r.IsDirectory
+		case l.IsDirectory != r.IsDirectory:
+			entry.Reason = domain.ReasonType
+			result.Different = append(result.Different, entry)
+			reported[rel] = true
+		case l.IsDirectory:
+			// Las carpetas presentes en ambos lados se comparan por su contenido
+		default:
+			reason, err := compareFiles(ctx, l, r, req.Hash)
+			if err != nil {
+				return nil, err
+			}
+			if reason == "" {
+				result.Identical = append(result.Identical, rel)
+				continue
+			}
+			entry.Reason = reason
+			result.Different = append(result.Different, entry)
+		}
+	}
+	return result, nil
+}
+
+// scan returns the regular files and folders below root keyed by slash-separated relative
+// path. Vaults are left out and reported as errors: their files can't be read or written
+// on the disk under the names the scanner returns.
+func (s *CompareService) scan(ctx context.Context, root string, result *domain.CompareResult) (map[string]fsdomain.LocalFile, error) {
+	scan, err := s.scanner.ScanTree(ctx, root, fsservices.ScanOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result.Errors = append(result.Errors, scan.Errors...)
+
+	entries := make(map[string]fsdomain.LocalFile, len(scan.Files)+len(scan.Directories))
+	refused := make(map[string]error)
+	for _, list := range [][]fsdomain.LocalFile{scan.Directories, scan.Files} {
+		for _, file := range list {
+			// Los enlaces y archivos especiales no se comparan ni se copian
+			if !file.IsDirectory && !strings.HasPrefix(file.Permissions, "-") {
+				continue
+			}
+			rel, err := filepath.Rel(root, file.Path)
+			if err != nil {
+				return nil, err
+			}
+			rel = filepath.ToSlash(rel)
+			if err := vaultservices.CheckDiskPath(ctx, s.storage, file.Path); err != nil {
+				refused[rel] = err
+				continue
+			}
+			entries[rel] = file
+		}
+	}
+	if len(refused) == 0 {
+		return entries, nil
+	}
+
+	// Solo se informa de la carpeta más alta de cada bóveda; su contenido se omite
+	reported := make(map[string]bool, len(refused))
+	rels := make([]string, 0, len(refused))
+	for rel := range refused {
+		reported[rel] = true
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		if !hasReportedParent(rel, reported) {
+			result.Errors = append(result.Errors, refused[rel].Error())
+		}
+	}
+	for rel := range entries {
+		if hasReportedParent(rel, reported) {
+			delete(entries, rel)
+		}
+	}
+	return entries, nil
+}
+
+func (s *CompareService) runSync(ctx context.Context, job *jobdomain.Job, progress *jobservices.Progress) (interface{}, error) {
+	var req domain.SyncRequest
+	if err := json.Unmarshal(job.Params, &req); err != nil {
+		return nil, fmt.Errorf("invalid sync parameters: %w", err)
+	}
+
+	progress.SetMessage("Comparing folders")
+	comparison, err := s.compare(ctx, domain.CompareRequest{Left: req.Source, Right: req.Target, Hash: req.Hash}, true)
+	if err != nil {
+		return nil, err
+	}
+	// Con una carpeta ilegible se podrían borrar o sobrescribir archivos por error
+	if len(comparison.Errors) > 0 {
+		return nil, fmt.Errorf("%w: %s", domain.ErrIncompleteScan, comparison.Errors[0])
+	}
+
+	plan, err := planSync(req, comparison)
+	if err != nil {
+		return nil, err
+	}
+	result := &domain.SyncResult{Source: req.Source, Target: req.Target, DryRun: req.DryRun}
+	var total int64
+	for _, action := range plan {
+		total += action.Size
+	}
+	progress.SetTotal(total, "bytes")
+
+	if !req.DryRun {
+		if err := s.checkQuota(req, job.UserID, plan); err != nil {
+			return nil, err
+		}
+		// Las copias conservan la fecha de origen: se asignan al usuario explícitamente
+		started := time.Now()
+		defer s.quotas.Track(job.UserID, req.Target, started)
+	}
+
+	for _, action := range plan {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !req.DryRun {
+			progress.SetMessage(action.Path)
+			if err := s.apply(ctx, req, job.UserID, action, progress); err != nil {
+				return nil, err
+			}
+		}
+
+		switch action.Action {
+		case domain.ActionCopy:
+			result.Copied++
+			result.Bytes += action.Size
+		case domain.ActionUpdate:
+			result.Updated++
+			result.Bytes += action.Size
+		case domain.ActionDelete:
+			result.Deleted++
+		case domain.ActionConflict:
+			result.Skipped++
+		}
+		if len(result.Actions) < maxListedActions {
+			result.Actions = append(result.Actions, action)
+		} else {
+			result.ActionsTruncated = true
+		}
+	}
+
+	progress.Flush()
+	return result, nil
+}
+
+// planSync turns a comparison into the actions of a sync. It fails before changing
+// anything when a conflict must stop the sync.
+func planSync(req domain.SyncRequest, comparison *domain.CompareResult) ([]domain.SyncAction, error) {
+	var plan []domain.SyncAction
+	for _, entry := range comparison.OnlyLeft {
+		size, err := treeSize(req.Source, entry)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, domain.SyncAction{Action: domain.ActionCopy, Path: entry.Path, Size: size})
+	}
+
+	for _, entry := range comparison.Different {
+		if entry.Reason == domain.ReasonType {
+			// El tipo cambió: se retira lo que hay en el destino y se copia de nuevo
+			size, err := treeSize(req.Source, entry)
+			if err != nil {
+				return nil, err
+			}
+			plan = append(plan,
+				domain.SyncAction{Action: domain.ActionDelete, Path: entry.Path, Reason: entry.Reason},
+				domain.SyncAction{Action: domain.ActionCopy, Path: entry.Path, Size: size, Reason: entry.Reason},
+			)
+			continue
+		}
+
+		if entry.Right.ModTime.After(entry.Left.ModTime.Add(modTimeTolerance)) {
+			switch req.Conflict {
+			case domain.ConflictFail:
+				return nil, fmt.Errorf("%w: %s", domain.ErrSyncConflict, entry.Path)
+			case domain.ConflictSkip:
+				plan = append(plan, domain.SyncAction{Action: domain.ActionConflict, Path: entry.Path, Reason: entry.Reason})
+				continue
+			}
+		}
+		plan = append(plan, domain.SyncAction{Action: domain.ActionUpdate, Path: entry.Path, Size: entry.Left.Size, Reason: entry.Reason})
+	}
+
+	if req.Delete {
+		for _, entry := range comparison.OnlyRight {
+			plan = append(plan, domain.SyncAction{Action: domain.ActionDelete, Path: entry.Path})
+		}
+	}
+	return plan, nil
+}
+
+func (s *CompareService) apply(ctx context.Context, req domain.SyncRequest, userID string, action domain.SyncAction, progress *jobservices.Progress) error {
+	source := filepath.Join(req.Source, filepath.FromSlash(action.Path))
+	target := filepath.Join(req.Target, filepath.FromSlash(action.Path))
+
+	switch action.Action {
+	case domain.ActionCopy, domain.ActionUpdate:
+		if err := copyTree(ctx, source, target, progress.Add); err != nil {
+			return err
+		}
+		s.quotas.Claim(userID, target)
+	case domain.ActionDelete:
+		// Lo que sobra en el destino va a la papelera y se puede recuperar
+		if _, err := s.trash.Move(ctx, target, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkQuota fails before copying anything when the plan would go over the quotas of the
+// user or of the target root. Updates only count what the files grow.
+func (s *CompareService) checkQuota(req domain.SyncRequest, userID string, plan []domain.SyncAction) error {
+	var bytes, files int64
+	for _, action := range plan {
+		switch action.Action {
+		case domain.ActionCopy:
+			bytes += action.Size
+			count, err := treeFiles(filepath.Join(req.Source, filepath.FromSlash(action.Path)))
+			if err != nil {
+				return err
+			}
+			files += count
+		case domain.ActionUpdate:
+			bytes += action.Size
+			if info, err := os.Stat(filepath.Join(req.Target, filepath.FromSlash(action.Path))); err == nil {
+				bytes -= info.Size()
+			}
+		}
+	}
+	return s.quotas.Check(userID, req.Target, bytes, files)
+}
+
+// copyTree copies a file or a folder, keeping permissions and modification times so the
+// next comparison sees both sides as identical
+func copyTree(ctx context.Context, source, target string, onWrite func(int64)) error {
+	return filepath.WalkDir(source, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, current)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join(target, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(destination, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to create %s: %w", destination, err)
+			}
+			return nil
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		return copyFile(ctx, current, destination, info, onWrite)
+	})
+}
+
+// copyFile writes to a temporary file next to the target and renames it into place
+func copyFile(ctx context.Context, source, target string, info fs.FileInfo, onWrite func(int64)) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(target), ".cubert-sync-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	defer os.Remove(temp.Name())
+
+	buffer := make([]byte, copyBufferSize)
+	for {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		n, readErr := in.Read(buffer)
+		if n > 0 {
+			if _, err = temp.Write(buffer[:n]); err != nil {
+				break
+			}
+			onWrite(int64(n))
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			err = readErr
+			break
+		}
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Chtimes(temp.Name(), info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), target)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", source, err)
+	}
+	return nil
+}
+
+// compareFiles returns why two files differ, or "" when they are considered identical
+func compareFiles(ctx context.Context, left, right fsdomain.LocalFile, hash bool) (string, error) {
+	if left.Size != right.Size {
+		return domain.ReasonSize, nil
+	}
+	if hash {
+		leftHash, err := hashFile(ctx, left.Path)
+		if err != nil {
+			return "", err
+		}
+		rightHash, err := hashFile(ctx, right.Path)
+		if err != nil {
+			return "", err
+		}
+		if leftHash != rightHash {
+			return domain.ReasonContent, nil
+		}
+		return "", nil
+	}
+
+	delta := left.ModTime.Sub(right.ModTime)
+	if delta > modTimeTolerance || delta < -modTimeTolerance {
+		return domain.ReasonModTime, nil
+	}
+	return "", nil
+}
+
+func hashFile(ctx context.Context, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	buffer := make([]byte, copyBufferSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := file.Read(buffer)
+		if n > 0 {
+			hasher.Write(buffer[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// treeSize returns the bytes a copy of the left side of entry will write
+func treeSize(root string, entry domain.CompareEntry) (int64, error) {
+	if !entry.Left.IsDirectory {
+		return entry.Left.Size, nil
+	}
+	var size int64
+	err := filepath.WalkDir(filepath.Join(root, filepath.FromSlash(entry.Path)), func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// treeFiles counts the regular files of a file or folder
+func treeFiles(path string) (int64, error) {
+	var files int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files++
+		}
+		return nil
+	})
+	return files, err
+}
+
+func entryInfo(file fsdomain.LocalFile) *domain.EntryInfo {
+	return &domain.EntryInfo{Size: file.Size, ModTime: file.ModTime, IsDirectory: file.IsDirectory}
+}
+
+func hasReportedParent(rel string, reported map[string]bool) bool {
+	for parent := path.Dir(rel); parent != "." && parent != "/"; parent = path.Dir(parent) {
+		if reported[parent] {
+			return true
+		}
+	}
+	return false
+}
+
+func checkDirectory(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s", domain.ErrNotADirectory, dir)
+	}
+	return nil
+}
+
+// isWithin reports whether child is root or below it
+func isWithin(child, root string) bool {
+	rel, err := filepath.Rel(root, child)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+type RecentKind string
+
+const (
+	RecentOpened   RecentKind = "opened"
+	RecentUploaded RecentKind = "uploaded"
+	RecentModified RecentKind = "modified"
+)
+
+func (k RecentKind) IsValid() bool {
+	return k == RecentOpened || k == RecentUploaded || k == RecentModified
+}
+
+// Los campos usan camelCase para coincidir con los tipos del dashboard del cliente
+
+type RecentItem struct {
+	Path        string     `json:"path"`
+	Name        string     `json:"name"`
+	Kind        RecentKind `json:"kind"`
+	IsDirectory bool       `json:"isDirectory"`
+	Size        int64      `json:"size"`
+	Extension   string     `json:"extension"`
+	Timestamp   time.Time  `json:"timestamp"`
+	FileID      string     `json:"fileId,omitempty"`
+}
+
+type Favorite struct {
+	ID           string    `json:"id"`
+	Path         string    `json:"path"`
+	Name         string    `json:"name"`
+	IsDirectory  bool      `json:"isDirectory"`
+	Size         int64     `json:"size"`
+	Extension    string    `json:"extension,omitempty"`
+	ItemCount    int       `json:"itemCount"`
+	Color        string    `json:"color,omitempty"`
+	LastModified time.Time `json:"lastModified"`
+	LastAccessed time.Time `json:"lastAccessed"`
+	CreatedAt    time.Time `json:"createdAt"`
+	FileID       string    `json:"fileId,omitempty"`
+}
+
+type QuickAccessItem struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Icon   string `json:"icon"`
+	Color  string `json:"color"`
+	FileID string `json:"fileId,omitempty"`
+}
+
+// UserDashboard is everything persisted per user
+type UserDashboard struct {
+	UserID      string            `json:"userId"`
+	Recent      []RecentItem      `json:"recent"`
+	Favorites   []Favorite        `json:"favorites"`
+	QuickAccess []QuickAccessItem `json:"quickAccess"`
+}
+
+// Overview groups the data rendered by the dashboard widgets
+type Overview struct {
+	Recent          []RecentItem      `json:"recent"`
+	FavoriteFiles   []Favorite        `json:"favoriteFiles"`
+	FavoriteFolders []Favorite        `json:"favoriteFolders"`
+	QuickAccess     []QuickAccessItem `json:"quickAccess"`
+}
+
+var (
+	ErrNotFound      = errors.New("dashboard entry not found")
+	ErrNotDirectory  = errors.New("quick access entries must be directories")
+	ErrAlreadyExists = errors.New("dashboard entry already exists")
+)
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/dashboard/domain"
+	"github.com/infortech07/cubert/internal/dashboard/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+type DashboardHandler struct {
+	dashboardService *services.DashboardService
+	aclService       *authservices.ACLService
+}
+
+func NewDashboardHandler(dashboardService *services.DashboardService, aclService *authservices.ACLService) *DashboardHandler {
+	return &DashboardHandler{
+		dashboardService: dashboardService,
+		aclService:       aclService,
+	}
+}
+
+// Overview returns recent files, favorites and quick access in a single call
+func (h *DashboardHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	overview, err := h.dashboardService.Overview(r.Context(), user.ID)
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, overview)
+}
+
+func (h *DashboardHandler) GetRecent(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	kind := domain.RecentKind(r.URL.Query().Get("kind"))
+	if kind != "" && !kind.IsValid() {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid kind (use opened, uploaded or modified)", nil)
+		return
+	}
+
+	limit := 0
+	if value, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && value > 0 {
+		limit = value
+	}
+
+	items, err := h.dashboardService.Recent(r.Context(), user.ID, kind, limit)
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+// RecordRecent lets the client report files opened outside the REST API (e.g. the media viewer)
+func (h *DashboardHandler) RecordRecent(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string            `json:"path"`
+		Kind domain.RecentKind `json:"kind"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if request.Kind == "" {
+		request.Kind = domain.RecentOpened
+	}
+	if !h.authorize(w, r, request.Path) {
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.dashboardService.RecordRecent(r.Context(), user.ID, request.Path, request.Kind); err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "Recent item recorded")
+}
+
+func (h *DashboardHandler) GetFavorites(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	favorites, err := h.dashboardService.Favorites(r.Context(), user.ID)
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"favorites": favorites,
+		"count":     len(favorites),
+	})
+}
+
+func (h *DashboardHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path  string `json:"path"`
+		Color string `json:"color"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if !h.authorize(w, r, request.Path) {
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	favorite, err := h.dashboardService.AddFavorite(r.Context(), user.ID, request.Path, request.Color)
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, favorite)
+}
+
+// RemoveFavorite accepts either /favorites/{id} or /favorites?path=
+func (h *DashboardHandler) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "id")
+	if key == "" {
+		key = r.URL.Query().Get("path")
+	}
+	if key == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Favorite id or path is required", nil)
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.dashboardService.RemoveFavorite(r.Context(), user.ID, key); err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "Favorite removed")
+}
+
+func (h *DashboardHandler) GetQuickAccess(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	items, err := h.dashboardService.QuickAccess(r.Context(), user.ID)
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+func (h *DashboardHandler) PinFolder(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path  string `json:"path"`
+		Name  string `json:"name"`
+		Icon  string `json:"icon"`
+		Color string `json:"color"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if !h.authorize(w, r, request.Path) {
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	item, err := h.dashboardService.PinFolder(r.Context(), user.ID, request.Path, request.Name, request.Icon, request.Color)
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, item)
+}
+
+func (h *DashboardHandler) UnpinFolder(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	if err := h.dashboardService.UnpinFolder(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "Quick access item removed")
+}
+
+func (h *DashboardHandler) ReorderQuickAccess(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		IDs []string `json:"ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	items, err := h.dashboardService.ReorderQuickAccess(r.Context(), user.ID, request.IDs)
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+// authorize requires read access to path before it is stored on the dashboard
+func (h *DashboardHandler) authorize(w http.ResponseWriter, r *http.Request, path string) bool {
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path is required", nil)
+		return false
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, path, authdomain.AccessRead); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return false
+	}
+	return true
+}
+
+func writeDashboardError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound), errors.Is(err, os.ErrNotExist):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Not found", err)
+	case errors.Is(err, domain.ErrNotDirectory):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid path", err)
+	case errors.Is(err, domain.ErrAlreadyExists):
+		utils.WriteErrorResponse(w, http.StatusConflict, "Already exists", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Dashboard operation failed", err)
+	}
+}
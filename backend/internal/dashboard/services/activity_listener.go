@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"os"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	"github.com/infortech07/cubert/internal/dashboard/domain"
+)
+
+// activityKinds maps the audited actions that touch a file to the recent list they feed.
+// Actions that aren't listed (listings, scans, compares, unlocks...) are ignored.
+var activityKinds = map[string]domain.RecentKind{
+	"filesystem.info":     domain.RecentOpened,
+	"filesystem.download": domain.RecentOpened,
+	"filesystem.content":  domain.RecentOpened,
+	"filesystem.preview":  domain.RecentOpened,
+
+	"filesystem.upload":            domain.RecentUploaded,
+	"s3.put_object":                domain.RecentUploaded,
+	"s3.complete_multipart_upload": domain.RecentUploaded,
+	"dav.put":                      domain.RecentUploaded,
+	"sftp.upload":                  domain.RecentUploaded,
+
+	"filesystem.save":     domain.RecentModified,
+	"filesystem.mkdir":    domain.RecentModified,
+	"archive.extract":     domain.RecentModified,
+	"archive.create":      domain.RecentModified,
+	"compare.sync":        domain.RecentModified,
+	"versions.restore":    domain.RecentModified,
+	"trash.restore":       domain.RecentModified,
+	"duplicates.hardlink": domain.RecentModified,
+	"s3.copy_object":      domain.RecentModified,
+	"dav.mkcol":           domain.RecentModified,
+	"dav.copy":            domain.RecentModified,
+	"dav.proppatch":       domain.RecentModified,
+	"sftp.mkdir":          domain.RecentModified,
+	"sftp.setstat":        domain.RecentModified,
+}
+
+// deleteActions remove their paths from the library
+var deleteActions = map[string]bool{
+	"filesystem.file":   true,
+	"trash":             true,
+	"duplicates.trash":  true,
+	"s3.delete_object":  true,
+	"s3.delete_objects": true,
+	"dav.delete":        true,
+	"sftp.delete":       true,
+}
+
+// moveActions carry the source and the destination as their two paths
+var moveActions = map[string]bool{
+	"filesystem.rename": true,
+	"dav.move":          true,
+	"sftp.rename":       true,
+}
+
+// HandleAuditEvent keeps dashboards in sync with successful file operations.
+// It is registered as an audit listener so handlers don't need to know about the dashboard.
+func (s *DashboardService) HandleAuditEvent(event auditdomain.Event) {
+	if event.Result != auditdomain.ResultSuccess || len(event.Paths) == 0 {
+		return
+	}
+
+	switch {
+	case deleteActions[event.Action]:
+		for _, path := range event.Paths {
+			// Una ruta que sigue en disco no se ha borrado
+			if _, err := os.Lstat(path); err == nil {
+				continue
+			}
+			s.PathDeleted(path)
+		}
+		return
+	case moveActions[event.Action] && len(event.Paths) == 2:
+		s.PathMoved(event.Paths[0], event.Paths[1])
+		s.record(event, event.Paths[1], domain.RecentModified)
+		return
+	}
+
+	kind, ok := activityKinds[event.Action]
+	if !ok {
+		return
+	}
+	for _, path := range event.Paths {
+		s.record(event, path, kind)
+	}
+}
+
+func (s *DashboardService) record(event auditdomain.Event, path string, kind domain.RecentKind) {
+	if event.UserID == "" {
+		return
+	}
+	// Los errores se ignoran: la ruta puede no ser un archivo local (p. ej. dentro de un archivo comprimido)
+	s.RecordRecent(context.Background(), event.UserID, path, kind)
+}
@@ -0,0 +1,504 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/infortech07/cubert/internal/dashboard/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const (
+	maxRecentItems      = 100
+	maxFavorites        = 500
+	maxQuickAccessItems = 24
+	defaultRecentLimit  = 20
+)
+
+// DashboardService persists per-user recent files, favorites and quick-access folders.
+// Entries are healed on read: renamed files are found again by their identity and
+// entries whose target disappeared are pruned.
+type DashboardService struct {
+	dir string
+
+	mu    sync.Mutex
+	cache map[string]*domain.UserDashboard
+}
+
+func NewDashboardService(dataDir string) (*DashboardService, error) {
+	dir := filepath.Join(dataDir, "dashboard")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create dashboard directory: %w", err)
+	}
+
+	return &DashboardService{
+		dir:   dir,
+		cache: make(map[string]*domain.UserDashboard),
+	}, nil
+}
+
+// RecordRecent pushes a file to the top of the user's recent list
+func (s *DashboardService) RecordRecent(ctx context.Context, userID, path string, kind domain.RecentKind) error {
+	if !kind.IsValid() {
+		return fmt.Errorf("invalid recent kind: %s", kind)
+	}
+
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	item := domain.RecentItem{
+		Path:        path,
+		Name:        filepath.Base(path),
+		Kind:        kind,
+		IsDirectory: info.IsDir(),
+		Size:        info.Size(),
+		Extension:   strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."),
+		Timestamp:   time.Now(),
+		FileID:      utils.FileIdentity(info),
+	}
+
+	return s.update(userID, func(d *domain.UserDashboard) error {
+		recent := make([]domain.RecentItem, 0, len(d.Recent)+1)
+		recent = append(recent, item)
+		for _, existing := range d.Recent {
+			if existing.Path != path {
+				recent = append(recent, existing)
+			}
+		}
+		if len(recent) > maxRecentItems {
+			recent = recent[:maxRecentItems]
+		}
+		d.Recent = recent
+
+		// Abrir un favorito actualiza su último acceso
+		for i := range d.Favorites {
+			if d.Favorites[i].Path == path {
+				d.Favorites[i].LastAccessed = item.Timestamp
+			}
+		}
+		return nil
+	})
+}
+
+func (s *DashboardService) Overview(ctx context.Context, userID string) (*domain.Overview, error) {
+	d, err := s.healed(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	overview := &domain.Overview{
+		Recent:          limitRecent(d.Recent, "", defaultRecentLimit),
+		FavoriteFiles:   []domain.Favorite{},
+		FavoriteFolders: []domain.Favorite{},
+		QuickAccess:     d.QuickAccess,
+	}
+	for _, favorite := range d.Favorites {
+		if favorite.IsDirectory {
+			overview.FavoriteFolders = append(overview.FavoriteFolders, favorite)
+		} else {
+			overview.FavoriteFiles = append(overview.FavoriteFiles, favorite)
+		}
+	}
+	return overview, nil
+}
+
+func (s *DashboardService) Recent(ctx context.Context, userID string, kind domain.RecentKind, limit int) ([]domain.RecentItem, error) {
+	d, err := s.healed(userID)
+	if err != nil {
+		return nil, err
+	}
+	return limitRecent(d.Recent, kind, limit), nil
+}
+
+func (s *DashboardService) Favorites(ctx context.Context, userID string) ([]domain.Favorite, error) {
+	d, err := s.healed(userID)
+	if err != nil {
+		return nil, err
+	}
+	return d.Favorites, nil
+}
+
+// AddFavorite stars a file or folder; starring an existing favorite updates its color
+func (s *DashboardService) AddFavorite(ctx context.Context, userID, path, color string) (*domain.Favorite, error) {
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	now := time.Now()
+	favorite := domain.Favorite{
+		ID:           uuid.NewString(),
+		Path:         path,
+		Color:        color,
+		CreatedAt:    now,
+		LastAccessed: now,
+	}
+	refreshFavorite(&favorite, info)
+
+	err = s.update(userID, func(d *domain.UserDashboard) error {
+		for i := range d.Favorites {
+			if d.Favorites[i].Path == path {
+				if color != "" {
+					d.Favorites[i].Color = color
+				}
+				favorite = d.Favorites[i]
+				return nil
+			}
+		}
+		if len(d.Favorites) >= maxFavorites {
+			return fmt.Errorf("favorite limit reached (%d)", maxFavorites)
+		}
+		d.Favorites = append(d.Favorites, favorite)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &favorite, nil
+}
+
+// RemoveFavorite unstars by favorite ID or by path
+func (s *DashboardService) RemoveFavorite(ctx context.Context, userID, idOrPath string) error {
+	return s.update(userID, func(d *domain.UserDashboard) error {
+		for i, favorite := range d.Favorites {
+			if favorite.ID == idOrPath || favorite.Path == filepath.Clean(idOrPath) {
+				d.Favorites = append(d.Favorites[:i:i], d.Favorites[i+1:]...)
+				return nil
+			}
+		}
+		return domain.ErrNotFound
+	})
+}
+
+func (s *DashboardService) QuickAccess(ctx context.Context, userID string) ([]domain.QuickAccessItem, error) {
+	d, err := s.healed(userID)
+	if err != nil {
+		return nil, err
+	}
+	return d.QuickAccess, nil
+}
+
+// PinFolder adds a folder to the quick-access bar
+func (s *DashboardService) PinFolder(ctx context.Context, userID, path, name, icon, color string) (*domain.QuickAccessItem, error) {
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return nil, domain.ErrNotDirectory
+	}
+
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	if icon == "" {
+		icon = "folder"
+	}
+
+	item := domain.QuickAccessItem{
+		ID:     uuid.NewString(),
+		Name:   name,
+		Path:   path,
+		Type:   "folder",
+		Icon:   icon,
+		Color:  color,
+		FileID: utils.FileIdentity(info),
+	}
+
+	err = s.update(userID, func(d *domain.UserDashboard) error {
+		for _, existing := range d.QuickAccess {
+			if existing.Path == path {
+				return domain.ErrAlreadyExists
+			}
+		}
+		if len(d.QuickAccess) >= maxQuickAccessItems {
+			return fmt.Errorf("quick access limit reached (%d)", maxQuickAccessItems)
+		}
+		d.QuickAccess = append(d.QuickAccess, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *DashboardService) UnpinFolder(ctx context.Context, userID, id string) error {
+	return s.update(userID, func(d *domain.UserDashboard) error {
+		for i, item := range d.QuickAccess {
+			if item.ID == id {
+				d.QuickAccess = append(d.QuickAccess[:i:i], d.QuickAccess[i+1:]...)
+				return nil
+			}
+		}
+		return domain.ErrNotFound
+	})
+}
+
+// ReorderQuickAccess sorts the pinned folders following ids; unknown ids are ignored
+func (s *DashboardService) ReorderQuickAccess(ctx context.Context, userID string, ids []string) ([]domain.QuickAccessItem, error) {
+	var result []domain.QuickAccessItem
+	err := s.update(userID, func(d *domain.UserDashboard) error {
+		position := make(map[string]int, len(ids))
+		for i, id := range ids {
+			position[id] = i
+		}
+		sort.SliceStable(d.QuickAccess, func(i, j int) bool {
+			pi, okI := position[d.QuickAccess[i].ID]
+			pj, okJ := position[d.QuickAccess[j].ID]
+			if okI != okJ {
+				return okI
+			}
+			return pi < pj
+		})
+		result = append([]domain.QuickAccessItem{}, d.QuickAccess...)
+		return nil
+	})
+	return result, err
+}
+
+// PathMoved rewrites every entry under oldPath to newPath for all users
+func (s *DashboardService) PathMoved(oldPath, newPath string) {
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+	rewrite := func(path string) (string, bool) {
+		if path == oldPath {
+			return newPath, true
+		}
+		if strings.HasPrefix(path, oldPath+"/") {
+			return newPath + strings.TrimPrefix(path, oldPath), true
+		}
+		return path, false
+	}
+
+	s.forEachUser(func(d *domain.UserDashboard) bool {
+		changed := false
+		for i := range d.Recent {
+			if path, ok := rewrite(d.Recent[i].Path); ok {
+				d.Recent[i].Path, d.Recent[i].Name = path, filepath.Base(path)
+				changed = true
+			}
+		}
+		for i := range d.Favorites {
+			if path, ok := rewrite(d.Favorites[i].Path); ok {
+				d.Favorites[i].Path, d.Favorites[i].Name = path, filepath.Base(path)
+				changed = true
+			}
+		}
+		for i := range d.QuickAccess {
+			if path, ok := rewrite(d.QuickAccess[i].Path); ok {
+				d.QuickAccess[i].Path = path
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// PathDeleted prunes every entry at or under path for all users
+func (s *DashboardService) PathDeleted(path string) {
+	path = filepath.Clean(path)
+	under := func(p string) bool {
+		return p == path || strings.HasPrefix(p, path+"/")
+	}
+
+	s.forEachUser(func(d *domain.UserDashboard) bool {
+		before := len(d.Recent) + len(d.Favorites) + len(d.QuickAccess)
+
+		recent := d.Recent[:0]
+		for _, item := range d.Recent {
+			if !under(item.Path) {
+				recent = append(recent, item)
+			}
+		}
+		d.Recent = recent
+
+		favorites := d.Favorites[:0]
+		for _, favorite := range d.Favorites {
+			if !under(favorite.Path) {
+				favorites = append(favorites, favorite)
+			}
+		}
+		d.Favorites = favorites
+
+		quick := d.QuickAccess[:0]
+		for _, item := range d.QuickAccess {
+			if !under(item.Path) {
+				quick = append(quick, item)
+			}
+		}
+		d.QuickAccess = quick
+
+		return before != len(d.Recent)+len(d.Favorites)+len(d.QuickAccess)
+	})
+}
+
+// healed loads the user's dashboard, repairing or pruning entries that no longer resolve
+func (s *DashboardService) healed(userID string) (*domain.UserDashboard, error) {
+	var snapshot domain.UserDashboard
+
+	err := s.update(userID, func(d *domain.UserDashboard) error {
+		recent := d.Recent[:0]
+		for _, item := range d.Recent {
+			if path, info, ok := utils.LocateFile(item.Path, item.FileID); ok {
+				item.Path, item.Name = path, filepath.Base(path)
+				item.Extension = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+				item.Size = info.Size()
+				item.FileID = utils.FileIdentity(info)
+				recent = append(recent, item)
+			}
+		}
+		d.Recent = recent
+
+		favorites := d.Favorites[:0]
+		for _, favorite := range d.Favorites {
+			if path, info, ok := utils.LocateFile(favorite.Path, favorite.FileID); ok {
+				favorite.Path = path
+				refreshFavorite(&favorite, info)
+				favorites = append(favorites, favorite)
+			}
+		}
+		d.Favorites = favorites
+
+		quick := d.QuickAccess[:0]
+		for _, item := range d.QuickAccess {
+			if path, info, ok := utils.LocateFile(item.Path, item.FileID); ok && info.IsDir() {
+				item.Path = path
+				item.FileID = utils.FileIdentity(info)
+				quick = append(quick, item)
+			}
+		}
+		d.QuickAccess = quick
+
+		snapshot = cloneDashboard(d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// update loads, mutates and persists a user's dashboard under the service lock
+func (s *DashboardService) update(userID string, fn func(d *domain.UserDashboard) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, err := s.loadLocked(userID)
+	if err != nil {
+		return err
+	}
+
+	before := cloneDashboard(d)
+	if err := fn(d); err != nil {
+		s.cache[userID] = &before
+		return err
+	}
+
+	if err := utils.SaveJSONFile(s.userPath(userID), d); err != nil {
+		s.cache[userID] = &before
+		return err
+	}
+	return nil
+}
+
+// forEachUser applies fn to every stored dashboard, persisting those that changed
+func (s *DashboardService) forEachUser(fn func(d *domain.UserDashboard) bool) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		userID := strings.TrimSuffix(name, ".json")
+		d, err := s.loadLocked(userID)
+		if err != nil {
+			continue
+		}
+		if fn(d) {
+			utils.SaveJSONFile(s.userPath(userID), d)
+		}
+	}
+}
+
+func (s *DashboardService) loadLocked(userID string) (*domain.UserDashboard, error) {
+	if d, ok := s.cache[userID]; ok {
+		return d, nil
+	}
+
+	d := &domain.UserDashboard{UserID: userID}
+	if err := utils.LoadJSONFile(s.userPath(userID), d); err != nil {
+		return nil, err
+	}
+	s.cache[userID] = d
+	return d, nil
+}
+
+func (s *DashboardService) userPath(userID string) string {
+	return filepath.Join(s.dir, utils.SanitizeFilename(userID)+".json")
+}
+
+func refreshFavorite(favorite *domain.Favorite, info os.FileInfo) {
+	favorite.Name = filepath.Base(favorite.Path)
+	favorite.IsDirectory = info.IsDir()
+	favorite.Size = info.Size()
+	favorite.LastModified = info.ModTime()
+	favorite.FileID = utils.FileIdentity(info)
+
+	if info.IsDir() {
+		favorite.Extension = ""
+		if entries, err := os.ReadDir(favorite.Path); err == nil {
+			favorite.ItemCount = len(entries)
+		}
+	} else {
+		favorite.Extension = strings.TrimPrefix(strings.ToLower(filepath.Ext(favorite.Path)), ".")
+		favorite.ItemCount = 0
+	}
+}
+
+func limitRecent(items []domain.RecentItem, kind domain.RecentKind, limit int) []domain.RecentItem {
+	if limit <= 0 {
+		limit = defaultRecentLimit
+	}
+
+	result := make([]domain.RecentItem, 0, limit)
+	for _, item := range items {
+		if kind != "" && item.Kind != kind {
+			continue
+		}
+		result = append(result, item)
+		if len(result) == limit {
+			break
+		}
+	}
+	return result
+}
+
+func cloneDashboard(d *domain.UserDashboard) domain.UserDashboard {
+	return domain.UserDashboard{
+		UserID:      d.UserID,
+		Recent:      append([]domain.RecentItem{}, d.Recent...),
+		Favorites:   append([]domain.Favorite{}, d.Favorites...),
+		QuickAccess: append([]domain.QuickAccessItem{}, d.QuickAccess...),
+	}
+}
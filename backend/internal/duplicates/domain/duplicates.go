@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// JobType identifies duplicate scans in the job subsystem
+const JobType = "duplicates.scan"
+
+// ScanPhase follows the size -> partial hash -> full hash pipeline
+type ScanPhase string
+
+const (
+	PhaseWalking     ScanPhase = "walking"
+	PhasePartialHash ScanPhase = "partial_hash"
+	PhaseFullHash    ScanPhase = "full_hash"
+)
+
+type ScanOptions struct {
+	Path          string `json:"path"`
+	MinSize       int64  `json:"min_size"`
+	IncludeHidden bool   `json:"include_hidden"`
+}
+
+type DuplicateFile struct {
+	Path    string    `json:"path"`
+	ModTime time.Time `json:"mod_time"`
+	// Links lists other paths that are already hard links to this file
+	Links []string `json:"links,omitempty"`
+}
+
+// DuplicateSet groups files with identical SHA-256 content
+type DuplicateSet struct {
+	Hash        string          `json:"hash"`
+	Size        int64           `json:"size"`
+	Files       []DuplicateFile `json:"files"`
+	WastedBytes int64           `json:"wasted_bytes"`
+}
+
+type ScanResult struct {
+	Sets           []DuplicateSet `json:"sets"`
+	SetCount       int            `json:"set_count"`
+	DuplicateFiles int            `json:"duplicate_files"`
+	WastedBytes    int64          `json:"wasted_bytes"`
+}
+
+type ResolveAction string
+
+const (
+	ActionTrash    ResolveAction = "trash"
+	ActionHardlink ResolveAction = "hardlink"
+)
+
+// ResolveRequest keeps one file of a set and trashes or hardlinks the others
+type ResolveRequest struct {
+	Hash   string        `json:"hash"`
+	Keep   string        `json:"keep"`
+	Action ResolveAction `json:"action"`
+	// Paths restricts the action to these members of the set; empty means all but Keep
+	Paths []string `json:"paths,omitempty"`
+}
+
+type ResolvedFile struct {
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"`
+	// TrashID is set when the file was moved to the trash
+	TrashID string `json:"trash_id,omitempty"`
+}
+
+type ResolveResult struct {
+	Kept       string         `json:"kept"`
+	Action     ResolveAction  `json:"action"`
+	Files      []ResolvedFile `json:"files"`
+	FreedBytes int64          `json:"freed_bytes"`
+}
+
+var (
+	ErrNotDuplicateScan = errors.New("job is not a duplicate scan")
+	ErrSetNotFound      = errors.New("duplicate set not found")
+	ErrInvalidAction    = errors.New("action must be trash or hardlink")
+	ErrKeepNotInSet     = errors.New("file to keep is not part of the set")
+	ErrFileChanged      = errors.New("file changed since the scan")
+	ErrCrossDevice      = errors.New("hard links require both files on the same filesystem")
+)
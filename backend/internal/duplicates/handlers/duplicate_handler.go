@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/duplicates/domain"
+	"github.com/infortech07/cubert/internal/duplicates/services"
+	jobdomain "github.com/infortech07/cubert/internal/jobs/domain"
+	jobhandlers "github.com/infortech07/cubert/internal/jobs/handlers"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+type DuplicateHandler struct {
+	duplicateService *services.DuplicateService
+	aclService       *authservices.ACLService
+}
+
+func NewDuplicateHandler(duplicateService *services.DuplicateService, aclService *authservices.ACLService) *DuplicateHandler {
+	return &DuplicateHandler{
+		duplicateService: duplicateService,
+		aclService:       aclService,
+	}
+}
+
+// StartScan queues a duplicate scan job and answers 202; progress and the result
+// are served by the jobs API
+func (h *DuplicateHandler) StartScan(w http.ResponseWriter, r *http.Request) {
+	var options domain.ScanOptions
+	if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if options.Path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path is required", nil)
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, options.Path, authdomain.AccessRead); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return
+	}
+	auditdomain.AddPaths(r.Context(), options.Path)
+
+	job, err := h.duplicateService.StartScan(r.Context(), user.ID, options)
+	if err != nil {
+		if errors.Is(err, jobdomain.ErrQueueFull) {
+			jobhandlers.WriteJobError(w, err)
+			return
+		}
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Failed to start duplicate scan", err)
+		return
+	}
+
+	jobhandlers.WriteJobAccepted(w, job)
+}
+
+// Resolve keeps one file of a set and moves the others to the trash or replaces them with hard links
+func (h *DuplicateHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	scan, ok := h.scan(w, r)
+	if !ok {
+		return
+	}
+
+	var request domain.ResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if request.Hash == "" || request.Keep == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Hash and keep are required", nil)
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, request.Keep, authdomain.AccessRead); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return
+	}
+	authorize := func(path string) error {
+		return h.aclService.Authorize(r.Context(), user, path, authdomain.AccessWrite)
+	}
+
+	result, err := h.duplicateService.Resolve(r.Context(), scan.ID, user.ID, request, authorize)
+	if err != nil {
+		writeDuplicateError(w, err)
+		return
+	}
+
+	// Solo se auditan los archivos modificados; la papelera cuenta como borrado
+	for _, file := range result.Files {
+		if file.Error == "" {
+			auditdomain.AddPaths(r.Context(), file.Path)
+		}
+	}
+	auditdomain.SetAction(r.Context(), "duplicates."+string(result.Action))
+
+	utils.WriteJSONResponse(w, http.StatusOK, result)
+}
+
+// scan loads the scan job in the URL, hiding jobs of other users from non-admins
+func (h *DuplicateHandler) scan(w http.ResponseWriter, r *http.Request) (*jobdomain.Job, bool) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	job, err := h.duplicateService.Scan(chi.URLParam(r, "id"))
+	if err == nil && !user.IsAdmin() && job.UserID != user.ID {
+		err = jobdomain.ErrJobNotFound
+	}
+	if err != nil {
+		writeDuplicateError(w, err)
+		return nil, false
+	}
+	return job, true
+}
+
+func writeDuplicateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobdomain.ErrJobNotFound), errors.Is(err, domain.ErrNotDuplicateScan), errors.Is(err, domain.ErrSetNotFound):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Not found", err)
+	case errors.Is(err, jobdomain.ErrJobNotCompleted):
+		utils.WriteErrorResponse(w, http.StatusConflict, "Scan has not completed", err)
+	case errors.Is(err, domain.ErrFileChanged):
+		utils.WriteErrorResponse(w, http.StatusConflict, "File changed since the scan", err)
+	case errors.Is(err, domain.ErrInvalidAction), errors.Is(err, domain.ErrKeepNotInSet):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Duplicate operation failed", err)
+	}
+}
@@ -0,0 +1,473 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/infortech07/cubert/internal/duplicates/domain"
+	fsservices "github.com/infortech07/cubert/internal/filesystem/services"
+	jobdomain "github.com/infortech07/cubert/internal/jobs/domain"
+	jobservices "github.com/infortech07/cubert/internal/jobs/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const (
+	partialHashChunk = 4096
+	hashBufferSize   = 256 * 1024
+)
+
+// DuplicateService finds files with identical content. Candidates are narrowed by size,
+// then by a SHA-256 of the first and last blocks, and only then hashed in full.
+// Scans run as background jobs; their result is the list of duplicate sets.
+type DuplicateService struct {
+	jobs  *jobservices.JobService
+	trash *fsservices.TrashService
+
+	// Serializa las resoluciones para que no se pisen al reescribir el resultado del trabajo
+	resolveMu sync.Mutex
+}
+
+type fileRecord struct {
+	path    string
+	size    int64
+	modTime time.Time
+	links   []string
+}
+
+func NewDuplicateService(jobs *jobservices.JobService, trash *fsservices.TrashService) *DuplicateService {
+	s := &DuplicateService{
+		jobs:  jobs,
+		trash: trash,
+	}
+	jobs.Register(domain.JobType, s.runScan, jobservices.RunnerOptions{Resumable: true})
+	return s
+}
+
+// StartScan validates the options and queues a scan job
+func (s *DuplicateService) StartScan(ctx context.Context, userID string, opts domain.ScanOptions) (*jobdomain.Job, error) {
+	opts.Path = filepath.Clean(opts.Path)
+	info, err := os.Stat(opts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access path %s: %w", opts.Path, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("path %s is not a directory", opts.Path)
+	}
+	if opts.MinSize < 1 {
+		opts.MinSize = 1
+	}
+
+	return s.jobs.Submit(ctx, domain.JobType, userID, opts)
+}
+
+// Scan returns the job of a duplicate scan
+func (s *DuplicateService) Scan(id string) (*jobdomain.Job, error) {
+	job, err := s.jobs.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type != domain.JobType {
+		return nil, domain.ErrNotDuplicateScan
+	}
+	return job, nil
+}
+
+func (s *DuplicateService) runScan(ctx context.Context, job *jobdomain.Job, progress *jobservices.Progress) (interface{}, error) {
+	var opts domain.ScanOptions
+	if err := json.Unmarshal(job.Params, &opts); err != nil {
+		return nil, fmt.Errorf("invalid scan parameters: %w", err)
+	}
+	return find(ctx, opts, progress)
+}
+
+// Resolve keeps one member of a duplicate set and trashes or hardlinks the others.
+// authorize is called for every file that would be modified; failures are reported per file.
+func (s *DuplicateService) Resolve(ctx context.Context, scanID, userID string, req domain.ResolveRequest, authorize func(path string) error) (*domain.ResolveResult, error) {
+	if req.Action != domain.ActionTrash && req.Action != domain.ActionHardlink {
+		return nil, domain.ErrInvalidAction
+	}
+
+	s.resolveMu.Lock()
+	defer s.resolveMu.Unlock()
+
+	if _, err := s.Scan(scanID); err != nil {
+		return nil, err
+	}
+	var scan domain.ScanResult
+	if err := s.jobs.Result(scanID, &scan); err != nil {
+		return nil, err
+	}
+
+	index := -1
+	for i, set := range scan.Sets {
+		if set.Hash == req.Hash {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, domain.ErrSetNotFound
+	}
+	set := scan.Sets[index]
+
+	keep, targets, err := planResolve(set, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnchanged(keep.Path, set.Size, keep.ModTime); err != nil {
+		return nil, err
+	}
+
+	result := &domain.ResolveResult{Kept: keep.Path, Action: req.Action}
+	done := make(map[string]bool)
+	for _, target := range targets {
+		file := domain.ResolvedFile{Path: target.Path}
+		if err := authorize(target.Path); err != nil {
+			file.Error = err.Error()
+		} else if err := checkUnchanged(target.Path, set.Size, target.ModTime); err != nil {
+			file.Error = err.Error()
+		} else if req.Action == domain.ActionTrash {
+			entry, err := s.trash.Move(ctx, target.Path, userID)
+			if err != nil {
+				file.Error = err.Error()
+			} else {
+				file.TrashID = entry.ID
+			}
+		} else if err := replaceWithHardlink(keep.Path, target.Path); err != nil {
+			file.Error = err.Error()
+		}
+
+		if file.Error == "" {
+			done[target.Path] = true
+			result.FreedBytes += set.Size
+		}
+		result.Files = append(result.Files, file)
+	}
+
+	if len(done) > 0 {
+		applyResolution(&scan, index, keep.Path, done, req.Action)
+		if err := s.jobs.UpdateResult(scanID, scan); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func planResolve(set domain.DuplicateSet, req domain.ResolveRequest) (domain.DuplicateFile, []domain.DuplicateFile, error) {
+	keepPath := filepath.Clean(req.Keep)
+	selected := make(map[string]bool, len(req.Paths))
+	for _, path := range req.Paths {
+		selected[filepath.Clean(path)] = true
+	}
+
+	var keep *domain.DuplicateFile
+	var targets []domain.DuplicateFile
+	for i, file := range set.Files {
+		switch {
+		case file.Path == keepPath:
+			keep = &set.Files[i]
+		case len(selected) == 0 || selected[file.Path]:
+			targets = append(targets, file)
+		}
+	}
+	if keep == nil {
+		return domain.DuplicateFile{}, nil, domain.ErrKeepNotInSet
+	}
+	return *keep, targets, nil
+}
+
+// applyResolution removes resolved files from their set; hard-linked ones become links of keep
+func applyResolution(scan *domain.ScanResult, index int, keep string, done map[string]bool, action domain.ResolveAction) {
+	set := &scan.Sets[index]
+
+	var files []domain.DuplicateFile
+	var linked []string
+	for _, file := range set.Files {
+		if done[file.Path] {
+			linked = append(linked, file.Path)
+			continue
+		}
+		files = append(files, file)
+	}
+	if action == domain.ActionHardlink {
+		for i := range files {
+			if files[i].Path == keep {
+				files[i].Links = append(files[i].Links, linked...)
+			}
+		}
+	}
+	set.Files = files
+	set.WastedBytes = int64(len(files)-1) * set.Size
+
+	if len(files) < 2 {
+		scan.Sets = append(scan.Sets[:index], scan.Sets[index+1:]...)
+	}
+	summarize(scan)
+}
+
+// find runs the three grouping phases. Overall progress is weighted: walking 0-10%,
+// partial hashing 10-20% and full hashing 20-100%.
+func find(ctx context.Context, opts domain.ScanOptions, progress *jobservices.Progress) (*domain.ScanResult, error) {
+	progress.SetMessage(string(domain.PhaseWalking))
+	progress.SetTotal(0, "files")
+
+	// 1. Agrupar por tamaño (los enlaces duros al mismo inodo cuentan como un solo archivo)
+	bySize := make(map[int64][]*fileRecord)
+	byIdentity := make(map[string]*fileRecord)
+	err := filepath.WalkDir(opts.Path, func(path string, entry fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !opts.IncludeHidden && path != opts.Path && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.Size() < opts.MinSize {
+			return nil
+		}
+
+		progress.Add(1)
+		id := utils.FileIdentity(info)
+		if existing, ok := byIdentity[id]; ok && id != "" {
+			existing.links = append(existing.links, path)
+			return nil
+		}
+
+		record := &fileRecord{
+			path:    path,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		}
+		if id != "" {
+			byIdentity[id] = record
+		}
+		bySize[record.size] = append(bySize[record.size], record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*fileRecord
+	for _, group := range bySize {
+		if len(group) > 1 {
+			candidates = append(candidates, group...)
+		}
+	}
+	progress.SetMessage(string(domain.PhasePartialHash))
+	progress.SetCurrent(0)
+	progress.SetTotal(int64(len(candidates)), "files")
+	progress.SetPercent(10)
+
+	// 2. Hash parcial del primer y último bloque
+	byPartial := make(map[string][]*fileRecord)
+	for i, record := range candidates {
+		hash, err := partialHash(record.path, record.size)
+		progress.SetCurrent(int64(i + 1))
+		progress.SetPercent(10 + 10*float64(i+1)/float64(len(candidates)))
+		if err != nil {
+			continue
+		}
+		key := fmt.Sprintf("%d:%s", record.size, hash)
+		byPartial[key] = append(byPartial[key], record)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	var toHash []*fileRecord
+	var bytesToHash int64
+	byFull := make(map[string][]*fileRecord)
+	for key, group := range byPartial {
+		if len(group) < 2 {
+			continue
+		}
+		// Para archivos pequeños el hash parcial ya cubre todo el contenido
+		if group[0].size <= 2*partialHashChunk {
+			byFull[key] = group
+			continue
+		}
+		toHash = append(toHash, group...)
+		bytesToHash += group[0].size * int64(len(group))
+	}
+	progress.SetMessage(string(domain.PhaseFullHash))
+	progress.SetCurrent(0)
+	progress.SetTotal(bytesToHash, "bytes")
+	progress.SetPercent(20)
+
+	// 3. SHA-256 completo
+	var hashed int64
+	for _, record := range toHash {
+		hash, err := fullHash(ctx, record.path, func(n int64) {
+			hashed += n
+			progress.SetCurrent(hashed)
+			progress.SetPercent(20 + 80*float64(hashed)/float64(bytesToHash))
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		key := fmt.Sprintf("%d:%s", record.size, hash)
+		byFull[key] = append(byFull[key], record)
+	}
+	progress.Flush()
+
+	result := &domain.ScanResult{}
+	for key, group := range byFull {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].path < group[j].path })
+
+		set := domain.DuplicateSet{
+			Hash:        key[strings.Index(key, ":")+1:],
+			Size:        group[0].size,
+			WastedBytes: group[0].size * int64(len(group)-1),
+		}
+		for _, record := range group {
+			set.Files = append(set.Files, domain.DuplicateFile{
+				Path:    record.path,
+				ModTime: record.modTime,
+				Links:   record.links,
+			})
+		}
+		result.Sets = append(result.Sets, set)
+	}
+	summarize(result)
+	return result, nil
+}
+
+func summarize(result *domain.ScanResult) {
+	sort.Slice(result.Sets, func(i, j int) bool {
+		return result.Sets[i].WastedBytes > result.Sets[j].WastedBytes
+	})
+	result.SetCount = len(result.Sets)
+	result.DuplicateFiles = 0
+	result.WastedBytes = 0
+	for _, set := range result.Sets {
+		result.DuplicateFiles += len(set.Files) - 1
+		result.WastedBytes += set.WastedBytes
+	}
+	if result.Sets == nil {
+		result.Sets = []domain.DuplicateSet{}
+	}
+}
+
+func partialHash(path string, size int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if size <= 2*partialHashChunk {
+		if _, err := io.Copy(hasher, file); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
+
+	buffer := make([]byte, partialHashChunk)
+	if _, err := io.ReadFull(file, buffer); err != nil {
+		return "", err
+	}
+	hasher.Write(buffer)
+	if _, err := file.ReadAt(buffer, size-partialHashChunk); err != nil && err != io.EOF {
+		return "", err
+	}
+	hasher.Write(buffer)
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func fullHash(ctx context.Context, path string, onRead func(n int64)) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	buffer := make([]byte, hashBufferSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := file.Read(buffer)
+		if n > 0 {
+			hasher.Write(buffer[:n])
+			onRead(int64(n))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// checkUnchanged guards destructive actions against files modified after the scan
+func checkUnchanged(path string, size int64, modTime time.Time) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("failed to access %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() || info.Size() != size || !info.ModTime().Equal(modTime) {
+		return fmt.Errorf("%w: %s", domain.ErrFileChanged, path)
+	}
+	return nil
+}
+
+// replaceWithHardlink atomically swaps target for a hard link to keep
+func replaceWithHardlink(keep, target string) error {
+	keepInfo, err := os.Stat(keep)
+	if err != nil {
+		return err
+	}
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if utils.GetDiskInfo(keepInfo).Device != utils.GetDiskInfo(targetInfo).Device {
+		return domain.ErrCrossDevice
+	}
+
+	temp := filepath.Join(filepath.Dir(target), ".cubert-link-"+uuid.NewString())
+	if err := os.Link(keep, temp); err != nil {
+		return fmt.Errorf("failed to create hard link: %w", err)
+	}
+	if err := os.Rename(temp, target); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+	return nil
+}
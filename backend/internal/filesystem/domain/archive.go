@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveSeparator splits the path of an archive from the path of an entry inside it,
+// e.g. /data/backup.zip!/docs/readme.md
+const ArchiveSeparator = "!"
+
+type ArchiveFormat string
+
+const (
+	ArchiveZip      ArchiveFormat = "zip"
+	ArchiveTar      ArchiveFormat = "tar"
+	ArchiveTarGzip  ArchiveFormat = "tar.gz"
+	ArchiveTarBzip2 ArchiveFormat = "tar.bz2"
+)
+
+var (
+	ErrArchiveEntryNotFound = errors.New("entry not found in archive")
+	ErrUnsupportedArchive   = errors.New("unsupported archive format")
+	ErrUnsupportedEntry     = errors.New("archive entry cannot be read")
+	ErrArchiveTooLarge      = errors.New("archive has too many entries")
+)
+
+// ArchiveFormatOf detects the archive format from the file name; ok is false for other files
+func ArchiveFormatOf(name string) (ArchiveFormat, bool) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, true
+	case strings.HasSuffix(lower, ".tar"):
+		return ArchiveTar, true
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGzip, true
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"), strings.HasSuffix(lower, ".tbz"):
+		return ArchiveTarBzip2, true
+	}
+	return "", false
+}
+
+// SplitArchivePath splits /a/b.zip!/c/d into the archive /a/b.zip and the entry c/d.
+// The entry is empty for the root of the archive. ok is false for regular paths.
+func SplitArchivePath(p string) (archive, entry string, ok bool) {
+	// Se busca el primer "!" que sigue a un nombre de archivo comprimido, así los
+	// nombres de directorio con "!" siguen funcionando
+	offset := 0
+	for {
+		index := strings.Index(p[offset:], ArchiveSeparator)
+		if index < 0 {
+			return "", "", false
+		}
+		index += offset
+
+		rest := p[index+len(ArchiveSeparator):]
+		if rest == "" || rest[0] == '/' || rest[0] == filepath.Separator {
+			if _, isArchive := ArchiveFormatOf(p[:index]); isArchive {
+				return filepath.Clean(p[:index]), CleanArchiveEntry(rest), true
+			}
+		}
+		offset = index + len(ArchiveSeparator)
+	}
+}
+
+// JoinArchivePath builds the virtual path of an entry inside an archive
+func JoinArchivePath(archive, entry string) string {
+	return archive + ArchiveSeparator + "/" + CleanArchiveEntry(entry)
+}
+
+// CleanArchiveEntry normalizes an entry name to a slash-separated path without leading
+// slash; "" is the root of the archive. ".." never escapes the root.
+func CleanArchiveEntry(entry string) string {
+	entry = strings.ReplaceAll(entry, "\\", "/")
+	entry = strings.TrimPrefix(path.Clean("/"+entry), "/")
+	return entry
+}
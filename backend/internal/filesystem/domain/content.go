@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Text encodings detected when reading a file for editing
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF8BOM = "utf-8-bom"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "iso-8859-1"
+)
+
+// Line ending styles; "mixed" and "none" are only reported, never written
+const (
+	LineEndingLF    = "lf"
+	LineEndingCRLF  = "crlf"
+	LineEndingCR    = "cr"
+	LineEndingMixed = "mixed"
+	LineEndingNone  = "none"
+)
+
+type TextContent struct {
+	Path       string    `json:"path"`
+	Content    string    `json:"content"`
+	Encoding   string    `json:"encoding"`
+	LineEnding string    `json:"line_ending"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+	ETag       string    `json:"etag"`
+	ReadOnly   bool      `json:"read_only"`
+}
+
+// ContentUpdate replaces the content of a text file. Encoding and LineEnding default to
+// those of the current file.
+type ContentUpdate struct {
+	Path       string `json:"path"`
+	Content    string `json:"content"`
+	Encoding   string `json:"encoding,omitempty"`
+	LineEnding string `json:"line_ending,omitempty"`
+}
+
+var (
+	ErrNotTextFile          = errors.New("file is not a text file")
+	ErrFileTooLarge         = errors.New("file is too large to edit")
+	ErrPreconditionFailed   = errors.New("file was modified by someone else")
+	ErrPreconditionRequired = errors.New("If-Match header is required")
+	ErrUnsupportedEncoding  = errors.New("unsupported encoding")
+	ErrReadOnly             = errors.New("file is read-only")
+)
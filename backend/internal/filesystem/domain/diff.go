@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Diff modes
+const (
+	DiffModeAuto   = "auto"
+	DiffModeText   = "text"
+	DiffModeBinary = "binary"
+)
+
+// Kinds of diff lines
+const (
+	DiffContext = "context"
+	DiffAdd     = "add"
+	DiffDelete  = "delete"
+)
+
+// DiffSource is one side of a comparison: a file (also inside an archive) or a stored version
+type DiffSource struct {
+	Path      string `json:"path"`
+	VersionID string `json:"version_id,omitempty"`
+}
+
+type DiffOptions struct {
+	Mode string
+	// Context is the number of unchanged lines around each change
+	Context int
+	// Words adds word-level segments to changed lines
+	Words bool
+}
+
+type DiffSide struct {
+	Path      string    `json:"path"`
+	VersionID string    `json:"version_id,omitempty"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+}
+
+// DiffResult compares two files. Text diffs fill Unified and Hunks; binary diffs only
+// report the first differing byte.
+type DiffResult struct {
+	A         DiffSide   `json:"a"`
+	B         DiffSide   `json:"b"`
+	Mode      string     `json:"mode"`
+	Identical bool       `json:"identical"`
+	Unified   string     `json:"unified,omitempty"`
+	Hunks     []DiffHunk `json:"hunks,omitempty"`
+	Added     int        `json:"added"`
+	Removed   int        `json:"removed"`
+	// FirstDifference is the offset of the first differing byte, -1 when identical
+	FirstDifference int64 `json:"first_difference"`
+}
+
+type DiffHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+type DiffLine struct {
+	Kind    string `json:"kind"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	// Segments split a changed line into changed and unchanged words
+	Segments []DiffSegment `json:"segments,omitempty"`
+}
+
+type DiffSegment struct {
+	Text    string `json:"text"`
+	Changed bool   `json:"changed"`
+}
+
+var ErrInvalidDiffMode = errors.New("invalid diff mode")
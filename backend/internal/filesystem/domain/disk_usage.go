@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"time"
+)
+
+// UsageNode is one entry of the disk usage tree. Children are sorted by allocated size
+// and truncated to the top-N; the remainder is summarized in Other*.
+type UsageNode struct {
+	Path           string      `json:"path"`
+	Name           string      `json:"name"`
+	IsDirectory    bool        `json:"is_directory"`
+	Size           int64       `json:"size"`
+	AllocatedSize  int64       `json:"allocated_size"`
+	Files          int64       `json:"files"`
+	Directories    int64       `json:"directories"`
+	ModTime        time.Time   `json:"mod_time"`
+	Errors         int64       `json:"errors,omitempty"`
+	Children       []UsageNode `json:"children,omitempty"`
+	OtherCount     int64       `json:"other_count,omitempty"`
+	OtherSize      int64       `json:"other_size,omitempty"`
+	OtherAllocated int64       `json:"other_allocated_size,omitempty"`
+}
+
+type DiskUsageReport struct {
+	Root      UsageNode `json:"root"`
+	ScannedAt time.Time `json:"scanned_at"`
+	ScanRoot  string    `json:"scan_root"`
+	Cached    bool      `json:"cached"`
+	Depth     int       `json:"depth"`
+	Top       int       `json:"top"`
+}
+
+type LargeFile struct {
+	Path          string    `json:"path"`
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	AllocatedSize int64     `json:"allocated_size"`
+	ModTime       time.Time `json:"mod_time"`
+	ContentType   string    `json:"content_type"`
+}
@@ -12,4 +12,6 @@ type LocalFile struct {
 	IsDirectory bool      `json:"is_directory"`
 	ContentType string    `json:"content_type"`
 	Permissions string    `json:"permissions"`
+	// IsArchive marks archives that can be browsed with the "!" path separator
+	IsArchive bool `json:"is_archive,omitempty"`
 }
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Kinds of rendered previews
+const (
+	PreviewMarkdown = "markdown"
+	PreviewCode     = "code"
+	PreviewJSON     = "json"
+	PreviewXML      = "xml"
+	PreviewCSV      = "csv"
+	PreviewText     = "text"
+)
+
+// Preview is a file rendered on the server for FileDetailPage. HTML is safe to insert
+// into the page: markdown is sanitized and highlighted code is escaped.
+type Preview struct {
+	Path     string    `json:"path"`
+	Kind     string    `json:"kind"`
+	Language string    `json:"language,omitempty"`
+	HTML     string    `json:"html,omitempty"`
+	Table    *CSVTable `json:"table,omitempty"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	// Truncated is set when only the beginning of a large file was rendered
+	Truncated bool `json:"truncated"`
+}
+
+// CSVTable is one page of a CSV file; Header is the first record of the file
+type CSVTable struct {
+	Header    []string   `json:"header"`
+	Rows      [][]string `json:"rows"`
+	Page      int        `json:"page"`
+	PageSize  int        `json:"page_size"`
+	TotalRows int        `json:"total_rows"`
+}
+
+var ErrNoPreview = errors.New("no preview available for this file")
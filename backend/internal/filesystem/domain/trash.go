@@ -0,0 +1,21 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+type TrashEntry struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"original_path"`
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	IsDirectory  bool      `json:"is_directory"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	DeletedBy    string    `json:"deleted_by"`
+}
+
+var (
+	ErrTrashEntryNotFound = errors.New("trash entry not found")
+	ErrRestoreConflict    = errors.New("a file already exists at the original location")
+)
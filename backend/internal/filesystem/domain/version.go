@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Reasons a version was recorded
+const (
+	VersionReasonEdit    = "edit"
+	VersionReasonRestore = "restore"
+)
+
+// Version is a previous content of a file. Contents are stored once per hash, so
+// identical versions share the same blob.
+type Version struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	Hash      string    `json:"hash"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+	Reason    string    `json:"reason"`
+}
+
+// VersionRetention decides which versions of a file are kept: the newest KeepLast plus
+// the newest of each day during the last KeepDailyDays
+type VersionRetention struct {
+	KeepLast      int
+	KeepDailyDays int
+}
+
+var (
+	ErrVersionNotFound = errors.New("version not found")
+	ErrNotVersioned    = errors.New("path is outside the versioned roots")
+)
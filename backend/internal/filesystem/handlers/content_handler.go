@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	"github.com/infortech07/cubert/internal/filesystem/services"
+	quotadomain "github.com/infortech07/cubert/internal/quota/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	vaultdomain "github.com/infortech07/cubert/internal/vault/domain"
+)
+
+type ContentHandler struct {
+	contentService *services.ContentService
+	previewService *services.PreviewService
+	aclService     *authservices.ACLService
+}
+
+func NewContentHandler(
+	contentService *services.ContentService,
+	previewService *services.PreviewService,
+	aclService *authservices.ACLService,
+) *ContentHandler {
+	return &ContentHandler{
+		contentService: contentService,
+		previewService: previewService,
+		aclService:     aclService,
+	}
+}
+
+// GetContent returns a text file for the editor with its ETag
+func (h *ContentHandler) GetContent(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
+	content, err := h.contentService.Read(r.Context(), path)
+	if err != nil {
+		writeContentError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", content.ETag)
+	if match := r.Header.Get("If-None-Match"); match != "" && match == content.ETag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, content)
+}
+
+// PutContent saves a text file. If-Match with the ETag from GetContent is required for
+// existing files; If-None-Match: * creates a new one.
+func (h *ContentHandler) PutContent(w http.ResponseWriter, r *http.Request) {
+	var update domain.ContentUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*services.MaxEditableSize)).Decode(&update); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if update.Path == "" {
+		update.Path = r.URL.Query().Get("path")
+	}
+	if update.Path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path is required", nil)
+		return
+	}
+
+	auditdomain.SetAction(r.Context(), "filesystem.save")
+	auditdomain.AddPaths(r.Context(), update.Path)
+	if !h.authorize(w, r, update.Path, authdomain.AccessWrite) {
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	content, err := h.contentService.Write(r.Context(), update, user.ID, r.Header.Get("If-Match"), r.Header.Get("If-None-Match"))
+	if err != nil {
+		writeContentError(w, err)
+		return
+	}
+
+	// El contenido ya lo tiene el cliente; basta con los metadatos y el nuevo ETag
+	content.Content = ""
+	w.Header().Set("ETag", content.ETag)
+	utils.WriteJSONResponse(w, http.StatusOK, content)
+}
+
+// GetPreview renders a file for FileDetailPage; page and page_size paginate CSV tables
+func (h *ContentHandler) GetPreview(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
+	page := queryInt(r, "page", 1, 1, 1<<20)
+	pageSize := queryInt(r, "page_size", 100, 1, 1000)
+	preview, err := h.previewService.Render(r.Context(), path, page, pageSize)
+	if err != nil {
+		writeContentError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, preview)
+}
+
+// authorize applies the ACL of the file, or of the archive that contains it
+func (h *ContentHandler) authorize(w http.ResponseWriter, r *http.Request, path string, access authdomain.Access) bool {
+	if archive, _, ok := domain.SplitArchivePath(path); ok {
+		path = archive
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, path, access); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return false
+	}
+	return true
+}
+
+func writeContentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrArchiveEntryNotFound), errors.Is(err, os.ErrNotExist):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "File not found", err)
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		utils.WriteErrorResponse(w, http.StatusPreconditionFailed, "File has changed since it was loaded", err)
+	case errors.Is(err, domain.ErrPreconditionRequired):
+		utils.WriteErrorResponse(w, http.StatusPreconditionRequired, "If-Match header is required", err)
+	case errors.Is(err, domain.ErrNotTextFile):
+		utils.WriteErrorResponse(w, http.StatusUnsupportedMediaType, "File is not a text file", err)
+	case errors.Is(err, domain.ErrNoPreview):
+		utils.WriteErrorResponse(w, http.StatusUnsupportedMediaType, "File cannot be previewed", err)
+	case errors.Is(err, domain.ErrFileTooLarge):
+		utils.WriteErrorResponse(w, http.StatusRequestEntityTooLarge, "File is too large to edit", err)
+	case errors.Is(err, domain.ErrUnsupportedEncoding):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Unsupported encoding", err)
+	case errors.Is(err, domain.ErrReadOnly):
+		utils.WriteErrorResponse(w, http.StatusConflict, "File is read-only", err)
+	case errors.Is(err, vaultdomain.ErrVaultLocked):
+		utils.WriteErrorResponse(w, http.StatusLocked, "Vault is locked", err)
+	case errors.Is(err, quotadomain.ErrQuotaExceeded):
+		utils.WriteErrorResponse(w, http.StatusInsufficientStorage, "Not enough quota to save the file", err)
+	case errors.Is(err, domain.ErrUnsupportedEntry), errors.Is(err, domain.ErrUnsupportedArchive),
+		errors.Is(err, vaultdomain.ErrCorruptVaultFile):
+		utils.WriteErrorResponse(w, http.StatusUnprocessableEntity, "File cannot be read", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to access file content", err)
+	}
+}
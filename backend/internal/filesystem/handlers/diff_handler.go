@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	"github.com/infortech07/cubert/internal/filesystem/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+type DiffHandler struct {
+	diffService    *services.DiffService
+	versionService *services.VersionService
+	aclService     *authservices.ACLService
+}
+
+func NewDiffHandler(
+	diffService *services.DiffService,
+	versionService *services.VersionService,
+	aclService *authservices.ACLService,
+) *DiffHandler {
+	return &DiffHandler{
+		diffService:    diffService,
+		versionService: versionService,
+		aclService:     aclService,
+	}
+}
+
+// Diff compares a and b. Each side is a path (a, b) or a stored version (a_version, b_version).
+func (h *DiffHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	a := domain.DiffSource{Path: query.Get("a"), VersionID: query.Get("a_version")}
+	b := domain.DiffSource{Path: query.Get("b"), VersionID: query.Get("b_version")}
+	if (a.Path == "" && a.VersionID == "") || (b.Path == "" && b.VersionID == "") {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Both sides are required (a or a_version, b or b_version)", nil)
+		return
+	}
+	if !h.authorize(w, r, a) || !h.authorize(w, r, b) {
+		return
+	}
+
+	opts := domain.DiffOptions{
+		Mode:    query.Get("mode"),
+		Context: queryInt(r, "context", 3, 0, 1000),
+		Words:   query.Get("words") == "true",
+	}
+	result, err := h.diffService.Diff(r.Context(), a, b, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidDiffMode):
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid mode (use auto, text or binary)", err)
+		case errors.Is(err, domain.ErrVersionNotFound):
+			utils.WriteErrorResponse(w, http.StatusNotFound, "Version not found", err)
+		default:
+			writeContentError(w, err)
+		}
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, result)
+}
+
+// authorize checks read access to the file of a side, or of the archive containing it
+func (h *DiffHandler) authorize(w http.ResponseWriter, r *http.Request, source domain.DiffSource) bool {
+	path := source.Path
+	if source.VersionID != "" {
+		version, err := h.versionService.Get(source.VersionID)
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusNotFound, "Version not found", err)
+			return false
+		}
+		path = version.Path
+	}
+	if archive, _, ok := domain.SplitArchivePath(path); ok {
+		path = archive
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, path, authdomain.AccessRead); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return false
+	}
+
+	auditdomain.AddPaths(r.Context(), path)
+	return true
+}
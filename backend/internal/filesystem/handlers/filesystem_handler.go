@@ -2,28 +2,76 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/filesystem/domain"
 	"github.com/infortech07/cubert/internal/filesystem/services"
+	quotadomain "github.com/infortech07/cubert/internal/quota/domain"
+	quotaservices "github.com/infortech07/cubert/internal/quota/services"
 	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	tagdomain "github.com/infortech07/cubert/internal/tags/domain"
+	tagservices "github.com/infortech07/cubert/internal/tags/services"
+	vaultdomain "github.com/infortech07/cubert/internal/vault/domain"
 )
 
 type FilesystemHandler struct {
 	scannerService  *services.ScannerService
 	explorerService *services.ExplorerService
+	usageService    *services.DiskUsageService
+	trashService    *services.TrashService
+	versionService  *services.VersionService
+	quotaService    *quotaservices.QuotaService
+	tagService      *tagservices.TagService
+	aclService      *authservices.ACLService
 }
 
 func NewFilesystemHandler(
 	scannerService *services.ScannerService,
 	explorerService *services.ExplorerService,
+	usageService *services.DiskUsageService,
+	trashService *services.TrashService,
+	versionService *services.VersionService,
+	quotaService *quotaservices.QuotaService,
+	tagService *tagservices.TagService,
+	aclService *authservices.ACLService,
 ) *FilesystemHandler {
 	return &FilesystemHandler{
 		scannerService:  scannerService,
 		explorerService: explorerService,
+		usageService:    usageService,
+		trashService:    trashService,
+		versionService:  versionService,
+		quotaService:    quotaService,
+		tagService:      tagService,
+		aclService:      aclService,
 	}
 }
 
+// authorize checks the ACL of the current user and writes a 403 when access is denied.
+// Paths inside an archive are governed by the ACL of the archive itself.
+func (h *FilesystemHandler) authorize(w http.ResponseWriter, r *http.Request, path string, access authdomain.Access) bool {
+	if archive, _, ok := domain.SplitArchivePath(path); ok {
+		path = archive
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, path, access); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return false
+	}
+	return true
+}
+
 func (h *FilesystemHandler) ScanDirectory(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -31,6 +79,10 @@ func (h *FilesystemHandler) ScanDirectory(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
 	// Configurar opciones de escaneo
 	if maxDepthStr := r.URL.Query().Get("max_depth"); maxDepthStr != "" {
 		if maxDepth, err := strconv.Atoi(maxDepthStr); err == nil && maxDepth > 0 {
@@ -60,8 +112,20 @@ func (h *FilesystemHandler) ListDirectory(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
 	files, err := h.explorerService.ListDirectory(r.Context(), path)
 	if err != nil {
+		if errors.Is(err, domain.ErrArchiveEntryNotFound) || errors.Is(err, os.ErrNotExist) {
+			utils.WriteErrorResponse(w, http.StatusNotFound, "Directory not found", err)
+			return
+		}
+		if errors.Is(err, vaultdomain.ErrVaultLocked) {
+			utils.WriteErrorResponse(w, http.StatusLocked, "Vault is locked", err)
+			return
+		}
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to list directory", err)
 		return
 	}
@@ -82,8 +146,16 @@ func (h *FilesystemHandler) GetFileInfo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
 	fileInfo, err := h.explorerService.GetFileInfo(r.Context(), path)
 	if err != nil {
+		if errors.Is(err, vaultdomain.ErrVaultLocked) {
+			utils.WriteErrorResponse(w, http.StatusLocked, "Vault is locked", err)
+			return
+		}
 		utils.WriteErrorResponse(w, http.StatusNotFound, "File not found", err)
 		return
 	}
@@ -91,6 +163,244 @@ func (h *FilesystemHandler) GetFileInfo(w http.ResponseWriter, r *http.Request)
 	utils.WriteJSONResponse(w, http.StatusOK, fileInfo)
 }
 
+// DownloadFile streams a file, including files inside zip and tar archives. Seekable
+// content supports range requests.
+func (h *FilesystemHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
+	reader, info, err := h.explorerService.OpenFile(r.Context(), path)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrArchiveEntryNotFound), errors.Is(err, os.ErrNotExist):
+			utils.WriteErrorResponse(w, http.StatusNotFound, "File not found", err)
+		case errors.Is(err, vaultdomain.ErrVaultLocked):
+			utils.WriteErrorResponse(w, http.StatusLocked, "Vault is locked", err)
+		case errors.Is(err, domain.ErrUnsupportedEntry), errors.Is(err, domain.ErrUnsupportedArchive),
+			errors.Is(err, vaultdomain.ErrCorruptVaultFile):
+			utils.WriteErrorResponse(w, http.StatusUnprocessableEntity, "File cannot be read", err)
+		default:
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "Failed to open file", err)
+		}
+		return
+	}
+	defer reader.Close()
+
+	// Las descargas grandes no deben cortarse por el WriteTimeout del servidor
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	disposition := "attachment"
+	if r.URL.Query().Get("inline") == "true" {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": info.Name}))
+	w.Header().Set("Content-Type", info.ContentType)
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, info.Name, info.ModTime, seeker)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		io.Copy(w, reader)
+	}
+}
+
+// UploadFile writes the request body to path, replacing an existing file. On local
+// roots the previous content is kept as a version.
+func (h *FilesystemHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+
+	auditdomain.SetAction(r.Context(), "filesystem.upload")
+	auditdomain.AddPaths(r.Context(), path)
+	if !h.authorize(w, r, path, authdomain.AccessWrite) {
+		return
+	}
+
+	// Las subidas grandes no deben cortarse por los timeouts del servidor
+	controller := http.NewResponseController(w)
+	controller.SetReadDeadline(time.Time{})
+	controller.SetWriteDeadline(time.Time{})
+
+	// Las bóvedas no guardan versiones: la copia quedaría fuera del cifrado de nombres
+	diskPath, err := h.explorerService.DiskPath(r.Context(), path)
+	if err != nil {
+		writeExplorerError(w, "Failed to upload file", err)
+		return
+	}
+	user, _ := authdomain.UserFromContext(r.Context())
+	existing, statErr := h.explorerService.GetFileInfo(r.Context(), path)
+	versioned := statErr == nil && !h.explorerService.IsRemote(path) && diskPath == path
+
+	// Las cuotas se comprueban antes de leer el cuerpo; sin Content-Length se cortan al pasarse
+	body := io.Reader(r.Body)
+	if !h.explorerService.IsRemote(path) {
+		allowance := h.quotaService.Allowance(user.ID, diskPath)
+		growth, files := r.ContentLength, int64(1)
+		if statErr == nil {
+			files = 0
+			if !versioned {
+				growth -= existing.Size
+			}
+		}
+		if r.ContentLength < 0 {
+			growth = 0
+			if statErr == nil && !versioned {
+				growth = -existing.Size
+			}
+			body = allowance.LimitReader(r.Body)
+		}
+		if err := allowance.Take(growth, files); err != nil {
+			writeExplorerError(w, "Not enough quota for the upload", err)
+			return
+		}
+	}
+
+	if versioned {
+		if _, err := h.versionService.Snapshot(r.Context(), path, user.ID, domain.VersionReasonEdit); err != nil {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to keep the previous version", err)
+			return
+		}
+	}
+
+	info, err := h.explorerService.WriteFile(r.Context(), path, body)
+	if err != nil {
+		writeExplorerError(w, "Failed to upload file", err)
+		return
+	}
+
+	status := http.StatusOK
+	if statErr != nil {
+		status = http.StatusCreated
+	}
+	utils.WriteJSONResponse(w, status, info)
+}
+
+func (h *FilesystemHandler) CreateDirectory(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), request.Path)
+	if !h.authorize(w, r, request.Path, authdomain.AccessWrite) {
+		return
+	}
+
+	info, err := h.explorerService.CreateDirectory(r.Context(), request.Path)
+	if err != nil {
+		writeExplorerError(w, "Failed to create directory", err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusCreated, info)
+}
+
+// Rename moves a file or folder; the destination must not exist
+func (h *FilesystemHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path    string `json:"path"`
+		NewPath string `json:"new_path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Path == "" || request.NewPath == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), request.Path, request.NewPath)
+	if !h.authorize(w, r, request.Path, authdomain.AccessWrite) || !h.authorize(w, r, request.NewPath, authdomain.AccessWrite) {
+		return
+	}
+
+	info, err := h.explorerService.Rename(r.Context(), request.Path, request.NewPath)
+	if err != nil {
+		writeExplorerError(w, "Failed to rename", err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, info)
+}
+
+// DeleteFile moves a local file or folder to the trash. Remote roots have no trash,
+// so their entries are deleted permanently.
+func (h *FilesystemHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), path)
+	if !h.authorize(w, r, path, authdomain.AccessWrite) {
+		return
+	}
+	if _, err := h.explorerService.GetFileInfo(r.Context(), path); err != nil {
+		writeExplorerError(w, "Failed to delete", err)
+		return
+	}
+
+	if h.explorerService.IsRemote(path) {
+		if err := h.explorerService.RemoveAll(r.Context(), path); err != nil {
+			writeExplorerError(w, "Failed to delete", err)
+			return
+		}
+		utils.WriteMessageResponse(w, http.StatusOK, "Deleted permanently")
+		return
+	}
+
+	// Dentro de una bóveda va a la papelera el archivo cifrado, tal como está en disco
+	diskPath, err := h.explorerService.DiskPath(r.Context(), path)
+	if err != nil {
+		writeExplorerError(w, "Failed to delete", err)
+		return
+	}
+	user, _ := authdomain.UserFromContext(r.Context())
+	entry, err := h.trashService.Move(r.Context(), diskPath, user.ID)
+	if err != nil {
+		writeExplorerError(w, "Failed to move to trash", err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, entry)
+}
+
+func writeExplorerError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "File not found", err)
+	case errors.Is(err, os.ErrExist):
+		utils.WriteErrorResponse(w, http.StatusConflict, "A file already exists at the destination", err)
+	case errors.Is(err, os.ErrPermission):
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Permission denied", err)
+	case errors.Is(err, domain.ErrReadOnly):
+		utils.WriteErrorResponse(w, http.StatusConflict, "Files inside archives are read-only", err)
+	case errors.Is(err, vaultdomain.ErrVaultLocked):
+		utils.WriteErrorResponse(w, http.StatusLocked, "Vault is locked", err)
+	case errors.Is(err, quotadomain.ErrQuotaExceeded):
+		utils.WriteErrorResponse(w, http.StatusInsufficientStorage, message, err)
+	case errors.Is(err, storagedomain.ErrCrossMount), errors.Is(err, storagedomain.ErrIsDir),
+		errors.Is(err, storagedomain.ErrNotDir), errors.Is(err, os.ErrInvalid),
+		errors.Is(err, vaultdomain.ErrCrossVault), errors.Is(err, vaultdomain.ErrNameTooLong):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, message, err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, message, err)
+	}
+}
+
 func (h *FilesystemHandler) GetDirectoryStats(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -98,6 +408,10 @@ func (h *FilesystemHandler) GetDirectoryStats(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
 	stats, err := h.scannerService.GetDirectoryStats(r.Context(), path)
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to get directory stats", err)
@@ -107,43 +421,147 @@ func (h *FilesystemHandler) GetDirectoryStats(w http.ResponseWriter, r *http.Req
 	utils.WriteJSONResponse(w, http.StatusOK, stats)
 }
 
+// GetDiskUsage returns the recursive size tree of a directory (depth levels, top children per level)
+func (h *FilesystemHandler) GetDiskUsage(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
+	depth := queryInt(r, "depth", 2, 0, 10)
+	top := queryInt(r, "top", 20, 1, 500)
+	refresh := r.URL.Query().Get("refresh") == "true"
+
+	report, err := h.usageService.Analyze(r.Context(), path, depth, top, refresh)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to analyze disk usage", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, report)
+}
+
+func (h *FilesystemHandler) GetLargestFiles(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
+	limit := queryInt(r, "limit", 20, 1, services.MaxLargestFilesQuery)
+	refresh := r.URL.Query().Get("refresh") == "true"
+
+	files, err := h.usageService.LargestFiles(r.Context(), path, limit, refresh)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to find largest files", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"path":  path,
+		"files": files,
+		"count": len(files),
+	})
+}
+
 func (h *FilesystemHandler) SearchFiles(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	query := r.URL.Query().Get("q")
+	tags := r.URL.Query()["tag"]
+	attributes := tagdomain.ParseAttributeFilters(r.URL.Query()["attr"])
+	byTags := len(tags) > 0 || len(attributes) > 0
 
 	if path == "" {
 		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
 		return
 	}
 
-	if query == "" {
+	if query == "" && !byTags {
 		utils.WriteErrorResponse(w, http.StatusBadRequest, "Query parameter is required", nil)
 		return
 	}
 
-	results, err := h.explorerService.SearchFiles(r.Context(), path, query)
-	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Search failed", err)
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
 		return
 	}
 
+	var results []domain.LocalFile
+	if byTags {
+		// Con filtros de etiquetas se busca en la base de metadatos en lugar de recorrer el árbol
+		results = h.searchTagged(r, tagdomain.Filter{Path: path, Tags: tags, Attributes: attributes, Query: query})
+	} else {
+		var err error
+		results, err = h.explorerService.SearchFiles(r.Context(), path, query)
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Search failed", err)
+			return
+		}
+	}
+
 	response := map[string]interface{}{
 		"path":    path,
 		"query":   query,
 		"results": results,
 		"count":   len(results),
 	}
+	if byTags {
+		response["tags"] = tags
+		response["attributes"] = attributes
+	}
 
 	utils.WriteJSONResponse(w, http.StatusOK, response)
 }
 
+// searchTagged lists the files below filter.Path carrying the tags and attributes asked for
+func (h *FilesystemHandler) searchTagged(r *http.Request, filter tagdomain.Filter) []domain.LocalFile {
+	user, _ := authdomain.UserFromContext(r.Context())
+	entries, _ := h.tagService.Search(filter, func(path string) bool {
+		return h.aclService.Authorize(r.Context(), user, path, authdomain.AccessRead) == nil
+	})
+
+	results := make([]domain.LocalFile, 0, len(entries))
+	for _, entry := range entries {
+		// Se consulta a través del explorador, como el resto de rutas (bóvedas, raíces remotas)
+		info, err := h.explorerService.GetFileInfo(r.Context(), entry.Path)
+		if err != nil {
+			continue
+		}
+		results = append(results, domain.LocalFile{
+			Path:        entry.Path,
+			Name:        info.Name,
+			Size:        info.Size,
+			ModTime:     info.ModTime,
+			IsDirectory: info.IsDirectory,
+			ContentType: info.ContentType,
+			Permissions: info.Permissions,
+		})
+	}
+	return results
+}
+
 func (h *FilesystemHandler) GetSystemRoots(w http.ResponseWriter, r *http.Request) {
-	roots, err := h.explorerService.GetSystemRoots()
+	roots, err := h.explorerService.GetSystemRoots(r.Context())
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to get system roots", err)
 		return
 	}
 
+	// Los usuarios con ACL restringida solo ven las rutas que tienen asignadas
+	if user, ok := authdomain.UserFromContext(r.Context()); ok {
+		if allowed := h.aclService.AllowedRoots(user); allowed != nil {
+			roots = allowed
+		}
+	}
+
 	response := map[string]interface{}{
 		"roots": roots,
 		"count": len(roots),
@@ -162,7 +580,13 @@ func (h *FilesystemHandler) ValidatePath(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.explorerService.ValidatePath(request.Path); err != nil {
+	auditdomain.AddPaths(r.Context(), request.Path)
+
+	if !h.authorize(w, r, request.Path, authdomain.AccessRead) {
+		return
+	}
+
+	if err := h.explorerService.ValidatePath(r.Context(), request.Path); err != nil {
 		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid path", err)
 		return
 	}
@@ -172,3 +596,12 @@ func (h *FilesystemHandler) ValidatePath(w http.ResponseWriter, r *http.Request)
 		"valid": true,
 	})
 }
+
+// queryInt parses an integer query parameter, falling back to def when missing or out of range
+func queryInt(r *http.Request, name string, def, min, max int) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || value < min || value > max {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	"github.com/infortech07/cubert/internal/filesystem/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+type TrashHandler struct {
+	trashService *services.TrashService
+	aclService   *authservices.ACLService
+}
+
+func NewTrashHandler(trashService *services.TrashService, aclService *authservices.ACLService) *TrashHandler {
+	return &TrashHandler{
+		trashService: trashService,
+		aclService:   aclService,
+	}
+}
+
+// ListTrash returns the caller's deleted items; admins may pass scope=all
+func (h *TrashHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	userID := user.ID
+	if user.IsAdmin() && r.URL.Query().Get("scope") == "all" {
+		userID = ""
+	}
+
+	entries := h.trashService.List(userID)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+func (h *TrashHandler) RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.entry(w, r)
+	if !ok {
+		return
+	}
+
+	restored, err := h.trashService.Restore(r.Context(), entry.ID)
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, restored)
+}
+
+func (h *TrashHandler) PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.entry(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.trashService.Purge(r.Context(), entry.ID); err != nil {
+		writeTrashError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "Trash entry deleted permanently")
+}
+
+// entry loads the entry in the URL and checks that the caller owns it and may write its location
+func (h *TrashHandler) entry(w http.ResponseWriter, r *http.Request) (*domain.TrashEntry, bool) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	entry, err := h.trashService.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeTrashError(w, err)
+		return nil, false
+	}
+	if !user.IsAdmin() && entry.DeletedBy != user.ID {
+		writeTrashError(w, domain.ErrTrashEntryNotFound)
+		return nil, false
+	}
+	if err := h.aclService.Authorize(r.Context(), user, entry.OriginalPath, authdomain.AccessWrite); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return nil, false
+	}
+
+	auditdomain.AddPaths(r.Context(), entry.OriginalPath)
+	return entry, true
+}
+
+func writeTrashError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrTrashEntryNotFound):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Trash entry not found", err)
+	case errors.Is(err, domain.ErrRestoreConflict):
+		utils.WriteErrorResponse(w, http.StatusConflict, "Cannot restore", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Trash operation failed", err)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	"github.com/infortech07/cubert/internal/filesystem/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+type VersionHandler struct {
+	versionService *services.VersionService
+	aclService     *authservices.ACLService
+}
+
+func NewVersionHandler(versionService *services.VersionService, aclService *authservices.ACLService) *VersionHandler {
+	return &VersionHandler{
+		versionService: versionService,
+		aclService:     aclService,
+	}
+}
+
+// ListVersions returns the previous versions of a file, newest first
+func (h *VersionHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, path, authdomain.AccessRead); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return
+	}
+
+	versions, err := h.versionService.List(path)
+	if err != nil {
+		writeVersionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"path":     path,
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+func (h *VersionHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	version, ok := h.version(w, r, authdomain.AccessRead)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, version)
+}
+
+// GetVersionContent downloads the stored content of a version
+func (h *VersionHandler) GetVersionContent(w http.ResponseWriter, r *http.Request) {
+	version, ok := h.version(w, r, authdomain.AccessRead)
+	if !ok {
+		return
+	}
+
+	file, _, err := h.versionService.Open(version.ID)
+	if err != nil {
+		writeVersionError(w, err)
+		return
+	}
+	defer file.Close()
+
+	name := filepath.Base(version.Path)
+	disposition := "attachment"
+	if r.URL.Query().Get("inline") == "true" {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	w.Header().Set("Content-Type", utils.GetContentType(name))
+	http.ServeContent(w, r, name, version.ModTime, file)
+}
+
+// RestoreVersion writes a version back to its file; the replaced content becomes a new version
+func (h *VersionHandler) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	version, ok := h.version(w, r, authdomain.AccessWrite)
+	if !ok {
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	restored, err := h.versionService.Restore(r.Context(), version.ID, user.ID)
+	if err != nil {
+		writeVersionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, restored)
+}
+
+// version loads the version in the URL and checks the ACL of its file
+func (h *VersionHandler) version(w http.ResponseWriter, r *http.Request, access authdomain.Access) (*domain.Version, bool) {
+	version, err := h.versionService.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeVersionError(w, err)
+		return nil, false
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, version.Path, access); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return nil, false
+	}
+
+	auditdomain.AddPaths(r.Context(), version.Path)
+	return version, true
+}
+
+func writeVersionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrVersionNotFound):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Version not found", err)
+	case errors.Is(err, domain.ErrNotVersioned):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path is not versioned", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Version operation failed", err)
+	}
+}
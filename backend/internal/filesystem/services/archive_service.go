@@ -0,0 +1,498 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+)
+
+const (
+	maxCachedArchives = 32
+	maxArchiveEntries = 1 << 20
+)
+
+// ArchiveService lets the explorer descend into zip and tar archives without extracting
+// them. Archives are read through the explorer's storage, so they can live on remote
+// roots and in unlocked vaults. The entry index of each archive is cached until the
+// archive changes.
+type ArchiveService struct {
+	storage storagedomain.Storage
+
+	mu    sync.Mutex
+	cache map[string]*archiveIndex
+}
+
+type archiveIndex struct {
+	format   domain.ArchiveFormat
+	size     int64
+	modTime  time.Time
+	entries  map[string]*archiveEntry
+	children map[string][]string
+	lastUsed time.Time
+}
+
+type archiveEntry struct {
+	name    string
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+	isDir   bool
+
+	// Posición de los datos dentro del archivo; -1 si hay que recorrer el flujo
+	dataOffset     int64
+	compressedSize int64
+	method         uint16
+	encrypted      bool
+}
+
+// archiveReader is an open entry. Entries stored uncompressed also implement io.Seeker.
+type archiveReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *archiveReader) Close() error {
+	var first error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if err := r.closers[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+type seekableArchiveReader struct {
+	*archiveReader
+	section *io.SectionReader
+}
+
+func (r *seekableArchiveReader) Seek(offset int64, whence int) (int64, error) {
+	return r.section.Seek(offset, whence)
+}
+
+func NewArchiveService(storage storagedomain.Storage) *ArchiveService {
+	return &ArchiveService{
+		storage: storage,
+		cache:   make(map[string]*archiveIndex),
+	}
+}
+
+// List returns the entries directly below a directory of an archive
+func (s *ArchiveService) List(ctx context.Context, virtualPath string) ([]domain.LocalFile, error) {
+	archive, name, index, err := s.resolve(ctx, virtualPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := index.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrArchiveEntryNotFound, virtualPath)
+	}
+	if !entry.isDir {
+		return nil, fmt.Errorf("%s is not a directory", virtualPath)
+	}
+
+	files := make([]domain.LocalFile, 0, len(index.children[name]))
+	for _, childName := range index.children[name] {
+		files = append(files, entryToLocalFile(archive, index.entries[childName]))
+	}
+	return files, nil
+}
+
+// Stat describes an entry of an archive; the archive root is reported as a directory
+func (s *ArchiveService) Stat(ctx context.Context, virtualPath string) (*domain.FileInfo, error) {
+	archive, name, index, err := s.resolve(ctx, virtualPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := index.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrArchiveEntryNotFound, virtualPath)
+	}
+	return entryToFileInfo(archive, index, entry), nil
+}
+
+func entryToFileInfo(archive string, index *archiveIndex, entry *archiveEntry) *domain.FileInfo {
+	name := entry.name
+	file := entryToLocalFile(archive, entry)
+	parent := filepath.Dir(archive)
+	if name != "" {
+		parent = domain.JoinArchivePath(archive, path.Dir(name))
+	}
+	return &domain.FileInfo{
+		Path:        file.Path,
+		Name:        file.Name,
+		Size:        file.Size,
+		ModTime:     file.ModTime,
+		IsDirectory: file.IsDirectory,
+		ContentType: file.ContentType,
+		Permissions: file.Permissions,
+		Extension:   path.Ext(file.Name),
+		Parent:      parent,
+		Metadata: map[string]string{
+			"archive":        archive,
+			"archive_format": string(index.format),
+			"readable":       fmt.Sprintf("%t", !entry.isDir && entry.mode.IsRegular() && !entry.encrypted),
+			"writable":       "false",
+		},
+	}
+}
+
+// Open returns a reader for a file inside an archive. Zip entries stored without
+// compression and entries of uncompressed tars are seekable.
+func (s *ArchiveService) Open(ctx context.Context, virtualPath string) (io.ReadCloser, *domain.FileInfo, error) {
+	archive, name, index, err := s.resolve(ctx, virtualPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, ok := index.entries[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", domain.ErrArchiveEntryNotFound, virtualPath)
+	}
+	info := entryToFileInfo(archive, index, entry)
+	if entry.isDir || !entry.mode.IsRegular() || entry.encrypted {
+		return nil, nil, fmt.Errorf("%w: %s", domain.ErrUnsupportedEntry, virtualPath)
+	}
+
+	file, err := s.storage.Open(ctx, archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open archive %s: %w", archive, err)
+	}
+
+	switch {
+	case index.format == domain.ArchiveZip && entry.method == zip.Store,
+		index.format == domain.ArchiveTar && entry.dataOffset >= 0:
+		section := io.NewSectionReader(readerAt(file), entry.dataOffset, entry.size)
+		return &seekableArchiveReader{
+			archiveReader: &archiveReader{Reader: section, closers: []io.Closer{file}},
+			section:       section,
+		}, info, nil
+	case index.format == domain.ArchiveZip && entry.method == zip.Deflate:
+		inflater := flate.NewReader(io.NewSectionReader(readerAt(file), entry.dataOffset, entry.compressedSize))
+		return &archiveReader{
+			Reader:  io.LimitReader(inflater, entry.size),
+			closers: []io.Closer{file, inflater},
+		}, info, nil
+	case index.format == domain.ArchiveZip:
+		file.Close()
+		return nil, nil, fmt.Errorf("%w: compression method %d", domain.ErrUnsupportedEntry, entry.method)
+	}
+
+	// Tar comprimido: hay que descomprimir hasta llegar a la entrada
+	stream, closers, err := OpenTarStream(file, index.format)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	closers = append([]io.Closer{file}, closers...)
+	reader := tar.NewReader(stream)
+	for {
+		if err := ctx.Err(); err != nil {
+			closeAll(closers)
+			return nil, nil, err
+		}
+		header, err := reader.Next()
+		if err == io.EOF {
+			closeAll(closers)
+			return nil, nil, fmt.Errorf("%w: %s", domain.ErrArchiveEntryNotFound, virtualPath)
+		}
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, fmt.Errorf("failed to read archive %s: %w", archive, err)
+		}
+		if domain.CleanArchiveEntry(header.Name) == name {
+			return &archiveReader{Reader: reader, closers: closers}, info, nil
+		}
+	}
+}
+
+// resolve splits the virtual path and loads the archive index
+func (s *ArchiveService) resolve(ctx context.Context, virtualPath string) (string, string, *archiveIndex, error) {
+	archive, name, ok := domain.SplitArchivePath(virtualPath)
+	if !ok {
+		return "", "", nil, fmt.Errorf("%w: %s", domain.ErrUnsupportedArchive, virtualPath)
+	}
+	index, err := s.index(ctx, archive)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return archive, name, index, nil
+}
+
+// index returns the cached entry index, rebuilding it when the archive changed
+func (s *ArchiveService) index(ctx context.Context, archive string) (*archiveIndex, error) {
+	format, ok := domain.ArchiveFormatOf(archive)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnsupportedArchive, archive)
+	}
+	info, err := s.storage.Stat(ctx, archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access archive %s: %w", archive, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%w: %s is a directory", domain.ErrUnsupportedArchive, archive)
+	}
+
+	s.mu.Lock()
+	if cached, ok := s.cache[archive]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		cached.lastUsed = time.Now()
+		s.mu.Unlock()
+		return cached, nil
+	}
+	s.mu.Unlock()
+
+	index := &archiveIndex{
+		format:   format,
+		size:     info.Size(),
+		modTime:  info.ModTime(),
+		entries:  map[string]*archiveEntry{"": {isDir: true, mode: fs.ModeDir | 0555, modTime: info.ModTime(), dataOffset: -1}},
+		children: make(map[string][]string),
+		lastUsed: time.Now(),
+	}
+	if format == domain.ArchiveZip {
+		err = s.indexZip(ctx, archive, info.Size(), index)
+	} else {
+		err = s.indexTar(ctx, archive, index)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for dir := range index.children {
+		sort.Strings(index.children[dir])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[archive] = index
+	if len(s.cache) > maxCachedArchives {
+		var oldest string
+		for key, cached := range s.cache {
+			if oldest == "" || cached.lastUsed.Before(s.cache[oldest].lastUsed) {
+				oldest = key
+			}
+		}
+		delete(s.cache, oldest)
+	}
+	return index, nil
+}
+
+func (s *ArchiveService) indexZip(ctx context.Context, archive string, size int64, index *archiveIndex) error {
+	file, err := s.storage.Open(ctx, archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s: %w", archive, err)
+	}
+	defer file.Close()
+
+	reader, err := zip.NewReader(readerAt(file), size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip %s: %w", archive, err)
+	}
+	if len(reader.File) > maxArchiveEntries {
+		return domain.ErrArchiveTooLarge
+	}
+
+	for _, f := range reader.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entry := &archiveEntry{
+			name:           domain.CleanArchiveEntry(f.Name),
+			size:           int64(f.UncompressedSize64),
+			modTime:        f.Modified,
+			mode:           f.Mode(),
+			isDir:          strings.HasSuffix(f.Name, "/") || f.Mode().IsDir(),
+			compressedSize: int64(f.CompressedSize64),
+			method:         f.Method,
+			encrypted:      f.Flags&0x1 != 0,
+			dataOffset:     -1,
+		}
+		if !entry.isDir {
+			if entry.dataOffset, err = f.DataOffset(); err != nil {
+				return fmt.Errorf("failed to read zip %s: %w", archive, err)
+			}
+		}
+		index.add(entry)
+	}
+	return nil
+}
+
+func (s *ArchiveService) indexTar(ctx context.Context, archive string, index *archiveIndex) error {
+	file, err := s.storage.Open(ctx, archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s: %w", archive, err)
+	}
+	defer file.Close()
+
+	stream, closers, err := OpenTarStream(file, index.format)
+	if err != nil {
+		return err
+	}
+	defer closeAll(closers)
+
+	reader := tar.NewReader(stream)
+	for count := 0; ; count++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if count > maxArchiveEntries {
+			return domain.ErrArchiveTooLarge
+		}
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar %s: %w", archive, err)
+		}
+
+		entry := &archiveEntry{
+			name:       domain.CleanArchiveEntry(header.Name),
+			size:       header.Size,
+			modTime:    header.ModTime,
+			mode:       header.FileInfo().Mode(),
+			isDir:      header.Typeflag == tar.TypeDir,
+			dataOffset: -1,
+		}
+		// En un tar sin comprimir los datos son contiguos salvo en archivos dispersos
+		if index.format == domain.ArchiveTar && !entry.isDir && !isSparse(header) {
+			if entry.dataOffset, err = file.Seek(0, io.SeekCurrent); err != nil {
+				entry.dataOffset = -1
+			}
+		}
+		index.add(entry)
+	}
+}
+
+// add registers an entry and the implicit directories above it
+func (x *archiveIndex) add(entry *archiveEntry) {
+	if entry.name == "" {
+		return
+	}
+	if _, ok := x.entries[entry.name]; ok {
+		// Una entrada repetida (o un directorio implícito) se sustituye, como al extraer
+		x.entries[entry.name] = entry
+		return
+	}
+
+	x.entries[entry.name] = entry
+	child := entry.name
+	for {
+		parent := path.Dir(child)
+		if parent == "." {
+			parent = ""
+		}
+		x.children[parent] = append(x.children[parent], child)
+		if _, ok := x.entries[parent]; ok {
+			return
+		}
+		x.entries[parent] = &archiveEntry{
+			name:       parent,
+			isDir:      true,
+			mode:       fs.ModeDir | 0755,
+			modTime:    entry.modTime,
+			dataOffset: -1,
+		}
+		child = parent
+	}
+}
+
+// OpenTarStream decompresses a tar, tar.gz or tar.bz2 stream. The closers must be
+// closed once the tar has been read.
+func OpenTarStream(file io.Reader, format domain.ArchiveFormat) (io.Reader, []io.Closer, error) {
+	switch format {
+	case domain.ArchiveTar:
+		return file, nil, nil
+	case domain.ArchiveTarGzip:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+		return gz, []io.Closer{gz}, nil
+	case domain.ArchiveTarBzip2:
+		return bzip2.NewReader(file), nil, nil
+	}
+	return nil, nil, domain.ErrUnsupportedArchive
+}
+
+func isSparse(header *tar.Header) bool {
+	if header.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range header.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// readerAt gives random access to an archive. Local files read at an offset directly;
+// other backends seek before each read, one read at a time.
+func readerAt(file storagedomain.File) io.ReaderAt {
+	if reader, ok := file.(io.ReaderAt); ok {
+		return reader
+	}
+	return &seekReaderAt{file: file}
+}
+
+type seekReaderAt struct {
+	mu   sync.Mutex
+	file storagedomain.File
+}
+
+func (r *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.file.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.file, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func closeAll(closers []io.Closer) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i].Close()
+	}
+}
+
+func entryToLocalFile(archive string, entry *archiveEntry) domain.LocalFile {
+	name := path.Base(entry.name)
+	if entry.name == "" {
+		name = filepath.Base(archive)
+	}
+	file := domain.LocalFile{
+		Path:        domain.JoinArchivePath(archive, entry.name),
+		Name:        name,
+		Size:        entry.size,
+		ModTime:     entry.modTime,
+		IsDirectory: entry.isDir,
+		ContentType: utils.GetContentType(name),
+		Permissions: entry.mode.String(),
+	}
+	if entry.isDir {
+		file.Size = 0
+	}
+	return file
+}
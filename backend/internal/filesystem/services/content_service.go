@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	quotaservices "github.com/infortech07/cubert/internal/quota/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+// MaxEditableSize is the largest file served by the text editor
+const MaxEditableSize = 10 << 20
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// ContentService reads and writes text files for the built-in editor. Writes are atomic
+// and guarded by an ETag so concurrent editors cannot overwrite each other silently.
+type ContentService struct {
+	explorer *ExplorerService
+	versions *VersionService
+	quotas   *quotaservices.QuotaService
+
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}
+
+// pathLock serializes the writes to one file. The entry is dropped when its last user
+// unlocks it, so the map only holds the files being saved.
+type pathLock struct {
+	sync.Mutex
+	refs int
+}
+
+func NewContentService(explorer *ExplorerService, versions *VersionService, quotas *quotaservices.QuotaService) *ContentService {
+	return &ContentService{
+		explorer: explorer,
+		versions: versions,
+		quotas:   quotas,
+		locks:    make(map[string]*pathLock),
+	}
+}
+
+// Read returns the decoded text of a file, also inside archives (read-only)
+func (s *ContentService) Read(ctx context.Context, path string) (*domain.TextContent, error) {
+	reader, info, err := s.explorer.OpenFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if info.Size > MaxEditableSize {
+		return nil, domain.ErrFileTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, MaxEditableSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(data) > MaxEditableSize {
+		return nil, domain.ErrFileTooLarge
+	}
+
+	text, encoding, err := decodeText(data)
+	if err != nil {
+		return nil, err
+	}
+	_, _, inArchive := domain.SplitArchivePath(path)
+	diskPath, err := s.explorer.DiskPath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TextContent{
+		Path:       path,
+		Content:    text,
+		Encoding:   encoding,
+		LineEnding: detectLineEnding(text),
+		Size:       int64(len(data)),
+		ModTime:    info.ModTime,
+		ETag:       contentETag(int64(len(data)), info.ModTime, data),
+		ReadOnly:   inArchive || diskPath != path || info.Metadata["writable"] == "false",
+	}, nil
+}
+
+// Write replaces a text file atomically. ifMatch must match the current ETag ("*" for
+// any existing file); ifNoneMatch "*" creates a file that must not exist yet. The
+// previous content is kept as a version of the file.
+func (s *ContentService) Write(ctx context.Context, update domain.ContentUpdate, userID, ifMatch, ifNoneMatch string) (*domain.TextContent, error) {
+	if _, _, ok := domain.SplitArchivePath(update.Path); ok {
+		return nil, domain.ErrReadOnly
+	}
+	// El editor escribe directamente en disco; dentro de una bóveda se guardaría sin cifrar
+	if diskPath, err := s.explorer.DiskPath(ctx, update.Path); err != nil {
+		return nil, err
+	} else if diskPath != update.Path {
+		return nil, domain.ErrReadOnly
+	}
+
+	unlock := s.lock(update.Path)
+	defer unlock()
+
+	// Las bibliotecas remotas se leen y escriben a través del almacenamiento, sin versiones ni cuotas
+	remote := s.explorer.IsRemote(update.Path)
+	encoding, lineEnding, perm := domain.EncodingUTF8, "", os.FileMode(0644)
+	info, err := s.explorer.GetFileInfo(ctx, update.Path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if ifMatch != "" {
+			return nil, domain.ErrPreconditionFailed
+		}
+		if ifNoneMatch != "*" {
+			return nil, domain.ErrPreconditionRequired
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to access %s: %w", update.Path, err)
+	case info.IsDirectory:
+		return nil, fmt.Errorf("%s is a directory", update.Path)
+	default:
+		if ifNoneMatch == "*" {
+			return nil, domain.ErrPreconditionFailed
+		}
+		if ifMatch == "" {
+			return nil, domain.ErrPreconditionRequired
+		}
+		current, err := s.Read(ctx, update.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !etagMatches(ifMatch, current.ETag) {
+			return nil, domain.ErrPreconditionFailed
+		}
+		encoding, lineEnding = current.Encoding, current.LineEnding
+		if !remote {
+			if local, err := os.Stat(update.Path); err == nil {
+				perm = local.Mode().Perm()
+			}
+		}
+	}
+
+	if update.Encoding != "" {
+		encoding = update.Encoding
+	}
+	if update.LineEnding != "" {
+		lineEnding = update.LineEnding
+	}
+
+	data, err := encodeText(convertLineEndings(update.Content, lineEnding), encoding)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxEditableSize {
+		return nil, domain.ErrFileTooLarge
+	}
+	// La versión anterior sigue ocupando espacio, así que cuenta todo el contenido nuevo
+	var files int64
+	if info == nil {
+		files = 1
+	}
+	if remote {
+		if _, err := s.explorer.WriteFile(ctx, update.Path, bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		return s.Read(ctx, update.Path)
+	}
+	if err := s.quotas.Check(userID, update.Path, int64(len(data)), files); err != nil {
+		return nil, err
+	}
+	if _, err := s.versions.Snapshot(ctx, update.Path, userID, domain.VersionReasonEdit); err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFileAtomic(update.Path, data, perm); err != nil {
+		return nil, err
+	}
+
+	return s.Read(ctx, update.Path)
+}
+
+// lock waits for the other writes to path and returns the function that releases it
+func (s *ContentService) lock(path string) func() {
+	s.mu.Lock()
+	lock, ok := s.locks[path]
+	if !ok {
+		lock = &pathLock{}
+		s.locks[path] = lock
+	}
+	lock.refs++
+	s.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if lock.refs--; lock.refs == 0 {
+			delete(s.locks, path)
+		}
+	}
+}
+
+// contentETag combines size, modification time and a content hash, so an edit within
+// the mtime resolution of the filesystem is still detected
+func contentETag(size int64, modTime time.Time, data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf(`"%x-%x-%s"`, size, modTime.UnixNano(), hex.EncodeToString(sum[:8]))
+}
+
+// etagMatches evaluates an If-Match header against the current ETag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeText(data []byte) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		if !utf8.Valid(data[3:]) {
+			return "", "", domain.ErrNotTextFile
+		}
+		return string(data[3:]), domain.EncodingUTF8BOM, nil
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return decodeUTF16(data[2:], binary.LittleEndian), domain.EncodingUTF16LE, nil
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return decodeUTF16(data[2:], binary.BigEndian), domain.EncodingUTF16BE, nil
+	}
+
+	// Un byte nulo en el inicio indica un archivo binario
+	sample := data
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return "", "", domain.ErrNotTextFile
+	}
+	if utf8.Valid(data) {
+		return string(data), domain.EncodingUTF8, nil
+	}
+
+	// Sin BOM ni UTF-8 válido se asume Latin-1, que asigna un carácter a cada byte
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes), domain.EncodingLatin1, nil
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
+
+func encodeText(text, encoding string) ([]byte, error) {
+	switch encoding {
+	case domain.EncodingUTF8:
+		return []byte(text), nil
+	case domain.EncodingUTF8BOM:
+		return append(append([]byte{}, bomUTF8...), text...), nil
+	case domain.EncodingUTF16LE, domain.EncodingUTF16BE:
+		var order binary.AppendByteOrder = binary.LittleEndian
+		data := append([]byte{}, bomUTF16LE...)
+		if encoding == domain.EncodingUTF16BE {
+			order, data = binary.BigEndian, append([]byte{}, bomUTF16BE...)
+		}
+		for _, unit := range utf16.Encode([]rune(text)) {
+			data = order.AppendUint16(data, unit)
+		}
+		return data, nil
+	case domain.EncodingLatin1:
+		data := make([]byte, 0, len(text))
+		for _, r := range text {
+			if r > 0xFF {
+				return nil, fmt.Errorf("%w: %q cannot be written as %s", domain.ErrUnsupportedEncoding, r, encoding)
+			}
+			data = append(data, byte(r))
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%w: %s", domain.ErrUnsupportedEncoding, encoding)
+}
+
+func detectLineEnding(text string) string {
+	crlf := strings.Count(text, "\r\n")
+	lf := strings.Count(text, "\n") - crlf
+	cr := strings.Count(text, "\r") - crlf
+
+	switch {
+	case crlf == 0 && lf == 0 && cr == 0:
+		return domain.LineEndingNone
+	case crlf == 0 && cr == 0:
+		return domain.LineEndingLF
+	case lf == 0 && cr == 0:
+		return domain.LineEndingCRLF
+	case crlf == 0 && lf == 0:
+		return domain.LineEndingCR
+	}
+	return domain.LineEndingMixed
+}
+
+// convertLineEndings rewrites every line break to the given style; other values keep the text as is
+func convertLineEndings(text, lineEnding string) string {
+	var separator string
+	switch lineEnding {
+	case domain.LineEndingLF:
+		separator = "\n"
+	case domain.LineEndingCRLF:
+		separator = "\r\n"
+	case domain.LineEndingCR:
+		separator = "\r"
+	default:
+		return text
+	}
+
+	normalized := strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	if separator == "\n" {
+		return normalized
+	}
+	return strings.ReplaceAll(normalized, "\n", separator)
+}
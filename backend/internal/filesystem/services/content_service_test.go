@@ -0,0 +1,42 @@
+package services
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestContentServiceLocksArePruned(t *testing.T) {
+	service := NewContentService(nil, nil, nil)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		holders int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := service.lock("/lib/notes.txt")
+			defer unlock()
+
+			mu.Lock()
+			holders++
+			if holders > 1 {
+				t.Error("two writers hold the same path")
+			}
+			mu.Unlock()
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	unlock := service.lock("/lib/other.txt")
+	unlock()
+	if len(service.locks) != 0 {
+		t.Errorf("%d locks left after every writer finished", len(service.locks))
+	}
+}
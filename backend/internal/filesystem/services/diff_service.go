@@ -0,0 +1,301 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+)
+
+// DiffService compares two files or stored versions, line by line for text and byte by
+// byte for anything else
+type DiffService struct {
+	explorer *ExplorerService
+	versions *VersionService
+}
+
+func NewDiffService(explorer *ExplorerService, versions *VersionService) *DiffService {
+	return &DiffService{
+		explorer: explorer,
+		versions: versions,
+	}
+}
+
+func (s *DiffService) Diff(ctx context.Context, a, b domain.DiffSource, opts domain.DiffOptions) (*domain.DiffResult, error) {
+	switch opts.Mode {
+	case "":
+		opts.Mode = domain.DiffModeAuto
+	case domain.DiffModeAuto, domain.DiffModeText, domain.DiffModeBinary:
+	default:
+		return nil, fmt.Errorf("%w: %s", domain.ErrInvalidDiffMode, opts.Mode)
+	}
+
+	readerA, sideA, err := s.open(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	defer readerA.Close()
+	readerB, sideB, err := s.open(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	defer readerB.Close()
+
+	result := &domain.DiffResult{A: sideA, B: sideB, Mode: domain.DiffModeBinary}
+
+	tooLarge := sideA.Size > MaxEditableSize || sideB.Size > MaxEditableSize
+	if opts.Mode == domain.DiffModeBinary || (opts.Mode == domain.DiffModeAuto && tooLarge) {
+		result.FirstDifference, err = firstDifference(ctx, readerA, readerB)
+		if err != nil {
+			return nil, err
+		}
+		result.Identical = result.FirstDifference < 0
+		return result, nil
+	}
+	if tooLarge {
+		return nil, domain.ErrFileTooLarge
+	}
+
+	dataA, err := io.ReadAll(io.LimitReader(readerA, MaxEditableSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", sideA.Path, err)
+	}
+	dataB, err := io.ReadAll(io.LimitReader(readerB, MaxEditableSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", sideB.Path, err)
+	}
+	result.FirstDifference, _ = firstDifference(ctx, bytes.NewReader(dataA), bytes.NewReader(dataB))
+	result.Identical = result.FirstDifference < 0
+
+	textA, _, errA := decodeText(dataA)
+	textB, _, errB := decodeText(dataB)
+	if errA != nil || errB != nil {
+		if opts.Mode == domain.DiffModeText {
+			return nil, domain.ErrNotTextFile
+		}
+		return result, nil
+	}
+
+	result.Mode = domain.DiffModeText
+	if !result.Identical {
+		diffText(result, splitLines(textA), splitLines(textB), opts)
+	}
+	return result, nil
+}
+
+// open returns a reader for a file or a stored version
+func (s *DiffService) open(ctx context.Context, source domain.DiffSource) (io.ReadCloser, domain.DiffSide, error) {
+	if source.VersionID != "" {
+		file, version, err := s.versions.Open(source.VersionID)
+		if err != nil {
+			return nil, domain.DiffSide{}, err
+		}
+		return file, domain.DiffSide{
+			Path:      version.Path,
+			VersionID: version.ID,
+			Size:      version.Size,
+			ModTime:   version.ModTime,
+		}, nil
+	}
+
+	reader, info, err := s.explorer.OpenFile(ctx, source.Path)
+	if err != nil {
+		return nil, domain.DiffSide{}, err
+	}
+	return reader, domain.DiffSide{Path: source.Path, Size: info.Size, ModTime: info.ModTime}, nil
+}
+
+// diffText fills the hunks and the unified diff of two lists of lines
+func diffText(result *domain.DiffResult, a, b []string, opts domain.DiffOptions) {
+	var unified strings.Builder
+	fmt.Fprintf(&unified, "--- %s\n+++ %s\n", diffLabel(result.A), diffLabel(result.B))
+
+	matcher := difflib.NewMatcher(a, b)
+	for _, group := range matcher.GetGroupedOpCodes(opts.Context) {
+		// Sin cambios de líneas (p. ej. solo cambian los finales de línea) no hay bloque
+		if len(group) == 1 && group[0].Tag == 'e' {
+			continue
+		}
+		first, last := group[0], group[len(group)-1]
+		hunk := domain.DiffHunk{
+			OldStart: first.I1 + 1,
+			OldLines: last.I2 - first.I1,
+			NewStart: first.J1 + 1,
+			NewLines: last.J2 - first.J1,
+		}
+		// En formato unificado un rango vacío apunta a la línea anterior
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+
+		for _, op := range group {
+			if op.Tag == 'e' {
+				for i := op.I1; i < op.I2; i++ {
+					hunk.Lines = append(hunk.Lines, domain.DiffLine{
+						Kind:    domain.DiffContext,
+						Text:    a[i],
+						OldLine: i + 1,
+						NewLine: op.J1 + i - op.I1 + 1,
+					})
+				}
+				continue
+			}
+
+			deleted := make([]domain.DiffLine, 0, op.I2-op.I1)
+			for i := op.I1; i < op.I2; i++ {
+				deleted = append(deleted, domain.DiffLine{Kind: domain.DiffDelete, Text: a[i], OldLine: i + 1})
+			}
+			added := make([]domain.DiffLine, 0, op.J2-op.J1)
+			for j := op.J1; j < op.J2; j++ {
+				added = append(added, domain.DiffLine{Kind: domain.DiffAdd, Text: b[j], NewLine: j + 1})
+			}
+			// Las líneas reemplazadas se emparejan en orden para resaltar las palabras
+			if opts.Words && op.Tag == 'r' {
+				for k := 0; k < len(deleted) && k < len(added); k++ {
+					deleted[k].Segments, added[k].Segments = diffWords(deleted[k].Text, added[k].Text)
+				}
+			}
+			hunk.Lines = append(hunk.Lines, deleted...)
+			hunk.Lines = append(hunk.Lines, added...)
+			result.Removed += len(deleted)
+			result.Added += len(added)
+		}
+
+		fmt.Fprintf(&unified, "@@ -%s +%s @@\n", hunkRange(hunk.OldStart, hunk.OldLines), hunkRange(hunk.NewStart, hunk.NewLines))
+		for _, line := range hunk.Lines {
+			prefix := " "
+			switch line.Kind {
+			case domain.DiffDelete:
+				prefix = "-"
+			case domain.DiffAdd:
+				prefix = "+"
+			}
+			unified.WriteString(prefix + line.Text + "\n")
+		}
+		result.Hunks = append(result.Hunks, hunk)
+	}
+	result.Unified = unified.String()
+}
+
+// diffWords splits two versions of a line into segments, marking the changed words
+func diffWords(before, after string) ([]domain.DiffSegment, []domain.DiffSegment) {
+	a, b := splitWords(before), splitWords(after)
+	var oldSegments, newSegments []domain.DiffSegment
+	for _, op := range difflib.NewMatcherWithJunk(a, b, false, nil).GetOpCodes() {
+		changed := op.Tag != 'e'
+		oldSegments = appendSegment(oldSegments, strings.Join(a[op.I1:op.I2], ""), changed)
+		newSegments = appendSegment(newSegments, strings.Join(b[op.J1:op.J2], ""), changed)
+	}
+	return oldSegments, newSegments
+}
+
+func appendSegment(segments []domain.DiffSegment, text string, changed bool) []domain.DiffSegment {
+	if text == "" {
+		return segments
+	}
+	if n := len(segments); n > 0 && segments[n-1].Changed == changed {
+		segments[n-1].Text += text
+		return segments
+	}
+	return append(segments, domain.DiffSegment{Text: text, Changed: changed})
+}
+
+// splitWords splits a line into words, runs of spaces and single punctuation characters
+func splitWords(line string) []string {
+	var words []string
+	start := 0
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+
+	previous := -1
+	for i, r := range line {
+		current := class(r)
+		if i > start && (current != previous || current == 0) {
+			words = append(words, line[start:i])
+			start = i
+		}
+		previous = current
+	}
+	if start < len(line) {
+		words = append(words, line[start:])
+	}
+	return words
+}
+
+// splitLines splits text into lines without their line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+func diffLabel(side domain.DiffSide) string {
+	if side.VersionID != "" {
+		return side.Path + "@" + side.VersionID
+	}
+	return side.Path
+}
+
+// firstDifference returns the offset of the first byte that differs, or -1 when both
+// readers have the same content
+func firstDifference(ctx context.Context, a, b io.Reader) (int64, error) {
+	bufferA := make([]byte, 64*1024)
+	bufferB := make([]byte, 64*1024)
+
+	var offset int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		na, errA := io.ReadFull(a, bufferA)
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return 0, errA
+		}
+		nb, errB := io.ReadFull(b, bufferB)
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return 0, errB
+		}
+
+		n := min(na, nb)
+		for i := 0; i < n; i++ {
+			if bufferA[i] != bufferB[i] {
+				return offset + int64(i), nil
+			}
+		}
+		if na != nb {
+			return offset + int64(n), nil
+		}
+		if na < len(bufferA) {
+			return -1, nil
+		}
+		offset += int64(n)
+	}
+}
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const (
+	defaultUsageTTL      = 10 * time.Minute
+	maxCachedUsageScans  = 16
+	largestFilesPerDir   = 50
+	MaxLargestFilesQuery = largestFilesPerDir
+)
+
+// DiskUsageService computes ncdu-style recursive sizes. A scan keeps the whole directory
+// tree in memory so drilling down into any subdirectory is answered from the cache.
+type DiskUsageService struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	scans    map[string]*usageScan
+	inflight map[string]*usageScanCall
+}
+
+type usageScan struct {
+	root      string
+	tree      *usageDir
+	scannedAt time.Time
+}
+
+// usageScanCall lets concurrent requests for the same path share a single walk
+type usageScanCall struct {
+	done chan struct{}
+	scan *usageScan
+	err  error
+}
+
+// usageDir only stores directories plus the largest files of each one, which is enough to
+// draw the tree and to answer "largest files" exactly for up to largestFilesPerDir results.
+type usageDir struct {
+	name      string
+	apparent  int64
+	allocated int64
+	files     int64
+	dirs      int64
+	errors    int64
+	modTime   time.Time
+	children  []*usageDir
+	largest   []usageFile
+}
+
+type usageFile struct {
+	name      string
+	size      int64
+	allocated int64
+	modTime   time.Time
+}
+
+func NewDiskUsageService() *DiskUsageService {
+	return &DiskUsageService{
+		ttl:      defaultUsageTTL,
+		scans:    make(map[string]*usageScan),
+		inflight: make(map[string]*usageScanCall),
+	}
+}
+
+// Analyze returns the usage tree of path limited to depth levels and top children per level
+func (s *DiskUsageService) Analyze(ctx context.Context, path string, depth, top int, refresh bool) (*domain.DiskUsageReport, error) {
+	path = filepath.Clean(path)
+
+	scan, dir, cached, err := s.lookup(ctx, path, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.DiskUsageReport{
+		Root:      dir.toNode(path, depth, top),
+		ScannedAt: scan.scannedAt,
+		ScanRoot:  scan.root,
+		Cached:    cached,
+		Depth:     depth,
+		Top:       top,
+	}, nil
+}
+
+// LargestFiles returns the biggest files (by allocated size) anywhere under path
+func (s *DiskUsageService) LargestFiles(ctx context.Context, path string, limit int, refresh bool) ([]domain.LargeFile, error) {
+	path = filepath.Clean(path)
+	if limit <= 0 || limit > MaxLargestFilesQuery {
+		limit = MaxLargestFilesQuery
+	}
+
+	_, dir, _, err := s.lookup(ctx, path, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []domain.LargeFile
+	var collect func(d *usageDir, dirPath string)
+	collect = func(d *usageDir, dirPath string) {
+		for _, file := range d.largest {
+			files = append(files, domain.LargeFile{
+				Path:          filepath.Join(dirPath, file.name),
+				Name:          file.name,
+				Size:          file.size,
+				AllocatedSize: file.allocated,
+				ModTime:       file.modTime,
+			})
+		}
+		for _, child := range d.children {
+			collect(child, filepath.Join(dirPath, child.name))
+		}
+	}
+	collect(dir, path)
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].AllocatedSize > files[j].AllocatedSize
+	})
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	for i := range files {
+		files[i].ContentType = utils.GetContentType(files[i].Name)
+	}
+	return files, nil
+}
+
+// Invalidate drops cached scans that contain path, e.g. after a write
+func (s *DiskUsageService) Invalidate(path string) {
+	path = filepath.Clean(path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for root := range s.scans {
+		if isWithin(path, root) || isWithin(root, path) {
+			delete(s.scans, root)
+		}
+	}
+}
+
+// lookup finds path inside a fresh cached scan or scans it
+func (s *DiskUsageService) lookup(ctx context.Context, path string, refresh bool) (*usageScan, *usageDir, bool, error) {
+	if !refresh {
+		if scan, dir := s.cached(path); dir != nil {
+			return scan, dir, true, nil
+		}
+	}
+
+	scan, err := s.scan(ctx, path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return scan, scan.tree, false, nil
+}
+
+func (s *DiskUsageService) cached(path string) (*usageScan, *usageDir) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for root, scan := range s.scans {
+		if time.Since(scan.scannedAt) > s.ttl {
+			delete(s.scans, root)
+			continue
+		}
+		if !isWithin(path, root) {
+			continue
+		}
+		if dir := scan.tree.find(root, path); dir != nil {
+			return scan, dir
+		}
+	}
+	return nil, nil
+}
+
+func (s *DiskUsageService) scan(ctx context.Context, path string) (*usageScan, error) {
+	s.mu.Lock()
+	if call, ok := s.inflight[path]; ok {
+		s.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.scan, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &usageScanCall{done: make(chan struct{})}
+	s.inflight[path] = call
+	s.mu.Unlock()
+
+	call.scan, call.err = s.walk(ctx, path)
+
+	s.mu.Lock()
+	delete(s.inflight, path)
+	if call.err == nil {
+		// Un escaneo más amplio reemplaza a los que contiene
+		for root := range s.scans {
+			if isWithin(root, path) {
+				delete(s.scans, root)
+			}
+		}
+		s.scans[path] = call.scan
+		s.evictLocked()
+	}
+	s.mu.Unlock()
+	close(call.done)
+
+	return call.scan, call.err
+}
+
+func (s *DiskUsageService) evictLocked() {
+	for len(s.scans) > maxCachedUsageScans {
+		var oldest string
+		for root, scan := range s.scans {
+			if oldest == "" || scan.scannedAt.Before(s.scans[oldest].scannedAt) {
+				oldest = root
+			}
+		}
+		delete(s.scans, oldest)
+	}
+}
+
+func (s *DiskUsageService) walk(ctx context.Context, path string) (*usageScan, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access path %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("path %s is not a directory", path)
+	}
+
+	w := &usageWalker{
+		ctx:    ctx,
+		device: utils.GetDiskInfo(info).Device,
+		seen:   make(map[string]bool),
+	}
+	tree := &usageDir{name: filepath.Base(path), modTime: info.ModTime()}
+	if err := w.walkDir(path, tree); err != nil {
+		return nil, err
+	}
+
+	return &usageScan{root: path, tree: tree, scannedAt: time.Now()}, nil
+}
+
+type usageWalker struct {
+	ctx    context.Context
+	device uint64
+	// Los enlaces duros se cuentan una sola vez, como hace du
+	seen map[string]bool
+}
+
+func (w *usageWalker) walkDir(dirPath string, dir *usageDir) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		dir.errors++
+		return nil
+	}
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(dirPath, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			dir.errors++
+			continue
+		}
+		disk := utils.GetDiskInfo(info)
+
+		if entry.IsDir() {
+			// No cruzar a otros sistemas de archivos montados
+			if disk.Device != w.device {
+				continue
+			}
+			child := &usageDir{name: entry.Name(), modTime: info.ModTime(), allocated: disk.Allocated}
+			if err := w.walkDir(entryPath, child); err != nil {
+				return err
+			}
+			dir.children = append(dir.children, child)
+			dir.apparent += child.apparent
+			dir.allocated += child.allocated
+			dir.files += child.files
+			dir.dirs += child.dirs + 1
+			dir.errors += child.errors
+			if child.modTime.After(dir.modTime) {
+				dir.modTime = child.modTime
+			}
+			continue
+		}
+
+		if disk.Links > 1 {
+			id := utils.FileIdentity(info)
+			if w.seen[id] {
+				continue
+			}
+			w.seen[id] = true
+		}
+
+		dir.files++
+		dir.apparent += info.Size()
+		dir.allocated += disk.Allocated
+		dir.addLargest(usageFile{
+			name:      entry.Name(),
+			size:      info.Size(),
+			allocated: disk.Allocated,
+			modTime:   info.ModTime(),
+		})
+	}
+
+	return nil
+}
+
+// addLargest keeps the largestFilesPerDir biggest files, sorted by allocated size
+func (d *usageDir) addLargest(file usageFile) {
+	if len(d.largest) == largestFilesPerDir && file.allocated <= d.largest[len(d.largest)-1].allocated {
+		return
+	}
+
+	i := sort.Search(len(d.largest), func(i int) bool {
+		return d.largest[i].allocated < file.allocated
+	})
+	d.largest = append(d.largest, usageFile{})
+	copy(d.largest[i+1:], d.largest[i:])
+	d.largest[i] = file
+
+	if len(d.largest) > largestFilesPerDir {
+		d.largest = d.largest[:largestFilesPerDir]
+	}
+}
+
+// find descends from the scan root to path
+func (d *usageDir) find(root, path string) *usageDir {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil
+	}
+	if rel == "." {
+		return d
+	}
+
+	current := d
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		var next *usageDir
+		for _, child := range current.children {
+			if child.name == name {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// toNode converts the subtree into the API shape, mixing subdirectories and the largest
+// files of each directory and keeping only the top children per level.
+func (d *usageDir) toNode(path string, depth, top int) domain.UsageNode {
+	node := domain.UsageNode{
+		Path:          path,
+		Name:          filepath.Base(path),
+		IsDirectory:   true,
+		Size:          d.apparent,
+		AllocatedSize: d.allocated,
+		Files:         d.files,
+		Directories:   d.dirs,
+		ModTime:       d.modTime,
+		Errors:        d.errors,
+	}
+	if depth <= 0 {
+		return node
+	}
+
+	type candidate struct {
+		allocated int64
+		dir       *usageDir
+		file      *usageFile
+	}
+	candidates := make([]candidate, 0, len(d.children)+len(d.largest))
+	for _, child := range d.children {
+		candidates = append(candidates, candidate{allocated: child.allocated, dir: child})
+	}
+	for i := range d.largest {
+		candidates = append(candidates, candidate{allocated: d.largest[i].allocated, file: &d.largest[i]})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].allocated > candidates[j].allocated
+	})
+	if top > 0 && len(candidates) > top {
+		candidates = candidates[:top]
+	}
+
+	shownSize, shownAllocated, shownCount := int64(0), int64(0), int64(0)
+	for _, c := range candidates {
+		if c.dir != nil {
+			child := c.dir.toNode(filepath.Join(path, c.dir.name), depth-1, top)
+			node.Children = append(node.Children, child)
+			shownSize += child.Size
+			shownAllocated += child.AllocatedSize
+			shownCount += child.Files + child.Directories + 1
+			continue
+		}
+		node.Children = append(node.Children, domain.UsageNode{
+			Path:          filepath.Join(path, c.file.name),
+			Name:          c.file.name,
+			Size:          c.file.size,
+			AllocatedSize: c.file.allocated,
+			Files:         1,
+			ModTime:       c.file.modTime,
+		})
+		shownSize += c.file.size
+		shownAllocated += c.file.allocated
+		shownCount++
+	}
+
+	// Lo que no se muestra (hijos truncados y archivos pequeños) se agrupa en "other"
+	node.OtherCount = d.files + d.dirs - shownCount
+	node.OtherSize = d.apparent - shownSize
+	node.OtherAllocated = d.allocated - shownAllocated
+	return node
+}
+
+// isWithin reports whether path equals root or is below it
+func isWithin(path, root string) bool {
+	if path == root {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
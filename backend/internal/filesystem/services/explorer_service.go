@@ -3,26 +3,37 @@ package services
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/infortech07/cubert/internal/filesystem/domain"
 	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
 )
 
 type ExplorerService struct {
+	storage        storagedomain.Storage
 	scannerService *ScannerService
+	archiveService *ArchiveService
 }
 
-func NewExplorerService(scannerService *ScannerService) *ExplorerService {
+func NewExplorerService(storage storagedomain.Storage, scannerService *ScannerService, archiveService *ArchiveService) *ExplorerService {
 	return &ExplorerService{
+		storage:        storage,
 		scannerService: scannerService,
+		archiveService: archiveService,
 	}
 }
 
 func (e *ExplorerService) GetFileInfo(ctx context.Context, path string) (*domain.FileInfo, error) {
-	info, err := os.Stat(path)
+	if _, _, ok := domain.SplitArchivePath(path); ok {
+		return e.archiveService.Stat(ctx, path)
+	}
+
+	info, err := e.storage.Stat(ctx, path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file info: %w", err)
 	}
@@ -49,13 +60,140 @@ func (e *ExplorerService) GetFileInfo(ctx context.Context, path string) (*domain
 }
 
 func (e *ExplorerService) ListDirectory(ctx context.Context, path string) ([]domain.LocalFile, error) {
+	if _, _, ok := domain.SplitArchivePath(path); ok {
+		return e.archiveService.List(ctx, path)
+	}
 	return e.scannerService.GetDirectoryListing(ctx, path)
 }
 
+// OpenFile opens a regular file or a file inside an archive for reading. The reader
+// also implements io.Seeker when the content can be served with range requests.
+func (e *ExplorerService) OpenFile(ctx context.Context, path string) (io.ReadCloser, *domain.FileInfo, error) {
+	if _, _, ok := domain.SplitArchivePath(path); ok {
+		return e.archiveService.Open(ctx, path)
+	}
+
+	info, err := e.GetFileInfo(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDirectory {
+		return nil, nil, fmt.Errorf("%s is a directory", path)
+	}
+	file, err := e.storage.Open(ctx, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, info, nil
+}
+
+// WriteFile stores body at path. The data goes to a hidden sibling first and is
+// renamed into place, so readers never see a partial file.
+func (e *ExplorerService) WriteFile(ctx context.Context, path string, body io.Reader) (*domain.FileInfo, error) {
+	if _, _, ok := domain.SplitArchivePath(path); ok {
+		return nil, domain.ErrReadOnly
+	}
+	if info, err := e.storage.Stat(ctx, path); err == nil && info.IsDir() {
+		return nil, fmt.Errorf("%s: %w", path, storagedomain.ErrIsDir)
+	}
+
+	token, err := utils.GenerateToken(8)
+	if err != nil {
+		return nil, err
+	}
+	temp := filepath.Join(filepath.Dir(path), ".cubert-upload-"+token)
+	writer, err := e.storage.Create(ctx, temp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	_, err = io.Copy(writer, body)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = e.storage.Rename(ctx, temp, path)
+	}
+	if err != nil {
+		e.storage.Remove(ctx, temp)
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	return e.GetFileInfo(ctx, path)
+}
+
+func (e *ExplorerService) CreateDirectory(ctx context.Context, path string) (*domain.FileInfo, error) {
+	if _, _, ok := domain.SplitArchivePath(path); ok {
+		return nil, domain.ErrReadOnly
+	}
+	// Algunos servidores SFTP no distinguen "ya existe" de un fallo genérico
+	if _, err := e.storage.Stat(ctx, path); err == nil {
+		return nil, fmt.Errorf("%s: %w", path, fs.ErrExist)
+	}
+	if err := e.storage.Mkdir(ctx, path); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return e.GetFileInfo(ctx, path)
+}
+
+// Rename moves a file or folder within the same library root; it never replaces
+// an existing entry
+func (e *ExplorerService) Rename(ctx context.Context, oldPath, newPath string) (*domain.FileInfo, error) {
+	_, _, oldInArchive := domain.SplitArchivePath(oldPath)
+	_, _, newInArchive := domain.SplitArchivePath(newPath)
+	if oldInArchive || newInArchive {
+		return nil, domain.ErrReadOnly
+	}
+	if _, err := e.storage.Stat(ctx, newPath); err == nil {
+		return nil, fmt.Errorf("%s: %w", newPath, fs.ErrExist)
+	}
+	if err := e.storage.Rename(ctx, oldPath, newPath); err != nil {
+		return nil, fmt.Errorf("failed to rename: %w", err)
+	}
+	return e.GetFileInfo(ctx, newPath)
+}
+
+// RemoveAll deletes path and everything below it without going through the
+// trash; it is used for remote roots, which have no trash
+func (e *ExplorerService) RemoveAll(ctx context.Context, path string) error {
+	var paths []string
+	err := e.storage.Walk(ctx, path, func(entryPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, entryPath)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", path, err)
+	}
+
+	// El recorrido es en orden léxico: al revés se borra el contenido antes que su carpeta
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := e.storage.Remove(ctx, paths[i]); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", paths[i], err)
+		}
+	}
+	return nil
+}
+
+// DiskPath returns where the backend keeps path. It only differs from path inside
+// encrypted vaults, whose names are stored encrypted.
+func (e *ExplorerService) DiskPath(ctx context.Context, path string) (string, error) {
+	if resolver, ok := e.storage.(storagedomain.PathResolver); ok {
+		return resolver.DiskPath(ctx, path)
+	}
+	return path, nil
+}
+
+// IsRemote reports whether path belongs to a library root on remote storage
+func (e *ExplorerService) IsRemote(path string) bool {
+	lister, ok := e.storage.(storagedomain.MountLister)
+	return ok && lister.Mounted(path)
+}
+
 func (e *ExplorerService) SearchFiles(ctx context.Context, rootPath, query string) ([]domain.LocalFile, error) {
 	var results []domain.LocalFile
 
-	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+	err := e.storage.Walk(ctx, rootPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Continuar con otros archivos
 		}
@@ -93,15 +231,15 @@ func (e *ExplorerService) GetParentDirectory(path string) string {
 	return filepath.Dir(path)
 }
 
-func (e *ExplorerService) ValidatePath(path string) error {
+func (e *ExplorerService) ValidatePath(ctx context.Context, path string) error {
 	// Verificar que el path existe
-	_, err := os.Stat(path)
+	_, err := e.storage.Stat(ctx, path)
 	if err != nil {
 		return fmt.Errorf("path does not exist: %s", path)
 	}
 
 	// Verificar que es accesible
-	file, err := os.Open(path)
+	file, err := e.storage.Open(ctx, path)
 	if err != nil {
 		return fmt.Errorf("path is not accessible: %s", path)
 	}
@@ -110,7 +248,7 @@ func (e *ExplorerService) ValidatePath(path string) error {
 	return nil
 }
 
-func (e *ExplorerService) GetSystemRoots() ([]string, error) {
+func (e *ExplorerService) GetSystemRoots(ctx context.Context) ([]string, error) {
 	// En sistemas Unix, típicamente solo hay "/"
 	// En Windows serían las unidades C:, D:, etc.
 	roots := []string{"/"}
@@ -118,10 +256,14 @@ func (e *ExplorerService) GetSystemRoots() ([]string, error) {
 	// Verificar algunos directorios comunes
 	commonDirs := []string{"/home", "/usr", "/var", "/tmp"}
 	for _, dir := range commonDirs {
-		if _, err := os.Stat(dir); err == nil {
+		if _, err := e.storage.Stat(ctx, dir); err == nil {
 			roots = append(roots, dir)
 		}
 	}
 
+	if lister, ok := e.storage.(storagedomain.MountLister); ok {
+		roots = append(roots, lister.Mounts()...)
+	}
+
 	return roots, nil
 }
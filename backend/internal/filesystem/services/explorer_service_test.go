@@ -0,0 +1,298 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	storageservices "github.com/infortech07/cubert/internal/storage/services"
+)
+
+// newMemoryExplorer returns an explorer over an in-memory tree rooted at /lib:
+//
+//	/lib/docs/report.txt  /lib/docs/Notes.md  /lib/docs/.hidden
+//	/lib/photos/beach.jpg /lib/readme.txt
+func newMemoryExplorer(t *testing.T) (*ExplorerService, *storageservices.MemoryStorage, string) {
+	t.Helper()
+
+	storage := storageservices.NewMemoryStorage()
+	root := filepath.FromSlash("/lib")
+	files := map[string]string{
+		"docs/report.txt":  "quarterly report",
+		"docs/Notes.md":    "# notes",
+		"docs/.hidden":     "secret",
+		"photos/beach.jpg": "jpeg",
+		"readme.txt":       "hello",
+	}
+	for name, content := range files {
+		if err := storage.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(content)); err != nil {
+			t.Fatalf("seeding %s: %v", name, err)
+		}
+	}
+
+	scanner := NewScannerService(storage)
+	return NewExplorerService(storage, scanner, NewArchiveService(storage)), storage, root
+}
+
+func TestExplorerGetFileInfo(t *testing.T) {
+	explorer, _, root := newMemoryExplorer(t)
+	ctx := context.Background()
+
+	info, err := explorer.GetFileInfo(ctx, filepath.Join(root, "readme.txt"))
+	if err != nil {
+		t.Fatalf("GetFileInfo: %v", err)
+	}
+	if info.Name != "readme.txt" || info.Size != 5 || info.IsDirectory || info.Parent != root {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	dir, err := explorer.GetFileInfo(ctx, filepath.Join(root, "docs"))
+	if err != nil || !dir.IsDirectory {
+		t.Fatalf("GetFileInfo(docs) = %+v, %v; want a directory", dir, err)
+	}
+
+	if _, err := explorer.GetFileInfo(ctx, filepath.Join(root, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("GetFileInfo(missing) error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestExplorerListDirectorySkipsHidden(t *testing.T) {
+	explorer, _, root := newMemoryExplorer(t)
+
+	files, err := explorer.ListDirectory(context.Background(), filepath.Join(root, "docs"))
+	if err != nil {
+		t.Fatalf("ListDirectory: %v", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	if got, want := strings.Join(names, ","), "Notes.md,report.txt"; got != want {
+		t.Errorf("listed %q, want %q", got, want)
+	}
+}
+
+func TestExplorerOpenFile(t *testing.T) {
+	explorer, _, root := newMemoryExplorer(t)
+	ctx := context.Background()
+
+	reader, info, err := explorer.OpenFile(ctx, filepath.Join(root, "docs", "report.txt"))
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if string(data) != "quarterly report" || info.Size != int64(len(data)) {
+		t.Errorf("read %q (size %d)", data, info.Size)
+	}
+	if _, ok := reader.(io.Seeker); !ok {
+		t.Error("reader does not support range requests")
+	}
+
+	if _, _, err := explorer.OpenFile(ctx, filepath.Join(root, "docs")); err == nil {
+		t.Error("OpenFile on a directory succeeded")
+	}
+}
+
+func TestExplorerWriteFile(t *testing.T) {
+	explorer, storage, root := newMemoryExplorer(t)
+	ctx := context.Background()
+	path := filepath.Join(root, "docs", "report.txt")
+
+	info, err := explorer.WriteFile(ctx, path, strings.NewReader("new report"))
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if info.Size != int64(len("new report")) {
+		t.Errorf("size = %d after write", info.Size)
+	}
+
+	file, err := storage.Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if string(data) != "new report" {
+		t.Errorf("content = %q", data)
+	}
+
+	// El temporal de la subida no debe quedar en la carpeta
+	entries, err := storage.ReadDir(ctx, filepath.Join(root, "docs"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".cubert-upload-") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+
+	if _, err := explorer.WriteFile(ctx, filepath.Join(root, "docs"), strings.NewReader("x")); !errors.Is(err, storagedomain.ErrIsDir) {
+		t.Errorf("WriteFile over a directory error = %v, want ErrIsDir", err)
+	}
+	if _, err := explorer.WriteFile(ctx, filepath.Join(root, "a.zip!/inner.txt"), strings.NewReader("x")); !errors.Is(err, domain.ErrReadOnly) {
+		t.Errorf("WriteFile inside an archive error = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestExplorerCreateDirectory(t *testing.T) {
+	explorer, _, root := newMemoryExplorer(t)
+	ctx := context.Background()
+
+	info, err := explorer.CreateDirectory(ctx, filepath.Join(root, "music"))
+	if err != nil || !info.IsDirectory {
+		t.Fatalf("CreateDirectory = %+v, %v", info, err)
+	}
+	if _, err := explorer.CreateDirectory(ctx, filepath.Join(root, "music")); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("second CreateDirectory error = %v, want fs.ErrExist", err)
+	}
+}
+
+func TestExplorerRename(t *testing.T) {
+	explorer, _, root := newMemoryExplorer(t)
+	ctx := context.Background()
+
+	from, to := filepath.Join(root, "photos"), filepath.Join(root, "pictures")
+	if _, err := explorer.Rename(ctx, from, to); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := explorer.GetFileInfo(ctx, filepath.Join(to, "beach.jpg")); err != nil {
+		t.Errorf("renamed folder lost its content: %v", err)
+	}
+	if _, err := explorer.GetFileInfo(ctx, from); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("old path still exists: %v", err)
+	}
+
+	// Nunca se sustituye un destino existente
+	if _, err := explorer.Rename(ctx, filepath.Join(root, "readme.txt"), filepath.Join(root, "docs", "report.txt")); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Rename onto an existing file error = %v, want fs.ErrExist", err)
+	}
+}
+
+func TestExplorerRemoveAll(t *testing.T) {
+	explorer, _, root := newMemoryExplorer(t)
+	ctx := context.Background()
+
+	if err := explorer.RemoveAll(ctx, filepath.Join(root, "docs")); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	if _, err := explorer.GetFileInfo(ctx, filepath.Join(root, "docs")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("docs still exists: %v", err)
+	}
+	if _, err := explorer.GetFileInfo(ctx, filepath.Join(root, "readme.txt")); err != nil {
+		t.Errorf("sibling was removed: %v", err)
+	}
+}
+
+func TestExplorerSearchFiles(t *testing.T) {
+	explorer, _, root := newMemoryExplorer(t)
+
+	results, err := explorer.SearchFiles(context.Background(), root, "NOTE")
+	if err != nil {
+		t.Fatalf("SearchFiles: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != filepath.Join(root, "docs", "Notes.md") {
+		t.Errorf("results = %+v, want docs/Notes.md", results)
+	}
+
+	results, err = explorer.SearchFiles(context.Background(), root, "hidden")
+	if err != nil {
+		t.Fatalf("SearchFiles: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("hidden files were returned: %+v", results)
+	}
+}
+
+// seekOnlyStorage hides io.ReaderAt from its files, like the remote backends
+type seekOnlyStorage struct {
+	*storageservices.MemoryStorage
+}
+
+func (s seekOnlyStorage) Open(ctx context.Context, path string) (storagedomain.File, error) {
+	file, err := s.MemoryStorage.Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ storagedomain.File }{file}, nil
+}
+
+func TestExplorerBrowsesArchivesInStorage(t *testing.T) {
+	memory := storageservices.NewMemoryStorage()
+	root := filepath.FromSlash("/lib")
+
+	var zipped bytes.Buffer
+	writer := zip.NewWriter(&zipped)
+	for name, method := range map[string]uint16{"docs/stored.txt": zip.Store, "docs/deflated.txt": zip.Deflate} {
+		entry, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatalf("zip entry: %v", err)
+		}
+		io.WriteString(entry, strings.Repeat(name, 100))
+	}
+	writer.Close()
+
+	var tarred bytes.Buffer
+	tarWriter := tar.NewWriter(&tarred)
+	tarWriter.WriteHeader(&tar.Header{Name: "notes.txt", Mode: 0644, Size: 5, Typeflag: tar.TypeReg})
+	io.WriteString(tarWriter, "notes")
+	tarWriter.Close()
+
+	memory.WriteFile(filepath.Join(root, "bundle.zip"), zipped.Bytes())
+	memory.WriteFile(filepath.Join(root, "bundle.tar"), tarred.Bytes())
+
+	storage := seekOnlyStorage{memory}
+	explorer := NewExplorerService(storage, NewScannerService(storage), NewArchiveService(storage))
+	ctx := context.Background()
+
+	files, err := explorer.ListDirectory(ctx, domain.JoinArchivePath(filepath.Join(root, "bundle.zip"), "docs"))
+	if err != nil {
+		t.Fatalf("listing the zip: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("listed %d zip entries, want 2", len(files))
+	}
+
+	for archive, name := range map[string]string{
+		"bundle.zip": "docs/stored.txt",
+		"bundle.tar": "notes.txt",
+	} {
+		reader, _, err := explorer.OpenFile(ctx, domain.JoinArchivePath(filepath.Join(root, archive), name))
+		if err != nil {
+			t.Fatalf("opening %s in %s: %v", name, archive, err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		want := "notes"
+		if archive == "bundle.zip" {
+			want = strings.Repeat(name, 100)
+		}
+		if err != nil || string(data) != want {
+			t.Errorf("%s in %s read %q, %v", name, archive, data, err)
+		}
+	}
+
+	reader, _, err := explorer.OpenFile(ctx, domain.JoinArchivePath(filepath.Join(root, "bundle.zip"), "docs/deflated.txt"))
+	if err != nil {
+		t.Fatalf("opening the deflated entry: %v", err)
+	}
+	defer reader.Close()
+	if data, err := io.ReadAll(reader); err != nil || string(data) != strings.Repeat("docs/deflated.txt", 100) {
+		t.Errorf("deflated entry read %d bytes, %v", len(data), err)
+	}
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+)
+
+const (
+	// maxPreviewSize is how much of a file is rendered; the rest is left out
+	maxPreviewSize       = 1 << 20
+	maxCachedPreviews    = 64
+	maxPreviewCacheBytes = 32 << 20
+)
+
+// PreviewService renders files to HTML (or a table for CSV) so the client does not need
+// a renderer per format. Results are cached until the file changes.
+type PreviewService struct {
+	explorer  *ExplorerService
+	markdown  goldmark.Markdown
+	sanitizer *bluemonday.Policy
+	formatter *chromahtml.Formatter
+	style     *chroma.Style
+
+	mu         sync.Mutex
+	cache      map[string]*cachedPreview
+	cacheBytes int
+}
+
+type cachedPreview struct {
+	preview  *domain.Preview
+	bytes    int
+	lastUsed time.Time
+}
+
+func NewPreviewService(explorer *ExplorerService) *PreviewService {
+	return &PreviewService{
+		explorer:  explorer,
+		markdown:  goldmark.New(goldmark.WithExtensions(extension.GFM)),
+		sanitizer: bluemonday.UGCPolicy(),
+		formatter: chromahtml.New(chromahtml.WithClasses(false), chromahtml.WithLineNumbers(true), chromahtml.TabWidth(4)),
+		style:     styles.Get("github"),
+		cache:     make(map[string]*cachedPreview),
+	}
+}
+
+// Render returns the preview of a file; page and pageSize only apply to CSV files
+func (s *PreviewService) Render(ctx context.Context, path string, page, pageSize int) (*domain.Preview, error) {
+	info, err := s.explorer.GetFileInfo(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDirectory {
+		return nil, domain.ErrNoPreview
+	}
+
+	kind := previewKind(info.Name)
+	key := fmt.Sprintf("%s|%d|%d", path, info.ModTime.UnixNano(), info.Size)
+	if kind == domain.PreviewCSV {
+		key += fmt.Sprintf("|%d|%d", page, pageSize)
+	}
+	if preview := s.cached(key); preview != nil {
+		return preview, nil
+	}
+
+	data, truncated, err := s.read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	text, _, err := decodeText(data)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotTextFile) {
+			return nil, domain.ErrNoPreview
+		}
+		return nil, err
+	}
+
+	preview := &domain.Preview{
+		Path:      path,
+		Kind:      kind,
+		Size:      info.Size,
+		ModTime:   info.ModTime,
+		Truncated: truncated,
+	}
+	switch kind {
+	case domain.PreviewMarkdown:
+		var out bytes.Buffer
+		if err := s.markdown.Convert([]byte(text), &out); err != nil {
+			return nil, fmt.Errorf("failed to render markdown: %w", err)
+		}
+		preview.HTML = s.sanitizer.Sanitize(out.String())
+	case domain.PreviewCSV:
+		preview.Table = renderCSV(text, filepath.Ext(info.Name), page, pageSize)
+	case domain.PreviewJSON:
+		// Un JSON truncado no es válido; se resalta tal cual
+		var out bytes.Buffer
+		if !truncated && json.Indent(&out, []byte(text), "", "  ") == nil {
+			text = out.String()
+		}
+		preview.Language = "json"
+		preview.HTML, err = s.highlight(text, lexers.Get("json"))
+	case domain.PreviewXML:
+		if !truncated {
+			if pretty, err := indentXML(text); err == nil {
+				text = pretty
+			}
+		}
+		preview.Language = "xml"
+		preview.HTML, err = s.highlight(text, lexers.Get("xml"))
+	default:
+		lexer := lexers.Match(info.Name)
+		if lexer == nil || lexer.Config().Name == "plaintext" {
+			preview.Kind = domain.PreviewText
+			lexer = lexers.Fallback
+		} else {
+			preview.Language = strings.ToLower(lexer.Config().Name)
+		}
+		preview.HTML, err = s.highlight(text, lexer)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.store(key, preview)
+	return preview, nil
+}
+
+// read loads at most maxPreviewSize bytes, cut at the last complete line when truncated
+func (s *PreviewService) read(ctx context.Context, path string) ([]byte, bool, error) {
+	reader, _, err := s.explorer.OpenFile(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxPreviewSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(data) <= maxPreviewSize {
+		return data, false, nil
+	}
+
+	data = data[:maxPreviewSize]
+	if i := bytes.LastIndexByte(data, '\n'); i > 0 {
+		data = data[:i+1]
+	}
+	return data, true, nil
+}
+
+func (s *PreviewService) highlight(text string, lexer chroma.Lexer) (string, error) {
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, text)
+	if err != nil {
+		return "", fmt.Errorf("failed to highlight: %w", err)
+	}
+	var out strings.Builder
+	if err := s.formatter.Format(&out, s.style, iterator); err != nil {
+		return "", fmt.Errorf("failed to highlight: %w", err)
+	}
+	return out.String(), nil
+}
+
+func (s *PreviewService) cached(key string) *domain.Preview {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[key]
+	if !ok {
+		return nil
+	}
+	entry.lastUsed = time.Now()
+	return entry.preview
+}
+
+// store caches a preview, evicting the least recently used ones over the limits
+func (s *PreviewService) store(key string, preview *domain.Preview) {
+	size := len(preview.HTML)
+	if preview.Table != nil {
+		for _, row := range preview.Table.Rows {
+			for _, cell := range row {
+				size += len(cell)
+			}
+		}
+	}
+	if size > maxPreviewCacheBytes/4 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.cache[key]; ok {
+		s.cacheBytes -= old.bytes
+	}
+	s.cache[key] = &cachedPreview{preview: preview, bytes: size, lastUsed: time.Now()}
+	s.cacheBytes += size
+
+	for len(s.cache) > maxCachedPreviews || s.cacheBytes > maxPreviewCacheBytes {
+		oldest := ""
+		for candidate, entry := range s.cache {
+			if oldest == "" || entry.lastUsed.Before(s.cache[oldest].lastUsed) {
+				oldest = candidate
+			}
+		}
+		s.cacheBytes -= s.cache[oldest].bytes
+		delete(s.cache, oldest)
+	}
+}
+
+func previewKind(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return domain.PreviewMarkdown
+	case ".json", ".geojson", ".webmanifest":
+		return domain.PreviewJSON
+	case ".xml", ".xsd", ".xsl", ".xslt", ".rss", ".atom", ".plist":
+		return domain.PreviewXML
+	case ".csv", ".tsv":
+		return domain.PreviewCSV
+	}
+	return domain.PreviewCode
+}
+
+// renderCSV parses the text leniently and returns one page of rows below the header
+func renderCSV(text, ext string, page, pageSize int) *domain.CSVTable {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if strings.EqualFold(ext, ".tsv") {
+		reader.Comma = '\t'
+	}
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Una línea mal formada no impide mostrar el resto
+			continue
+		}
+		records = append(records, record)
+	}
+
+	table := &domain.CSVTable{Page: page, PageSize: pageSize, Rows: [][]string{}}
+	if len(records) == 0 {
+		return table
+	}
+	table.Header = records[0]
+	rows := records[1:]
+	table.TotalRows = len(rows)
+
+	start := (page - 1) * pageSize
+	if start < len(rows) {
+		end := min(start+pageSize, len(rows))
+		table.Rows = rows[start:end]
+	}
+	return table
+}
+
+// indentXML re-indents an XML document. Raw tokens keep namespace prefixes as written.
+func indentXML(text string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	decoder.Strict = false
+
+	var out strings.Builder
+	depth := 0
+	// inline indica que lo siguiente continúa en la línea actual (tras una etiqueta de apertura)
+	inline := false
+	newline := func() {
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		out.WriteString(strings.Repeat("  ", depth))
+	}
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			newline()
+			out.WriteString("<" + xmlName(t.Name))
+			for _, attr := range t.Attr {
+				out.WriteString(" " + xmlName(attr.Name) + `="`)
+				xml.EscapeText(&out, []byte(attr.Value))
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+			depth++
+			inline = true
+		case xml.EndElement:
+			depth = max(depth-1, 0)
+			if !inline {
+				newline()
+			}
+			out.WriteString("</" + xmlName(t.Name) + ">")
+			inline = false
+		case xml.CharData:
+			data := bytes.TrimSpace(t)
+			if len(data) == 0 {
+				continue
+			}
+			if !inline {
+				newline()
+			}
+			xml.EscapeText(&out, data)
+			inline = true
+		case xml.Comment:
+			newline()
+			out.WriteString("<!--" + string(t) + "-->")
+			inline = false
+		case xml.ProcInst:
+			newline()
+			out.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+			inline = false
+		case xml.Directive:
+			newline()
+			out.WriteString("<!" + string(t) + ">")
+			inline = false
+		}
+	}
+	return out.String(), nil
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
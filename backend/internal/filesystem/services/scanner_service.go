@@ -10,21 +10,36 @@ import (
 
 	"github.com/infortech07/cubert/internal/filesystem/domain"
 	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
 )
 
 type ScannerService struct {
+	storage    storagedomain.Storage
 	maxDepth   int
 	skipHidden bool
 }
 
-func NewScannerService() *ScannerService {
+func NewScannerService(storage storagedomain.Storage) *ScannerService {
 	return &ScannerService{
+		storage:    storage,
 		maxDepth:   10,
 		skipHidden: true,
 	}
 }
 
+// ScanOptions tunes a single scan without changing the defaults of the service
+type ScanOptions struct {
+	// MaxDepth limits recursion; 0 means unlimited
+	MaxDepth   int
+	SkipHidden bool
+}
+
 func (s *ScannerService) ScanDirectory(ctx context.Context, path string) (*domain.ScanResult, error) {
+	return s.ScanTree(ctx, path, ScanOptions{MaxDepth: s.maxDepth, SkipHidden: s.skipHidden})
+}
+
+// ScanTree scans path recursively with explicit options and stops when ctx is done
+func (s *ScannerService) ScanTree(ctx context.Context, path string, opts ScanOptions) (*domain.ScanResult, error) {
 	startTime := time.Now()
 
 	result := &domain.ScanResult{
@@ -36,7 +51,7 @@ func (s *ScannerService) ScanDirectory(ctx context.Context, path string) (*domai
 	}
 
 	// Verificar que el path existe
-	info, err := os.Stat(path)
+	info, err := s.storage.Stat(ctx, path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to access path %s: %w", path, err)
 	}
@@ -46,7 +61,10 @@ func (s *ScannerService) ScanDirectory(ctx context.Context, path string) (*domai
 	}
 
 	// Escanear el directorio
-	err = s.scanDirectoryRecursive(path, result, 0)
+	err = s.scanDirectoryRecursive(ctx, path, result, 0, opts)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if err != nil {
 		result.Errors = append(result.Errors, err.Error())
 		result.ErrorCount++
@@ -61,19 +79,22 @@ func (s *ScannerService) ScanDirectory(ctx context.Context, path string) (*domai
 	return result, nil
 }
 
-func (s *ScannerService) scanDirectoryRecursive(dirPath string, result *domain.ScanResult, depth int) error {
-	if depth > s.maxDepth {
-		return fmt.Errorf("maximum depth exceeded: %d", s.maxDepth)
+func (s *ScannerService) scanDirectoryRecursive(ctx context.Context, dirPath string, result *domain.ScanResult, depth int, opts ScanOptions) error {
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return fmt.Errorf("maximum depth exceeded: %d", opts.MaxDepth)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	entries, err := os.ReadDir(dirPath)
+	entries, err := s.storage.ReadDir(ctx, dirPath)
 	if err != nil {
 		return fmt.Errorf("failed to read directory %s: %w", dirPath, err)
 	}
 
 	for _, entry := range entries {
 		// Saltar archivos ocultos si está configurado
-		if s.skipHidden && strings.HasPrefix(entry.Name(), ".") {
+		if opts.SkipHidden && strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 
@@ -100,7 +121,7 @@ func (s *ScannerService) scanDirectoryRecursive(dirPath string, result *domain.S
 			result.Directories = append(result.Directories, localFile)
 
 			// Escanear subdirectorio recursivamente
-			if err := s.scanDirectoryRecursive(entryPath, result, depth+1); err != nil {
+			if err := s.scanDirectoryRecursive(ctx, entryPath, result, depth+1, opts); err != nil {
 				result.Errors = append(result.Errors, err.Error())
 				result.ErrorCount++
 			}
@@ -113,7 +134,7 @@ func (s *ScannerService) scanDirectoryRecursive(dirPath string, result *domain.S
 }
 
 func (s *ScannerService) GetDirectoryListing(ctx context.Context, path string) ([]domain.LocalFile, error) {
-	entries, err := os.ReadDir(path)
+	entries, err := s.storage.ReadDir(ctx, path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read directory %s: %w", path, err)
 	}
@@ -142,6 +163,9 @@ func (s *ScannerService) GetDirectoryListing(ctx context.Context, path string) (
 			ContentType: utils.GetContentType(entry.Name()),
 			Permissions: info.Mode().String(),
 		}
+		if _, ok := domain.ArchiveFormatOf(entry.Name()); ok && info.Mode().IsRegular() {
+			localFile.IsArchive = true
+		}
 
 		files = append(files, localFile)
 	}
@@ -154,7 +178,7 @@ func (s *ScannerService) GetDirectoryStats(ctx context.Context, path string) (*d
 	var totalSize int64
 	var lastModified time.Time
 
-	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+	err := s.storage.Walk(ctx, path, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Continuar con otros archivos
 		}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	storageservices "github.com/infortech07/cubert/internal/storage/services"
+)
+
+func newMemoryScanner(t *testing.T) (*ScannerService, string) {
+	t.Helper()
+
+	storage := storageservices.NewMemoryStorage()
+	root := filepath.FromSlash("/lib")
+	files := map[string]string{
+		"a.txt":         "12345",
+		".hidden":       "123",
+		"one/b.txt":     "1234567890",
+		"one/two/c.txt": "1",
+		"empty/":        "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		var err error
+		if name[len(name)-1] == '/' {
+			err = storage.MkdirAll(path)
+		} else {
+			err = storage.WriteFile(path, []byte(content))
+		}
+		if err != nil {
+			t.Fatalf("seeding %s: %v", name, err)
+		}
+	}
+	return NewScannerService(storage), root
+}
+
+func TestScanTree(t *testing.T) {
+	scanner, root := newMemoryScanner(t)
+
+	result, err := scanner.ScanTree(context.Background(), root, ScanOptions{SkipHidden: true})
+	if err != nil {
+		t.Fatalf("ScanTree: %v", err)
+	}
+	if result.TotalFiles != 3 || result.TotalSize != 16 {
+		t.Errorf("got %d files / %d bytes, want 3 / 16", result.TotalFiles, result.TotalSize)
+	}
+	if len(result.Directories) != 3 {
+		t.Errorf("got %d directories, want 3", len(result.Directories))
+	}
+	if result.ErrorCount != 0 {
+		t.Errorf("unexpected errors: %v", result.Errors)
+	}
+
+	withHidden, err := scanner.ScanTree(context.Background(), root, ScanOptions{})
+	if err != nil {
+		t.Fatalf("ScanTree: %v", err)
+	}
+	if withHidden.TotalFiles != 4 {
+		t.Errorf("got %d files including hidden ones, want 4", withHidden.TotalFiles)
+	}
+}
+
+func TestScanTreeMaxDepth(t *testing.T) {
+	scanner, root := newMemoryScanner(t)
+
+	result, err := scanner.ScanTree(context.Background(), root, ScanOptions{MaxDepth: 1, SkipHidden: true})
+	if err != nil {
+		t.Fatalf("ScanTree: %v", err)
+	}
+	// one/two está a profundidad 2: su contenido no se recorre y queda anotado como error
+	if result.TotalFiles != 2 {
+		t.Errorf("got %d files, want 2", result.TotalFiles)
+	}
+	if result.ErrorCount != 1 {
+		t.Errorf("got %d errors, want 1: %v", result.ErrorCount, result.Errors)
+	}
+}
+
+func TestScanTreeRejectsFilesAndMissingPaths(t *testing.T) {
+	scanner, root := newMemoryScanner(t)
+	ctx := context.Background()
+
+	if _, err := scanner.ScanTree(ctx, filepath.Join(root, "a.txt"), ScanOptions{}); err == nil {
+		t.Error("scanning a file succeeded")
+	}
+	if _, err := scanner.ScanTree(ctx, filepath.Join(root, "missing"), ScanOptions{}); err == nil {
+		t.Error("scanning a missing path succeeded")
+	}
+}
+
+func TestScanTreeStopsWhenCancelled(t *testing.T) {
+	scanner, root := newMemoryScanner(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := scanner.ScanTree(ctx, root, ScanOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("ScanTree error = %v, want context.Canceled", err)
+	}
+}
+
+func TestGetDirectoryStats(t *testing.T) {
+	scanner, root := newMemoryScanner(t)
+
+	stats, err := scanner.GetDirectoryStats(context.Background(), root)
+	if err != nil {
+		t.Fatalf("GetDirectoryStats: %v", err)
+	}
+	// La raíz cuenta como directorio; el archivo oculto no
+	if stats.TotalFiles != 3 || stats.TotalDirectories != 4 || stats.TotalSize != 16 {
+		t.Errorf("stats = %+v, want 3 files, 4 directories, 16 bytes", stats)
+	}
+	if stats.LastModified.IsZero() {
+		t.Error("LastModified not set")
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+// TrashService moves deleted files into the data directory so they can be restored.
+// Items are renamed when possible and copied when the trash lives on another filesystem.
+type TrashService struct {
+	filesDir  string
+	indexPath string
+
+	mu      sync.Mutex
+	entries []domain.TrashEntry
+}
+
+func NewTrashService(dataDir string) (*TrashService, error) {
+	dir := filepath.Join(dataDir, "trash")
+	filesDir := filepath.Join(dir, "files")
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	s := &TrashService{
+		filesDir:  filesDir,
+		indexPath: filepath.Join(dir, "index.json"),
+	}
+	if err := utils.LoadJSONFile(s.indexPath, &s.entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Move sends path to the trash on behalf of userID
+func (s *TrashService) Move(ctx context.Context, path, userID string) (*domain.TrashEntry, error) {
+	path = filepath.Clean(path)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", path, err)
+	}
+
+	entry := domain.TrashEntry{
+		ID:           uuid.NewString(),
+		OriginalPath: path,
+		Name:         filepath.Base(path),
+		Size:         info.Size(),
+		IsDirectory:  info.IsDir(),
+		DeletedAt:    time.Now(),
+		DeletedBy:    userID,
+	}
+
+	if err := movePath(path, filepath.Join(s.filesDir, entry.ID)); err != nil {
+		return nil, fmt.Errorf("failed to move %s to trash: %w", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+	if err := utils.SaveJSONFile(s.indexPath, s.entries); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// List returns the entries deleted by userID, or every entry when userID is empty
+func (s *TrashService) List(userID string) []domain.TrashEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]domain.TrashEntry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		if userID == "" || entry.DeletedBy == userID {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DeletedAt.After(result[j].DeletedAt)
+	})
+	return result
+}
+
+func (s *TrashService) Get(id string) (*domain.TrashEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.entries {
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+	return nil, domain.ErrTrashEntryNotFound
+}
+
+// Restore moves an entry back to its original location
+func (s *TrashService) Restore(ctx context.Context, id string) (*domain.TrashEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(id)
+	if index < 0 {
+		return nil, domain.ErrTrashEntryNotFound
+	}
+	entry := s.entries[index]
+
+	if _, err := os.Lstat(entry.OriginalPath); err == nil {
+		return nil, domain.ErrRestoreConflict
+	}
+	if err := os.MkdirAll(filepath.Dir(entry.OriginalPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to recreate parent directory: %w", err)
+	}
+	if err := movePath(filepath.Join(s.filesDir, entry.ID), entry.OriginalPath); err != nil {
+		return nil, fmt.Errorf("failed to restore %s: %w", entry.OriginalPath, err)
+	}
+
+	s.entries = append(s.entries[:index], s.entries[index+1:]...)
+	if err := utils.SaveJSONFile(s.indexPath, s.entries); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Purge deletes an entry permanently
+func (s *TrashService) Purge(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(id)
+	if index < 0 {
+		return domain.ErrTrashEntryNotFound
+	}
+
+	if err := os.RemoveAll(filepath.Join(s.filesDir, id)); err != nil {
+		return fmt.Errorf("failed to delete trash entry: %w", err)
+	}
+
+	s.entries = append(s.entries[:index], s.entries[index+1:]...)
+	return utils.SaveJSONFile(s.indexPath, s.entries)
+}
+
+func (s *TrashService) indexOf(id string) int {
+	for i, entry := range s.entries {
+		if entry.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// movePath renames src to dst, falling back to copy+delete across filesystems
+func movePath(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := copyPath(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+func copyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+
+	case info.IsDir():
+		if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyPath(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return os.Chtimes(dst, info.ModTime(), info.ModTime())
+
+	default:
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/infortech07/cubert/internal/filesystem/domain"
+	quotadomain "github.com/infortech07/cubert/internal/quota/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+// VersionService keeps previous contents of files that are overwritten. Every library
+// root has its own store with an index and content-addressed blobs, so saving the same
+// content twice costs no space.
+type VersionService struct {
+	retention domain.VersionRetention
+	stores    []*versionStore
+}
+
+// versionStore holds the versions of the files below one root
+type versionStore struct {
+	root      string
+	blobsDir  string
+	indexPath string
+
+	mu       sync.Mutex
+	versions []domain.Version
+}
+
+// versionIndex is the on-disk format of a store
+type versionIndex struct {
+	Root     string           `json:"root"`
+	Versions []domain.Version `json:"versions"`
+}
+
+func NewVersionService(dataDir string, roots []string, retention domain.VersionRetention) (*VersionService, error) {
+	s := &VersionService{retention: retention}
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		sum := sha256.Sum256([]byte(root))
+		dir := filepath.Join(dataDir, "versions", hex.EncodeToString(sum[:6]))
+
+		store := &versionStore{
+			root:      root,
+			blobsDir:  filepath.Join(dir, "blobs"),
+			indexPath: filepath.Join(dir, "index.json"),
+		}
+		if err := os.MkdirAll(store.blobsDir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create versions directory: %w", err)
+		}
+		var index versionIndex
+		if err := utils.LoadJSONFile(store.indexPath, &index); err != nil {
+			return nil, err
+		}
+		store.versions = index.Versions
+		s.stores = append(s.stores, store)
+	}
+
+	// Las raíces más largas primero para que gane la más específica
+	sort.Slice(s.stores, func(i, j int) bool {
+		return len(s.stores[i].root) > len(s.stores[j].root)
+	})
+	return s, nil
+}
+
+// Snapshot records the current content of path before it is overwritten. Paths outside
+// the roots and missing files are ignored and return nil.
+func (s *VersionService) Snapshot(ctx context.Context, path, userID, reason string) (*domain.Version, error) {
+	path = filepath.Clean(path)
+	store := s.storeFor(path)
+	if store == nil {
+		return nil, nil
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	version, err := store.snapshot(ctx, path, userID, reason)
+	if err != nil || version == nil {
+		return version, err
+	}
+	if err := store.prune(path, s.retention); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// List returns the versions of path, newest first
+func (s *VersionService) List(path string) ([]domain.Version, error) {
+	path = filepath.Clean(path)
+	store := s.storeFor(path)
+	if store == nil {
+		return nil, domain.ErrNotVersioned
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.versionsOf(path), nil
+}
+
+func (s *VersionService) Get(id string) (*domain.Version, error) {
+	_, version, err := s.find(id)
+	return version, err
+}
+
+// Open returns the stored content of a version
+func (s *VersionService) Open(id string) (*os.File, *domain.Version, error) {
+	store, version, err := s.find(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(store.blobPath(version.Hash))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open version %s: %w", id, err)
+	}
+	return file, version, nil
+}
+
+// Restore writes a version back to its path. The content being replaced is kept as a
+// new version, so a restore can be undone.
+func (s *VersionService) Restore(ctx context.Context, id, userID string) (*domain.Version, error) {
+	store, version, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, err := store.snapshot(ctx, version.Path, userID, domain.VersionReasonRestore); err != nil {
+		return nil, err
+	}
+	if err := store.restore(version); err != nil {
+		return nil, err
+	}
+	if err := store.prune(version.Path, s.retention); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// VersionUsage reports the bytes the versions of a root take on disk; they do not count
+// as files. Blobs shared by several versions count once in the total and once for each
+// user who caused them.
+func (s *VersionService) VersionUsage(root string) (quotadomain.Usage, map[string]quotadomain.Usage) {
+	root = filepath.Clean(root)
+	var total quotadomain.Usage
+	byUser := make(map[string]quotadomain.Usage)
+	for _, store := range s.stores {
+		if store.root != root {
+			continue
+		}
+
+		store.mu.Lock()
+		blobs := make(map[string]bool)
+		userBlobs := make(map[string]bool)
+		for _, version := range store.versions {
+			if !blobs[version.Hash] {
+				blobs[version.Hash] = true
+				total = total.Add(quotadomain.Usage{Bytes: version.Size})
+			}
+			if key := version.CreatedBy + "/" + version.Hash; !userBlobs[key] {
+				userBlobs[key] = true
+				byUser[version.CreatedBy] = byUser[version.CreatedBy].Add(quotadomain.Usage{Bytes: version.Size})
+			}
+		}
+		store.mu.Unlock()
+	}
+	return total, byUser
+}
+
+func (s *VersionService) storeFor(path string) *versionStore {
+	for _, store := range s.stores {
+		if isWithinRoot(path, store.root) {
+			return store
+		}
+	}
+	return nil
+}
+
+func (s *VersionService) find(id string) (*versionStore, *domain.Version, error) {
+	for _, store := range s.stores {
+		store.mu.Lock()
+		for _, version := range store.versions {
+			if version.ID == id {
+				store.mu.Unlock()
+				return store, &version, nil
+			}
+		}
+		store.mu.Unlock()
+	}
+	return nil, nil, domain.ErrVersionNotFound
+}
+
+// snapshot stores the content of path as a blob and appends a version. Consecutive saves
+// of identical content do not add a new version.
+func (s *versionStore) snapshot(ctx context.Context, path, userID, reason string) (*domain.Version, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	hash, err := s.storeBlob(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing := s.versionsOf(path); len(existing) > 0 && existing[0].Hash == hash {
+		return &existing[0], nil
+	}
+
+	version := domain.Version{
+		ID:        uuid.NewString(),
+		Path:      path,
+		Hash:      hash,
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		CreatedAt: time.Now(),
+		CreatedBy: userID,
+		Reason:    reason,
+	}
+	s.versions = append(s.versions, version)
+	return &version, s.save()
+}
+
+// storeBlob copies path into the blob store under its SHA-256 and returns the hash
+func (s *versionStore) storeBlob(ctx context.Context, path string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer in.Close()
+
+	temp, err := os.CreateTemp(s.blobsDir, ".blob-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create version blob: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(temp, hasher), in)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", path, err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	target := s.blobPath(hash)
+	if _, err := os.Stat(target); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return "", fmt.Errorf("failed to create version blob: %w", err)
+	}
+	if err := os.Rename(temp.Name(), target); err != nil {
+		return "", fmt.Errorf("failed to store version blob: %w", err)
+	}
+	return hash, nil
+}
+
+// restore replaces the file atomically with the content of version
+func (s *versionStore) restore(version *domain.Version) error {
+	in, err := os.Open(s.blobPath(version.Hash))
+	if err != nil {
+		return fmt.Errorf("failed to open version %s: %w", version.ID, err)
+	}
+	defer in.Close()
+
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(version.Path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(version.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to recreate parent directory: %w", err)
+	}
+	temp, err := os.CreateTemp(dir, ".cubert-restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to restore %s: %w", version.Path, err)
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = io.Copy(temp, in)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), version.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore %s: %w", version.Path, err)
+	}
+	return nil
+}
+
+// prune applies the retention rules to the versions of path and deletes blobs no
+// version refers to anymore
+func (s *versionStore) prune(path string, retention domain.VersionRetention) error {
+	dailyLimit := time.Now().AddDate(0, 0, -retention.KeepDailyDays)
+	days := make(map[string]bool)
+	drop := make(map[string]bool)
+	for i, version := range s.versionsOf(path) {
+		day := version.CreatedAt.Local().Format("2006-01-02")
+		keep := i < retention.KeepLast
+		if version.CreatedAt.After(dailyLimit) && !days[day] {
+			days[day] = true
+			keep = true
+		}
+		if !keep {
+			drop[version.ID] = true
+		}
+	}
+	if len(drop) == 0 {
+		return nil
+	}
+
+	kept := s.versions[:0]
+	var dropped []domain.Version
+	for _, version := range s.versions {
+		if drop[version.ID] {
+			dropped = append(dropped, version)
+			continue
+		}
+		kept = append(kept, version)
+	}
+	s.versions = kept
+	if err := s.save(); err != nil {
+		return err
+	}
+
+	referenced := make(map[string]bool, len(s.versions))
+	for _, version := range s.versions {
+		referenced[version.Hash] = true
+	}
+	for _, version := range dropped {
+		if !referenced[version.Hash] {
+			os.Remove(s.blobPath(version.Hash))
+			referenced[version.Hash] = true
+		}
+	}
+	return nil
+}
+
+// versionsOf returns the versions of path, newest first
+func (s *versionStore) versionsOf(path string) []domain.Version {
+	result := make([]domain.Version, 0)
+	for _, version := range s.versions {
+		if version.Path == path {
+			result = append(result, version)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+func (s *versionStore) blobPath(hash string) string {
+	return filepath.Join(s.blobsDir, hash[:2], hash)
+}
+
+func (s *versionStore) save() error {
+	return utils.SaveJSONFile(s.indexPath, versionIndex{Root: s.root, Versions: s.versions})
+}
+
+// isWithinRoot reports whether path is root or below it
+func isWithinRoot(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
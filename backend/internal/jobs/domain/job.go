@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+func (s Status) IsFinished() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCancelled
+}
+
+type Progress struct {
+	Current int64   `json:"current"`
+	Total   int64   `json:"total"`
+	Unit    string  `json:"unit,omitempty"`
+	Percent float64 `json:"percent"`
+	Message string  `json:"message,omitempty"`
+	// ETASeconds is extrapolated from the elapsed time and the percentage done
+	ETASeconds *int64 `json:"eta_seconds,omitempty"`
+}
+
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	UserID     string          `json:"user_id"`
+	Status     Status          `json:"status"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	Progress   Progress        `json:"progress"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Attempts   int             `json:"attempts"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Event is pushed to SSE subscribers whenever a job changes
+type Event struct {
+	Type string `json:"type"`
+	Job  Job    `json:"job"`
+}
+
+const (
+	EventCreated  = "created"
+	EventProgress = "progress"
+	EventStatus   = "status"
+	EventDeleted  = "deleted"
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrUnknownJobType  = errors.New("unknown job type")
+	ErrQueueFull       = errors.New("job queue is full")
+	ErrJobNotFinished  = errors.New("job has not finished")
+	ErrJobNotCompleted = errors.New("job has not completed")
+)
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/jobs/domain"
+	"github.com/infortech07/cubert/internal/jobs/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const sseKeepAlive = 15 * time.Second
+
+type JobHandler struct {
+	jobService *services.JobService
+}
+
+func NewJobHandler(jobService *services.JobService) *JobHandler {
+	return &JobHandler{
+		jobService: jobService,
+	}
+}
+
+// ListJobs returns the caller's jobs; admins may pass scope=all. Filters: type, status.
+func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	jobs := h.jobService.List(scopeUserID(r, user), r.URL.Query().Get("type"), domain.Status(r.URL.Query().Get("status")))
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"jobs":  jobs,
+		"count": len(jobs),
+	})
+}
+
+// GetJob returns status, progress and, once completed, the result
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, job)
+}
+
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobService.Cancel(job.ID)
+	if err != nil {
+		WriteJobError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusAccepted, job)
+}
+
+func (h *JobHandler) DeleteJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.jobService.Delete(job.ID); err != nil {
+		WriteJobError(w, err)
+		return
+	}
+
+	utils.WriteMessageResponse(w, http.StatusOK, "Job deleted")
+}
+
+// Events streams job changes as Server-Sent Events. With {id} only that job is streamed
+// and the stream ends once it finishes.
+func (h *JobHandler) Events(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+	userID := scopeUserID(r, user)
+
+	var only *domain.Job
+	if chi.URLParam(r, "id") != "" {
+		job, ok := h.job(w, r)
+		if !ok {
+			return
+		}
+		only = job
+	}
+
+	// Los flujos SSE no deben cortarse por el WriteTimeout del servidor
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	events, unsubscribe := h.jobService.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Estado inicial para que el cliente no dependa de eventos previos a la conexión
+	if only != nil {
+		current, err := h.jobService.Get(only.ID)
+		if err != nil {
+			return
+		}
+		current.Result = nil
+		writeEvent(w, domain.Event{Type: domain.EventStatus, Job: *current})
+		controller.Flush()
+		if current.Status.IsFinished() {
+			return
+		}
+	}
+	controller.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			controller.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if only != nil && event.Job.ID != only.ID {
+				continue
+			}
+			if userID != "" && event.Job.UserID != userID {
+				continue
+			}
+			writeEvent(w, event)
+			controller.Flush()
+			if only != nil && event.Job.Status.IsFinished() {
+				return
+			}
+		}
+	}
+}
+
+// job loads the job in the URL, hiding jobs of other users from non-admins
+func (h *JobHandler) job(w http.ResponseWriter, r *http.Request) (*domain.Job, bool) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	job, err := h.jobService.Get(chi.URLParam(r, "id"))
+	if err == nil && !user.IsAdmin() && job.UserID != user.ID {
+		err = domain.ErrJobNotFound
+	}
+	if err != nil {
+		WriteJobError(w, err)
+		return nil, false
+	}
+	return job, true
+}
+
+func scopeUserID(r *http.Request, user *authdomain.User) string {
+	if user.IsAdmin() && r.URL.Query().Get("scope") == "all" {
+		return ""
+	}
+	return user.ID
+}
+
+func writeEvent(w http.ResponseWriter, event domain.Event) {
+	data, err := json.Marshal(event.Job)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\nid: %s\ndata: %s\n\n", event.Type, event.Job.ID, data)
+}
+
+// WriteJobAccepted answers 202 with the queued job and where to follow it
+func WriteJobAccepted(w http.ResponseWriter, job *domain.Job) {
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	utils.WriteJSONResponse(w, http.StatusAccepted, job)
+}
+
+// WriteJobError maps job errors to HTTP status codes
+func WriteJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrJobNotFound):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Job not found", err)
+	case errors.Is(err, domain.ErrJobNotFinished), errors.Is(err, domain.ErrJobNotCompleted):
+		utils.WriteErrorResponse(w, http.StatusConflict, "Job still running", err)
+	case errors.Is(err, domain.ErrQueueFull):
+		utils.WriteErrorResponse(w, http.StatusServiceUnavailable, "Too many queued jobs", err)
+	case errors.Is(err, domain.ErrUnknownJobType):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid job type", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Job operation failed", err)
+	}
+}
@@ -0,0 +1,503 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/infortech07/cubert/internal/jobs/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+const (
+	queueSize           = 1024
+	subscriberBuffer    = 64
+	persistInterval     = 2 * time.Second
+	defaultJobRetention = 7 * 24 * time.Hour
+)
+
+// Runner executes one job. It must stop promptly when ctx is cancelled and may report
+// progress through p. The returned value is stored as the job result.
+type Runner func(ctx context.Context, job *domain.Job, p *Progress) (interface{}, error)
+
+type RunnerOptions struct {
+	// Resumable jobs interrupted by a restart are queued again; others are marked failed
+	Resumable bool
+}
+
+type registration struct {
+	run  Runner
+	opts RunnerOptions
+}
+
+type JobConfig struct {
+	Dir       string
+	Workers   int
+	Retention time.Duration
+}
+
+// JobService runs long operations on a bounded worker pool. Every job is persisted as a
+// JSON file so its status and result survive restarts.
+type JobService struct {
+	cfg JobConfig
+
+	mu          sync.Mutex
+	jobs        map[string]*jobState
+	runners     map[string]registration
+	subscribers map[int]chan domain.Event
+	nextSubID   int
+
+	queue    chan string
+	baseCtx  context.Context
+	stop     context.CancelFunc
+	workers  sync.WaitGroup
+	started  bool
+	stopping bool
+}
+
+type jobState struct {
+	job         domain.Job
+	cancel      context.CancelFunc
+	cancelled   bool
+	lastPersist time.Time
+}
+
+func NewJobService(cfg JobConfig) (*JobService, error) {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultJobRetention
+	}
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create jobs directory %s: %w", cfg.Dir, err)
+	}
+
+	baseCtx, stop := context.WithCancel(context.Background())
+	s := &JobService{
+		cfg:         cfg,
+		jobs:        make(map[string]*jobState),
+		runners:     make(map[string]registration),
+		subscribers: make(map[int]chan domain.Event),
+		queue:       make(chan string, queueSize),
+		baseCtx:     baseCtx,
+		stop:        stop,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Register associates a job type with its runner. It must be called before Start.
+func (s *JobService) Register(jobType string, run Runner, opts RunnerOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runners[jobType] = registration{run: run, opts: opts}
+}
+
+// Start recovers jobs left over from a previous run and starts the workers
+func (s *JobService) Start() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+
+	var requeue []*jobState
+	for _, state := range s.jobs {
+		if state.job.Status.IsFinished() {
+			continue
+		}
+		reg, ok := s.runners[state.job.Type]
+		if state.job.Status == domain.StatusRunning && (!ok || !reg.opts.Resumable) {
+			s.finishLocked(state, domain.StatusFailed, "interrupted by a server restart")
+			continue
+		}
+		state.job.Status = domain.StatusPending
+		requeue = append(requeue, state)
+	}
+	sort.Slice(requeue, func(i, j int) bool {
+		return requeue[i].job.CreatedAt.Before(requeue[j].job.CreatedAt)
+	})
+	for _, state := range requeue {
+		select {
+		case s.queue <- state.job.ID:
+			s.persistLocked(state)
+		default:
+			s.finishLocked(state, domain.StatusFailed, domain.ErrQueueFull.Error())
+		}
+	}
+	s.mu.Unlock()
+
+	for i := 0; i < s.cfg.Workers; i++ {
+		s.workers.Add(1)
+		go s.worker()
+	}
+	go s.purgeLoop()
+}
+
+// Shutdown stops the workers. Running resumable jobs go back to pending for the next start.
+func (s *JobService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+	s.stop()
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Submit queues a job of jobType for userID; params are stored with the job
+func (s *JobService) Submit(ctx context.Context, jobType, userID string, params interface{}) (*domain.Job, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job parameters: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.runners[jobType]; !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnknownJobType, jobType)
+	}
+
+	state := &jobState{
+		job: domain.Job{
+			ID:        uuid.NewString(),
+			Type:      jobType,
+			UserID:    userID,
+			Status:    domain.StatusPending,
+			Params:    raw,
+			CreatedAt: time.Now(),
+		},
+	}
+
+	select {
+	case s.queue <- state.job.ID:
+	default:
+		return nil, domain.ErrQueueFull
+	}
+
+	s.jobs[state.job.ID] = state
+	s.persistLocked(state)
+	s.publishLocked(domain.EventCreated, state)
+
+	job := state.job
+	return &job, nil
+}
+
+func (s *JobService) Get(id string) (*domain.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[id]
+	if !ok {
+		return nil, domain.ErrJobNotFound
+	}
+	job := state.job
+	return &job, nil
+}
+
+// List returns jobs newest first. Empty filters match everything; results are omitted.
+func (s *JobService) List(userID, jobType string, status domain.Status) []domain.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]domain.Job, 0, len(s.jobs))
+	for _, state := range s.jobs {
+		job := state.job
+		if (userID != "" && job.UserID != userID) ||
+			(jobType != "" && job.Type != jobType && !strings.HasPrefix(job.Type, jobType+".")) ||
+			(status != "" && job.Status != status) {
+			continue
+		}
+		job.Result = nil
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Cancel stops a pending or running job
+func (s *JobService) Cancel(id string) (*domain.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[id]
+	if !ok {
+		return nil, domain.ErrJobNotFound
+	}
+
+	switch state.job.Status {
+	case domain.StatusPending:
+		s.finishLocked(state, domain.StatusCancelled, "")
+	case domain.StatusRunning:
+		state.cancelled = true
+		state.cancel()
+	}
+
+	job := state.job
+	return &job, nil
+}
+
+// Delete forgets a finished job and its result
+func (s *JobService) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[id]
+	if !ok {
+		return domain.ErrJobNotFound
+	}
+	if !state.job.Status.IsFinished() {
+		return domain.ErrJobNotFinished
+	}
+
+	s.deleteLocked(state)
+	return nil
+}
+
+// Result decodes the result of a completed job into v
+func (s *JobService) Result(id string, v interface{}) error {
+	job, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if job.Status != domain.StatusCompleted {
+		return domain.ErrJobNotCompleted
+	}
+	return json.Unmarshal(job.Result, v)
+}
+
+// UpdateResult replaces the result of a completed job, e.g. after acting on part of it
+func (s *JobService) UpdateResult(id string, result interface{}) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode job result: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[id]
+	if !ok {
+		return domain.ErrJobNotFound
+	}
+	state.job.Result = raw
+	s.persistLocked(state)
+	return nil
+}
+
+// Subscribe returns a channel of job events and a function to stop receiving them.
+// Slow subscribers miss events rather than blocking the workers.
+func (s *JobService) Subscribe() (<-chan domain.Event, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextSubID
+	s.nextSubID++
+	ch := make(chan domain.Event, subscriberBuffer)
+	s.subscribers[id] = ch
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[id]; ok {
+			delete(s.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+func (s *JobService) worker() {
+	defer s.workers.Done()
+
+	for {
+		select {
+		case <-s.baseCtx.Done():
+			return
+		case id := <-s.queue:
+			s.execute(id)
+		}
+	}
+}
+
+func (s *JobService) execute(id string) {
+	s.mu.Lock()
+	state, ok := s.jobs[id]
+	if !ok || state.job.Status != domain.StatusPending {
+		s.mu.Unlock()
+		return
+	}
+	reg := s.runners[state.job.Type]
+
+	ctx, cancel := context.WithCancel(s.baseCtx)
+	now := time.Now()
+	state.cancel = cancel
+	state.job.Status = domain.StatusRunning
+	state.job.StartedAt = &now
+	state.job.Attempts++
+	state.job.Progress = domain.Progress{}
+	s.persistLocked(state)
+	s.publishLocked(domain.EventStatus, state)
+	job := state.job
+	s.mu.Unlock()
+
+	progress := &Progress{service: s, state: state, startedAt: now}
+	result, err := s.safeRun(ctx, reg.run, &job, progress)
+	cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		raw, encodeErr := json.Marshal(result)
+		if encodeErr != nil {
+			s.finishLocked(state, domain.StatusFailed, encodeErr.Error())
+			return
+		}
+		state.job.Result = raw
+		state.job.Progress.Percent = 100
+		state.job.Progress.ETASeconds = nil
+		s.finishLocked(state, domain.StatusCompleted, "")
+	case state.cancelled:
+		s.finishLocked(state, domain.StatusCancelled, "")
+	case s.stopping && errors.Is(err, context.Canceled):
+		// Apagado: los trabajos reanudables se reanudan en el próximo arranque
+		if reg.opts.Resumable {
+			state.job.Status = domain.StatusPending
+			s.persistLocked(state)
+			return
+		}
+		s.finishLocked(state, domain.StatusFailed, "interrupted by server shutdown")
+	default:
+		s.finishLocked(state, domain.StatusFailed, err.Error())
+	}
+}
+
+// safeRun turns a runner panic into a failed job instead of crashing the server
+func (s *JobService) safeRun(ctx context.Context, run Runner, job *domain.Job, p *Progress) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("jobs: %s job %s panicked: %v", job.Type, job.ID, r)
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return run(ctx, job, p)
+}
+
+func (s *JobService) finishLocked(state *jobState, status domain.Status, message string) {
+	now := time.Now()
+	state.job.Status = status
+	state.job.Error = message
+	state.job.FinishedAt = &now
+	state.job.Progress.ETASeconds = nil
+	s.persistLocked(state)
+	s.publishLocked(domain.EventStatus, state)
+}
+
+func (s *JobService) deleteLocked(state *jobState) {
+	delete(s.jobs, state.job.ID)
+	if err := os.Remove(s.jobPath(state.job.ID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("jobs: failed to delete job %s: %v", state.job.ID, err)
+	}
+	s.publishLocked(domain.EventDeleted, state)
+}
+
+func (s *JobService) persistLocked(state *jobState) {
+	state.lastPersist = time.Now()
+	if err := utils.SaveJSONFile(s.jobPath(state.job.ID), state.job); err != nil {
+		log.Printf("jobs: failed to persist job %s: %v", state.job.ID, err)
+	}
+}
+
+func (s *JobService) publishLocked(eventType string, state *jobState) {
+	event := domain.Event{Type: eventType, Job: state.job}
+	event.Job.Result = nil
+	for _, ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// purgeLoop removes finished jobs older than the retention period
+func (s *JobService) purgeLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		s.purge()
+		select {
+		case <-s.baseCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *JobService) purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().Add(-s.cfg.Retention)
+	for _, state := range s.jobs {
+		if state.job.FinishedAt != nil && state.job.FinishedAt.Before(cutoff) {
+			s.deleteLocked(state)
+		}
+	}
+}
+
+func (s *JobService) load() error {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read jobs directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		var job domain.Job
+		if err := utils.LoadJSONFile(filepath.Join(s.cfg.Dir, entry.Name()), &job); err != nil {
+			log.Printf("jobs: skipping unreadable job file %s: %v", entry.Name(), err)
+			continue
+		}
+		if job.ID == "" {
+			continue
+		}
+		s.jobs[job.ID] = &jobState{job: job}
+	}
+	return nil
+}
+
+func (s *JobService) jobPath(id string) string {
+	return filepath.Join(s.cfg.Dir, utils.SanitizeFilename(id)+".json")
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/infortech07/cubert/internal/jobs/domain"
+)
+
+const progressInterval = 250 * time.Millisecond
+
+// Progress is handed to runners to report how far along a job is. Updates are batched:
+// subscribers are notified at most every progressInterval and the job file is rewritten
+// at most every persistInterval.
+type Progress struct {
+	service   *JobService
+	state     *jobState
+	startedAt time.Time
+
+	mu         sync.Mutex
+	current    int64
+	total      int64
+	unit       string
+	message    string
+	percent    float64
+	hasPercent bool
+	lastFlush  time.Time
+}
+
+// SetTotal declares the amount of work, e.g. bytes or files
+func (p *Progress) SetTotal(total int64, unit string) {
+	p.mu.Lock()
+	p.total, p.unit = total, unit
+	p.mu.Unlock()
+	p.maybeFlush(true)
+}
+
+// Add advances the current amount of work
+func (p *Progress) Add(n int64) {
+	p.mu.Lock()
+	p.current += n
+	p.mu.Unlock()
+	p.maybeFlush(false)
+}
+
+func (p *Progress) SetCurrent(current int64) {
+	p.mu.Lock()
+	p.current = current
+	p.mu.Unlock()
+	p.maybeFlush(false)
+}
+
+// SetMessage describes the current step, e.g. the file being processed
+func (p *Progress) SetMessage(message string) {
+	p.mu.Lock()
+	p.message = message
+	p.mu.Unlock()
+	p.maybeFlush(false)
+}
+
+// SetPercent overrides current/total for jobs whose phases are not linear
+func (p *Progress) SetPercent(percent float64) {
+	p.mu.Lock()
+	p.percent, p.hasPercent = percent, true
+	p.mu.Unlock()
+	p.maybeFlush(false)
+}
+
+// Flush publishes the progress immediately
+func (p *Progress) Flush() {
+	p.maybeFlush(true)
+}
+
+func (p *Progress) maybeFlush(force bool) {
+	p.mu.Lock()
+	if !force && time.Since(p.lastFlush) < progressInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastFlush = time.Now()
+	progress := p.snapshotLocked()
+	p.mu.Unlock()
+
+	s := p.service
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p.state.job.Status != domain.StatusRunning {
+		return
+	}
+	p.state.job.Progress = progress
+	if force || time.Since(p.state.lastPersist) >= persistInterval {
+		s.persistLocked(p.state)
+	}
+	s.publishLocked(domain.EventProgress, p.state)
+}
+
+func (p *Progress) snapshotLocked() domain.Progress {
+	progress := domain.Progress{
+		Current: p.current,
+		Total:   p.total,
+		Unit:    p.unit,
+		Message: p.message,
+	}
+
+	switch {
+	case p.hasPercent:
+		progress.Percent = p.percent
+	case p.total > 0:
+		progress.Percent = float64(p.current) / float64(p.total) * 100
+	}
+	if progress.Percent > 100 {
+		progress.Percent = 100
+	}
+	progress.Percent = float64(int(progress.Percent*10)) / 10
+
+	if progress.Percent > 0 && progress.Percent < 100 {
+		elapsed := time.Since(p.startedAt)
+		remaining := time.Duration(float64(elapsed) * (100 - progress.Percent) / progress.Percent)
+		eta := int64(remaining.Seconds())
+		progress.ETASeconds = &eta
+	}
+	return progress
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Scopes a quota applies to
+const (
+	ScopeUser = "user"
+	ScopeRoot = "root"
+)
+
+// Limits caps bytes and regular files; zero means unlimited
+type Limits struct {
+	MaxBytes int64 `json:"max_bytes"`
+	MaxFiles int64 `json:"max_files"`
+}
+
+type Usage struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+func (u Usage) Add(other Usage) Usage {
+	return Usage{Bytes: u.Bytes + other.Bytes, Files: u.Files + other.Files}
+}
+
+func (u Usage) Sub(other Usage) Usage {
+	return Usage{Bytes: u.Bytes - other.Bytes, Files: u.Files - other.Files}
+}
+
+// Status is the usage of a user or a library root against its limits
+type Status struct {
+	Scope   string `json:"scope"`
+	Subject string `json:"subject"`
+	// Name es el nombre de usuario, para las vistas de administración
+	Name   string `json:"name,omitempty"`
+	Limits Limits `json:"limits"`
+	// Overridden indica que un administrador fijó límites propios
+	Overridden bool  `json:"overridden"`
+	Usage      Usage `json:"usage"`
+	// Los porcentajes son 0 cuando no hay límite
+	BytesPercent float64 `json:"bytes_percent"`
+	FilesPercent float64 `json:"files_percent"`
+	// Warning se activa al pasar el umbral blando
+	Warning  bool `json:"warning"`
+	Exceeded bool `json:"exceeded"`
+}
+
+// Report is what GET /quota returns to a user
+type Report struct {
+	User        Status    `json:"user"`
+	Roots       []Status  `json:"roots"`
+	Warnings    []string  `json:"warnings"`
+	SoftPercent int       `json:"soft_percent"`
+	IndexedAt   time.Time `json:"indexed_at,omitempty"`
+}
+
+// Overview is the administrator view of every root and every user with usage or own limits
+type Overview struct {
+	Defaults    map[string]Limits `json:"defaults"`
+	SoftPercent int               `json:"soft_percent"`
+	Users       []Status          `json:"users"`
+	Roots       []Status          `json:"roots"`
+	IndexedAt   time.Time         `json:"indexed_at,omitempty"`
+}
+
+// Overrides are the limits set by administrators in place of the defaults
+type Overrides struct {
+	Users map[string]Limits `json:"users"`
+	Roots map[string]Limits `json:"roots"`
+}
+
+// VersionUsage reports the space taken by the versions kept for a library root, in
+// total and per user who caused them
+type VersionUsage interface {
+	VersionUsage(root string) (Usage, map[string]Usage)
+}
+
+var (
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrInvalidLimits = errors.New("limits cannot be negative")
+	ErrUnknownRoot   = errors.New("path is not a library root")
+)
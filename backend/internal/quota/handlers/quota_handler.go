@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/quota/domain"
+	"github.com/infortech07/cubert/internal/quota/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+type QuotaHandler struct {
+	quotaService *services.QuotaService
+	aclService   *authservices.ACLService
+	userStore    *authservices.UserStore
+}
+
+func NewQuotaHandler(quotaService *services.QuotaService, aclService *authservices.ACLService, userStore *authservices.UserStore) *QuotaHandler {
+	return &QuotaHandler{
+		quotaService: quotaService,
+		aclService:   aclService,
+		userStore:    userStore,
+	}
+}
+
+// GetQuota returns the usage of the current user and of the roots they can reach
+func (h *QuotaHandler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+	report := h.quotaService.Report(user.ID, h.visibleRoots(r, user))
+	report.User.Name = user.Username
+	utils.WriteJSONResponse(w, http.StatusOK, report)
+}
+
+// Overview lists the defaults, every root and every user with usage or own limits
+func (h *QuotaHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	overview := h.quotaService.Overview()
+	for i := range overview.Users {
+		if user, err := h.userStore.Get(overview.Users[i].Subject); err == nil {
+			overview.Users[i].Name = user.Username
+		}
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, overview)
+}
+
+// SetUserLimits overrides the default limits of a user
+func (h *QuotaHandler) SetUserLimits(w http.ResponseWriter, r *http.Request) {
+	var limits domain.Limits
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	h.setUserLimits(w, chi.URLParam(r, "id"), &limits)
+}
+
+// DeleteUserLimits puts a user back on the default limits
+func (h *QuotaHandler) DeleteUserLimits(w http.ResponseWriter, r *http.Request) {
+	h.setUserLimits(w, chi.URLParam(r, "id"), nil)
+}
+
+func (h *QuotaHandler) setUserLimits(w http.ResponseWriter, userID string, limits *domain.Limits) {
+	user, err := h.userStore.Get(userID)
+	if err != nil {
+		utils.WriteErrorResponse(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	status, err := h.quotaService.SetUserLimits(user.ID, limits)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	status.Name = user.Username
+	utils.WriteJSONResponse(w, http.StatusOK, status)
+}
+
+// SetRootLimits overrides the default limits of a library root
+func (h *QuotaHandler) SetRootLimits(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string `json:"path"`
+		domain.Limits
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), request.Path)
+	status, err := h.quotaService.SetRootLimits(request.Path, &request.Limits)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, status)
+}
+
+// DeleteRootLimits puts a library root back on the default limits
+func (h *QuotaHandler) DeleteRootLimits(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), path)
+	status, err := h.quotaService.SetRootLimits(path, nil)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, status)
+}
+
+// visibleRoots returns the roots the user can read or that contain a folder granted to them
+func (h *QuotaHandler) visibleRoots(r *http.Request, user *authdomain.User) []string {
+	allowed := h.aclService.AllowedRoots(user)
+	visible := make([]string, 0)
+	for _, root := range h.quotaService.Roots() {
+		if h.aclService.Authorize(r.Context(), user, root, authdomain.AccessRead) == nil {
+			visible = append(visible, root)
+			continue
+		}
+		for _, path := range allowed {
+			if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				visible = append(visible, root)
+				break
+			}
+		}
+	}
+	return visible
+}
+
+func writeQuotaError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidLimits):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid limits", err)
+	case errors.Is(err, domain.ErrUnknownRoot):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Library root not found", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to update quota", err)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"github.com/infortech07/cubert/internal/quota/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+// Allowance is the room a user has left for writing below a path, taken when an
+// operation starts. Operations that write several files take from it as they go.
+type Allowance struct {
+	mu     sync.Mutex
+	scopes []allowanceScope
+}
+
+type allowanceScope struct {
+	scope   string
+	subject string
+	limits  domain.Limits
+	left    domain.Usage
+}
+
+// Allowance returns the room left for userID under the user and root limits of path.
+// A nil service allows everything.
+func (s *QuotaService) Allowance(userID, path string) *Allowance {
+	allowance := &Allowance{}
+	if s == nil {
+		return allowance
+	}
+
+	statuses := make([]domain.Status, 0, 2)
+	if userID != "" {
+		statuses = append(statuses, s.userStatus(userID))
+	}
+	if root := s.rootFor(filepath.Clean(path)); root != "" {
+		statuses = append(statuses, s.rootStatus(root))
+	}
+	for _, status := range statuses {
+		if status.Limits.MaxBytes <= 0 && status.Limits.MaxFiles <= 0 {
+			continue
+		}
+		allowance.scopes = append(allowance.scopes, allowanceScope{
+			scope:   status.Scope,
+			subject: status.Subject,
+			limits:  status.Limits,
+			left:    domain.Usage{Bytes: status.Limits.MaxBytes, Files: status.Limits.MaxFiles}.Sub(status.Usage),
+		})
+	}
+	return allowance
+}
+
+// Check fails with ErrQuotaExceeded if writing bytes and files below path would go over
+// the limits of userID or of the root
+func (s *QuotaService) Check(userID, path string, bytes, files int64) error {
+	return s.Allowance(userID, path).Fits(bytes, files)
+}
+
+// Fits reports whether bytes and files still fit, without taking them
+func (a *Allowance) Fits(bytes, files int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.fits(bytes, files)
+}
+
+// Take consumes bytes and files from the allowance, or fails without consuming anything.
+// Negative values give room back, as when a file is overwritten with a smaller one.
+func (a *Allowance) Take(bytes, files int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.fits(bytes, files); err != nil {
+		return err
+	}
+	for i := range a.scopes {
+		a.scopes[i].left = a.scopes[i].left.Sub(domain.Usage{Bytes: bytes, Files: files})
+	}
+	return nil
+}
+
+func (a *Allowance) fits(bytes, files int64) error {
+	for _, scope := range a.scopes {
+		if scope.limits.MaxBytes > 0 && bytes > 0 && bytes > scope.left.Bytes {
+			return scope.exceeded(fmt.Sprintf("%s needed, %s left of %s",
+				utils.FormatFileSize(bytes), utils.FormatFileSize(max(scope.left.Bytes, 0)), utils.FormatFileSize(scope.limits.MaxBytes)))
+		}
+		if scope.limits.MaxFiles > 0 && files > 0 && files > scope.left.Files {
+			return scope.exceeded(fmt.Sprintf("%d files needed, %d left of %d",
+				files, max(scope.left.Files, 0), scope.limits.MaxFiles))
+		}
+	}
+	return nil
+}
+
+func (scope allowanceScope) exceeded(detail string) error {
+	if scope.scope == domain.ScopeRoot {
+		return fmt.Errorf("%w for library root %s: %s", domain.ErrQuotaExceeded, scope.subject, detail)
+	}
+	return fmt.Errorf("%w for user: %s", domain.ErrQuotaExceeded, detail)
+}
+
+// LimitReader fails with ErrQuotaExceeded once more bytes than the allowance has left
+// have been read from r, for bodies whose length is not known up front
+func (a *Allowance) LimitReader(r io.Reader) io.Reader {
+	return &limitedReader{reader: r, allowance: a}
+}
+
+type limitedReader struct {
+	reader    io.Reader
+	allowance *Allowance
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.reader.Read(p)
+	if n > 0 {
+		if takeErr := l.allowance.Take(int64(n), 0); takeErr != nil {
+			return 0, takeErr
+		}
+	}
+	return n, err
+}
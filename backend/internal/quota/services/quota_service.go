@@ -0,0 +1,448 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/infortech07/cubert/internal/quota/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+)
+
+// flushInterval is how often the ownership ledger is written to disk
+const flushInterval = time.Minute
+
+// QuotaConfig holds the default limits and the soft-limit threshold
+type QuotaConfig struct {
+	UserLimits  domain.Limits
+	RootLimits  domain.Limits
+	SoftPercent int
+}
+
+// owner is who wrote a file last and the size it had then
+type owner struct {
+	UserID string `json:"user_id"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// QuotaService tracks how much each user and each library root stores and enforces
+// their limits. Root usage is kept per folder: the library index sets the baseline and
+// the audit events refresh only the folders a request touched. User usage comes from a
+// ledger of the files each user wrote last. Versions count for both.
+type QuotaService struct {
+	cfg           QuotaConfig
+	roots         []string
+	versions      domain.VersionUsage
+	overridesFile string
+	ownersFile    string
+
+	mu        sync.Mutex
+	overrides domain.Overrides
+	// Ficheros regulares directamente dentro de cada carpeta
+	dirs      map[string]domain.Usage
+	rootUsage map[string]domain.Usage
+	owners    map[string]owner
+	userUsage map[string]domain.Usage
+	indexedAt time.Time
+	dirty     bool
+
+	events  chan func()
+	dropped atomic.Bool
+}
+
+// NewQuotaService loads the overrides and the ownership ledger from dataDir. versions
+// may be nil when versioning is disabled.
+func NewQuotaService(cfg QuotaConfig, dataDir string, roots []string, versions domain.VersionUsage) (*QuotaService, error) {
+	if cfg.SoftPercent <= 0 || cfg.SoftPercent > 100 {
+		cfg.SoftPercent = 100
+	}
+
+	s := &QuotaService{
+		cfg:           cfg,
+		versions:      versions,
+		overridesFile: filepath.Join(dataDir, "quota", "overrides.json"),
+		ownersFile:    filepath.Join(dataDir, "quota", "owners.json"),
+		dirs:          make(map[string]domain.Usage),
+		rootUsage:     make(map[string]domain.Usage),
+		owners:        make(map[string]owner),
+		userUsage:     make(map[string]domain.Usage),
+		events:        make(chan func(), 256),
+	}
+	for _, root := range roots {
+		s.roots = append(s.roots, filepath.Clean(root))
+	}
+
+	if err := utils.LoadJSONFile(s.overridesFile, &s.overrides); err != nil {
+		return nil, err
+	}
+	if s.overrides.Users == nil {
+		s.overrides.Users = make(map[string]domain.Limits)
+	}
+	if s.overrides.Roots == nil {
+		s.overrides.Roots = make(map[string]domain.Limits)
+	}
+
+	owners := make(map[string]owner)
+	if err := utils.LoadJSONFile(s.ownersFile, &owners); err != nil {
+		return nil, err
+	}
+	for path, o := range owners {
+		o := o
+		s.setOwner(path, &o)
+	}
+	s.dirty = false
+	return s, nil
+}
+
+// Start applies the audit events in order, rescans the roots after dropped events and
+// flushes the ledger until ctx is done
+func (s *QuotaService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case apply := <-s.events:
+				apply()
+			case <-ticker.C:
+				if s.dropped.Swap(false) {
+					log.Printf("quota: events were dropped, rescanning the libraries")
+					s.rescan()
+				}
+				if err := s.Flush(); err != nil {
+					log.Printf("quota: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Flush writes the ownership ledger if it changed since the last write
+func (s *QuotaService) Flush() error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	owners := make(map[string]owner, len(s.owners))
+	for path, o := range s.owners {
+		owners[path] = o
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err := utils.SaveJSONFile(s.ownersFile, owners); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("failed to save quota ledger: %w", err)
+	}
+	return nil
+}
+
+// Report returns the usage of a user and of the given roots, with soft-limit warnings
+func (s *QuotaService) Report(userID string, roots []string) domain.Report {
+	report := domain.Report{
+		User:        s.userStatus(userID),
+		Roots:       make([]domain.Status, 0, len(roots)),
+		Warnings:    make([]string, 0),
+		SoftPercent: s.cfg.SoftPercent,
+	}
+	for _, root := range roots {
+		report.Roots = append(report.Roots, s.rootStatus(filepath.Clean(root)))
+	}
+
+	for _, status := range append([]domain.Status{report.User}, report.Roots...) {
+		if warning := s.warning(status); warning != "" {
+			report.Warnings = append(report.Warnings, warning)
+		}
+	}
+
+	s.mu.Lock()
+	report.IndexedAt = s.indexedAt
+	s.mu.Unlock()
+	return report
+}
+
+// Roots returns the library roots quotas apply to
+func (s *QuotaService) Roots() []string {
+	return append([]string{}, s.roots...)
+}
+
+// Overview returns every root and every user with stored files or own limits
+func (s *QuotaService) Overview() domain.Overview {
+	s.mu.Lock()
+	seen := make(map[string]bool)
+	for userID, usage := range s.userUsage {
+		if usage != (domain.Usage{}) {
+			seen[userID] = true
+		}
+	}
+	for userID := range s.overrides.Users {
+		seen[userID] = true
+	}
+	indexedAt := s.indexedAt
+	s.mu.Unlock()
+
+	if s.versions != nil {
+		for _, root := range s.roots {
+			_, byUser := s.versions.VersionUsage(root)
+			for userID := range byUser {
+				seen[userID] = true
+			}
+		}
+	}
+	delete(seen, "")
+
+	userIDs := make([]string, 0, len(seen))
+	for userID := range seen {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+
+	users := make([]domain.Status, 0, len(userIDs))
+	for _, userID := range userIDs {
+		users = append(users, s.userStatus(userID))
+	}
+	roots := make([]domain.Status, 0, len(s.roots))
+	for _, root := range s.roots {
+		roots = append(roots, s.rootStatus(root))
+	}
+
+	return domain.Overview{
+		Defaults: map[string]domain.Limits{
+			domain.ScopeUser: s.cfg.UserLimits,
+			domain.ScopeRoot: s.cfg.RootLimits,
+		},
+		SoftPercent: s.cfg.SoftPercent,
+		Users:       users,
+		Roots:       roots,
+		IndexedAt:   indexedAt,
+	}
+}
+
+// SetUserLimits replaces the default limits of a user; nil goes back to the defaults
+func (s *QuotaService) SetUserLimits(userID string, limits *domain.Limits) (domain.Status, error) {
+	if err := s.setOverride(s.overrides.Users, userID, limits); err != nil {
+		return domain.Status{}, err
+	}
+	return s.userStatus(userID), nil
+}
+
+// SetRootLimits replaces the default limits of a library root; nil goes back to the defaults
+func (s *QuotaService) SetRootLimits(root string, limits *domain.Limits) (domain.Status, error) {
+	root = filepath.Clean(root)
+	if s.rootFor(root) != root {
+		return domain.Status{}, domain.ErrUnknownRoot
+	}
+	if err := s.setOverride(s.overrides.Roots, root, limits); err != nil {
+		return domain.Status{}, err
+	}
+	return s.rootStatus(root), nil
+}
+
+func (s *QuotaService) setOverride(target map[string]domain.Limits, key string, limits *domain.Limits) error {
+	if limits != nil && (limits.MaxBytes < 0 || limits.MaxFiles < 0) {
+		return domain.ErrInvalidLimits
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := target[key]
+	if limits == nil {
+		delete(target, key)
+	} else {
+		target[key] = *limits
+	}
+	if err := utils.SaveJSONFile(s.overridesFile, s.overrides); err != nil {
+		// Se deshace el cambio para no divergir del disco
+		if existed {
+			target[key] = previous
+		} else {
+			delete(target, key)
+		}
+		return fmt.Errorf("failed to save quota overrides: %w", err)
+	}
+	return nil
+}
+
+func (s *QuotaService) userStatus(userID string) domain.Status {
+	s.mu.Lock()
+	limits, overridden := s.overrides.Users[userID]
+	if !overridden {
+		limits = s.cfg.UserLimits
+	}
+	usage := s.userUsage[userID]
+	s.mu.Unlock()
+
+	if s.versions != nil {
+		for _, root := range s.roots {
+			_, byUser := s.versions.VersionUsage(root)
+			usage = usage.Add(byUser[userID])
+		}
+	}
+	return s.status(domain.ScopeUser, userID, limits, overridden, usage)
+}
+
+func (s *QuotaService) rootStatus(root string) domain.Status {
+	s.mu.Lock()
+	limits, overridden := s.overrides.Roots[root]
+	if !overridden {
+		limits = s.cfg.RootLimits
+	}
+	usage := s.rootUsage[root]
+	s.mu.Unlock()
+
+	if s.versions != nil {
+		total, _ := s.versions.VersionUsage(root)
+		usage = usage.Add(total)
+	}
+	return s.status(domain.ScopeRoot, root, limits, overridden, usage)
+}
+
+func (s *QuotaService) status(scope, subject string, limits domain.Limits, overridden bool, usage domain.Usage) domain.Status {
+	status := domain.Status{
+		Scope:      scope,
+		Subject:    subject,
+		Limits:     limits,
+		Overridden: overridden,
+		Usage:      usage,
+	}
+	if limits.MaxBytes > 0 {
+		status.BytesPercent = percent(usage.Bytes, limits.MaxBytes)
+		status.Exceeded = status.Exceeded || usage.Bytes >= limits.MaxBytes
+	}
+	if limits.MaxFiles > 0 {
+		status.FilesPercent = percent(usage.Files, limits.MaxFiles)
+		status.Exceeded = status.Exceeded || usage.Files >= limits.MaxFiles
+	}
+	soft := float64(s.cfg.SoftPercent)
+	status.Warning = status.BytesPercent >= soft || status.FilesPercent >= soft
+	return status
+}
+
+func (s *QuotaService) warning(status domain.Status) string {
+	if !status.Warning {
+		return ""
+	}
+	subject := "Your quota"
+	if status.Scope == domain.ScopeRoot {
+		subject = "Library root " + status.Subject
+	}
+	if status.Exceeded {
+		return subject + " is full"
+	}
+	used := status.BytesPercent
+	if status.FilesPercent > used {
+		used = status.FilesPercent
+	}
+	return fmt.Sprintf("%s is %.0f%% used", subject, used)
+}
+
+func percent(used, limit int64) float64 {
+	value := float64(used) * 100 / float64(limit)
+	return float64(int64(value*10)) / 10
+}
+
+// rootFor returns the innermost library root containing path, or "" for other paths
+func (s *QuotaService) rootFor(path string) string {
+	found := ""
+	for _, root := range s.roots {
+		if within(path, root) && len(root) > len(found) {
+			found = root
+		}
+	}
+	return found
+}
+
+// setDir records the files directly inside dir. Callers hold s.mu.
+func (s *QuotaService) setDir(dir string, usage domain.Usage) {
+	root := s.rootFor(dir)
+	if root == "" {
+		return
+	}
+	previous := s.dirs[dir]
+	if usage == (domain.Usage{}) {
+		delete(s.dirs, dir)
+	} else {
+		s.dirs[dir] = usage
+	}
+	s.rootUsage[root] = s.rootUsage[root].Sub(previous).Add(usage)
+}
+
+// setOwner records or, with nil, forgets who owns path. Callers hold s.mu.
+func (s *QuotaService) setOwner(path string, o *owner) {
+	if previous, ok := s.owners[path]; ok {
+		s.userUsage[previous.UserID] = s.userUsage[previous.UserID].Sub(domain.Usage{Bytes: previous.Bytes, Files: 1})
+		delete(s.owners, path)
+	}
+	if o != nil {
+		s.owners[path] = *o
+		s.userUsage[o.UserID] = s.userUsage[o.UserID].Add(domain.Usage{Bytes: o.Bytes, Files: 1})
+	}
+	s.dirty = true
+}
+
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// scan tallies the regular files below path per folder and returns those modified
+// since the given time. A missing path gives an empty result.
+func scan(path string, since time.Time) (map[string]domain.Usage, map[string]int64) {
+	dirs := make(map[string]domain.Usage)
+	modified := make(map[string]int64)
+	filepath.WalkDir(path, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		dir := filepath.Dir(current)
+		dirs[dir] = dirs[dir].Add(domain.Usage{Bytes: info.Size(), Files: 1})
+		if !since.IsZero() && !info.ModTime().Before(since) {
+			modified[current] = info.Size()
+		}
+		return nil
+	})
+	return dirs, modified
+}
+
+// shallow tallies the regular files directly inside dir
+func shallow(dir string) domain.Usage {
+	var usage domain.Usage
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return usage
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			usage = usage.Add(domain.Usage{Bytes: info.Size(), Files: 1})
+		}
+	}
+	return usage
+}
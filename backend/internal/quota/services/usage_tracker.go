@@ -0,0 +1,277 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	"github.com/infortech07/cubert/internal/quota/domain"
+	sysdomain "github.com/infortech07/cubert/internal/system/domain"
+)
+
+// mtimeSlack covers filesystems that store modification times with coarse precision
+const mtimeSlack = 2 * time.Second
+
+// trackedActions change the content below their paths. Jobs (extract, sync...) are not
+// listed: they call Track themselves once they finish.
+var trackedActions = map[string]bool{
+	"filesystem.upload":            true,
+	"filesystem.save":              true,
+	"versions.restore":             true,
+	"trash.restore":                true,
+	"duplicates.hardlink":          true,
+	"s3.put_object":                true,
+	"s3.copy_object":               true,
+	"s3.complete_multipart_upload": true,
+	"dav.put":                      true,
+	"dav.copy":                     true,
+	"sftp.upload":                  true,
+	"sftp.setstat":                 true,
+}
+
+// removeActions delete their paths
+var removeActions = map[string]bool{
+	"filesystem.file":   true,
+	"duplicates.trash":  true,
+	"s3.delete_object":  true,
+	"s3.delete_objects": true,
+	"dav.delete":        true,
+	"sftp.delete":       true,
+}
+
+// moveActions carry the source and the destination as their two paths
+var moveActions = map[string]bool{
+	"filesystem.rename": true,
+	"dav.move":          true,
+	"sftp.rename":       true,
+}
+
+// HandleAuditEvent refreshes the usage of the paths a successful request changed. The
+// work is queued and applied in order by the loop started with Start.
+func (s *QuotaService) HandleAuditEvent(event auditdomain.Event) {
+	if event.Result != auditdomain.ResultSuccess || len(event.Paths) == 0 {
+		return
+	}
+	if !trackedActions[event.Action] && !removeActions[event.Action] && !moveActions[event.Action] {
+		return
+	}
+
+	paths := make([]string, 0, len(event.Paths))
+	for _, path := range event.Paths {
+		if path = filepath.Clean(path); s.rootFor(path) != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+
+	since := event.Timestamp.Add(-time.Duration(event.DurationMs) * time.Millisecond)
+	switch {
+	case removeActions[event.Action]:
+		s.enqueue(func() {
+			for _, path := range paths {
+				s.removed(path)
+			}
+		})
+	case moveActions[event.Action]:
+		if len(paths) == 2 {
+			s.enqueue(func() { s.moved(paths[0], paths[1]) })
+		}
+	default:
+		s.enqueue(func() {
+			for _, path := range paths {
+				s.Track(event.UserID, path, since)
+			}
+		})
+	}
+}
+
+// enqueue hands apply to the loop without blocking the request. When the queue is full
+// the event is dropped and the loop rescans the roots on its next tick.
+func (s *QuotaService) enqueue(apply func()) {
+	select {
+	case s.events <- apply:
+	default:
+		s.dropped.Store(true)
+	}
+}
+
+// rescan refreshes the usage of every root after dropped events. The files written
+// meanwhile keep their previous owner, or none.
+func (s *QuotaService) rescan() {
+	for _, root := range s.roots {
+		s.Track("", root, time.Time{})
+	}
+}
+
+// Track refreshes the usage below path and assigns to userID the files written there
+// since the given time. Background jobs call it once they finish.
+func (s *QuotaService) Track(userID, path string, since time.Time) {
+	path = filepath.Clean(path)
+	if s.rootFor(path) == "" {
+		return
+	}
+
+	if userID == "" {
+		since = time.Time{}
+	} else {
+		since = since.Add(-mtimeSlack)
+	}
+	dirs, modified := scan(path, since)
+	parent := shallow(filepath.Dir(path))
+
+	s.mu.Lock()
+	s.replaceDirs(path, dirs, parent)
+	for file, size := range modified {
+		s.setOwner(file, &owner{UserID: userID, Bytes: size})
+	}
+	s.mu.Unlock()
+
+	// Los ficheros de otros dueños pueden haber cambiado de tamaño o desaparecido
+	s.reconcile(path, modified)
+}
+
+// Claim gives userID every regular file at or below paths, for jobs that write files
+// with the modification times of their source. It does not refresh the folder usage:
+// callers follow up with Track once the job is done.
+func (s *QuotaService) Claim(userID string, paths ...string) {
+	if userID == "" {
+		return
+	}
+
+	files := make(map[string]int64)
+	for _, path := range paths {
+		if path = filepath.Clean(path); s.rootFor(path) != "" {
+			_, found := scan(path, time.Unix(0, 0))
+			for file, size := range found {
+				files[file] = size
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for file, size := range files {
+		s.setOwner(file, &owner{UserID: userID, Bytes: size})
+	}
+}
+
+// removed forgets the files below a deleted path
+func (s *QuotaService) removed(path string) {
+	dirs, _ := scan(path, time.Time{})
+	parent := shallow(filepath.Dir(path))
+
+	s.mu.Lock()
+	s.replaceDirs(path, dirs, parent)
+	s.mu.Unlock()
+
+	s.reconcile(path, nil)
+}
+
+// moved keeps the owners of the files below a renamed or moved path
+func (s *QuotaService) moved(from, to string) {
+	fromDirs, _ := scan(from, time.Time{})
+	fromParent := shallow(filepath.Dir(from))
+	toDirs, _ := scan(to, time.Time{})
+	toParent := shallow(filepath.Dir(to))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replaceDirs(from, fromDirs, fromParent)
+	s.replaceDirs(to, toDirs, toParent)
+
+	moved := make(map[string]owner)
+	for file, o := range s.owners {
+		if within(file, from) {
+			rel, _ := filepath.Rel(from, file)
+			moved[filepath.Join(to, rel)] = o
+			s.setOwner(file, nil)
+		}
+	}
+	for file, o := range moved {
+		if s.rootFor(file) == "" {
+			continue
+		}
+		o := o
+		s.setOwner(file, &o)
+	}
+}
+
+// replaceDirs swaps the tallies of the folders below path for a fresh scan and updates
+// the folder holding path. Callers hold s.mu.
+func (s *QuotaService) replaceDirs(path string, dirs map[string]domain.Usage, parent domain.Usage) {
+	for dir := range s.dirs {
+		if within(dir, path) {
+			s.setDir(dir, domain.Usage{})
+		}
+	}
+	for dir, usage := range dirs {
+		if within(dir, path) {
+			s.setDir(dir, usage)
+		}
+	}
+	s.setDir(filepath.Dir(path), parent)
+}
+
+// HandleIndex takes the per-folder tallies of a library index walk as the new baseline
+// for root and reconciles the ledger with what is on disk.
+func (s *QuotaService) HandleIndex(root string, dirs map[string]sysdomain.FamilyStats) {
+	root = filepath.Clean(root)
+	if s.rootFor(root) != root {
+		return
+	}
+
+	s.mu.Lock()
+	for dir := range s.dirs {
+		if within(dir, root) {
+			s.setDir(dir, domain.Usage{})
+		}
+	}
+	for dir, stats := range dirs {
+		s.setDir(dir, domain.Usage{Bytes: stats.Bytes, Files: stats.Files})
+	}
+	s.indexedAt = time.Now()
+	s.mu.Unlock()
+
+	s.reconcile(root, nil)
+}
+
+// reconcile drops the ledger entries below path whose files are gone and updates the
+// sizes of the rest, except for the files in skip
+func (s *QuotaService) reconcile(path string, skip map[string]int64) {
+	s.mu.Lock()
+	files := make([]string, 0)
+	for file := range s.owners {
+		if _, ok := skip[file]; !ok && within(file, path) {
+			files = append(files, file)
+		}
+	}
+	s.mu.Unlock()
+
+	// Los stat se hacen sin el cerrojo: puede haber muchos ficheros
+	sizes := make(map[string]int64, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || !info.Mode().IsRegular() {
+			sizes[file] = -1
+			continue
+		}
+		sizes[file] = info.Size()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for file, size := range sizes {
+		o, ok := s.owners[file]
+		switch {
+		case !ok:
+		case size < 0:
+			s.setOwner(file, nil)
+		case size != o.Bytes:
+			s.setOwner(file, &owner{UserID: o.UserID, Bytes: size})
+		}
+	}
+}
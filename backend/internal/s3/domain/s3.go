@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+// Prefix is the URL path of the S3 endpoint; clients must use path-style addressing
+const Prefix = "/s3"
+
+// AuditSource identifies S3 requests in the audit log
+const AuditSource = "s3"
+
+// MaxKeys is the largest page returned by a listing
+const MaxKeys = 1000
+
+// Bucket is a library root exposed under an S3-compatible name
+type Bucket struct {
+	Name      string
+	Path      string
+	CreatedAt time.Time
+}
+
+type ListOptions struct {
+	Prefix     string
+	Delimiter  string
+	StartAfter string
+	MaxKeys    int
+}
+
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	ETag    string
+}
+
+type ListResult struct {
+	Objects        []Object
+	CommonPrefixes []string
+	IsTruncated    bool
+	// NextMarker is the last key or prefix returned, from which the next page starts
+	NextMarker string
+}
+
+// Upload is an in-progress multipart upload
+type Upload struct {
+	ID        string    `json:"id"`
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// CompleteMultipartUpload is the body of a CompleteMultipartUpload request
+type CompleteMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPart `xml:"Part"`
+}
+
+// Delete is the body of a DeleteObjects request
+type Delete struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+// Error is an S3 error with its code and HTTP status
+type Error struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrAccessDenied          = &Error{"AccessDenied", "Access Denied", http.StatusForbidden}
+	ErrInvalidAccessKeyID    = &Error{"InvalidAccessKeyId", "The access key ID does not exist", http.StatusForbidden}
+	ErrSignatureDoesNotMatch = &Error{"SignatureDoesNotMatch", "The request signature does not match", http.StatusForbidden}
+	ErrRequestTimeTooSkewed  = &Error{"RequestTimeTooSkewed", "The request time is too far from the server time", http.StatusForbidden}
+	ErrExpiredToken          = &Error{"AccessDenied", "Request has expired", http.StatusForbidden}
+	ErrMissingSecurityHeader = &Error{"MissingSecurityHeader", "The request is not signed with SigV4", http.StatusBadRequest}
+	ErrAuthorizationHeader   = &Error{"AuthorizationHeaderMalformed", "The authorization header is malformed", http.StatusBadRequest}
+	ErrContentSHA256Mismatch = &Error{"XAmzContentSHA256Mismatch", "The payload does not match x-amz-content-sha256", http.StatusBadRequest}
+	ErrIncompleteBody        = &Error{"IncompleteBody", "The request body is malformed or incomplete", http.StatusBadRequest}
+	ErrNoSuchBucket          = &Error{"NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound}
+	ErrNoSuchKey             = &Error{"NoSuchKey", "The specified key does not exist", http.StatusNotFound}
+	ErrNoSuchUpload          = &Error{"NoSuchUpload", "The specified multipart upload does not exist", http.StatusNotFound}
+	ErrInvalidArgument       = &Error{"InvalidArgument", "Invalid argument", http.StatusBadRequest}
+	ErrInvalidKey            = &Error{"InvalidArgument", "Invalid object key", http.StatusBadRequest}
+	ErrInvalidPart           = &Error{"InvalidPart", "One or more of the specified parts could not be found", http.StatusBadRequest}
+	ErrInvalidPartOrder      = &Error{"InvalidPartOrder", "The parts must be listed in ascending order", http.StatusBadRequest}
+	ErrMalformedXML          = &Error{"MalformedXML", "The XML body is not well-formed", http.StatusBadRequest}
+	ErrKeyIsDirectory        = &Error{"ObjectExistsAsDirectory", "A folder exists at the specified key", http.StatusConflict}
+	ErrQuotaExceeded         = &Error{"QuotaExceeded", "The storage quota has been exceeded", http.StatusInsufficientStorage}
+	ErrVaultLocked           = &Error{"VaultLocked", "The key is inside an encrypted vault", http.StatusLocked}
+	ErrNotImplemented        = &Error{"NotImplemented", "This operation is not supported", http.StatusNotImplemented}
+	ErrMethodNotAllowed      = &Error{"MethodNotAllowed", "The method is not allowed against this resource", http.StatusMethodNotAllowed}
+	ErrInternal              = &Error{"InternalError", "We encountered an internal error", http.StatusInternalServerError}
+)
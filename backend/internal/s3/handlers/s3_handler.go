@@ -0,0 +1,571 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	quotadomain "github.com/infortech07/cubert/internal/quota/domain"
+	"github.com/infortech07/cubert/internal/s3/domain"
+	"github.com/infortech07/cubert/internal/s3/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	vaultdomain "github.com/infortech07/cubert/internal/vault/domain"
+)
+
+const (
+	s3Namespace  = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3TimeFormat = "2006-01-02T15:04:05.000Z"
+	// Cuerpos XML de CompleteMultipartUpload y DeleteObjects
+	maxXMLBodySize = 1 << 20
+	maxDeleteKeys  = 1000
+)
+
+var unsupportedSubresources = []string{
+	"acl", "cors", "encryption", "lifecycle", "object-lock", "policy", "tagging", "uploads", "versioning", "versions",
+}
+
+type S3Handler struct {
+	gatewayService   *services.GatewayService
+	signatureService *services.SignatureService
+}
+
+func NewS3Handler(gatewayService *services.GatewayService, signatureService *services.SignatureService) *S3Handler {
+	return &S3Handler{
+		gatewayService:   gatewayService,
+		signatureService: signatureService,
+	}
+}
+
+// Authenticate verifies the SigV4 signature and stores the owner of the API key in the context
+func (h *S3Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, err := utils.GenerateToken(8); err == nil {
+			w.Header().Set("X-Amz-Request-Id", strings.ToUpper(id))
+		}
+
+		user, err := h.signatureService.Authenticate(r)
+		if err != nil {
+			writeS3Error(w, r, err)
+			return
+		}
+		ctx := authdomain.ContextWithUser(r.Context(), user, nil)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ServeHTTP dispatches path-style requests: /s3/, /s3/{bucket} and /s3/{bucket}/{key}
+func (h *S3Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auditdomain.SetSource(r.Context(), domain.AuditSource)
+
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, domain.Prefix), "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	query := r.URL.Query()
+
+	switch {
+	case bucket == "":
+		if r.Method != http.MethodGet {
+			writeS3Error(w, r, domain.ErrMethodNotAllowed)
+			return
+		}
+		h.listBuckets(w, r)
+	case key == "":
+		switch {
+		case r.Method == http.MethodHead:
+			h.headBucket(w, r, bucket)
+		case r.Method == http.MethodGet && query.Has("location"):
+			h.getBucketLocation(w, r, bucket)
+		case r.Method == http.MethodGet && !hasSubresource(query):
+			h.listObjects(w, r, bucket)
+		case r.Method == http.MethodPost && query.Has("delete"):
+			h.deleteObjects(w, r, bucket)
+		default:
+			writeS3Error(w, r, domain.ErrNotImplemented)
+		}
+	default:
+		switch {
+		case r.Method == http.MethodGet, r.Method == http.MethodHead:
+			h.getObject(w, r, bucket, key)
+		case r.Method == http.MethodPut && query.Has("uploadId"):
+			h.uploadPart(w, r, bucket, key)
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+			h.copyObject(w, r, bucket, key)
+		case r.Method == http.MethodPut:
+			h.putObject(w, r, bucket, key)
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			h.createMultipartUpload(w, r, bucket, key)
+		case r.Method == http.MethodPost && query.Has("uploadId"):
+			h.completeMultipartUpload(w, r, bucket, key)
+		case r.Method == http.MethodDelete && query.Has("uploadId"):
+			h.abortMultipartUpload(w, r, bucket, key)
+		case r.Method == http.MethodDelete:
+			h.deleteObject(w, r, bucket, key)
+		default:
+			writeS3Error(w, r, domain.ErrMethodNotAllowed)
+		}
+	}
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Owner   owner        `xml:"Owner"`
+	Buckets []bucketItem `xml:"Buckets>Bucket"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type bucketItem struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+func (h *S3Handler) listBuckets(w http.ResponseWriter, r *http.Request) {
+	auditdomain.SetAction(r.Context(), "s3.list_buckets")
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	result := listAllMyBucketsResult{
+		Xmlns: s3Namespace,
+		Owner: owner{ID: user.ID, DisplayName: user.Username},
+	}
+	for _, bucket := range h.gatewayService.ListBuckets(r.Context()) {
+		result.Buckets = append(result.Buckets, bucketItem{
+			Name:         bucket.Name,
+			CreationDate: bucket.CreatedAt.UTC().Format(s3TimeFormat),
+		})
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *S3Handler) headBucket(w http.ResponseWriter, r *http.Request, bucketName string) {
+	auditdomain.SetAction(r.Context(), "s3.head_bucket")
+	bucket, err := h.gatewayService.Bucket(r.Context(), bucketName)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	auditdomain.AddPaths(r.Context(), bucket.Path)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *S3Handler) getBucketLocation(w http.ResponseWriter, r *http.Request, bucketName string) {
+	auditdomain.SetAction(r.Context(), "s3.get_bucket_location")
+	if _, err := h.gatewayService.Bucket(r.Context(), bucketName); err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	writeXML(w, http.StatusOK, struct {
+		XMLName xml.Name `xml:"LocationConstraint"`
+		Xmlns   string   `xml:"xmlns,attr"`
+	}{Xmlns: s3Namespace})
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Marker                *string        `xml:"Marker"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	KeyCount              *int           `xml:"KeyCount"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	Contents              []objectItem   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type objectItem struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listObjects serves ListObjectsV2 (list-type=2) and the original ListObjects
+func (h *S3Handler) listObjects(w http.ResponseWriter, r *http.Request, bucketName string) {
+	query := r.URL.Query()
+	v2 := query.Get("list-type") == "2"
+	auditdomain.SetAction(r.Context(), "s3.list_objects")
+
+	opts := domain.ListOptions{
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get("delimiter"),
+		MaxKeys:   domain.MaxKeys,
+	}
+	if value := query.Get("max-keys"); value != "" {
+		maxKeys, err := strconv.Atoi(value)
+		if err != nil || maxKeys < 0 {
+			writeS3Error(w, r, domain.ErrInvalidArgument)
+			return
+		}
+		opts.MaxKeys = maxKeys
+	}
+	if v2 {
+		opts.StartAfter = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				writeS3Error(w, r, domain.ErrInvalidArgument)
+				return
+			}
+			opts.StartAfter = string(decoded)
+		}
+	} else {
+		opts.StartAfter = query.Get("marker")
+	}
+
+	result := listBucketResult{
+		Xmlns:        s3Namespace,
+		Name:         bucketName,
+		MaxKeys:      opts.MaxKeys,
+		EncodingType: query.Get("encoding-type"),
+	}
+	encode := func(value string) string { return value }
+	if result.EncodingType == "url" {
+		encode = encodeKey
+	}
+	result.Prefix = encode(opts.Prefix)
+	result.Delimiter = encode(opts.Delimiter)
+
+	// max-keys=0 devuelve una página vacía sin recorrer nada
+	listing := &domain.ListResult{}
+	if opts.MaxKeys > 0 {
+		var err error
+		listing, err = h.gatewayService.ListObjects(r.Context(), bucketName, opts)
+		if err != nil {
+			writeS3Error(w, r, err)
+			return
+		}
+	} else if _, err := h.gatewayService.Bucket(r.Context(), bucketName); err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+
+	for _, object := range listing.Objects {
+		result.Contents = append(result.Contents, objectItem{
+			Key:          encode(object.Key),
+			LastModified: object.ModTime.UTC().Format(s3TimeFormat),
+			ETag:         object.ETag,
+			Size:         object.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+	for _, prefix := range listing.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(prefix)})
+	}
+	result.IsTruncated = listing.IsTruncated
+
+	if v2 {
+		keyCount := len(result.Contents) + len(result.CommonPrefixes)
+		result.KeyCount = &keyCount
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = encode(query.Get("start-after"))
+		if listing.IsTruncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(listing.NextMarker))
+		}
+	} else {
+		marker := encode(query.Get("marker"))
+		result.Marker = &marker
+		if listing.IsTruncated {
+			result.NextMarker = encode(listing.NextMarker)
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *S3Handler) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	auditdomain.SetAction(r.Context(), "s3."+strings.ToLower(r.Method)+"_object")
+	file, info, path, err := h.gatewayService.OpenObject(r.Context(), bucket, key)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	auditdomain.AddPaths(r.Context(), path)
+
+	w.Header().Set("ETag", services.ETag(info))
+	w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+	if file == nil {
+		// Marcador de carpeta: objeto vacío
+		w.Header().Set("Content-Type", "application/x-directory")
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", utils.GetContentType(info.Name()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+func (h *S3Handler) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	auditdomain.SetAction(r.Context(), "s3.put_object")
+	etag, path, err := h.gatewayService.PutObject(r.Context(), bucket, key, r.Body, r.ContentLength)
+	auditdomain.AddPaths(r.Context(), path)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+func (h *S3Handler) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	auditdomain.SetAction(r.Context(), "s3.copy_object")
+
+	// X-Amz-Copy-Source: [/]bucket/key, codificado como URL; no hay versiones de objeto
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil || strings.Contains(source, "?versionId=") {
+		writeS3Error(w, r, domain.ErrInvalidArgument)
+		return
+	}
+	srcBucket, srcKey, ok := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !ok || srcBucket == "" || srcKey == "" {
+		writeS3Error(w, r, domain.ErrInvalidArgument)
+		return
+	}
+
+	etag, srcPath, path, err := h.gatewayService.CopyObject(r.Context(), srcBucket, srcKey, bucket, key)
+	auditdomain.AddPaths(r.Context(), srcPath, path)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	writeXML(w, http.StatusOK, copyObjectResult{
+		Xmlns:        s3Namespace,
+		LastModified: time.Now().UTC().Format(s3TimeFormat),
+		ETag:         etag,
+	})
+}
+
+func (h *S3Handler) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	auditdomain.SetAction(r.Context(), "s3.delete_object")
+	path, err := h.gatewayService.DeleteObject(r.Context(), bucket, key)
+	auditdomain.AddPaths(r.Context(), path)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type deleteResult struct {
+	XMLName xml.Name      `xml:"DeleteResult"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	Deleted []deletedItem `xml:"Deleted"`
+	Errors  []deleteError `xml:"Error"`
+}
+
+type deletedItem struct {
+	Key string `xml:"Key"`
+}
+
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (h *S3Handler) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	auditdomain.SetAction(r.Context(), "s3.delete_objects")
+	var request domain.Delete
+	if err := xml.NewDecoder(io.LimitReader(r.Body, maxXMLBodySize)).Decode(&request); err != nil ||
+		len(request.Objects) > maxDeleteKeys {
+		writeS3Error(w, r, domain.ErrMalformedXML)
+		return
+	}
+
+	result := deleteResult{Xmlns: s3Namespace}
+	for _, object := range request.Objects {
+		path, err := h.gatewayService.DeleteObject(r.Context(), bucket, object.Key)
+		auditdomain.AddPaths(r.Context(), path)
+		if err != nil {
+			s3Err := toS3Error(err)
+			result.Errors = append(result.Errors, deleteError{Key: object.Key, Code: s3Err.Code, Message: s3Err.Message})
+			continue
+		}
+		if !request.Quiet {
+			result.Deleted = append(result.Deleted, deletedItem{Key: object.Key})
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *S3Handler) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	auditdomain.SetAction(r.Context(), "s3.create_multipart_upload")
+	upload, err := h.gatewayService.CreateMultipartUpload(r.Context(), bucket, key)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	writeXML(w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}{Xmlns: s3Namespace, Bucket: bucket, Key: key, UploadID: upload.ID})
+}
+
+func (h *S3Handler) uploadPart(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	auditdomain.SetAction(r.Context(), "s3.upload_part")
+	query := r.URL.Query()
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil {
+		writeS3Error(w, r, domain.ErrInvalidArgument)
+		return
+	}
+
+	etag, err := h.gatewayService.UploadPart(r.Context(), bucket, key, query.Get("uploadId"), partNumber, r.Body, r.ContentLength)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *S3Handler) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	auditdomain.SetAction(r.Context(), "s3.complete_multipart_upload")
+	var request domain.CompleteMultipartUpload
+	if err := xml.NewDecoder(io.LimitReader(r.Body, maxXMLBodySize)).Decode(&request); err != nil {
+		writeS3Error(w, r, domain.ErrMalformedXML)
+		return
+	}
+
+	etag, path, err := h.gatewayService.CompleteMultipartUpload(r.Context(), bucket, key, r.URL.Query().Get("uploadId"), request.Parts)
+	auditdomain.AddPaths(r.Context(), path)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	writeXML(w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string   `xml:"Location"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		ETag     string   `xml:"ETag"`
+	}{Xmlns: s3Namespace, Location: r.URL.Path, Bucket: bucket, Key: key, ETag: etag})
+}
+
+func (h *S3Handler) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	auditdomain.SetAction(r.Context(), "s3.abort_multipart_upload")
+	if err := h.gatewayService.AbortMultipartUpload(r.Context(), bucket, key, r.URL.Query().Get("uploadId")); err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeXML(w http.ResponseWriter, status int, body interface{}) {
+	data, err := xml.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+// toS3Error maps service errors to the closest S3 error
+func toS3Error(err error) *domain.Error {
+	var s3Err *domain.Error
+	switch {
+	case errors.As(err, &s3Err):
+		return s3Err
+	case errors.Is(err, authdomain.ErrAccessDenied), errors.Is(err, os.ErrPermission):
+		return domain.ErrAccessDenied
+	case errors.Is(err, os.ErrNotExist):
+		return domain.ErrNoSuchKey
+	case errors.Is(err, quotadomain.ErrQuotaExceeded):
+		return domain.ErrQuotaExceeded
+	case errors.Is(err, vaultdomain.ErrVaultLocked):
+		return domain.ErrVaultLocked
+	default:
+		return domain.ErrInternal
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, err error) {
+	s3Err := toS3Error(err)
+	auditdomain.SetError(r.Context(), err)
+	if s3Err == domain.ErrInternal {
+		log.Printf("s3: %s %s: %v", r.Method, r.URL.Path, err)
+	}
+
+	// HEAD no lleva cuerpo
+	if r.Method == http.MethodHead {
+		w.WriteHeader(s3Err.Status)
+		return
+	}
+	writeXML(w, s3Err.Status, struct {
+		XMLName   xml.Name `xml:"Error"`
+		Code      string   `xml:"Code"`
+		Message   string   `xml:"Message"`
+		Resource  string   `xml:"Resource"`
+		RequestID string   `xml:"RequestId"`
+	}{
+		Code:      s3Err.Code,
+		Message:   s3Err.Message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("X-Amz-Request-Id"),
+	})
+}
+
+// encodeKey applies encoding-type=url to keys and prefixes in listings
+func encodeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hasSubresource reports bucket subresources (?acl, ?versioning...) that are not supported
+func hasSubresource(query url.Values) bool {
+	for _, name := range unsupportedSubresources {
+		if query.Has(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/go-chi/chi/v5"
+
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	fsdomain "github.com/infortech07/cubert/internal/filesystem/domain"
+	fsservices "github.com/infortech07/cubert/internal/filesystem/services"
+	quotadomain "github.com/infortech07/cubert/internal/quota/domain"
+	quotaservices "github.com/infortech07/cubert/internal/quota/services"
+	"github.com/infortech07/cubert/internal/s3/domain"
+	"github.com/infortech07/cubert/internal/s3/services"
+	storageservices "github.com/infortech07/cubert/internal/storage/services"
+	vaultservices "github.com/infortech07/cubert/internal/vault/services"
+)
+
+// newTestGateway serves a "media" library root through the S3 handler and returns
+// the root and the credentials of an API key owned by an administrator
+func newTestGateway(t *testing.T) (string, string, aws.Credentials) {
+	t.Helper()
+	return newTestGatewayWith(t, quotaservices.QuotaConfig{})
+}
+
+// newTestGatewayWith also applies quotaConfig and turns the given folders of the root
+// into vaults
+func newTestGatewayWith(t *testing.T, quotaConfig quotaservices.QuotaConfig, vaults ...string) (string, string, aws.Credentials) {
+	t.Helper()
+
+	dataDir, libraryDir := t.TempDir(), t.TempDir()
+	root := filepath.Join(libraryDir, "media")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatalf("creating root: %v", err)
+	}
+	roots := []string{root}
+
+	users, err := authservices.NewUserStore(dataDir)
+	if err != nil {
+		t.Fatalf("NewUserStore: %v", err)
+	}
+	authService := authservices.NewAuthService(users, authservices.NewSessionStore(), authservices.NewTOTPService("Cubert"), time.Hour)
+	admin, err := authService.CreateUser(context.Background(), "admin", "", "Admin#1234", authdomain.RoleAdmin)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	key, err := authService.CreateAPIKey(context.Background(), admin.ID, "sdk")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	trash, err := fsservices.NewTrashService(dataDir)
+	if err != nil {
+		t.Fatalf("NewTrashService: %v", err)
+	}
+	versions, err := fsservices.NewVersionService(dataDir, roots, fsdomain.VersionRetention{})
+	if err != nil {
+		t.Fatalf("NewVersionService: %v", err)
+	}
+	quotas, err := quotaservices.NewQuotaService(quotaConfig, dataDir, roots, versions)
+	if err != nil {
+		t.Fatalf("NewQuotaService: %v", err)
+	}
+	vaultService, err := vaultservices.NewVaultService(dataDir, storageservices.NewLocalStorage())
+	if err != nil {
+		t.Fatalf("NewVaultService: %v", err)
+	}
+	for _, name := range vaults {
+		if _, err := vaultService.Create(context.Background(), filepath.Join(root, name), "vault-passphrase"); err != nil {
+			t.Fatalf("creating vault %s: %v", name, err)
+		}
+	}
+	storage := vaultservices.NewVaultStorage(storageservices.NewLocalStorage(), vaultService)
+	gateway, err := services.NewGatewayService(dataDir, roots, authservices.NewACLService(users), trash, versions, quotas, storage)
+	if err != nil {
+		t.Fatalf("NewGatewayService: %v", err)
+	}
+
+	handler := NewS3Handler(gateway, services.NewSignatureService(authService))
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(handler.Authenticate)
+		r.Handle(domain.Prefix, handler)
+		r.Handle(domain.Prefix+"/*", handler)
+	})
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	return root, server.URL + domain.Prefix, aws.Credentials{
+		AccessKeyID:     key.AccessKeyID,
+		SecretAccessKey: key.SecretAccessKey,
+	}
+}
+
+func newTestClient(endpoint string, creds aws.Credentials) *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(creds.AccessKeyID, creds.SecretAccessKey, ""),
+	})
+}
+
+func s3ErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+func TestS3ListObjectsWithDelimiter(t *testing.T) {
+	root, endpoint, creds := newTestGateway(t)
+	client := newTestClient(endpoint, creds)
+	ctx := context.Background()
+
+	for name, content := range map[string]string{
+		"a.txt":              "alpha",
+		"docs/report.txt":    "report",
+		"docs/2024/q1.txt":   "q1",
+		"photos/beach.jpg":   "jpeg",
+		"photos/summer.jpg":  "jpeg",
+		"photos/raw/img.cr2": "raw",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("seeding %s: %v", name, err)
+		}
+	}
+
+	out, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String("media"),
+		Delimiter: aws.String("/"),
+	})
+	if err != nil {
+		t.Fatalf("ListObjectsV2: %v", err)
+	}
+	if got, want := listedKeys(out), "a.txt,docs/,photos/"; got != want {
+		t.Errorf("root listed %q, want %q", got, want)
+	}
+
+	out, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String("media"),
+		Prefix:    aws.String("photos/"),
+		Delimiter: aws.String("/"),
+	})
+	if err != nil {
+		t.Fatalf("ListObjectsV2(photos/): %v", err)
+	}
+	if got, want := listedKeys(out), "photos/beach.jpg,photos/summer.jpg,photos/raw/"; got != want {
+		t.Errorf("photos/ listed %q, want %q", got, want)
+	}
+
+	// Sin delimitador se recorre todo el subárbol, por páginas
+	out, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String("media"),
+		Prefix:  aws.String("photos/"),
+		MaxKeys: aws.Int32(2),
+	})
+	if err != nil {
+		t.Fatalf("ListObjectsV2(paged): %v", err)
+	}
+	if !aws.ToBool(out.IsTruncated) || len(out.Contents) != 2 {
+		t.Fatalf("first page = %q, truncated %v", listedKeys(out), aws.ToBool(out.IsTruncated))
+	}
+	next, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:            aws.String("media"),
+		Prefix:            aws.String("photos/"),
+		MaxKeys:           aws.Int32(2),
+		ContinuationToken: out.NextContinuationToken,
+	})
+	if err != nil {
+		t.Fatalf("ListObjectsV2(next page): %v", err)
+	}
+	if got, want := listedKeys(next), "photos/summer.jpg"; got != want {
+		t.Errorf("second page = %q, want %q", got, want)
+	}
+
+	if _, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("missing")}); s3ErrorCode(err) != "NoSuchBucket" {
+		t.Errorf("listing a missing bucket error = %v, want NoSuchBucket", err)
+	}
+}
+
+func listedKeys(out *s3.ListObjectsV2Output) string {
+	var keys []string
+	for _, object := range out.Contents {
+		keys = append(keys, aws.ToString(object.Key))
+	}
+	for _, prefix := range out.CommonPrefixes {
+		keys = append(keys, aws.ToString(prefix.Prefix))
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestS3PutGetHeadDelete(t *testing.T) {
+	root, endpoint, creds := newTestGateway(t)
+	client := newTestClient(endpoint, creds)
+	ctx := context.Background()
+
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("media"),
+		Key:    aws.String("docs/notes.txt"),
+		Body:   strings.NewReader("hello from the sdk"),
+	})
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "docs", "notes.txt")); err != nil || string(data) != "hello from the sdk" {
+		t.Errorf("file on disk = %q, %v", data, err)
+	}
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("media"), Key: aws.String("docs/notes.txt")})
+	if err != nil {
+		t.Fatalf("HeadObject: %v", err)
+	}
+	if aws.ToInt64(head.ContentLength) != 18 || aws.ToString(head.ETag) == "" {
+		t.Errorf("head = length %d, etag %q", aws.ToInt64(head.ContentLength), aws.ToString(head.ETag))
+	}
+
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String("media"),
+		Key:    aws.String("docs/notes.txt"),
+		Range:  aws.String("bytes=11-"),
+	})
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	data, _ := io.ReadAll(get.Body)
+	get.Body.Close()
+	if string(data) != "the sdk" {
+		t.Errorf("range read = %q, want %q", data, "the sdk")
+	}
+
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("media"), Key: aws.String("docs/notes.txt")}); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "docs", "notes.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("deleted file still on disk: %v", err)
+	}
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("media"), Key: aws.String("docs/notes.txt")})
+	var notFound *types.NotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("HeadObject after delete error = %v, want NotFound", err)
+	}
+	if _, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("media"), Key: aws.String("docs/notes.txt")}); s3ErrorCode(err) != "NoSuchKey" {
+		t.Errorf("GetObject after delete error = %v, want NoSuchKey", err)
+	}
+	// Como en S3, borrar una clave inexistente no es un error
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("media"), Key: aws.String("docs/notes.txt")}); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestS3MultipartUpload(t *testing.T) {
+	root, endpoint, creds := newTestGateway(t)
+	client := newTestClient(endpoint, creds)
+	ctx := context.Background()
+
+	created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String("media"),
+		Key:    aws.String("big/video.bin"),
+	})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+
+	contents := [][]byte{bytes.Repeat([]byte("a"), 5<<20), []byte("tail")}
+	var parts []types.CompletedPart
+	for i, content := range contents {
+		part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String("media"),
+			Key:        aws.String("big/video.bin"),
+			UploadId:   created.UploadId,
+			PartNumber: aws.Int32(int32(i + 1)),
+			Body:       bytes.NewReader(content),
+		})
+		if err != nil {
+			t.Fatalf("UploadPart %d: %v", i+1, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: part.ETag, PartNumber: aws.Int32(int32(i + 1))})
+	}
+
+	// Una parte con una ETag que no coincide invalida la petición
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("media"),
+		Key:             aws.String("big/video.bin"),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: []types.CompletedPart{{ETag: aws.String(`"bad"`), PartNumber: aws.Int32(1)}}},
+	})
+	if s3ErrorCode(err) != "InvalidPart" {
+		t.Errorf("completing with a bad ETag error = %v, want InvalidPart", err)
+	}
+
+	if _, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("media"),
+		Key:             aws.String("big/video.bin"),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	}); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "big", "video.bin"))
+	if err != nil {
+		t.Fatalf("reading the uploaded file: %v", err)
+	}
+	if !bytes.Equal(data, append(append([]byte{}, contents[0]...), contents[1]...)) {
+		t.Errorf("uploaded file has %d bytes, want %d", len(data), len(contents[0])+len(contents[1]))
+	}
+
+	// La subida terminada ya no admite más partes
+	_, err = client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String("media"),
+		Key:        aws.String("big/video.bin"),
+		UploadId:   created.UploadId,
+		PartNumber: aws.Int32(3),
+		Body:       strings.NewReader("late"),
+	})
+	if s3ErrorCode(err) != "NoSuchUpload" {
+		t.Errorf("UploadPart after completion error = %v, want NoSuchUpload", err)
+	}
+}
+
+func TestS3UploadPartChecksQuota(t *testing.T) {
+	_, endpoint, creds := newTestGatewayWith(t, quotaservices.QuotaConfig{
+		RootLimits: quotadomain.Limits{MaxBytes: 8 << 20},
+	})
+	client := newTestClient(endpoint, creds)
+	ctx := context.Background()
+
+	created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String("media"),
+		Key:    aws.String("big.bin"),
+	})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	uploadPart := func(number int32) error {
+		_, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String("media"),
+			Key:        aws.String("big.bin"),
+			UploadId:   created.UploadId,
+			PartNumber: aws.Int32(number),
+			Body:       bytes.NewReader(bytes.Repeat([]byte("a"), 5<<20)),
+		})
+		return err
+	}
+
+	if err := uploadPart(1); err != nil {
+		t.Fatalf("UploadPart 1: %v", err)
+	}
+	// Las partes ya recibidas cuentan aunque la subida no se haya completado
+	if err := uploadPart(2); s3ErrorCode(err) != "QuotaExceeded" {
+		t.Errorf("UploadPart over the quota error = %v, want QuotaExceeded", err)
+	}
+	// Reemplazar una parte solo cuenta su nuevo tamaño
+	if err := uploadPart(1); err != nil {
+		t.Errorf("replacing part 1: %v", err)
+	}
+}
+
+func TestS3RefusesVaults(t *testing.T) {
+	root, endpoint, creds := newTestGatewayWith(t, quotaservices.QuotaConfig{}, "secret")
+	client := newTestClient(endpoint, creds)
+	ctx := context.Background()
+
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("alpha"), 0644); err != nil {
+		t.Fatalf("seeding: %v", err)
+	}
+
+	// La bóveda no aparece en los listados, ni con delimitador ni sin él
+	for _, delimiter := range []*string{aws.String("/"), nil} {
+		out, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("media"), Delimiter: delimiter})
+		if err != nil {
+			t.Fatalf("ListObjectsV2: %v", err)
+		}
+		if got := listedKeys(out); got != "a.txt" {
+			t.Errorf("listed %q, want only a.txt", got)
+		}
+	}
+	_, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("media"), Prefix: aws.String("secret/")})
+	if s3ErrorCode(err) != "VaultLocked" {
+		t.Errorf("listing inside the vault error = %v, want VaultLocked", err)
+	}
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("media"),
+		Key:    aws.String("secret/plain.txt"),
+		Body:   strings.NewReader("plaintext"),
+	})
+	if s3ErrorCode(err) != "VaultLocked" {
+		t.Errorf("PutObject into the vault error = %v, want VaultLocked", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "secret", "plain.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("plaintext written into the vault: %v", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(root, "secret"))
+	for _, entry := range entries {
+		_, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("media"), Key: aws.String("secret/" + entry.Name())})
+		if s3ErrorCode(err) != "VaultLocked" {
+			t.Errorf("GetObject on %s error = %v, want VaultLocked", entry.Name(), err)
+		}
+	}
+}
+
+func TestS3RejectsBadSignature(t *testing.T) {
+	root, endpoint, creds := newTestGateway(t)
+	ctx := context.Background()
+
+	wrongSecret := newTestClient(endpoint, aws.Credentials{AccessKeyID: creds.AccessKeyID, SecretAccessKey: "not-the-secret"})
+	_, err := wrongSecret.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("media"),
+		Key:    aws.String("forged.txt"),
+		Body:   strings.NewReader("forged"),
+	})
+	if s3ErrorCode(err) != "SignatureDoesNotMatch" {
+		t.Errorf("PutObject with a wrong secret error = %v, want SignatureDoesNotMatch", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "forged.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("forged object was written: %v", err)
+	}
+
+	unknownKey := newTestClient(endpoint, aws.Credentials{AccessKeyID: "CUBUNKNOWN", SecretAccessKey: creds.SecretAccessKey})
+	if _, err := unknownKey.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("media")}); s3ErrorCode(err) != "InvalidAccessKeyId" {
+		t.Errorf("listing with an unknown key error = %v, want InvalidAccessKeyId", err)
+	}
+
+	anonymous := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		UsePathStyle: true,
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	if _, err := anonymous.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("media")}); s3ErrorCode(err) != "MissingSecurityHeader" {
+		t.Errorf("anonymous listing error = %v, want MissingSecurityHeader", err)
+	}
+}
@@ -0,0 +1,777 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	fsdomain "github.com/infortech07/cubert/internal/filesystem/domain"
+	fsservices "github.com/infortech07/cubert/internal/filesystem/services"
+	quotaservices "github.com/infortech07/cubert/internal/quota/services"
+	"github.com/infortech07/cubert/internal/s3/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	vaultservices "github.com/infortech07/cubert/internal/vault/services"
+)
+
+const (
+	maxPartNumber = 10000
+	// Las subidas multiparte abandonadas se eliminan al arrancar
+	staleUploadAge = 7 * 24 * time.Hour
+	uploadMetaFile = "upload.json"
+)
+
+var invalidBucketChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// GatewayService maps S3 buckets to library roots and object keys to paths below them.
+// Objects are read and written on the local disk, so the encrypted vaults of storage
+// are left out of listings and refused as keys.
+type GatewayService struct {
+	buckets    []domain.Bucket
+	storage    storagedomain.Storage
+	acl        *authservices.ACLService
+	trash      *fsservices.TrashService
+	versions   *fsservices.VersionService
+	quotas     *quotaservices.QuotaService
+	uploadsDir string
+
+	// staging suma los bytes de las partes que se están recibiendo en cada subida
+	mu      sync.Mutex
+	staging map[string]int64
+}
+
+func NewGatewayService(
+	dataDir string,
+	roots []string,
+	acl *authservices.ACLService,
+	trash *fsservices.TrashService,
+	versions *fsservices.VersionService,
+	quotas *quotaservices.QuotaService,
+	storage storagedomain.Storage,
+) (*GatewayService, error) {
+	s := &GatewayService{
+		storage:    storage,
+		acl:        acl,
+		trash:      trash,
+		versions:   versions,
+		quotas:     quotas,
+		uploadsDir: filepath.Join(dataDir, "s3", "uploads"),
+		staging:    make(map[string]int64),
+	}
+	if err := os.MkdirAll(s.uploadsDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create uploads directory: %w", err)
+	}
+	s.removeStaleUploads()
+
+	// Mismos nombres y sufijos que las carpetas de WebDAV, con las reglas de los buckets
+	names := utils.RootNames(roots, bucketName)
+	for i, root := range roots {
+		bucket := domain.Bucket{Name: names[i], Path: filepath.Clean(root)}
+		if info, err := os.Stat(bucket.Path); err == nil {
+			bucket.CreatedAt = info.ModTime()
+		}
+		s.buckets = append(s.buckets, bucket)
+	}
+	return s, nil
+}
+
+// bucketName turns a folder name into a valid bucket name (3-63 lowercase letters, digits
+// and hyphens), leaving room for the suffix of duplicated names
+func bucketName(folder string) string {
+	name := strings.ToLower(folder)
+	name = strings.Trim(invalidBucketChars.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		name = "root"
+	}
+	if len(name) < 3 {
+		name += "-root"
+	}
+	if len(name) > 56 {
+		name = strings.TrimRight(name[:56], "-")
+	}
+	return name
+}
+
+// ListBuckets returns the buckets the user can read or browse into
+func (s *GatewayService) ListBuckets(ctx context.Context) []domain.Bucket {
+	var buckets []domain.Bucket
+	for _, bucket := range s.buckets {
+		if readable, traversable := s.access(ctx, bucket.Path); readable || traversable {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets
+}
+
+func (s *GatewayService) Bucket(ctx context.Context, name string) (*domain.Bucket, error) {
+	for _, bucket := range s.buckets {
+		if bucket.Name == name {
+			if readable, traversable := s.access(ctx, bucket.Path); !readable && !traversable {
+				return nil, domain.ErrAccessDenied
+			}
+			return &bucket, nil
+		}
+	}
+	return nil, domain.ErrNoSuchBucket
+}
+
+// ListObjects lists the keys of a bucket in lexical order. With the "/" delimiter only the
+// folder named by the prefix is read; other listings walk the whole subtree.
+func (s *GatewayService) ListObjects(ctx context.Context, bucketName string, opts domain.ListOptions) (*domain.ListResult, error) {
+	bucket, err := s.Bucket(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxKeys <= 0 || opts.MaxKeys > domain.MaxKeys {
+		opts.MaxKeys = domain.MaxKeys
+	}
+
+	// Carpeta de partida: la parte del prefijo hasta la última barra
+	baseKey := opts.Prefix[:strings.LastIndex(opts.Prefix, "/")+1]
+	if baseKey != "" && validateKey(baseKey) != nil {
+		return &domain.ListResult{}, nil
+	}
+	baseDir := filepath.Join(bucket.Path, filepath.FromSlash(baseKey))
+	if info, err := os.Stat(baseDir); err != nil || !info.IsDir() {
+		return &domain.ListResult{}, nil
+	}
+	if err := vaultservices.CheckDiskPath(ctx, s.storage, baseDir); err != nil {
+		return nil, err
+	}
+
+	var entries []domain.Object
+	if opts.Delimiter == "/" {
+		entries, err = s.readFolder(ctx, baseDir, baseKey)
+	} else {
+		entries, err = s.walkFolder(ctx, baseDir, baseKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Como en S3, una carpeta vacía aparece como su propia clave terminada en "/"
+	if baseKey != "" && len(entries) == 0 && isEmptyDir(baseDir) {
+		if readable, _ := s.access(ctx, baseDir); readable {
+			if info, err := os.Stat(baseDir); err == nil {
+				entries = append(entries, domain.Object{Key: baseKey, ModTime: info.ModTime(), ETag: ETag(info)})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	result := &domain.ListResult{}
+	seenPrefixes := make(map[string]bool)
+	count := 0
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Key, opts.Prefix) {
+			continue
+		}
+
+		key, isPrefix := entry.Key, false
+		if opts.Delimiter != "" {
+			if i := strings.Index(entry.Key[len(opts.Prefix):], opts.Delimiter); i >= 0 {
+				key = entry.Key[:len(opts.Prefix)+i+len(opts.Delimiter)]
+				isPrefix = true
+			}
+		}
+		if key <= opts.StartAfter || (isPrefix && seenPrefixes[key]) {
+			continue
+		}
+
+		if count == opts.MaxKeys {
+			result.IsTruncated = true
+			break
+		}
+		count++
+		result.NextMarker = key
+		if isPrefix {
+			seenPrefixes[key] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, key)
+		} else {
+			result.Objects = append(result.Objects, entry)
+		}
+	}
+	if !result.IsTruncated {
+		result.NextMarker = ""
+	}
+	return result, nil
+}
+
+// readFolder lists one folder; subfolders are returned as keys ending in "/"
+func (s *GatewayService) readFolder(ctx context.Context, dir, baseKey string) ([]domain.Object, error) {
+	readable, traversable := s.access(ctx, dir)
+	if !readable && !traversable {
+		return nil, domain.ErrAccessDenied
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []domain.Object
+	for _, entry := range dirEntries {
+		path := filepath.Join(dir, entry.Name())
+		if !readable {
+			if childReadable, childTraversable := s.access(ctx, path); !childReadable && !childTraversable {
+				continue
+			}
+		}
+		if vaultservices.CheckDiskPath(ctx, s.storage, path) != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if object, ok := objectFor(baseKey+entry.Name(), info); ok {
+			entries = append(entries, object)
+		}
+	}
+	return entries, nil
+}
+
+// walkFolder lists every file below dir; empty folders are returned as keys ending in "/"
+func (s *GatewayService) walkFolder(ctx context.Context, dir, baseKey string) ([]domain.Object, error) {
+	// Si se puede leer la carpeta de partida, también todo su contenido
+	dirReadable, _ := s.access(ctx, dir)
+
+	var entries []domain.Object
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path != dir && vaultservices.CheckDiskPath(ctx, s.storage, path) != nil {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		readable, traversable := true, true
+		if !dirReadable {
+			readable, traversable = s.access(ctx, path)
+		}
+		if entry.IsDir() {
+			if !readable && !traversable {
+				if path == dir {
+					return domain.ErrAccessDenied
+				}
+				return filepath.SkipDir
+			}
+			if path != dir && readable && isEmptyDir(path) {
+				if info, err := entry.Info(); err == nil {
+					entries = append(entries, domain.Object{Key: keyFor(dir, baseKey, path) + "/", ModTime: info.ModTime(), ETag: ETag(info)})
+				}
+			}
+			return nil
+		}
+		if !readable {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		if object, ok := objectFor(keyFor(dir, baseKey, path), info); ok && !info.IsDir() {
+			entries = append(entries, object)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// OpenObject opens the file behind a key. A key ending in "/" names a folder and reads as empty.
+func (s *GatewayService) OpenObject(ctx context.Context, bucketName, key string) (*os.File, os.FileInfo, string, error) {
+	path, err := s.resolve(ctx, bucketName, key, authdomain.AccessRead)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, "", domain.ErrNoSuchKey
+	}
+	if info.IsDir() != strings.HasSuffix(key, "/") {
+		return nil, nil, "", domain.ErrNoSuchKey
+	}
+	if info.IsDir() {
+		return nil, info, path, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return file, info, path, nil
+}
+
+// PutObject writes body to the key, creating missing folders. A key ending in "/" creates a
+// folder. The previous content of an overwritten file is kept as a version. size is the
+// length of body, or -1 when unknown.
+func (s *GatewayService) PutObject(ctx context.Context, bucketName, key string, body io.Reader, size int64) (string, string, error) {
+	path, err := s.resolve(ctx, bucketName, key, authdomain.AccessWrite)
+	if err != nil {
+		return "", "", err
+	}
+
+	if strings.HasSuffix(key, "/") {
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return "", "", err
+		}
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return "", "", err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", "", err
+		}
+		return ETag(info), path, nil
+	}
+
+	allowance, err := s.reserve(ctx, path, size)
+	if err != nil {
+		return "", "", err
+	}
+	if size < 0 {
+		body = allowance.LimitReader(body)
+	}
+	if err := s.prepareTarget(path); err != nil {
+		return "", "", err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".cubert-s3-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = io.Copy(temp, body)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	info, err := s.commit(ctx, temp.Name(), path)
+	if err != nil {
+		return "", "", err
+	}
+	return ETag(info), path, nil
+}
+
+// CopyObject copies an object, possibly between buckets, with the same rules as PutObject
+// for the target. It returns the new ETag, the source and target paths.
+func (s *GatewayService) CopyObject(ctx context.Context, srcBucket, srcKey, bucketName, key string) (string, string, string, error) {
+	file, info, srcPath, err := s.OpenObject(ctx, srcBucket, srcKey)
+	if err != nil {
+		return "", "", "", err
+	}
+	var body io.Reader = strings.NewReader("")
+	size := int64(0)
+	if file != nil {
+		defer file.Close()
+		body, size = file, info.Size()
+	}
+	if strings.HasSuffix(srcKey, "/") != strings.HasSuffix(key, "/") {
+		return "", srcPath, "", domain.ErrInvalidKey
+	}
+
+	etag, path, err := s.PutObject(ctx, bucketName, key, body, size)
+	return etag, srcPath, path, err
+}
+
+// DeleteObject moves the file to the trash. Folders are only removed when empty, and
+// deleting a missing key succeeds as in S3.
+func (s *GatewayService) DeleteObject(ctx context.Context, bucketName, key string) (string, error) {
+	path, err := s.resolve(ctx, bucketName, key, authdomain.AccessWrite)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil || info.IsDir() != strings.HasSuffix(key, "/") {
+		return path, nil
+	}
+	if info.IsDir() && !isEmptyDir(path) {
+		return path, nil
+	}
+
+	user, _ := authdomain.UserFromContext(ctx)
+	if _, err := s.trash.Move(ctx, path, user.ID); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *GatewayService) CreateMultipartUpload(ctx context.Context, bucketName, key string) (*domain.Upload, error) {
+	if strings.HasSuffix(key, "/") {
+		return nil, domain.ErrInvalidKey
+	}
+	if _, err := s.resolve(ctx, bucketName, key, authdomain.AccessWrite); err != nil {
+		return nil, err
+	}
+
+	id, err := utils.GenerateToken(16)
+	if err != nil {
+		return nil, err
+	}
+	user, _ := authdomain.UserFromContext(ctx)
+	upload := &domain.Upload{
+		ID:        id,
+		Bucket:    bucketName,
+		Key:       key,
+		UserID:    user.ID,
+		CreatedAt: time.Now(),
+	}
+
+	dir := filepath.Join(s.uploadsDir, id)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := utils.SaveJSONFile(filepath.Join(dir, uploadMetaFile), upload); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return upload, nil
+}
+
+// UploadPart stores one part and returns its ETag (the MD5 of its content). The parts
+// already staged count against the quota of the target, so an upload cannot fill the disk
+// before it is completed. size is the length of body, or -1 when unknown.
+func (s *GatewayService) UploadPart(ctx context.Context, bucketName, key, uploadID string, partNumber int, body io.Reader, size int64) (string, error) {
+	if partNumber < 1 || partNumber > maxPartNumber {
+		return "", fmt.Errorf("%w: part number must be between 1 and %d", domain.ErrInvalidArgument, maxPartNumber)
+	}
+	dir, _, err := s.upload(ctx, bucketName, key, uploadID)
+	if err != nil {
+		return "", err
+	}
+	path, err := s.resolve(ctx, bucketName, key, authdomain.AccessWrite)
+	if err != nil {
+		return "", err
+	}
+
+	allowance, release, err := s.reservePart(ctx, dir, path, uploadID, partNumber, size)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	if size < 0 {
+		body = allowance.LimitReader(body)
+	}
+
+	temp, err := os.CreateTemp(dir, ".part-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(temp, hash), body)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if err := os.Rename(temp.Name(), partPath(dir, partNumber)); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(partPath(dir, partNumber)+".etag", []byte(sum), 0600); err != nil {
+		return "", err
+	}
+	return `"` + sum + `"`, nil
+}
+
+// CompleteMultipartUpload joins the listed parts into the target file
+func (s *GatewayService) CompleteMultipartUpload(ctx context.Context, bucketName, key, uploadID string, parts []domain.CompletedPart) (string, string, error) {
+	dir, _, err := s.upload(ctx, bucketName, key, uploadID)
+	if err != nil {
+		return "", "", err
+	}
+	path, err := s.resolve(ctx, bucketName, key, authdomain.AccessWrite)
+	if err != nil {
+		return "", "", err
+	}
+	if len(parts) == 0 {
+		return "", "", domain.ErrMalformedXML
+	}
+	for i, part := range parts {
+		if i > 0 && part.PartNumber <= parts[i-1].PartNumber {
+			return "", "", domain.ErrInvalidPartOrder
+		}
+		stored, err := os.ReadFile(partPath(dir, part.PartNumber) + ".etag")
+		if err != nil || string(stored) != strings.Trim(part.ETag, `"`) {
+			return "", "", fmt.Errorf("%w: part %d", domain.ErrInvalidPart, part.PartNumber)
+		}
+	}
+
+	var size int64
+	for _, part := range parts {
+		if info, err := os.Stat(partPath(dir, part.PartNumber)); err == nil {
+			size += info.Size()
+		}
+	}
+	if _, err := s.reserve(ctx, path, size); err != nil {
+		return "", "", err
+	}
+	if err := s.prepareTarget(path); err != nil {
+		return "", "", err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".cubert-s3-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(temp.Name())
+
+	for _, part := range parts {
+		if err = appendFile(temp, partPath(dir, part.PartNumber)); err != nil {
+			break
+		}
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	info, err := s.commit(ctx, temp.Name(), path)
+	if err != nil {
+		return "", "", err
+	}
+	os.RemoveAll(dir)
+	return ETag(info), path, nil
+}
+
+func (s *GatewayService) AbortMultipartUpload(ctx context.Context, bucketName, key, uploadID string) error {
+	dir, _, err := s.upload(ctx, bucketName, key, uploadID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// upload loads an upload started by the current user for the same bucket and key
+func (s *GatewayService) upload(ctx context.Context, bucketName, key, uploadID string) (string, *domain.Upload, error) {
+	if uploadID == "" || strings.ContainsAny(uploadID, `/\.`) {
+		return "", nil, domain.ErrNoSuchUpload
+	}
+	dir := filepath.Join(s.uploadsDir, uploadID)
+
+	var upload domain.Upload
+	if err := utils.LoadJSONFile(filepath.Join(dir, uploadMetaFile), &upload); err != nil || upload.ID == "" {
+		return "", nil, domain.ErrNoSuchUpload
+	}
+	user, _ := authdomain.UserFromContext(ctx)
+	if upload.Bucket != bucketName || upload.Key != key || upload.UserID != user.ID {
+		return "", nil, domain.ErrNoSuchUpload
+	}
+	return dir, &upload, nil
+}
+
+// resolve maps a key to its path and checks the ACL
+func (s *GatewayService) resolve(ctx context.Context, bucketName, key string, access authdomain.Access) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	bucket, err := s.Bucket(ctx, bucketName)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(bucket.Path, filepath.FromSlash(key))
+
+	user, _ := authdomain.UserFromContext(ctx)
+	if err := s.acl.Authorize(ctx, user, path, access); err != nil {
+		return "", fmt.Errorf("%w: %v", domain.ErrAccessDenied, err)
+	}
+	if err := vaultservices.CheckDiskPath(ctx, s.storage, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// reserve checks the quotas before writing size bytes at path; the previous content
+// stays as a version, so overwrites count in full. An unknown size (-1) only checks the
+// file count and the returned allowance limits the body.
+func (s *GatewayService) reserve(ctx context.Context, path string, size int64) (*quotaservices.Allowance, error) {
+	user, _ := authdomain.UserFromContext(ctx)
+	allowance := s.quotas.Allowance(user.ID, path)
+	files := int64(1)
+	if _, err := os.Stat(path); err == nil {
+		files = 0
+	}
+	if err := allowance.Take(max(size, 0), files); err != nil {
+		return nil, err
+	}
+	return allowance, nil
+}
+
+// reservePart takes from the quota of path the parts already staged for the upload, other
+// than the one being replaced, plus the parts still being received and this one
+func (s *GatewayService) reservePart(ctx context.Context, dir, path, uploadID string, partNumber int, size int64) (*quotaservices.Allowance, func(), error) {
+	var staged int64
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".part") || filepath.Join(dir, entry.Name()) == partPath(dir, partNumber) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			staged += info.Size()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	allowance, err := s.reserve(ctx, path, staged+s.staging[uploadID]+max(size, 0))
+	if err != nil {
+		return nil, nil, err
+	}
+	s.staging[uploadID] += max(size, 0)
+	return allowance, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.staging[uploadID] -= max(size, 0); s.staging[uploadID] <= 0 {
+			delete(s.staging, uploadID)
+		}
+	}, nil
+}
+
+// prepareTarget creates the parent folders of a new file
+func (s *GatewayService) prepareTarget(path string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return domain.ErrKeyIsDirectory
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrKeyIsDirectory, err)
+	}
+	return nil
+}
+
+// commit replaces path with the written temporary file, keeping the previous content as a version
+func (s *GatewayService) commit(ctx context.Context, temp, path string) (os.FileInfo, error) {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		user, _ := authdomain.UserFromContext(ctx)
+		if _, err := s.versions.Snapshot(ctx, path, user.ID, fsdomain.VersionReasonEdit); err != nil {
+			log.Printf("s3: failed to snapshot %s: %v", path, err)
+		}
+	}
+	if err := os.Chmod(temp, mode); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		return nil, err
+	}
+	return os.Stat(path)
+}
+
+// access reports whether the user can read path, or only browse through it towards a path
+// they were granted
+func (s *GatewayService) access(ctx context.Context, path string) (readable, traversable bool) {
+	user, _ := authdomain.UserFromContext(ctx)
+	if s.acl.Authorize(ctx, user, path, authdomain.AccessRead) == nil {
+		return true, true
+	}
+	if user == nil {
+		return false, false
+	}
+	for _, allowed := range s.acl.AllowedRoots(user) {
+		if rel, err := filepath.Rel(path, allowed); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+func (s *GatewayService) removeStaleUploads() {
+	entries, err := os.ReadDir(s.uploadsDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && time.Since(info.ModTime()) > staleUploadAge {
+			os.RemoveAll(filepath.Join(s.uploadsDir, entry.Name()))
+		}
+	}
+}
+
+// validateKey rejects keys that would escape the bucket or have empty segments
+func validateKey(key string) error {
+	trimmed := strings.TrimSuffix(key, "/")
+	if trimmed == "" || strings.ContainsAny(key, "\x00\\") {
+		return domain.ErrInvalidKey
+	}
+	for _, segment := range strings.Split(trimmed, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return domain.ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+func objectFor(key string, info os.FileInfo) (domain.Object, bool) {
+	switch {
+	case info.IsDir():
+		return domain.Object{Key: key + "/", ModTime: info.ModTime(), ETag: ETag(info)}, true
+	case info.Mode().IsRegular():
+		return domain.Object{Key: key, Size: info.Size(), ModTime: info.ModTime(), ETag: ETag(info)}, true
+	}
+	return domain.Object{}, false
+}
+
+func keyFor(dir, baseKey, path string) string {
+	rel, _ := filepath.Rel(dir, path)
+	return baseKey + filepath.ToSlash(rel)
+}
+
+// ETag identifies a file version by its modification time and size, so it needs no hashing
+func ETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func isEmptyDir(path string) bool {
+	dir, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer dir.Close()
+	_, err = dir.Readdirnames(1)
+	return err == io.EOF
+}
+
+func partPath(dir string, partNumber int) string {
+	return filepath.Join(dir, fmt.Sprintf("%05d.part", partNumber))
+}
+
+func appendFile(dst *os.File, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(dst, src)
+	return err
+}
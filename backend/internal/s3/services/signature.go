@@ -0,0 +1,472 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/s3/domain"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	maxClockSkew     = 15 * time.Minute
+	maxPresignExpiry = 7 * 24 * time.Hour
+	// Tamaño máximo de un fragmento aws-chunked, que se guarda entero para verificar su firma
+	maxChunkSize = 16 << 20
+
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+var emptySHA256 = hex.EncodeToString(sha256.New().Sum(nil))
+
+// SignatureService authenticates S3 requests signed with AWS Signature Version 4 using the
+// API keys of Cubert users
+type SignatureService struct {
+	authService *authservices.AuthService
+	now         func() time.Time
+}
+
+func NewSignatureService(authService *authservices.AuthService) *SignatureService {
+	return &SignatureService{
+		authService: authService,
+		now:         time.Now,
+	}
+}
+
+// signature holds the parsed SigV4 parameters of a request
+type signature struct {
+	accessKeyID   string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	amzDate       time.Time
+	payloadHash   string
+	presigned     bool
+}
+
+func (s signature) scope() string {
+	return strings.Join([]string{s.date, s.region, s.service, "aws4_request"}, "/")
+}
+
+// Authenticate verifies the signature of r and returns the user owning the access key. The
+// request body is replaced by a reader that decodes aws-chunked payloads and fails at the
+// end when the payload does not match what was signed.
+func (s *SignatureService) Authenticate(r *http.Request) (*authdomain.User, error) {
+	var (
+		sig   signature
+		err   error
+		query = r.URL.Query()
+	)
+	switch {
+	case strings.HasPrefix(r.Header.Get("Authorization"), signingAlgorithm+" "):
+		sig, err = parseAuthorizationHeader(r)
+	case query.Get("X-Amz-Algorithm") == signingAlgorithm:
+		sig, err = s.parsePresignedQuery(query)
+	default:
+		return nil, domain.ErrMissingSecurityHeader
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, secret, err := s.authService.LookupAPIKey(sig.accessKeyID)
+	if err != nil {
+		if errors.Is(err, authdomain.ErrAPIKeyNotFound) {
+			return nil, domain.ErrInvalidAccessKeyID
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrAccessDenied, err)
+	}
+
+	key := signingKey(secret, sig.date, sig.region, sig.service)
+	expected := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign(sig, canonicalRequest(r, sig)))))
+	if !hmac.Equal([]byte(expected), []byte(sig.signature)) {
+		return nil, domain.ErrSignatureDoesNotMatch
+	}
+
+	if err := s.wrapBody(r, sig, key); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func parseAuthorizationHeader(r *http.Request) (signature, error) {
+	var sig signature
+	fields := strings.TrimPrefix(r.Header.Get("Authorization"), signingAlgorithm+" ")
+	for _, field := range strings.Split(fields, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return sig, domain.ErrAuthorizationHeader
+		}
+		switch name {
+		case "Credential":
+			if err := sig.parseCredential(value); err != nil {
+				return sig, err
+			}
+		case "SignedHeaders":
+			sig.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			sig.signature = value
+		}
+	}
+	if sig.accessKeyID == "" || len(sig.signedHeaders) == 0 || sig.signature == "" {
+		return sig, domain.ErrAuthorizationHeader
+	}
+
+	amzDate, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return sig, fmt.Errorf("%w: missing or invalid X-Amz-Date", domain.ErrAuthorizationHeader)
+	}
+	sig.amzDate = amzDate
+	sig.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	if sig.payloadHash == "" {
+		return sig, fmt.Errorf("%w: missing X-Amz-Content-Sha256", domain.ErrAuthorizationHeader)
+	}
+	return sig, nil
+}
+
+func (s *SignatureService) parsePresignedQuery(query url.Values) (signature, error) {
+	var sig signature
+	if err := sig.parseCredential(query.Get("X-Amz-Credential")); err != nil {
+		return sig, err
+	}
+	sig.signedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	sig.signature = query.Get("X-Amz-Signature")
+	sig.payloadHash = unsignedPayload
+
+	amzDate, err := time.Parse(amzDateFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return sig, fmt.Errorf("%w: invalid X-Amz-Date", domain.ErrAuthorizationHeader)
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires <= 0 || time.Duration(expires)*time.Second > maxPresignExpiry {
+		return sig, fmt.Errorf("%w: invalid X-Amz-Expires", domain.ErrAuthorizationHeader)
+	}
+	if s.now().After(amzDate.Add(time.Duration(expires) * time.Second)) {
+		return sig, domain.ErrExpiredToken
+	}
+	sig.amzDate = amzDate
+	sig.presigned = true
+	return sig, nil
+}
+
+// parseCredential splits "AKID/20060102/region/s3/aws4_request"
+func (s *signature) parseCredential(value string) error {
+	parts := strings.Split(value, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" || parts[3] != "s3" {
+		return fmt.Errorf("%w: invalid credential scope", domain.ErrAuthorizationHeader)
+	}
+	s.accessKeyID, s.date, s.region, s.service = parts[0], parts[1], parts[2], parts[3]
+	return nil
+}
+
+func canonicalRequest(r *http.Request, sig signature) string {
+	var headers strings.Builder
+	for _, name := range sig.signedHeaders {
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(canonicalHeaderValue(r, name))
+		headers.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		headers.String(),
+		strings.Join(sig.signedHeaders, ";"),
+		sig.payloadHash,
+	}, "\n")
+}
+
+func canonicalHeaderValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		return r.Host
+	case "content-length":
+		// net/http retira la cabecera y la expone en ContentLength
+		if value := r.Header.Get("Content-Length"); value != "" {
+			return value
+		}
+		return strconv.FormatInt(r.ContentLength, 10)
+	}
+	var values []string
+	for _, value := range r.Header.Values(name) {
+		values = append(values, strings.Join(strings.Fields(value), " "))
+	}
+	return strings.Join(values, ",")
+}
+
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		if name == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func stringToSign(sig signature, request string) string {
+	hashed := sha256.Sum256([]byte(request))
+	return strings.Join([]string{
+		signingAlgorithm,
+		sig.amzDate.UTC().Format(amzDateFormat),
+		sig.scope(),
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+}
+
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	key = hmacSHA256(key, []byte(region))
+	key = hmacSHA256(key, []byte(service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// uriEncode applies the SigV4 encoding: everything but unreserved characters is escaped
+func uriEncode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// wrapBody checks the clock skew and installs the reader that verifies the payload
+func (s *SignatureService) wrapBody(r *http.Request, sig signature, key []byte) error {
+	// Las URL prefirmadas se validan por caducidad, no por desfase de reloj
+	if skew := s.now().Sub(sig.amzDate); !sig.presigned && (skew > maxClockSkew || skew < -maxClockSkew) {
+		return domain.ErrRequestTimeTooSkewed
+	}
+
+	switch sig.payloadHash {
+	case unsignedPayload:
+		return nil
+	case streamingPayload, streamingPayloadTrailer, streamingUnsignedTrailer:
+		decoded, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: missing X-Amz-Decoded-Content-Length", domain.ErrIncompleteBody)
+		}
+		chunked := &chunkedReader{
+			reader:   bufio.NewReader(r.Body),
+			closer:   r.Body,
+			trailer:  sig.payloadHash != streamingPayload,
+			remain:   decoded,
+			key:      key,
+			sig:      sig,
+			previous: sig.signature,
+			signed:   sig.payloadHash != streamingUnsignedTrailer,
+		}
+		r.Body = chunked
+		r.ContentLength = decoded
+		return nil
+	default:
+		expected, err := hex.DecodeString(sig.payloadHash)
+		if err != nil || len(expected) != sha256.Size {
+			return fmt.Errorf("%w: invalid X-Amz-Content-Sha256", domain.ErrAuthorizationHeader)
+		}
+		r.Body = &hashingReader{reader: r.Body, hash: sha256.New(), expected: expected}
+		return nil
+	}
+}
+
+// hashingReader fails at EOF when the body does not match the signed SHA-256
+type hashingReader struct {
+	reader   io.ReadCloser
+	hash     hash.Hash
+	expected []byte
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.reader.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(h.hash.Sum(nil), h.expected) {
+		return n, domain.ErrContentSHA256Mismatch
+	}
+	return n, err
+}
+
+func (h *hashingReader) Close() error {
+	return h.reader.Close()
+}
+
+// chunkedReader decodes an aws-chunked body and verifies each chunk signature
+type chunkedReader struct {
+	reader   *bufio.Reader
+	closer   io.Closer
+	trailer  bool
+	signed   bool
+	remain   int64
+	key      []byte
+	sig      signature
+	previous string
+	buffer   []byte
+	done     bool
+	err      error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.buffer) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = c.nextChunk()
+	}
+	n := copy(p, c.buffer)
+	c.buffer = c.buffer[n:]
+	return n, nil
+}
+
+func (c *chunkedReader) Close() error {
+	return c.closer.Close()
+}
+
+// nextChunk reads "size[;chunk-signature=sig]\r\n data \r\n"
+func (c *chunkedReader) nextChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeField, params, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize || size > c.remain {
+		return domain.ErrIncompleteBody
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return domain.ErrIncompleteBody
+	}
+	if size > 0 {
+		if crlf, err := c.readLine(); err != nil || crlf != "" {
+			return domain.ErrIncompleteBody
+		}
+	}
+
+	if c.signed {
+		chunkSig, ok := strings.CutPrefix(params, "chunk-signature=")
+		if !ok {
+			return domain.ErrSignatureDoesNotMatch
+		}
+		hashed := sha256.Sum256(data)
+		toSign := strings.Join([]string{
+			signingAlgorithm + "-PAYLOAD",
+			c.sig.amzDate.UTC().Format(amzDateFormat),
+			c.sig.scope(),
+			c.previous,
+			emptySHA256,
+			hex.EncodeToString(hashed[:]),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(c.key, []byte(toSign)))
+		if !hmac.Equal([]byte(expected), []byte(chunkSig)) {
+			return domain.ErrSignatureDoesNotMatch
+		}
+		c.previous = chunkSig
+	}
+
+	c.remain -= size
+	if size == 0 {
+		if c.remain != 0 {
+			return domain.ErrIncompleteBody
+		}
+		c.done = true
+		return c.readTrailer()
+	}
+	c.buffer = data
+	return nil
+}
+
+// readTrailer consumes the trailing headers (checksums) after the last chunk
+func (c *chunkedReader) readTrailer() error {
+	if !c.trailer {
+		// Cuerpo firmado sin trailer: queda la línea vacía final
+		_, _ = c.readLine()
+		return nil
+	}
+
+	var headers strings.Builder
+	trailerSig := ""
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return domain.ErrIncompleteBody
+		}
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "x-amz-trailer-signature:"); ok {
+			trailerSig = strings.TrimSpace(value)
+			continue
+		}
+		headers.WriteString(line)
+		headers.WriteByte('\n')
+	}
+
+	if c.signed {
+		hashed := sha256.Sum256([]byte(headers.String()))
+		toSign := strings.Join([]string{
+			signingAlgorithm + "-TRAILER",
+			c.sig.amzDate.UTC().Format(amzDateFormat),
+			c.sig.scope(),
+			c.previous,
+			hex.EncodeToString(hashed[:]),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(c.key, []byte(toSign)))
+		if !hmac.Equal([]byte(expected), []byte(trailerSig)) {
+			return domain.ErrSignatureDoesNotMatch
+		}
+	}
+	return nil
+}
+
+func (c *chunkedReader) readLine() (string, error) {
+	// ReadSlice limita la longitud de línea al tamaño del búfer
+	line, err := c.reader.ReadSlice('\n')
+	if err != nil {
+		return "", domain.ErrIncompleteBody
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// AuditSource identifies SFTP operations in the audit log
+const AuditSource = "sftp"
+
+// Subsystem is the SSH subsystem name clients request to start SFTP
+const Subsystem = "sftp"
+
+// ErrServerClosed is returned by the server after Shutdown
+var ErrServerClosed = errors.New("sftp: server closed")
+
+type contextKey int
+
+const activityContextKey contextKey = iota
+
+// Activity counts the files a connection has open, so shutdown only waits for
+// transfers in progress and not for idle clients
+type Activity struct {
+	mu   sync.Mutex
+	open int
+}
+
+func (a *Activity) Idle() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.open == 0
+}
+
+func ContextWithActivity(ctx context.Context, activity *Activity) context.Context {
+	return context.WithValue(ctx, activityContextKey, activity)
+}
+
+// StartTransfer marks a file of the connection in ctx as open until the returned
+// function is called
+func StartTransfer(ctx context.Context) func() {
+	activity, ok := ctx.Value(activityContextKey).(*Activity)
+	if !ok {
+		return func() {}
+	}
+	activity.mu.Lock()
+	activity.open++
+	activity.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			activity.mu.Lock()
+			activity.open--
+			activity.mu.Unlock()
+		})
+	}
+}
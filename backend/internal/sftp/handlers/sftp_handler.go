@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	auditservices "github.com/infortech07/cubert/internal/audit/services"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/sftp/domain"
+	vaultdomain "github.com/infortech07/cubert/internal/vault/domain"
+	webdavservices "github.com/infortech07/cubert/internal/webdav/services"
+)
+
+// SFTPHandler serves SFTP requests on the same ACL-filtered view of the library roots
+// as WebDAV: every user sees only the roots and folders their rules allow. Each
+// operation is written to the audit log like the equivalent REST request.
+type SFTPHandler struct {
+	fileSystem   *webdavservices.FileSystem
+	auditService *auditservices.AuditService
+}
+
+func NewSFTPHandler(fileSystem *webdavservices.FileSystem, auditService *auditservices.AuditService) *SFTPHandler {
+	return &SFTPHandler{fileSystem: fileSystem, auditService: auditService}
+}
+
+// Handlers returns the request handlers of a session; ctx must carry the user
+func (h *SFTPHandler) Handlers(ctx context.Context, ip string) sftp.Handlers {
+	s := &session{handler: h, ctx: ctx, ip: ip}
+	return sftp.Handlers{FileGet: s, FilePut: s, FileCmd: s, FileList: s}
+}
+
+// session implements the pkg/sftp handler interfaces for one authenticated user
+type session struct {
+	handler *SFTPHandler
+	ctx     context.Context
+	ip      string
+}
+
+func (s *session) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	start := time.Now()
+	file, err := s.handler.fileSystem.OpenFile(s.ctx, r.Filepath, os.O_RDONLY, 0)
+	if err == nil {
+		if reader, ok := file.(io.ReaderAt); ok {
+			// La transferencia se audita al cerrar, con su duración y su resultado
+			return &auditedFile{session: s, file: file, ReaderAt: reader, method: http.MethodGet, action: "sftp.download", path: r.Filepath, start: start, done: domain.StartTransfer(s.ctx)}, nil
+		}
+		file.Close()
+		err = fmt.Errorf("%w: %s is a directory", fs.ErrInvalid, r.Filepath)
+	}
+	s.record(start, http.MethodGet, "sftp.download", err, r.Filepath)
+	return nil, sftpError(err)
+}
+
+func (s *session) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	start := time.Now()
+	// O_APPEND no se usa: los clientes indican siempre el desplazamiento de cada escritura
+	flag := os.O_WRONLY
+	pflags := r.Pflags()
+	if pflags.Creat {
+		flag |= os.O_CREATE
+	}
+	if pflags.Trunc {
+		flag |= os.O_TRUNC
+	}
+	if pflags.Excl {
+		flag |= os.O_EXCL
+	}
+
+	file, err := s.handler.fileSystem.OpenFile(s.ctx, r.Filepath, flag, 0644)
+	if err == nil {
+		if writer, ok := file.(io.WriterAt); ok {
+			return &auditedFile{session: s, file: file, WriterAt: writer, method: http.MethodPut, action: "sftp.upload", path: r.Filepath, start: start, done: domain.StartTransfer(s.ctx)}, nil
+		}
+		file.Close()
+		err = fmt.Errorf("%w: %s is a directory", fs.ErrInvalid, r.Filepath)
+	}
+	s.record(start, http.MethodPut, "sftp.upload", err, r.Filepath)
+	return nil, sftpError(err)
+}
+
+func (s *session) Filecmd(r *sftp.Request) error {
+	start := time.Now()
+	fileSystem := s.handler.fileSystem
+
+	var err error
+	switch r.Method {
+	case "Mkdir":
+		err = fileSystem.Mkdir(s.ctx, r.Filepath, 0755)
+		s.record(start, http.MethodPost, "sftp.mkdir", err, r.Filepath)
+	case "Rename":
+		// El rename de SFTP nunca reemplaza el destino
+		if _, statErr := fileSystem.Stat(s.ctx, r.Target); statErr == nil {
+			err = fmt.Errorf("%s: %w", r.Target, fs.ErrExist)
+		} else {
+			err = fileSystem.Rename(s.ctx, r.Filepath, r.Target)
+		}
+		s.record(start, http.MethodPost, "sftp.rename", err, r.Filepath, r.Target)
+	case "Remove", "Rmdir":
+		err = s.remove(r.Filepath, r.Method == "Rmdir")
+		s.record(start, http.MethodDelete, "sftp.delete", err, r.Filepath)
+	case "Setstat":
+		err = s.setstat(r)
+		s.record(start, http.MethodPatch, "sftp.setstat", err, r.Filepath)
+	default:
+		err = sftp.ErrSSHFxOpUnsupported
+		s.record(start, "", "sftp."+strings.ToLower(r.Method), err, r.Filepath)
+	}
+	return sftpError(err)
+}
+
+// PosixRename replaces the target like rename(2); the replaced entry goes to the trash
+func (s *session) PosixRename(r *sftp.Request) error {
+	start := time.Now()
+	fileSystem := s.handler.fileSystem
+
+	_, err := fileSystem.Stat(s.ctx, r.Filepath)
+	if err == nil {
+		err = fileSystem.RemoveAll(s.ctx, r.Target)
+	}
+	if err == nil {
+		err = fileSystem.Rename(s.ctx, r.Filepath, r.Target)
+	}
+	s.record(start, http.MethodPost, "sftp.rename", err, r.Filepath, r.Target)
+	return sftpError(err)
+}
+
+func (s *session) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	start := time.Now()
+	fileSystem := s.handler.fileSystem
+
+	switch r.Method {
+	case "List":
+		infos, err := s.readDir(r.Filepath)
+		s.record(start, http.MethodGet, "sftp.list", err, r.Filepath)
+		return listerAt(infos), sftpError(err)
+	case "Stat":
+		info, err := fileSystem.Stat(s.ctx, r.Filepath)
+		s.record(start, http.MethodGet, "sftp.stat", err, r.Filepath)
+		if err != nil {
+			return nil, sftpError(err)
+		}
+		return listerAt{info}, nil
+	default:
+		// Los enlaces simbólicos no se exponen
+		s.record(start, http.MethodGet, "sftp."+strings.ToLower(r.Method), sftp.ErrSSHFxOpUnsupported, r.Filepath)
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+func (s *session) readDir(name string) ([]os.FileInfo, error) {
+	dir, err := s.handler.fileSystem.OpenFile(s.ctx, name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	return dir.Readdir(-1)
+}
+
+// remove moves the entry to the trash after checking the SFTP rules: Remove is for
+// files and Rmdir for empty folders
+func (s *session) remove(name string, dir bool) error {
+	fileSystem := s.handler.fileSystem
+	info, err := fileSystem.Stat(s.ctx, name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case dir && !info.IsDir():
+		return fmt.Errorf("%w: %s is not a directory", fs.ErrInvalid, name)
+	case !dir && info.IsDir():
+		return fmt.Errorf("%w: %s is a directory", fs.ErrInvalid, name)
+	case dir:
+		entries, err := s.readDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return fmt.Errorf("%w: %s is not empty", fs.ErrInvalid, name)
+		}
+	}
+	return fileSystem.RemoveAll(s.ctx, name)
+}
+
+// setstat applies size and times; ownership and permissions stay under Cubert's control
+func (s *session) setstat(r *sftp.Request) error {
+	fileSystem := s.handler.fileSystem
+	local, err := fileSystem.Authorize(s.ctx, r.Filepath, authdomain.AccessWrite)
+	if err != nil {
+		return err
+	}
+	flags, attrs := r.AttrFlags(), r.Attributes()
+	if flags.Size {
+		if err := fileSystem.Truncate(s.ctx, r.Filepath, int64(attrs.Size)); err != nil {
+			return err
+		}
+	}
+	if flags.Acmodtime {
+		if err := os.Chtimes(local, attrs.AccessTime(), attrs.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record writes an audit event with the same fields the REST middleware fills in
+func (s *session) record(start time.Time, method, action string, err error, names ...string) {
+	event := auditdomain.Event{
+		Source:     domain.AuditSource,
+		Method:     method,
+		Action:     action,
+		IP:         s.ip,
+		DurationMs: time.Since(start).Milliseconds(),
+		Result:     auditdomain.ResultSuccess,
+	}
+	for _, name := range names {
+		if local, _, resolveErr := s.handler.fileSystem.Resolve(name); resolveErr == nil && local != "" {
+			event.Paths = append(event.Paths, local)
+		}
+	}
+	if user, ok := authdomain.UserFromContext(s.ctx); ok {
+		event.UserID = user.ID
+		event.Username = user.Username
+	}
+	if err != nil {
+		event.Error = err.Error()
+		event.Result = auditdomain.ResultFailure
+		if errors.Is(err, fs.ErrPermission) || errors.Is(err, sftp.ErrSSHFxPermissionDenied) {
+			event.Result = auditdomain.ResultDenied
+		}
+	}
+	s.handler.auditService.Log(event)
+}
+
+// sftpError maps errors to SFTP status codes; pkg/sftp only recognizes unwrapped ones
+func sftpError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		return sftp.ErrSSHFxNoSuchFile
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, vaultdomain.ErrVaultLocked):
+		return sftp.ErrSSHFxPermissionDenied
+	}
+	return err
+}
+
+// auditedFile records the transfer when the client closes the handle
+type auditedFile struct {
+	io.ReaderAt
+	io.WriterAt
+	session *session
+	file    io.Closer
+	method  string
+	action  string
+	path    string
+	start   time.Time
+	failed  error
+	// done avisa al servidor de que la conexión ya no tiene este archivo abierto
+	done func()
+}
+
+func (f *auditedFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.ReaderAt.ReadAt(p, off)
+	if err != nil && err != io.EOF && f.failed == nil {
+		f.failed = err
+	}
+	return n, err
+}
+
+func (f *auditedFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.WriterAt.WriteAt(p, off)
+	if err != nil && f.failed == nil {
+		f.failed = err
+	}
+	return n, err
+}
+
+// TransferError is called by pkg/sftp when the session ends with the file still open
+func (f *auditedFile) TransferError(err error) {
+	if f.failed == nil {
+		f.failed = err
+	}
+}
+
+func (f *auditedFile) Close() error {
+	defer f.done()
+	err := f.file.Close()
+	failed := f.failed
+	if failed == nil {
+		failed = err
+	}
+	f.session.record(f.start, f.method, f.action, failed, f.path)
+	return err
+}
+
+// listerAt serves a fixed list of entries to pkg/sftp
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(infos []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(infos, l[offset:])
+	if n < len(infos) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/sftp/domain"
+)
+
+const (
+	// userIDExtension lleva el usuario autenticado del handshake a la sesión
+	userIDExtension      = "cubert-user-id"
+	shutdownPollInterval = 500 * time.Millisecond
+)
+
+type ServerConfig struct {
+	Addr string
+	// HostKeyFile se genera (ed25519) si no existe
+	HostKeyFile string
+}
+
+// HandlersFunc builds the SFTP request handlers of a session. ctx carries the user.
+type HandlersFunc func(ctx context.Context, ip string) sftp.Handlers
+
+// Server accepts SSH connections authenticated with Cubert passwords, session tokens
+// or registered public keys, and serves the SFTP subsystem only
+type Server struct {
+	cfg      ServerConfig
+	config   *ssh.ServerConfig
+	auth     *authservices.AuthService
+	handlers HandlersFunc
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]*domain.Activity
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func NewServer(cfg ServerConfig, auth *authservices.AuthService, handlers HandlersFunc) (*Server, error) {
+	hostKey, err := loadHostKey(cfg.HostKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		cfg:      cfg,
+		auth:     auth,
+		handlers: handlers,
+		conns:    make(map[net.Conn]*domain.Activity),
+	}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			user, _, err := auth.AuthenticateBasic(conn.User(), string(password))
+			if err != nil {
+				return nil, err
+			}
+			return permissionsFor(user), nil
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			user, err := auth.AuthenticateSSHKey(conn.User(), key)
+			if err != nil {
+				return nil, err
+			}
+			return permissionsFor(user), nil
+		},
+		ServerVersion: "SSH-2.0-Cubert",
+	}
+	s.config.AddHostKey(hostKey)
+	log.Printf("SFTP host key: %s", ssh.FingerprintSHA256(hostKey.PublicKey()))
+	return s, nil
+}
+
+// ListenAndServe blocks until the server fails or Shutdown is called, in which case
+// it returns domain.ErrServerClosed
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return domain.ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return domain.ErrServerClosed
+			}
+			return err
+		}
+		activity := s.track(conn)
+		if activity == nil {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.untrack(conn)
+			s.serveConn(conn, activity)
+		}()
+	}
+}
+
+// Shutdown stops accepting connections, closes the idle ones and waits for the open
+// transfers to finish. When ctx expires first, the remaining connections are closed
+// and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		s.closeConns(false)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			s.closeConns(true)
+			<-done
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeConns closes the connections without open files, or all of them when force is set
+func (s *Server) closeConns(force bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, activity := range s.conns {
+		if force || activity.Idle() {
+			conn.Close()
+		}
+	}
+}
+
+func (s *Server) track(conn net.Conn) *domain.Activity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	activity := &domain.Activity{}
+	s.conns[conn] = activity
+	s.wg.Add(1)
+	return activity
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+func (s *Server) serveConn(conn net.Conn, activity *domain.Activity) {
+	defer conn.Close()
+
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	// El usuario se vuelve a leer por si cambió durante el handshake
+	user, err := s.auth.GetUser(context.Background(), sshConn.Permissions.Extensions[userIDExtension])
+	if err != nil || user.Disabled {
+		return
+	}
+	ctx := domain.ContextWithActivity(authdomain.ContextWithUser(context.Background(), user, nil), activity)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ip := remoteIP(sshConn.RemoteAddr())
+	var sessions sync.WaitGroup
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		sessions.Add(1)
+		go func() {
+			defer sessions.Done()
+			s.serveSession(ctx, ip, channel, channelRequests)
+		}()
+	}
+	sessions.Wait()
+}
+
+// serveSession waits for the sftp subsystem request; shells and commands are refused
+func (s *Server) serveSession(ctx context.Context, ip string, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for request := range requests {
+		if request.Type != "subsystem" || subsystemName(request.Payload) != domain.Subsystem {
+			request.Reply(false, nil)
+			continue
+		}
+		request.Reply(true, nil)
+		go ssh.DiscardRequests(requests)
+
+		server := sftp.NewRequestServer(channel, s.handlers(ctx, ip))
+		if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+			log.Printf("sftp: session of %s ended: %v", ip, err)
+		}
+		server.Close()
+		return
+	}
+}
+
+func permissionsFor(user *authdomain.User) *ssh.Permissions {
+	return &ssh.Permissions{Extensions: map[string]string{userIDExtension: user.ID}}
+}
+
+// subsystemName decodes the SSH string carried by a subsystem request
+func subsystemName(payload []byte) string {
+	if len(payload) < 4 {
+		return ""
+	}
+	size := int(payload[0])<<24 | int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3])
+	if size > len(payload)-4 {
+		return ""
+	}
+	return string(payload[4 : 4+size])
+}
+
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// loadHostKey reads the server key, creating an ed25519 key on first start so clients
+// see the same fingerprint across restarts
+func loadHostKey(file string) (ssh.Signer, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		block, err := ssh.MarshalPrivateKey(private, "cubert sftp host key")
+		if err != nil {
+			return nil, err
+		}
+		data = pem.EncodeToMemory(block)
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create host key directory: %w", err)
+		}
+		if err := os.WriteFile(file, data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write host key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read host key: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key %s: %w", file, err)
+	}
+	return signer, nil
+}
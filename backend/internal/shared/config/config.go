@@ -0,0 +1,172 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Port    string
+	DataDir string
+
+	// Autenticación
+	AdminUsername   string
+	AdminPassword   string
+	SessionTTL      time.Duration
+	TOTPIssuer      string
+	Require2FARoles []string
+
+	// OpenID Connect
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCScopes        []string
+	OIDCUsernameClaim string
+	OIDCGroupsClaim   string
+	OIDCRolesClaim    string
+	OIDCAdminGroups   []string
+	OIDCPostLoginURL  string
+	OIDCProviderName  string
+
+	// Auditoría
+	AuditDir           string
+	AuditMaxSizeMB     int
+	AuditMaxFiles      int
+	AuditRetentionDays int
+
+	// Bibliotecas
+	LibraryRoots       []string
+	StatsIndexInterval time.Duration
+	// RemoteRootsFile describe las bibliotecas servidas por almacenamiento remoto
+	RemoteRootsFile string
+
+	// Trabajos en segundo plano
+	JobsDir      string
+	JobWorkers   int
+	JobRetention time.Duration
+
+	// Historial de versiones
+	VersionsKeepLast      int
+	VersionsKeepDailyDays int
+
+	// Límites de extracción de archivos comprimidos
+	ExtractMaxSizeMB  int
+	ExtractMaxEntries int
+
+	// Servidor SFTP integrado; sin dirección no se inicia
+	SFTPAddr        string
+	SFTPHostKeyFile string
+
+	// Cuotas por defecto (0 = sin límite); los administradores las ajustan por usuario y raíz
+	QuotaUserMaxMB    int
+	QuotaUserMaxFiles int
+	QuotaRootMaxMB    int
+	QuotaRootMaxFiles int
+	QuotaSoftPercent  int
+
+	// Copia las etiquetas en atributos extendidos (user.cubert.*) donde el disco lo permita
+	TagsXattr bool
+}
+
+// Load builds the configuration from environment variables
+func Load() *Config {
+	cfg := &Config{
+		Port:            getEnv("PORT", "8080"),
+		DataDir:         getEnv("CUBERT_DATA_DIR", filepath.Join(".", "data")),
+		AdminUsername:   getEnv("CUBERT_ADMIN_USERNAME", "admin"),
+		AdminPassword:   os.Getenv("CUBERT_ADMIN_PASSWORD"),
+		SessionTTL:      getEnvDuration("CUBERT_SESSION_TTL", 12*time.Hour),
+		TOTPIssuer:      getEnv("CUBERT_TOTP_ISSUER", "Cubert"),
+		Require2FARoles: getEnvList("CUBERT_REQUIRE_2FA_ROLES"),
+
+		OIDCIssuer:        os.Getenv("CUBERT_OIDC_ISSUER"),
+		OIDCClientID:      os.Getenv("CUBERT_OIDC_CLIENT_ID"),
+		OIDCClientSecret:  os.Getenv("CUBERT_OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:   os.Getenv("CUBERT_OIDC_REDIRECT_URL"),
+		OIDCScopes:        getEnvListDefault("CUBERT_OIDC_SCOPES", []string{"openid", "profile", "email"}),
+		OIDCUsernameClaim: getEnv("CUBERT_OIDC_USERNAME_CLAIM", "preferred_username"),
+		OIDCGroupsClaim:   getEnv("CUBERT_OIDC_GROUPS_CLAIM", "groups"),
+		OIDCRolesClaim:    getEnv("CUBERT_OIDC_ROLES_CLAIM", "realm_access.roles"),
+		OIDCAdminGroups:   getEnvList("CUBERT_OIDC_ADMIN_GROUPS"),
+		OIDCPostLoginURL:  getEnv("CUBERT_OIDC_POST_LOGIN_URL", "/"),
+		OIDCProviderName:  getEnv("CUBERT_OIDC_PROVIDER_NAME", "SSO"),
+	}
+
+	cfg.AuditDir = getEnv("CUBERT_AUDIT_DIR", filepath.Join(cfg.DataDir, "audit"))
+	cfg.AuditMaxSizeMB = getEnvInt("CUBERT_AUDIT_MAX_SIZE_MB", 10)
+	cfg.AuditMaxFiles = getEnvInt("CUBERT_AUDIT_MAX_FILES", 10)
+	cfg.AuditRetentionDays = getEnvInt("CUBERT_AUDIT_RETENTION_DAYS", 90)
+
+	cfg.LibraryRoots = getEnvListDefault("CUBERT_LIBRARY_ROOTS", []string{"/"})
+	cfg.StatsIndexInterval = getEnvDuration("CUBERT_STATS_INDEX_INTERVAL", 6*time.Hour)
+	cfg.RemoteRootsFile = getEnv("CUBERT_REMOTE_ROOTS_FILE", filepath.Join(cfg.DataDir, "remote_roots.json"))
+
+	cfg.JobsDir = filepath.Join(cfg.DataDir, "jobs")
+	cfg.JobWorkers = getEnvInt("CUBERT_JOB_WORKERS", 2)
+	cfg.JobRetention = getEnvDuration("CUBERT_JOB_RETENTION", 7*24*time.Hour)
+
+	cfg.VersionsKeepLast = getEnvInt("CUBERT_VERSIONS_KEEP_LAST", 20)
+	cfg.VersionsKeepDailyDays = getEnvInt("CUBERT_VERSIONS_KEEP_DAILY_DAYS", 30)
+
+	cfg.ExtractMaxSizeMB = getEnvInt("CUBERT_EXTRACT_MAX_SIZE_MB", 10240)
+	cfg.ExtractMaxEntries = getEnvInt("CUBERT_EXTRACT_MAX_ENTRIES", 100000)
+
+	cfg.SFTPAddr = os.Getenv("CUBERT_SFTP_ADDR")
+	cfg.SFTPHostKeyFile = getEnv("CUBERT_SFTP_HOST_KEY", filepath.Join(cfg.DataDir, "sftp_host_ed25519_key"))
+
+	cfg.QuotaUserMaxMB = getEnvInt("CUBERT_QUOTA_USER_MAX_MB", 0)
+	cfg.QuotaUserMaxFiles = getEnvInt("CUBERT_QUOTA_USER_MAX_FILES", 0)
+	cfg.QuotaRootMaxMB = getEnvInt("CUBERT_QUOTA_ROOT_MAX_MB", 0)
+	cfg.QuotaRootMaxFiles = getEnvInt("CUBERT_QUOTA_ROOT_MAX_FILES", 0)
+	cfg.QuotaSoftPercent = getEnvInt("CUBERT_QUOTA_SOFT_PERCENT", 90)
+
+	cfg.TagsXattr = getEnv("CUBERT_TAGS_XATTR", "false") == "true"
+
+	return cfg
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvListDefault(key string, fallback []string) []string {
+	if items := getEnvList(key); len(items) > 0 {
+		return items
+	}
+	return fallback
+}
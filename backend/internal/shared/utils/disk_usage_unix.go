@@ -0,0 +1,28 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+// DiskInfo describes how a file is laid out on disk
+type DiskInfo struct {
+	Allocated int64  // bytes actually allocated (st_blocks * 512)
+	Device    uint64 // device holding the file
+	Links     uint64 // number of hard links
+}
+
+// GetDiskInfo returns the allocated size, device and link count of a file.
+// It falls back to the apparent size when the platform data is unavailable.
+func GetDiskInfo(info os.FileInfo) DiskInfo {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return DiskInfo{
+			Allocated: int64(stat.Blocks) * 512,
+			Device:    uint64(stat.Dev),
+			Links:     uint64(stat.Nlink),
+		}
+	}
+	return DiskInfo{Allocated: info.Size(), Links: 1}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package utils
+
+import (
+	"os"
+)
+
+// DiskInfo describes how a file is laid out on disk
+type DiskInfo struct {
+	Allocated int64  // bytes actually allocated
+	Device    uint64 // device holding the file
+	Links     uint64 // number of hard links
+}
+
+// GetDiskInfo is approximated with the apparent size on Windows
+func GetDiskInfo(info os.FileInfo) DiskInfo {
+	return DiskInfo{Allocated: info.Size(), Links: 1}
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// LocateFile finds a file recorded by path and FileIdentity: the path itself if it still
+// exists, otherwise the entry with the same identity in the original parent directory,
+// which is where a file renamed in place ends up.
+func LocateFile(path, fileID string) (string, os.FileInfo, bool) {
+	if info, err := os.Stat(path); err == nil {
+		return path, info, true
+	}
+	if fileID == "" {
+		return "", nil, false
+	}
+
+	parent := filepath.Dir(path)
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return "", nil, false
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if FileIdentity(info) == fileID {
+			return filepath.Join(parent, entry.Name()), info, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// FileIdentity returns a stable "device:inode" identifier that survives renames within a filesystem
+func FileIdentity(info os.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", uint64(stat.Dev), uint64(stat.Ino))
+	}
+	return ""
+}
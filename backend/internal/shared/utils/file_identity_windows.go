@@ -0,0 +1,12 @@
+//go:build windows
+
+package utils
+
+import (
+	"os"
+)
+
+// FileIdentity is not available on Windows; callers fall back to the path
+func FileIdentity(info os.FileInfo) string {
+	return ""
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -92,3 +93,28 @@ func GenerateUniquePath(basePath string, counter int) string {
 	newFilename := fmt.Sprintf("%s_%d%s", nameWithoutExt, counter, ext)
 	return filepath.Join(dir, newFilename)
 }
+
+// RootNames names each library root after its folder, as the WebDAV and S3 endpoints expose
+// them. normalize, when not nil, adapts a name to the rules of the endpoint; roots whose names
+// collide get a "-2", "-3"... suffix in the order given, so every endpoint agrees on which
+// root is which.
+func RootNames(roots []string, normalize func(string) string) []string {
+	names := make([]string, 0, len(roots))
+	used := make(map[string]int)
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		name := filepath.Base(root)
+		if name == string(filepath.Separator) || name == "." || filepath.VolumeName(root)+`\` == root {
+			name = "root"
+		}
+		if normalize != nil {
+			name = normalize(name)
+		}
+		used[name]++
+		if used[name] > 1 {
+			name += "-" + strconv.Itoa(used[name])
+		}
+		names = append(names, name)
+	}
+	return names
+}
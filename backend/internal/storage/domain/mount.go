@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	MountTypeS3   = "s3"
+	MountTypeSFTP = "sftp"
+)
+
+// DefaultCacheTTL is how long remote directory listings are reused
+const DefaultCacheTTL = 30 * time.Second
+
+// Mount serves a remote backend as a library root at Path
+type Mount struct {
+	Path string      `json:"path"`
+	Type string      `json:"type"`
+	S3   *S3Config   `json:"s3,omitempty"`
+	SFTP *SFTPConfig `json:"sftp,omitempty"`
+}
+
+// S3Config points a mount at a bucket of an S3-compatible service such as MinIO
+type S3Config struct {
+	// Endpoint is the service URL; empty means AWS
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	// PathStyle is required by MinIO and most self-hosted services
+	PathStyle       bool `json:"path_style"`
+	CacheTTLSeconds int  `json:"cache_ttl_seconds"`
+}
+
+// SFTPConfig points a mount at a directory of an SSH server. The host key is
+// always verified, against KnownHostsFile or against HostKey.
+type SFTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	// Password o clave privada; si hay ambas se prueba primero la clave
+	Password             string `json:"password"`
+	PrivateKeyFile       string `json:"private_key_file"`
+	PrivateKeyPassphrase string `json:"private_key_passphrase"`
+	KnownHostsFile       string `json:"known_hosts_file"`
+	// HostKey is a public key in authorized_keys format, e.g. "ssh-ed25519 AAAA..."
+	HostKey        string `json:"host_key"`
+	RemotePath     string `json:"remote_path"`
+	MaxConnections int    `json:"max_connections"`
+}
+
+// MountLister is implemented by storages that serve other backends below some paths
+type MountLister interface {
+	Mounts() []string
+	// Mounted reports whether path is served by a remote backend
+	Mounted(path string) bool
+}
+
+var (
+	ErrCrossMount       = errors.New("cannot move between storage backends")
+	ErrUnknownMountType = errors.New("unknown storage type")
+	ErrNoHostKey        = errors.New("known_hosts_file or host_key is required to verify the server")
+)
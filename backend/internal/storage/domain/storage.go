@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+)
+
+// Storage is the file system a library root is served from. Paths are absolute
+// in the host syntax, so the same path works with the local disk and with any
+// other backend mounted at it.
+type Storage interface {
+	Stat(ctx context.Context, path string) (fs.FileInfo, error)
+	// ReadDir returns the entries of a directory sorted by name
+	ReadDir(ctx context.Context, path string) ([]fs.DirEntry, error)
+	Open(ctx context.Context, path string) (File, error)
+	// Create truncates or creates a regular file; its parent must exist
+	Create(ctx context.Context, path string) (io.WriteCloser, error)
+	Mkdir(ctx context.Context, path string) error
+	Rename(ctx context.Context, oldPath, newPath string) error
+	// Remove deletes a file or an empty directory
+	Remove(ctx context.Context, path string) error
+	// Walk visits root and everything below it in lexical order, like filepath.Walk
+	Walk(ctx context.Context, root string, fn filepath.WalkFunc) error
+}
+
+// File is an open file that supports range reads
+type File interface {
+	io.ReadSeekCloser
+	Stat() (fs.FileInfo, error)
+}
+
+// PathResolver is implemented by storages that keep entries under a different name
+// on disk, such as encrypted vaults
+type PathResolver interface {
+	// DiskPath returns where path is stored by the underlying backend
+	DiskPath(ctx context.Context, path string) (string, error)
+}
+
+// LockChecker is implemented by storages whose entries stay unreadable until the session
+// unlocks them, such as encrypted vaults
+type LockChecker interface {
+	// Locked reports whether path is a locked vault or lies inside one
+	Locked(ctx context.Context, path string) bool
+}
+
+var (
+	ErrNotDir   = errors.New("not a directory")
+	ErrIsDir    = errors.New("is a directory")
+	ErrNotEmpty = errors.New("directory not empty")
+)
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/infortech07/cubert/internal/storage/domain"
+)
+
+// LocalStorage serves paths straight from the host file system
+type LocalStorage struct{}
+
+func NewLocalStorage() *LocalStorage {
+	return &LocalStorage{}
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (s *LocalStorage) ReadDir(ctx context.Context, path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
+func (s *LocalStorage) Open(ctx context.Context, path string) (domain.File, error) {
+	return os.Open(path)
+}
+
+func (s *LocalStorage) Create(ctx context.Context, path string) (io.WriteCloser, error) {
+	return os.Create(path)
+}
+
+func (s *LocalStorage) Mkdir(ctx context.Context, path string) error {
+	return os.Mkdir(path, 0755)
+}
+
+func (s *LocalStorage) Rename(ctx context.Context, oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (s *LocalStorage) Remove(ctx context.Context, path string) error {
+	return os.Remove(path)
+}
+
+func (s *LocalStorage) Walk(ctx context.Context, root string, fn filepath.WalkFunc) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fn(path, info, err)
+	})
+}
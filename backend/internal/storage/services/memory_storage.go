@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infortech07/cubert/internal/storage/domain"
+)
+
+// MemoryStorage keeps a whole file tree in memory. It is meant for tests and
+// for scratch roots that do not need to survive a restart.
+type MemoryStorage struct {
+	mu sync.RWMutex
+	// Un árbol por volumen; en Unix solo existe ""
+	volumes map[string]*memNode
+}
+
+type memNode struct {
+	name     string
+	dir      bool
+	data     []byte
+	modTime  time.Time
+	children map[string]*memNode
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{volumes: make(map[string]*memNode)}
+}
+
+// MkdirAll creates a directory and any missing parents
+func (s *MemoryStorage) MkdirAll(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent, names := s.root(path), splitPath(path)
+	for _, name := range names {
+		child, ok := parent.children[name]
+		if !ok {
+			child = newDirNode(name)
+			parent.children[name] = child
+			parent.modTime = child.modTime
+		} else if !child.dir {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: domain.ErrNotDir}
+		}
+		parent = child
+	}
+	return nil
+}
+
+// WriteFile stores data at path, creating the parent directories
+func (s *MemoryStorage) WriteFile(path string, data []byte) error {
+	if err := s.MkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	w, err := s.Create(context.Background(), path)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, path string) (fs.FileInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	node, err := s.lookup("stat", path)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(), nil
+}
+
+func (s *MemoryStorage) ReadDir(ctx context.Context, path string) ([]fs.DirEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	node, err := s.lookup("readdir", path)
+	if err != nil {
+		return nil, err
+	}
+	if !node.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: path, Err: domain.ErrNotDir}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(node.children))
+	for _, child := range node.children {
+		entries = append(entries, fs.FileInfoToDirEntry(child.info()))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (s *MemoryStorage) Open(ctx context.Context, path string) (domain.File, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	node, err := s.lookup("open", path)
+	if err != nil {
+		return nil, err
+	}
+	// Se lee una instantánea para que las escrituras posteriores no afecten al lector
+	return &memFile{Reader: bytes.NewReader(node.data), stat: node.info()}, nil
+}
+
+func (s *MemoryStorage) Create(ctx context.Context, path string) (io.WriteCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent, name, err := s.parent("open", path)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := parent.children[name]
+	if ok && node.dir {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: domain.ErrIsDir}
+	}
+	if !ok {
+		node = &memNode{name: name}
+		parent.children[name] = node
+	}
+	node.data = nil
+	node.modTime = time.Now()
+	return &memWriter{storage: s, node: node}, nil
+}
+
+func (s *MemoryStorage) Mkdir(ctx context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent, name, err := s.parent("mkdir", path)
+	if err != nil {
+		return err
+	}
+	if _, ok := parent.children[name]; ok {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrExist}
+	}
+	parent.children[name] = newDirNode(name)
+	return nil
+}
+
+func (s *MemoryStorage) Rename(ctx context.Context, oldPath, newPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldParent, oldName, err := s.parent("rename", oldPath)
+	if err != nil {
+		return err
+	}
+	node, ok := oldParent.children[oldName]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldPath, Err: fs.ErrNotExist}
+	}
+	newParent, newName, err := s.parent("rename", newPath)
+	if err != nil {
+		return err
+	}
+	if node.dir && isWithin(filepath.Clean(newPath), filepath.Clean(oldPath)) {
+		return &fs.PathError{Op: "rename", Path: newPath, Err: fs.ErrInvalid}
+	}
+	if target, ok := newParent.children[newName]; ok && target != node {
+		switch {
+		case target.dir && (!node.dir || len(target.children) > 0):
+			return &fs.PathError{Op: "rename", Path: newPath, Err: fs.ErrExist}
+		case !target.dir && node.dir:
+			return &fs.PathError{Op: "rename", Path: newPath, Err: domain.ErrNotDir}
+		}
+	}
+
+	delete(oldParent.children, oldName)
+	node.name = newName
+	newParent.children[newName] = node
+	return nil
+}
+
+func (s *MemoryStorage) Remove(ctx context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent, name, err := s.parent("remove", path)
+	if err != nil {
+		return err
+	}
+	node, ok := parent.children[name]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrNotExist}
+	}
+	if node.dir && len(node.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: path, Err: domain.ErrNotEmpty}
+	}
+	delete(parent.children, name)
+	return nil
+}
+
+func (s *MemoryStorage) Walk(ctx context.Context, root string, fn filepath.WalkFunc) error {
+	return WalkStorage(ctx, s, root, fn)
+}
+
+// root devuelve el directorio raíz del volumen de path, creándolo si hace falta
+func (s *MemoryStorage) root(path string) *memNode {
+	volume := filepath.VolumeName(path)
+	node, ok := s.volumes[volume]
+	if !ok {
+		node = newDirNode(volume + string(filepath.Separator))
+		s.volumes[volume] = node
+	}
+	return node
+}
+
+func (s *MemoryStorage) lookup(op, path string) (*memNode, error) {
+	if !filepath.IsAbs(path) {
+		return nil, &fs.PathError{Op: op, Path: path, Err: fs.ErrInvalid}
+	}
+	node, ok := s.volumes[filepath.VolumeName(path)]
+	if !ok {
+		// Un volumen sin escrituras es un directorio raíz vacío
+		node = newDirNode(filepath.VolumeName(path) + string(filepath.Separator))
+	}
+
+	for _, name := range splitPath(path) {
+		if !node.dir {
+			return nil, &fs.PathError{Op: op, Path: path, Err: domain.ErrNotDir}
+		}
+		child, ok := node.children[name]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// parent resuelve el directorio que contiene path y el nombre del último elemento
+func (s *MemoryStorage) parent(op, path string) (*memNode, string, error) {
+	clean := filepath.Clean(path)
+	if !filepath.IsAbs(clean) || filepath.Dir(clean) == clean {
+		return nil, "", &fs.PathError{Op: op, Path: path, Err: fs.ErrInvalid}
+	}
+	s.root(clean)
+	parent, err := s.lookup(op, filepath.Dir(clean))
+	if err != nil {
+		return nil, "", err
+	}
+	if !parent.dir {
+		return nil, "", &fs.PathError{Op: op, Path: path, Err: domain.ErrNotDir}
+	}
+	return parent, filepath.Base(clean), nil
+}
+
+func newDirNode(name string) *memNode {
+	return &memNode{name: name, dir: true, modTime: time.Now(), children: make(map[string]*memNode)}
+}
+
+func (n *memNode) info() fs.FileInfo {
+	info := memInfo{name: n.name, size: int64(len(n.data)), mode: 0644, modTime: n.modTime}
+	if n.dir {
+		info.size = 0
+		info.mode = fs.ModeDir | 0755
+	}
+	return info
+}
+
+func splitPath(path string) []string {
+	rest := strings.TrimPrefix(filepath.Clean(path), filepath.VolumeName(path))
+	var names []string
+	for _, name := range strings.Split(rest, string(filepath.Separator)) {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
+
+type memFile struct {
+	*bytes.Reader
+	stat fs.FileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.stat, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memWriter struct {
+	storage *MemoryStorage
+	node    *memNode
+	closed  bool
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fs.ErrClosed
+	}
+	w.storage.mu.Lock()
+	defer w.storage.mu.Unlock()
+
+	// append nunca modifica los bytes que ya ven los lectores abiertos
+	w.node.data = append(w.node.data, p...)
+	w.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (w *memWriter) Close() error {
+	if w.closed {
+		return fs.ErrClosed
+	}
+	w.closed = true
+	return nil
+}
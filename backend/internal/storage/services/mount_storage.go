@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/infortech07/cubert/internal/shared/utils"
+	"github.com/infortech07/cubert/internal/storage/domain"
+)
+
+// MountStorage routes every path to the backend mounted at its longest matching
+// prefix and falls back to another storage, usually the local disk, elsewhere
+type MountStorage struct {
+	fallback domain.Storage
+	mounts   map[string]domain.Storage
+}
+
+func NewMountStorage(fallback domain.Storage) *MountStorage {
+	return &MountStorage{
+		fallback: fallback,
+		mounts:   make(map[string]domain.Storage),
+	}
+}
+
+// LoadMounts opens the remote roots described in a JSON file. A missing file
+// means there are no remote roots.
+func LoadMounts(ctx context.Context, file string, fallback domain.Storage) (*MountStorage, error) {
+	var mounts []domain.Mount
+	if err := utils.LoadJSONFile(file, &mounts); err != nil {
+		return nil, err
+	}
+
+	storage := NewMountStorage(fallback)
+	for _, mount := range mounts {
+		if !filepath.IsAbs(mount.Path) {
+			return nil, fmt.Errorf("remote root %q: path must be absolute", mount.Path)
+		}
+
+		var backend domain.Storage
+		var err error
+		switch mount.Type {
+		case domain.MountTypeS3:
+			if mount.S3 == nil {
+				return nil, fmt.Errorf("remote root %s: missing s3 settings", mount.Path)
+			}
+			backend, err = NewS3Storage(ctx, mount.Path, *mount.S3)
+		case domain.MountTypeSFTP:
+			if mount.SFTP == nil {
+				return nil, fmt.Errorf("remote root %s: missing sftp settings", mount.Path)
+			}
+			backend, err = NewSFTPStorage(mount.Path, *mount.SFTP)
+		default:
+			err = fmt.Errorf("%w %q", domain.ErrUnknownMountType, mount.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("remote root %s: %w", mount.Path, err)
+		}
+		storage.Mount(mount.Path, backend)
+	}
+	return storage, nil
+}
+
+// Mount serves backend at path, replacing any previous backend there
+func (s *MountStorage) Mount(path string, backend domain.Storage) {
+	s.mounts[filepath.Clean(path)] = backend
+}
+
+// Mounts returns the paths served by remote backends
+func (s *MountStorage) Mounts() []string {
+	paths := make([]string, 0, len(s.mounts))
+	for path := range s.mounts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (s *MountStorage) Mounted(path string) bool {
+	return s.backend(path) != s.fallback
+}
+
+func (s *MountStorage) Stat(ctx context.Context, path string) (fs.FileInfo, error) {
+	info, err := s.backend(path).Stat(ctx, path)
+	if errors.Is(err, fs.ErrNotExist) && len(s.childMounts(path)) > 0 {
+		// Los directorios que solo contienen puntos de montaje existen aunque no estén en disco
+		return memInfo{name: filepath.Base(path), mode: fs.ModeDir | 0755}, nil
+	}
+	return info, err
+}
+
+func (s *MountStorage) ReadDir(ctx context.Context, path string) ([]fs.DirEntry, error) {
+	children := s.childMounts(path)
+	entries, err := s.backend(path).ReadDir(ctx, path)
+	if err != nil && !(errors.Is(err, fs.ErrNotExist) && len(children) > 0) {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return entries, nil
+	}
+
+	// Un punto de montaje sustituye a lo que haya con el mismo nombre en el directorio;
+	// los directorios intermedios solo se añaden si no existen
+	merged := make([]fs.DirEntry, 0, len(entries)+len(children))
+	for _, entry := range entries {
+		if mountPoint, ok := children[entry.Name()]; ok && mountPoint {
+			continue
+		}
+		delete(children, entry.Name())
+		merged = append(merged, entry)
+	}
+	for name := range children {
+		merged = append(merged, fs.FileInfoToDirEntry(memInfo{name: name, mode: fs.ModeDir | 0755}))
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name() < merged[j].Name() })
+	return merged, nil
+}
+
+func (s *MountStorage) Open(ctx context.Context, path string) (domain.File, error) {
+	return s.backend(path).Open(ctx, path)
+}
+
+func (s *MountStorage) Create(ctx context.Context, path string) (io.WriteCloser, error) {
+	return s.backend(path).Create(ctx, path)
+}
+
+func (s *MountStorage) Mkdir(ctx context.Context, path string) error {
+	return s.backend(path).Mkdir(ctx, path)
+}
+
+func (s *MountStorage) Rename(ctx context.Context, oldPath, newPath string) error {
+	backend := s.backend(oldPath)
+	if backend != s.backend(newPath) {
+		return &fs.PathError{Op: "rename", Path: newPath, Err: domain.ErrCrossMount}
+	}
+	return backend.Rename(ctx, oldPath, newPath)
+}
+
+func (s *MountStorage) Remove(ctx context.Context, path string) error {
+	return s.backend(path).Remove(ctx, path)
+}
+
+func (s *MountStorage) Walk(ctx context.Context, root string, fn filepath.WalkFunc) error {
+	if len(s.childMounts(root)) == 0 {
+		return s.backend(root).Walk(ctx, root, fn)
+	}
+	// Se recorre a través del enrutador para entrar también en los montajes
+	return WalkStorage(ctx, s, root, fn)
+}
+
+// backend devuelve el almacenamiento montado en el prefijo más largo de path
+func (s *MountStorage) backend(path string) domain.Storage {
+	clean := filepath.Clean(path)
+	for {
+		if backend, ok := s.mounts[clean]; ok {
+			return backend
+		}
+		parent := filepath.Dir(clean)
+		if parent == clean {
+			return s.fallback
+		}
+		clean = parent
+	}
+}
+
+// childMounts devuelve los nombres de las entradas de dir que llevan a un montaje,
+// indicando si la entrada es el propio punto de montaje
+func (s *MountStorage) childMounts(dir string) map[string]bool {
+	dir = filepath.Clean(dir)
+	children := make(map[string]bool)
+	for path := range s.mounts {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		name, _, nested := strings.Cut(rel, string(filepath.Separator))
+		children[name] = children[name] || !nested
+	}
+	return children
+}
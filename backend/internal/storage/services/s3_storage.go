@@ -0,0 +1,550 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/infortech07/cubert/internal/storage/domain"
+)
+
+// S3Storage serves a bucket, or a prefix inside it, as a directory tree. Folders
+// are the common prefixes of a delimiter listing plus any "folder/" marker
+// objects created by Mkdir.
+type S3Storage struct {
+	client   *s3.Client
+	uploader *manager.Uploader
+	root     string
+	bucket   string
+	prefix   string
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]s3Listing
+}
+
+type s3Listing struct {
+	entries []fs.DirEntry
+	expires time.Time
+}
+
+func NewS3Storage(ctx context.Context, root string, cfg domain.S3Config) (*S3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("missing bucket")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	cacheTTL := domain.DefaultCacheTTL
+	if cfg.CacheTTLSeconds > 0 {
+		cacheTTL = time.Duration(cfg.CacheTTLSeconds) * time.Second
+	}
+
+	awsConfig := aws.Config{
+		Region: region,
+		// Muchos servicios compatibles no aceptan las sumas de comprobación opcionales
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	}
+	if cfg.AccessKeyID != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	}
+	client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.PathStyle
+	})
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3Storage{
+		client:   client,
+		uploader: manager.NewUploader(client),
+		root:     filepath.Clean(root),
+		bucket:   cfg.Bucket,
+		prefix:   prefix,
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]s3Listing),
+	}, nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	key, err := s.key("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if key == s.prefix {
+		return memInfo{name: filepath.Base(name), mode: fs.ModeDir | 0755}, nil
+	}
+
+	// Si el listado del padre está en caché se evita una petición
+	if entries, ok := s.cached(parentKey(key)); ok {
+		base := path.Base(key)
+		for _, entry := range entries {
+			if entry.Name() == base {
+				return entry.Info()
+			}
+		}
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &s.bucket, Key: aws.String(key)})
+	if err == nil {
+		return s3FileInfo(path.Base(key), aws.ToInt64(head.ContentLength), aws.ToTime(head.LastModified)), nil
+	}
+	if !isS3NotFound(err) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	isDir, err := s.isDir(ctx, key)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if !isDir {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memInfo{name: path.Base(key), mode: fs.ModeDir | 0755}, nil
+}
+
+func (s *S3Storage) ReadDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	key, err := s.key("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	dirKey := dirKeyOf(key, s.prefix)
+	if entries, ok := s.cached(dirKey); ok {
+		return entries, nil
+	}
+
+	var entries []fs.DirEntry
+	found := dirKey == s.prefix
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    &s.bucket,
+		Prefix:    aws.String(dirKey),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		for _, prefix := range page.CommonPrefixes {
+			found = true
+			dirName := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(prefix.Prefix), dirKey), "/")
+			if dirName != "" {
+				entries = append(entries, fs.FileInfoToDirEntry(memInfo{name: dirName, mode: fs.ModeDir | 0755}))
+			}
+		}
+		for _, object := range page.Contents {
+			found = true
+			fileName := strings.TrimPrefix(aws.ToString(object.Key), dirKey)
+			// El propio marcador de la carpeta no es una entrada
+			if fileName == "" {
+				continue
+			}
+			entries = append(entries, fs.FileInfoToDirEntry(s3FileInfo(fileName, aws.ToInt64(object.Size), aws.ToTime(object.LastModified))))
+		}
+	}
+
+	if !found {
+		// Un prefijo vacío puede ser en realidad un objeto
+		if _, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &s.bucket, Key: aws.String(key)}); err == nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: domain.ErrNotDir}
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	s.store(dirKey, entries)
+	return entries, nil
+}
+
+func (s *S3Storage) Open(ctx context.Context, name string) (domain.File, error) {
+	info, err := s.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: domain.ErrIsDir}
+	}
+	key, _ := s.key("open", name)
+	return &s3File{ctx: ctx, storage: s, key: key, info: info}, nil
+}
+
+func (s *S3Storage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	key, err := s.key("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if key == s.prefix {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: domain.ErrIsDir}
+	}
+	parent := filepath.Dir(name)
+	if info, err := s.Stat(ctx, parent); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: domain.ErrNotDir}
+	}
+
+	// El cargador divide en partes multipart lo que supere el tamaño de una parte
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket: &s.bucket,
+			Key:    aws.String(key),
+			Body:   reader,
+		})
+		reader.CloseWithError(err)
+		done <- err
+	}()
+	return &s3Writer{storage: s, key: key, name: name, pipe: writer, done: done}, nil
+}
+
+func (s *S3Storage) Mkdir(ctx context.Context, name string) error {
+	key, err := s.key("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Stat(ctx, name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if info, err := s.Stat(ctx, filepath.Dir(name)); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: domain.ErrNotDir}
+	}
+
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &s.bucket,
+		Key:    aws.String(key + "/"),
+		Body:   strings.NewReader(""),
+	})
+	s.invalidate(parentKey(key))
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Rename copies the objects to their new keys and then deletes the originals,
+// so renaming a large folder is not atomic
+func (s *S3Storage) Rename(ctx context.Context, oldName, newName string) error {
+	oldKey, err := s.key("rename", oldName)
+	if err != nil {
+		return err
+	}
+	newKey, err := s.key("rename", newName)
+	if err != nil {
+		return err
+	}
+	if oldKey == s.prefix || newKey == s.prefix {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrInvalid}
+	}
+
+	info, err := s.Stat(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	defer s.invalidateAll()
+
+	if !info.IsDir() {
+		if err := s.move(ctx, oldKey, newKey); err != nil {
+			return &fs.PathError{Op: "rename", Path: oldName, Err: err}
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(newKey+"/", oldKey+"/") {
+		return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrInvalid}
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: &s.bucket,
+		Prefix: aws.String(oldKey + "/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return &fs.PathError{Op: "rename", Path: oldName, Err: err}
+		}
+		for _, object := range page.Contents {
+			from := aws.ToString(object.Key)
+			to := newKey + "/" + strings.TrimPrefix(from, oldKey+"/")
+			if err := s.move(ctx, from, to); err != nil {
+				return &fs.PathError{Op: "rename", Path: oldName, Err: err}
+			}
+		}
+	}
+	// Los servicios respaldados por un sistema de archivos dejan la carpeta vacía
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &s.bucket, Key: aws.String(oldKey + "/")}); err != nil {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: err}
+	}
+	return nil
+}
+
+func (s *S3Storage) Remove(ctx context.Context, name string) error {
+	key, err := s.key("remove", name)
+	if err != nil {
+		return err
+	}
+	if key == s.prefix {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := s.Stat(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer s.invalidate(parentKey(key))
+
+	if !info.IsDir() {
+		if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &s.bucket, Key: aws.String(key)}); err != nil {
+			return &fs.PathError{Op: "remove", Path: name, Err: err}
+		}
+		return nil
+	}
+
+	// Una carpeta solo se puede borrar si no contiene nada más que su marcador
+	list, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  &s.bucket,
+		Prefix:  aws.String(key + "/"),
+		MaxKeys: aws.Int32(2),
+	})
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	for _, object := range list.Contents {
+		if aws.ToString(object.Key) != key+"/" {
+			return &fs.PathError{Op: "remove", Path: name, Err: domain.ErrNotEmpty}
+		}
+	}
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &s.bucket, Key: aws.String(key + "/")}); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	s.invalidate(key + "/")
+	return nil
+}
+
+func (s *S3Storage) Walk(ctx context.Context, root string, fn filepath.WalkFunc) error {
+	return WalkStorage(ctx, s, root, fn)
+}
+
+// key convierte una ruta del host en la clave del objeto, sin barra final
+func (s *S3Storage) key(op, name string) (string, error) {
+	rel, err := filepath.Rel(s.root, filepath.Clean(name))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if rel == "." {
+		return s.prefix, nil
+	}
+	return s.prefix + filepath.ToSlash(rel), nil
+}
+
+func (s *S3Storage) isDir(ctx context.Context, key string) (bool, error) {
+	list, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  &s.bucket,
+		Prefix:  aws.String(key + "/"),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(list.Contents) > 0 || len(list.CommonPrefixes) > 0, nil
+}
+
+func (s *S3Storage) move(ctx context.Context, from, to string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     &s.bucket,
+		Key:        aws.String(to),
+		CopySource: aws.String(s.bucket + "/" + escapeKey(from)),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &s.bucket, Key: aws.String(from)})
+	return err
+}
+
+func (s *S3Storage) cached(dirKey string) ([]fs.DirEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listing, ok := s.cache[dirKey]
+	if !ok || time.Now().After(listing.expires) {
+		delete(s.cache, dirKey)
+		return nil, false
+	}
+	return listing.entries, true
+}
+
+func (s *S3Storage) store(dirKey string, entries []fs.DirEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[dirKey] = s3Listing{entries: entries, expires: time.Now().Add(s.cacheTTL)}
+}
+
+func (s *S3Storage) invalidate(dirKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, dirKey)
+}
+
+func (s *S3Storage) invalidateAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = make(map[string]s3Listing)
+}
+
+// parentKey devuelve el prefijo de listado del directorio que contiene key
+func parentKey(key string) string {
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		return key[:i+1]
+	}
+	return ""
+}
+
+func dirKeyOf(key, prefix string) string {
+	if key == prefix {
+		return prefix
+	}
+	return key + "/"
+}
+
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func isS3NotFound(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotFound", "NoSuchKey":
+			return true
+		}
+	}
+	var notFound *types.NotFound
+	return errors.As(err, &notFound)
+}
+
+func s3FileInfo(name string, size int64, modTime time.Time) fs.FileInfo {
+	return memInfo{name: name, size: size, mode: 0644, modTime: modTime}
+}
+
+// s3File lee el objeto bajo demanda con peticiones Range desde la posición actual
+type s3File struct {
+	ctx     context.Context
+	storage *S3Storage
+	key     string
+	info    fs.FileInfo
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.offset >= f.info.Size() {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		object, err := f.storage.client.GetObject(f.ctx, &s3.GetObjectInput{
+			Bucket: &f.storage.bucket,
+			Key:    aws.String(f.key),
+			Range:  aws.String(fmt.Sprintf("bytes=%d-", f.offset)),
+		})
+		if err != nil {
+			if isS3NotFound(err) {
+				return 0, &fs.PathError{Op: "read", Path: f.key, Err: fs.ErrNotExist}
+			}
+			return 0, err
+		}
+		f.body = object.Body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, fs.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *s3File) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
+
+type s3Writer struct {
+	storage *S3Storage
+	key     string
+	name    string
+	pipe    *io.PipeWriter
+	done    chan error
+	closed  bool
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	return w.pipe.Write(p)
+}
+
+func (w *s3Writer) Close() error {
+	if w.closed {
+		return fs.ErrClosed
+	}
+	w.closed = true
+	w.pipe.Close()
+	err := <-w.done
+	w.storage.invalidate(parentKey(w.key))
+	if err != nil {
+		return &fs.PathError{Op: "write", Path: w.name, Err: err}
+	}
+	return nil
+}
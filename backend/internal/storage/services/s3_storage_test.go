@@ -0,0 +1,557 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/infortech07/cubert/internal/storage/domain"
+)
+
+// fakeS3 is a path-style S3 service holding a single bucket in memory. It only
+// implements the calls S3Storage makes.
+type fakeS3 struct {
+	bucket string
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte
+	nextID   int
+	requests []string
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	t.Helper()
+
+	fake := &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) put(key, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = []byte(content)
+}
+
+func (f *fakeS3) get(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	return data, ok
+}
+
+// count devuelve cuántas peticiones recibidas empiezan por prefix, p. ej. "GET list"
+func (f *fakeS3) count(prefix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		f.requests = append(f.requests, "GET list "+query.Get("prefix"))
+		f.list(w, query.Get("prefix"), query.Get("delimiter"), query.Get("max-keys"))
+
+	case r.Method == http.MethodHead:
+		f.requests = append(f.requests, "HEAD "+key)
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodGet:
+		f.requests = append(f.requests, "GET "+key+" "+r.Header.Get("Range"))
+		data, ok := f.objects[key]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if spec := r.Header.Get("Range"); spec != "" {
+			start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(spec, "bytes="), "-"))
+			if err != nil || start >= len(data) {
+				s3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)-start))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start:])
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.requests = append(f.requests, "POST uploads "+key)
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: id})
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		f.requests = append(f.requests, "PUT part "+key)
+		parts, ok := f.uploads[query.Get("uploadId")]
+		number, err := strconv.Atoi(query.Get("partNumber"))
+		if !ok || err != nil {
+			s3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		parts[number] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.requests = append(f.requests, "POST complete "+key)
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		numbers := make([]int, 0, len(parts))
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, number := range numbers {
+			data = append(data, parts[number]...)
+		}
+		f.objects[key] = data
+		delete(f.uploads, query.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: `"complete"`})
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.requests = append(f.requests, "COPY "+key)
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		_, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+		data, ok := f.objects[sourceKey]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		f.objects[key] = bytes.Clone(data)
+		writeXML(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			ETag         string
+			LastModified string
+		}{ETag: `"copy"`, LastModified: time.Now().UTC().Format(time.RFC3339)})
+
+	case r.Method == http.MethodPut:
+		f.requests = append(f.requests, "PUT "+key)
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+		w.Header().Set("ETag", `"put"`)
+
+	case r.Method == http.MethodDelete:
+		f.requests = append(f.requests, "DELETE "+key)
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		s3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix, delimiter, maxKeys string) {
+	type object struct {
+		Key          string
+		Size         int64
+		LastModified string
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Name           string
+		Prefix         string
+		KeyCount       int
+		IsTruncated    bool
+		Contents       []object
+		CommonPrefixes []commonPrefix
+	}{Name: f.bucket, Prefix: prefix}
+
+	limit, err := strconv.Atoi(maxKeys)
+	if err != nil {
+		limit = 1000
+	}
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if result.KeyCount == limit {
+			result.IsTruncated = true
+			break
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			common := prefix + rest[:i+len(delimiter)]
+			if !seen[common] {
+				seen[common] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: common})
+				result.KeyCount++
+			}
+			continue
+		}
+		result.Contents = append(result.Contents, object{
+			Key:          key,
+			Size:         int64(len(f.objects[key])),
+			LastModified: time.Now().UTC().Format(time.RFC3339),
+		})
+		result.KeyCount++
+	}
+	writeXML(w, result)
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message></Error>", xml.Header, code, code)
+}
+
+// newTestS3Storage mounts the "media/" prefix of the fake bucket at /remote
+func newTestS3Storage(t *testing.T) (*S3Storage, *fakeS3, string) {
+	t.Helper()
+
+	fake, server := newFakeS3(t, "data")
+	root := filepath.FromSlash("/remote")
+	storage, err := NewS3Storage(context.Background(), root, domain.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "data",
+		Prefix:          "/media/",
+		AccessKeyID:     "test",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return storage, fake, root
+}
+
+func entryNames(entries []fs.DirEntry) string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestS3StorageReadDirUsesPrefixAndDelimiter(t *testing.T) {
+	storage, fake, root := newTestS3Storage(t)
+	ctx := context.Background()
+
+	fake.put("media/a.txt", "alpha")
+	fake.put("media/docs/report.txt", "report")
+	fake.put("media/docs/2024/q1.txt", "q1")
+	fake.put("media/empty/", "")
+	// Fuera del prefijo del montaje
+	fake.put("other/secret.txt", "secret")
+	fake.put("mediafile.txt", "x")
+
+	entries, err := storage.ReadDir(ctx, root)
+	if err != nil {
+		t.Fatalf("ReadDir(root): %v", err)
+	}
+	if got, want := entryNames(entries), "a.txt,docs/,empty/"; got != want {
+		t.Errorf("root listed %q, want %q", got, want)
+	}
+
+	entries, err = storage.ReadDir(ctx, filepath.Join(root, "docs"))
+	if err != nil {
+		t.Fatalf("ReadDir(docs): %v", err)
+	}
+	if got, want := entryNames(entries), "2024/,report.txt"; got != want {
+		t.Errorf("docs listed %q, want %q", got, want)
+	}
+
+	// El marcador de la carpeta no aparece como entrada
+	entries, err = storage.ReadDir(ctx, filepath.Join(root, "empty"))
+	if err != nil {
+		t.Fatalf("ReadDir(empty): %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("empty listed %q", entryNames(entries))
+	}
+
+	if _, err := storage.ReadDir(ctx, filepath.Join(root, "a.txt")); !errors.Is(err, domain.ErrNotDir) {
+		t.Errorf("ReadDir on a file error = %v, want ErrNotDir", err)
+	}
+	if _, err := storage.ReadDir(ctx, filepath.Join(root, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadDir on a missing folder error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := storage.ReadDir(ctx, filepath.FromSlash("/elsewhere")); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("ReadDir outside the root error = %v, want fs.ErrInvalid", err)
+	}
+}
+
+func TestS3StorageStat(t *testing.T) {
+	storage, fake, root := newTestS3Storage(t)
+	ctx := context.Background()
+
+	fake.put("media/docs/report.txt", "report")
+
+	info, err := storage.Stat(ctx, filepath.Join(root, "docs", "report.txt"))
+	if err != nil {
+		t.Fatalf("Stat(file): %v", err)
+	}
+	if info.Name() != "report.txt" || info.Size() != 6 || info.IsDir() {
+		t.Errorf("file info = %s %d %v", info.Name(), info.Size(), info.IsDir())
+	}
+
+	// Una carpeta sin marcador existe por sus objetos
+	info, err = storage.Stat(ctx, filepath.Join(root, "docs"))
+	if err != nil || !info.IsDir() {
+		t.Errorf("Stat(docs) = %v, %v; want a directory", info, err)
+	}
+	if _, err := storage.Stat(ctx, filepath.Join(root, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(missing) error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestS3StorageRangeReads(t *testing.T) {
+	storage, fake, root := newTestS3Storage(t)
+	ctx := context.Background()
+
+	fake.put("media/digits.txt", "0123456789")
+
+	file, err := storage.Open(ctx, filepath.Join(root, "digits.txt"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(file, buf); err != nil || string(buf) != "012" {
+		t.Fatalf("first read = %q, %v", buf, err)
+	}
+
+	if _, err := file.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	rest, err := io.ReadAll(file)
+	if err != nil || string(rest) != "6789" {
+		t.Errorf("read after seek = %q, %v", rest, err)
+	}
+
+	if _, err := file.Seek(-2, io.SeekEnd); err != nil {
+		t.Fatalf("Seek from end: %v", err)
+	}
+	tail, err := io.ReadAll(file)
+	if err != nil || string(tail) != "89" {
+		t.Errorf("read from end = %q, %v", tail, err)
+	}
+
+	for _, want := range []string{"bytes=0-", "bytes=6-", "bytes=8-"} {
+		if fake.count("GET media/digits.txt "+want) != 1 {
+			t.Errorf("no GetObject with Range %s", want)
+		}
+	}
+
+	if _, err := storage.Open(ctx, root); !errors.Is(err, domain.ErrIsDir) {
+		t.Errorf("Open on the root error = %v, want ErrIsDir", err)
+	}
+}
+
+func TestS3StorageMultipartUpload(t *testing.T) {
+	storage, fake, root := newTestS3Storage(t)
+	ctx := context.Background()
+
+	fake.put("media/docs/", "")
+
+	// Por encima de los 5 MiB de una parte el cargador pasa a multipart
+	data := bytes.Repeat([]byte("0123456789abcdef"), 6<<20/16)
+	writer, err := storage.Create(ctx, filepath.Join(root, "docs", "big.bin"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := io.Copy(writer, bytes.NewReader(data)); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if fake.count("POST uploads media/docs/big.bin") != 1 || fake.count("POST complete media/docs/big.bin") != 1 {
+		t.Errorf("upload was not multipart: %v", fake.requests)
+	}
+	if parts := fake.count("PUT part media/docs/big.bin"); parts != 2 {
+		t.Errorf("uploaded %d parts, want 2", parts)
+	}
+	if stored, _ := fake.get("media/docs/big.bin"); !bytes.Equal(stored, data) {
+		t.Errorf("stored %d bytes, want %d", len(stored), len(data))
+	}
+
+	// Un archivo pequeño se sube con una sola petición
+	writer, err = storage.Create(ctx, filepath.Join(root, "docs", "small.txt"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	io.WriteString(writer, "small")
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if fake.count("PUT media/docs/small.txt") != 1 {
+		t.Errorf("small file was not a single PutObject: %v", fake.requests)
+	}
+
+	if _, err := storage.Create(ctx, filepath.Join(root, "missing", "file.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Create in a missing folder error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestS3StorageListingCache(t *testing.T) {
+	storage, fake, root := newTestS3Storage(t)
+	ctx := context.Background()
+	docs := filepath.Join(root, "docs")
+
+	fake.put("media/docs/a.txt", "a")
+	if _, err := storage.ReadDir(ctx, docs); err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+
+	// Un cambio hecho por otro cliente no se ve mientras dure la caché
+	fake.put("media/docs/b.txt", "b")
+	entries, err := storage.ReadDir(ctx, docs)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if got := entryNames(entries); got != "a.txt" {
+		t.Errorf("cached listing = %q, want a.txt", got)
+	}
+	if lists := fake.count("GET list media/docs/"); lists != 1 {
+		t.Errorf("listed the bucket %d times, want 1", lists)
+	}
+	// Stat también responde desde el listado en caché
+	if _, err := storage.Stat(ctx, filepath.Join(docs, "b.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(b.txt) within the TTL error = %v, want fs.ErrNotExist", err)
+	}
+
+	// Escribir a través del almacenamiento invalida el listado del padre
+	writer, err := storage.Create(ctx, filepath.Join(docs, "c.txt"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	io.WriteString(writer, "c")
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	entries, err = storage.ReadDir(ctx, docs)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if got, want := entryNames(entries), "a.txt,b.txt,c.txt"; got != want {
+		t.Errorf("listing after a write = %q, want %q", got, want)
+	}
+
+	// Y al caducar se vuelve a listar
+	storage.cacheTTL = time.Millisecond
+	storage.invalidateAll()
+	if _, err := storage.ReadDir(ctx, docs); err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	fake.put("media/docs/d.txt", "d")
+	time.Sleep(5 * time.Millisecond)
+	entries, err = storage.ReadDir(ctx, docs)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if got, want := entryNames(entries), "a.txt,b.txt,c.txt,d.txt"; got != want {
+		t.Errorf("listing after the TTL = %q, want %q", got, want)
+	}
+}
+
+func TestS3StorageRenameAndRemove(t *testing.T) {
+	storage, fake, root := newTestS3Storage(t)
+	ctx := context.Background()
+
+	fake.put("media/docs/a.txt", "a")
+	fake.put("media/docs/sub/b.txt", "b")
+	if err := storage.Rename(ctx, filepath.Join(root, "docs"), filepath.Join(root, "papers")); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if data, ok := fake.get("media/papers/sub/b.txt"); !ok || string(data) != "b" {
+		t.Errorf("nested object not moved: %q, %v", data, ok)
+	}
+	if _, ok := fake.get("media/docs/a.txt"); ok {
+		t.Error("old object left behind")
+	}
+
+	if err := storage.Remove(ctx, filepath.Join(root, "papers")); !errors.Is(err, domain.ErrNotEmpty) {
+		t.Errorf("Remove on a non-empty folder error = %v, want ErrNotEmpty", err)
+	}
+	if err := storage.Remove(ctx, filepath.Join(root, "papers", "a.txt")); err != nil {
+		t.Errorf("Remove(file): %v", err)
+	}
+	if _, err := storage.Stat(ctx, filepath.Join(root, "papers", "a.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("removed file still exists: %v", err)
+	}
+}
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/infortech07/cubert/internal/storage/domain"
+)
+
+const (
+	sftpDefaultPort           = 22
+	sftpDefaultMaxConnections = 4
+	sftpDialTimeout           = 15 * time.Second
+)
+
+// SFTPStorage serves a directory of an SSH server. Requests share a small pool
+// of connections: a new one is opened only when all the others are busy.
+type SFTPStorage struct {
+	root       string
+	remoteRoot string
+	addr       string
+	config     *ssh.ClientConfig
+	maxConns   int
+
+	mu    sync.Mutex
+	conns []*sftpConn
+}
+
+type sftpConn struct {
+	ssh    *ssh.Client
+	client *sftp.Client
+	// active cuenta las operaciones y archivos abiertos que usan la conexión
+	active int
+}
+
+// NewSFTPStorage validates the configuration without connecting, so a server
+// that is down does not prevent Cubert from starting
+func NewSFTPStorage(root string, cfg domain.SFTPConfig) (*SFTPStorage, error) {
+	if cfg.Host == "" || cfg.Username == "" {
+		return nil, errors.New("host and username are required")
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = sftpDefaultPort
+	}
+
+	hostKeyCallback, err := sftpHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth []ssh.AuthMethod
+	if cfg.PrivateKeyFile != "" {
+		keyData, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		var signer ssh.Signer
+		if cfg.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(cfg.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(keyData)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		password := cfg.Password
+		auth = append(auth, ssh.Password(password), ssh.KeyboardInteractive(
+			func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("password or private_key_file is required")
+	}
+
+	remoteRoot := cfg.RemotePath
+	if remoteRoot == "" {
+		remoteRoot = "."
+	}
+	maxConns := cfg.MaxConnections
+	if maxConns <= 0 {
+		maxConns = sftpDefaultMaxConnections
+	}
+
+	return &SFTPStorage{
+		root:       filepath.Clean(root),
+		remoteRoot: path.Clean(remoteRoot),
+		addr:       net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		config: &ssh.ClientConfig{
+			User:            cfg.Username,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         sftpDialTimeout,
+		},
+		maxConns: maxConns,
+	}, nil
+}
+
+func sftpHostKeyCallback(cfg domain.SFTPConfig) (ssh.HostKeyCallback, error) {
+	switch {
+	case cfg.KnownHostsFile != "":
+		callback, err := knownhosts.New(cfg.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known hosts: %w", err)
+		}
+		return callback, nil
+	case cfg.HostKey != "":
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host key: %w", err)
+		}
+		return ssh.FixedHostKey(key), nil
+	default:
+		return nil, domain.ErrNoHostKey
+	}
+}
+
+func (s *SFTPStorage) Stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	remote, err := s.remote("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	var info fs.FileInfo
+	err = s.do(ctx, func(client *sftp.Client) (err error) {
+		info, err = client.Stat(remote)
+		return err
+	})
+	if err != nil {
+		return nil, sftpError("stat", name, err)
+	}
+	if remote == s.remoteRoot {
+		// La raíz remota toma el nombre del punto de montaje
+		return memInfo{name: filepath.Base(name), mode: info.Mode(), modTime: info.ModTime()}, nil
+	}
+	return info, nil
+}
+
+func (s *SFTPStorage) ReadDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	remote, err := s.remote("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	var infos []os.FileInfo
+	err = s.do(ctx, func(client *sftp.Client) (err error) {
+		infos, err = client.ReadDir(remote)
+		return err
+	})
+	if err != nil {
+		return nil, sftpError("readdir", name, err)
+	}
+
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (s *SFTPStorage) Open(ctx context.Context, name string) (domain.File, error) {
+	remote, err := s.remote("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, release, err := s.openFile(ctx, func(client *sftp.Client) (*sftp.File, error) {
+		return client.Open(remote)
+	})
+	if err != nil {
+		return nil, sftpError("open", name, err)
+	}
+	return &sftpFile{File: file, release: release}, nil
+}
+
+func (s *SFTPStorage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	remote, err := s.remote("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, release, err := s.openFile(ctx, func(client *sftp.Client) (*sftp.File, error) {
+		return client.Create(remote)
+	})
+	if err != nil {
+		return nil, sftpError("open", name, err)
+	}
+	return &sftpFile{File: file, release: release}, nil
+}
+
+func (s *SFTPStorage) Mkdir(ctx context.Context, name string) error {
+	remote, err := s.remote("mkdir", name)
+	if err != nil {
+		return err
+	}
+	err = s.do(ctx, func(client *sftp.Client) error {
+		return client.Mkdir(remote)
+	})
+	return sftpError("mkdir", name, err)
+}
+
+func (s *SFTPStorage) Rename(ctx context.Context, oldName, newName string) error {
+	oldRemote, err := s.remote("rename", oldName)
+	if err != nil {
+		return err
+	}
+	newRemote, err := s.remote("rename", newName)
+	if err != nil {
+		return err
+	}
+	err = s.do(ctx, func(client *sftp.Client) error {
+		// posix-rename reemplaza el destino como os.Rename; no todos los servidores lo tienen
+		if _, ok := client.HasExtension("posix-rename@openssh.com"); ok {
+			return client.PosixRename(oldRemote, newRemote)
+		}
+		return client.Rename(oldRemote, newRemote)
+	})
+	return sftpError("rename", oldName, err)
+}
+
+func (s *SFTPStorage) Remove(ctx context.Context, name string) error {
+	remote, err := s.remote("remove", name)
+	if err != nil {
+		return err
+	}
+	if remote == s.remoteRoot {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	err = s.do(ctx, func(client *sftp.Client) error {
+		return client.Remove(remote)
+	})
+	return sftpError("remove", name, err)
+}
+
+func (s *SFTPStorage) Walk(ctx context.Context, root string, fn filepath.WalkFunc) error {
+	return WalkStorage(ctx, s, root, fn)
+}
+
+// remote convierte una ruta del host en la ruta del servidor
+func (s *SFTPStorage) remote(op, name string) (string, error) {
+	rel, err := filepath.Rel(s.root, filepath.Clean(name))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(s.remoteRoot, filepath.ToSlash(rel)), nil
+}
+
+// do ejecuta op con una conexión del pool y la repite una vez con una conexión
+// nueva si la anterior se había cerrado
+func (s *SFTPStorage) do(ctx context.Context, op func(client *sftp.Client) error) error {
+	for attempt := 0; ; attempt++ {
+		conn, err := s.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		err = op(conn.client)
+		lost := isConnectionLost(err)
+		s.release(conn, lost)
+		if !lost || attempt > 0 {
+			return err
+		}
+	}
+}
+
+// openFile abre un archivo que retiene su conexión hasta que se cierra
+func (s *SFTPStorage) openFile(ctx context.Context, open func(client *sftp.Client) (*sftp.File, error)) (*sftp.File, func(), error) {
+	for attempt := 0; ; attempt++ {
+		conn, err := s.acquire(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		file, err := open(conn.client)
+		if err == nil {
+			var once sync.Once
+			return file, func() { once.Do(func() { s.release(conn, false) }) }, nil
+		}
+		lost := isConnectionLost(err)
+		s.release(conn, lost)
+		if !lost || attempt > 0 {
+			return nil, nil, err
+		}
+	}
+}
+
+// acquire devuelve la conexión menos ocupada, o abre otra si todas tienen trabajo
+// y no se ha llegado al máximo
+func (s *SFTPStorage) acquire(ctx context.Context) (*sftpConn, error) {
+	s.mu.Lock()
+	var best *sftpConn
+	for _, conn := range s.conns {
+		if best == nil || conn.active < best.active {
+			best = conn
+		}
+	}
+	if best != nil && (best.active == 0 || len(s.conns) >= s.maxConns) {
+		best.active++
+		s.mu.Unlock()
+		return best, nil
+	}
+	s.mu.Unlock()
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn.active = 1
+	s.conns = append(s.conns, conn)
+	return conn, nil
+}
+
+func (s *SFTPStorage) release(conn *sftpConn, discard bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conn.active--
+	if !discard {
+		return
+	}
+	for i, candidate := range s.conns {
+		if candidate == conn {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			break
+		}
+	}
+	conn.client.Close()
+	conn.ssh.Close()
+}
+
+func (s *SFTPStorage) dial(ctx context.Context) (*sftpConn, error) {
+	dialer := net.Dialer{Timeout: sftpDialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", s.addr, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, s.addr, s.config)
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("ssh handshake with %s failed: %w", s.addr, err)
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("failed to start sftp on %s: %w", s.addr, err)
+	}
+
+	// La conexión se descarta en cuanto el servidor la cierra
+	conn := &sftpConn{ssh: sshClient, client: client}
+	go func() {
+		sshClient.Wait()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, candidate := range s.conns {
+			if candidate == conn {
+				s.conns = append(s.conns[:i], s.conns[i+1:]...)
+				break
+			}
+		}
+	}()
+	return conn, nil
+}
+
+func isConnectionLost(err error) bool {
+	return errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// sftpError informa de la ruta del host en lugar de la del servidor
+func sftpError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// sftpFile devuelve la conexión al pool al cerrarse
+type sftpFile struct {
+	*sftp.File
+	release func()
+}
+
+func (f *sftpFile) Close() error {
+	defer f.release()
+	return f.File.Close()
+}
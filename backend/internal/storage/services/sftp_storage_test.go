@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/infortech07/cubert/internal/storage/domain"
+)
+
+const (
+	testSFTPUser     = "cubert"
+	testSFTPPassword = "sftp-secret"
+)
+
+// startSFTPServer serves dir over SFTP on a local port and returns the address
+// and the host key in authorized_keys format
+func startSFTPServer(t *testing.T) (host string, port int, hostKey string) {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("host key signer: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == testSFTPUser && string(password) == testSFTPPassword {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		conns []net.Conn
+		wg    sync.WaitGroup
+	)
+	t.Cleanup(func() {
+		listener.Close()
+		mu.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				serveSFTPConn(conn, config)
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+}
+
+func serveSFTPConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				// El payload del subsistema es una cadena SSH: longitud y nombre
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+			}
+		}()
+		go func() {
+			defer channel.Close()
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+		}()
+	}
+}
+
+// newTestSFTPStorage mounts a temporary directory served over SFTP at /remote
+func newTestSFTPStorage(t *testing.T) (*SFTPStorage, string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	host, port, hostKey := startSFTPServer(t)
+	root := filepath.FromSlash("/remote")
+	storage, err := NewSFTPStorage(root, domain.SFTPConfig{
+		Host:       host,
+		Port:       port,
+		Username:   testSFTPUser,
+		Password:   testSFTPPassword,
+		HostKey:    hostKey,
+		RemotePath: filepath.ToSlash(dir),
+	})
+	if err != nil {
+		t.Fatalf("NewSFTPStorage: %v", err)
+	}
+	return storage, root, dir
+}
+
+func TestSFTPStorageReadDirAndStat(t *testing.T) {
+	storage, root, dir := newTestSFTPStorage(t)
+	ctx := context.Background()
+
+	os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	os.WriteFile(filepath.Join(dir, "docs", "report.txt"), []byte("report"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644)
+
+	entries, err := storage.ReadDir(ctx, root)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if got, want := entryNames(entries), "b.txt,docs/"; got != want {
+		t.Errorf("listed %q, want %q", got, want)
+	}
+
+	// La raíz remota toma el nombre del punto de montaje
+	info, err := storage.Stat(ctx, root)
+	if err != nil || !info.IsDir() || info.Name() != "remote" {
+		t.Errorf("Stat(root) = %v, %v", info, err)
+	}
+	info, err = storage.Stat(ctx, filepath.Join(root, "docs", "report.txt"))
+	if err != nil {
+		t.Fatalf("Stat(file): %v", err)
+	}
+	if info.Name() != "report.txt" || info.Size() != 6 || info.IsDir() {
+		t.Errorf("file info = %s %d %v", info.Name(), info.Size(), info.IsDir())
+	}
+
+	_, err = storage.Stat(ctx, filepath.Join(root, "missing"))
+	var pathErr *fs.PathError
+	if !errors.Is(err, fs.ErrNotExist) || !errors.As(err, &pathErr) || pathErr.Path != filepath.Join(root, "missing") {
+		t.Errorf("Stat(missing) error = %v, want fs.ErrNotExist on the host path", err)
+	}
+	if _, err := storage.Stat(ctx, filepath.FromSlash("/elsewhere")); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Stat outside the root error = %v, want fs.ErrInvalid", err)
+	}
+}
+
+func TestSFTPStorageReadAndWrite(t *testing.T) {
+	storage, root, dir := newTestSFTPStorage(t)
+	ctx := context.Background()
+	name := filepath.Join(root, "notes.txt")
+
+	writer, err := storage.Create(ctx, name)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	content := strings.Repeat("0123456789", 10000)
+	if _, err := io.WriteString(writer, content); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.txt")); string(data) != content {
+		t.Errorf("server has %d bytes, want %d", len(data), len(content))
+	}
+
+	file, err := storage.Open(ctx, name)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	tail, err := io.ReadAll(file)
+	if err != nil || string(tail) != "56789" {
+		t.Errorf("read after seek = %q, %v", tail, err)
+	}
+	if info, err := file.Stat(); err != nil || info.Size() != int64(len(content)) {
+		t.Errorf("file Stat = %v, %v", info, err)
+	}
+
+	if _, err := storage.Open(ctx, filepath.Join(root, "missing.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open(missing) error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestSFTPStorageMkdirRenameRemove(t *testing.T) {
+	storage, root, dir := newTestSFTPStorage(t)
+	ctx := context.Background()
+
+	if err := storage.Mkdir(ctx, filepath.Join(root, "docs")); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "docs", "a.txt"), []byte("a"), 0644)
+
+	if err := storage.Rename(ctx, filepath.Join(root, "docs"), filepath.Join(root, "papers")); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "papers", "a.txt")); err != nil || string(data) != "a" {
+		t.Errorf("renamed folder lost its content: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("old folder still exists: %v", err)
+	}
+
+	if err := storage.Remove(ctx, filepath.Join(root, "papers")); err == nil {
+		t.Error("Remove on a non-empty folder succeeded")
+	}
+	if err := storage.Remove(ctx, filepath.Join(root, "papers", "a.txt")); err != nil {
+		t.Errorf("Remove(file): %v", err)
+	}
+	if err := storage.Remove(ctx, filepath.Join(root, "papers")); err != nil {
+		t.Errorf("Remove(empty folder): %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "papers")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("removed folder still exists: %v", err)
+	}
+	if err := storage.Remove(ctx, root); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Remove(root) error = %v, want fs.ErrInvalid", err)
+	}
+}
+
+func TestSFTPStorageRejectsUnknownHostKey(t *testing.T) {
+	host, port, _ := startSFTPServer(t)
+
+	// La clave de otro servidor
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	otherKey, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatalf("public key: %v", err)
+	}
+
+	storage, err := NewSFTPStorage(filepath.FromSlash("/remote"), domain.SFTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: testSFTPUser,
+		Password: testSFTPPassword,
+		HostKey:  string(ssh.MarshalAuthorizedKey(otherKey)),
+	})
+	if err != nil {
+		t.Fatalf("NewSFTPStorage: %v", err)
+	}
+	_, err = storage.Stat(context.Background(), filepath.FromSlash("/remote"))
+	if err == nil || !strings.Contains(err.Error(), "handshake") {
+		t.Errorf("Stat with a wrong host key error = %v, want a failed handshake", err)
+	}
+
+	// Sin clave ni known_hosts no se acepta la configuración
+	_, err = NewSFTPStorage(filepath.FromSlash("/remote"), domain.SFTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: testSFTPUser,
+		Password: testSFTPPassword,
+	})
+	if !errors.Is(err, domain.ErrNoHostKey) {
+		t.Errorf("NewSFTPStorage without a host key error = %v, want ErrNoHostKey", err)
+	}
+
+	// Una entrada de known_hosts para otro servidor tampoco vale
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := "[" + host + "]:" + strconv.Itoa(port) + " " + string(ssh.MarshalAuthorizedKey(otherKey))
+	if err := os.WriteFile(knownHosts, []byte(line), 0600); err != nil {
+		t.Fatalf("writing known_hosts: %v", err)
+	}
+	storage, err = NewSFTPStorage(filepath.FromSlash("/remote"), domain.SFTPConfig{
+		Host:           host,
+		Port:           port,
+		Username:       testSFTPUser,
+		Password:       testSFTPPassword,
+		KnownHostsFile: knownHosts,
+	})
+	if err != nil {
+		t.Fatalf("NewSFTPStorage: %v", err)
+	}
+	if _, err := storage.Stat(context.Background(), filepath.FromSlash("/remote")); err == nil {
+		t.Error("Stat with a mismatching known_hosts entry succeeded")
+	}
+}
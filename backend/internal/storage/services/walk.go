@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/infortech07/cubert/internal/storage/domain"
+)
+
+// WalkStorage implements Walk on top of Stat and ReadDir with the same rules as
+// filepath.Walk for SkipDir, SkipAll and read errors
+func WalkStorage(ctx context.Context, storage domain.Storage, root string, fn filepath.WalkFunc) error {
+	info, err := storage.Stat(ctx, root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkEntry(ctx, storage, root, info, fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkEntry(ctx context.Context, storage domain.Storage, path string, info fs.FileInfo, fn filepath.WalkFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	entries, readErr := storage.ReadDir(ctx, path)
+	err := fn(path, info, readErr)
+	if readErr != nil || err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		childPath := filepath.Join(path, entry.Name())
+		childInfo, err := entry.Info()
+		if err != nil {
+			if err := fn(childPath, childInfo, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := walkEntry(ctx, storage, childPath, childInfo, fn); err != nil {
+			if !childInfo.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// TypeFamily groups content types the same way the explorer icons do
+type TypeFamily string
+
+const (
+	FamilyImages    TypeFamily = "images"
+	FamilyVideo     TypeFamily = "video"
+	FamilyAudio     TypeFamily = "audio"
+	FamilyDocuments TypeFamily = "documents"
+	FamilyOther     TypeFamily = "other"
+)
+
+var Families = []TypeFamily{FamilyImages, FamilyVideo, FamilyAudio, FamilyDocuments, FamilyOther}
+
+type SystemStats struct {
+	Filesystems []FilesystemStats `json:"filesystems"`
+	CPU         CPUStats          `json:"cpu"`
+	Memory      MemoryStats       `json:"memory"`
+	Uptime      UptimeStats       `json:"uptime"`
+	Index       IndexStats        `json:"index"`
+	GeneratedAt time.Time         `json:"generated_at"`
+}
+
+// FilesystemStats describes the mounted filesystem backing one or more library roots
+type FilesystemStats struct {
+	MountPoint    string   `json:"mount_point"`
+	Device        string   `json:"device"`
+	Type          string   `json:"type"`
+	Roots         []string `json:"roots"`
+	TotalBytes    uint64   `json:"total_bytes"`
+	UsedBytes     uint64   `json:"used_bytes"`
+	FreeBytes     uint64   `json:"free_bytes"`
+	UsedPercent   float64  `json:"used_percent"`
+	TotalInodes   uint64   `json:"total_inodes"`
+	UsedInodes    uint64   `json:"used_inodes"`
+	FreeInodes    uint64   `json:"free_inodes"`
+	InodesPercent float64  `json:"inodes_used_percent"`
+	ReadOnly      bool     `json:"read_only"`
+}
+
+type CPUStats struct {
+	Cores        int     `json:"cores"`
+	Load1        float64 `json:"load_1"`
+	Load5        float64 `json:"load_5"`
+	Load15       float64 `json:"load_15"`
+	UsagePercent float64 `json:"usage_percent"`
+}
+
+type MemoryStats struct {
+	TotalBytes     uint64  `json:"total_bytes"`
+	UsedBytes      uint64  `json:"used_bytes"`
+	AvailableBytes uint64  `json:"available_bytes"`
+	UsedPercent    float64 `json:"used_percent"`
+	SwapTotalBytes uint64  `json:"swap_total_bytes"`
+	SwapUsedBytes  uint64  `json:"swap_used_bytes"`
+	ProcessBytes   uint64  `json:"process_bytes"`
+}
+
+type UptimeStats struct {
+	SystemSeconds int64     `json:"system_seconds"`
+	ServerSeconds int64     `json:"server_seconds"`
+	StartedAt     time.Time `json:"started_at"`
+}
+
+type FamilyStats struct {
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// ReindexJobType is the background job that walks the library roots on demand
+const ReindexJobType = "system.reindex"
+
+type IndexStatus string
+
+const (
+	IndexPending IndexStatus = "pending"
+	IndexRunning IndexStatus = "running"
+	IndexReady   IndexStatus = "ready"
+)
+
+// IndexStats is the result of the last walk over the library roots
+type IndexStats struct {
+	Status       IndexStatus                `json:"status"`
+	Roots        []string                   `json:"roots"`
+	TotalFiles   int64                      `json:"total_files"`
+	TotalFolders int64                      `json:"total_folders"`
+	TotalBytes   int64                      `json:"total_bytes"`
+	Families     map[TypeFamily]FamilyStats `json:"families"`
+	ErrorCount   int64                      `json:"error_count"`
+	IndexedAt    time.Time                  `json:"indexed_at,omitempty"`
+	DurationMs   int64                      `json:"duration_ms"`
+}
+
+var ErrUnsupportedPlatform = errors.New("system statistics are only available on Linux")
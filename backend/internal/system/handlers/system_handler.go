@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	jobhandlers "github.com/infortech07/cubert/internal/jobs/handlers"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	"github.com/infortech07/cubert/internal/system/domain"
+	"github.com/infortech07/cubert/internal/system/services"
+)
+
+type SystemHandler struct {
+	statsService *services.StatsService
+}
+
+func NewSystemHandler(statsService *services.StatsService) *SystemHandler {
+	return &SystemHandler{
+		statsService: statsService,
+	}
+}
+
+func (h *SystemHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.statsService.Stats(r.Context())
+	if err != nil {
+		if errors.Is(err, domain.ErrUnsupportedPlatform) {
+			utils.WriteErrorResponse(w, http.StatusNotImplemented, "System statistics unavailable", err)
+			return
+		}
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to read system statistics", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, stats)
+}
+
+// Reindex queues a walk of the library roots that feeds the per-type breakdown
+func (h *SystemHandler) Reindex(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+
+	job, err := h.statsService.Reindex(r.Context(), user.ID)
+	if err != nil {
+		jobhandlers.WriteJobError(w, err)
+		return
+	}
+
+	jobhandlers.WriteJobAccepted(w, job)
+}
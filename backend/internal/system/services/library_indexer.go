@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infortech07/cubert/internal/shared/utils"
+	"github.com/infortech07/cubert/internal/system/domain"
+)
+
+// LibraryIndexer periodically walks the library roots and tallies bytes per type family.
+// Walks never cross into another mounted filesystem, so /proc, /sys and network mounts
+// below a root are skipped.
+type LibraryIndexer struct {
+	roots    []string
+	interval time.Duration
+
+	mu        sync.RWMutex
+	current   domain.IndexStats
+	listeners []IndexListener
+
+	// Evita que el recorrido periódico y un reindexado manual se solapen
+	running chan struct{}
+}
+
+func NewLibraryIndexer(roots []string, interval time.Duration) *LibraryIndexer {
+	return &LibraryIndexer{
+		roots:    roots,
+		interval: interval,
+		current: domain.IndexStats{
+			Status:   domain.IndexPending,
+			Roots:    roots,
+			Families: emptyFamilies(),
+		},
+		running: make(chan struct{}, 1),
+	}
+}
+
+// IndexListener receives, after each root is walked, the regular files and bytes found
+// directly inside every folder of that root
+type IndexListener func(root string, dirs map[string]domain.FamilyStats)
+
+// Subscribe registers a listener for the per-folder tallies of each walk
+func (x *LibraryIndexer) Subscribe(listener IndexListener) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.listeners = append(x.listeners, listener)
+}
+
+// Start runs the first walk immediately and then every interval until ctx is done
+func (x *LibraryIndexer) Start(ctx context.Context) {
+	go func() {
+		var tick <-chan time.Time
+		if x.interval > 0 {
+			ticker := time.NewTicker(x.interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		x.Index(ctx, nil)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+				x.Index(ctx, nil)
+			}
+		}
+	}()
+}
+
+// Stats returns the last completed walk, or the pending/running placeholder
+func (x *LibraryIndexer) Stats() domain.IndexStats {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	stats := x.current
+	stats.Families = make(map[domain.TypeFamily]domain.FamilyStats, len(x.current.Families))
+	for family, value := range x.current.Families {
+		stats.Families[family] = value
+	}
+	return stats
+}
+
+// Index walks the roots now, waiting for a walk already in progress. onFile, if set,
+// receives the running count of indexed files.
+func (x *LibraryIndexer) Index(ctx context.Context, onFile func(files int64)) (domain.IndexStats, error) {
+	select {
+	case x.running <- struct{}{}:
+		defer func() { <-x.running }()
+	case <-ctx.Done():
+		return domain.IndexStats{}, ctx.Err()
+	}
+
+	x.mu.Lock()
+	x.current.Status = domain.IndexRunning
+	x.mu.Unlock()
+
+	start := time.Now()
+	result := domain.IndexStats{
+		Status:   domain.IndexReady,
+		Roots:    x.roots,
+		Families: emptyFamilies(),
+	}
+	familyByExt := make(map[string]domain.TypeFamily)
+
+	x.mu.RLock()
+	listeners := append([]IndexListener{}, x.listeners...)
+	x.mu.RUnlock()
+
+	for _, root := range x.roots {
+		rootDevice, hasDevice := uint64(0), false
+		// Solo se lleva la cuenta por carpeta si alguien la necesita
+		var dirs map[string]domain.FamilyStats
+		if len(listeners) > 0 {
+			dirs = make(map[string]domain.FamilyStats)
+		}
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				result.ErrorCount++
+				if entry != nil && entry.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				result.ErrorCount++
+				return nil
+			}
+
+			if entry.IsDir() {
+				device, ok := deviceOf(info)
+				if path == root {
+					rootDevice, hasDevice = device, ok
+				} else if ok && hasDevice && device != rootDevice {
+					return fs.SkipDir
+				}
+				if path != root {
+					result.TotalFolders++
+				}
+				return nil
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			family, ok := familyByExt[ext]
+			if !ok {
+				family = classify(utils.GetContentType(entry.Name()))
+				familyByExt[ext] = family
+			}
+
+			stats := result.Families[family]
+			stats.Files++
+			stats.Bytes += info.Size()
+			result.Families[family] = stats
+
+			result.TotalFiles++
+			result.TotalBytes += info.Size()
+			if dirs != nil {
+				dir := dirs[filepath.Dir(path)]
+				dir.Files++
+				dir.Bytes += info.Size()
+				dirs[filepath.Dir(path)] = dir
+			}
+			if onFile != nil {
+				onFile(result.TotalFiles)
+			}
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("stats: failed to index %s: %v", root, err)
+		}
+		if err == nil && ctx.Err() == nil {
+			for _, listener := range listeners {
+				listener(root, dirs)
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		// Se conserva el último resultado completo
+		x.mu.Lock()
+		if x.current.IndexedAt.IsZero() {
+			x.current.Status = domain.IndexPending
+		} else {
+			x.current.Status = domain.IndexReady
+		}
+		x.mu.Unlock()
+		return domain.IndexStats{}, err
+	}
+
+	result.IndexedAt = time.Now()
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	x.mu.Lock()
+	x.current = result
+	x.mu.Unlock()
+	return result, nil
+}
+
+func classify(contentType string) domain.TypeFamily {
+	switch {
+	case utils.IsImageFile(contentType):
+		return domain.FamilyImages
+	case utils.IsVideoFile(contentType):
+		return domain.FamilyVideo
+	case utils.IsAudioFile(contentType):
+		return domain.FamilyAudio
+	case utils.IsDocumentFile(contentType):
+		return domain.FamilyDocuments
+	default:
+		return domain.FamilyOther
+	}
+}
+
+func emptyFamilies() map[domain.TypeFamily]domain.FamilyStats {
+	families := make(map[domain.TypeFamily]domain.FamilyStats, len(domain.Families))
+	for _, family := range domain.Families {
+		families[family] = domain.FamilyStats{}
+	}
+	return families
+}
@@ -0,0 +1,281 @@
+//go:build linux
+
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/infortech07/cubert/internal/system/domain"
+)
+
+type mountEntry struct {
+	mountPoint string
+	device     string
+	fsType     string
+	readOnly   bool
+}
+
+// readFilesystems statfs()s the mount backing each root; roots on the same mount are grouped
+func readFilesystems(roots []string) ([]domain.FilesystemStats, error) {
+	mounts, err := readMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []domain.FilesystemStats
+	index := make(map[string]int)
+
+	for _, root := range roots {
+		resolved, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+
+		mount := findMount(mounts, resolved)
+		if i, ok := index[mount.mountPoint]; ok {
+			result[i].Roots = append(result[i].Roots, root)
+			continue
+		}
+
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(resolved, &st); err != nil {
+			continue
+		}
+
+		blockSize := uint64(st.Bsize)
+		total := st.Blocks * blockSize
+		free := st.Bavail * blockSize
+		used := (st.Blocks - st.Bfree) * blockSize
+		usedInodes := st.Files - st.Ffree
+
+		index[mount.mountPoint] = len(result)
+		result = append(result, domain.FilesystemStats{
+			MountPoint:    mount.mountPoint,
+			Device:        mount.device,
+			Type:          mount.fsType,
+			Roots:         []string{root},
+			TotalBytes:    total,
+			UsedBytes:     used,
+			FreeBytes:     free,
+			UsedPercent:   percent(used, used+free),
+			TotalInodes:   st.Files,
+			UsedInodes:    usedInodes,
+			FreeInodes:    st.Ffree,
+			InodesPercent: percent(usedInodes, st.Files),
+			ReadOnly:      mount.readOnly,
+		})
+	}
+
+	return result, nil
+}
+
+// readMounts parses /proc/self/mountinfo; later entries shadow earlier ones on the same point
+func readMounts() ([]mountEntry, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mount table: %w", err)
+	}
+	defer file.Close()
+
+	var mounts []mountEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(scanner.Text())
+		separator := -1
+		for i, field := range fields {
+			if field == "-" {
+				separator = i
+				break
+			}
+		}
+		if len(fields) < 6 || separator < 0 || separator+2 >= len(fields) {
+			continue
+		}
+
+		options := strings.Split(fields[5], ",")
+		mounts = append(mounts, mountEntry{
+			mountPoint: unescapeMount(fields[4]),
+			fsType:     fields[separator+1],
+			device:     unescapeMount(fields[separator+2]),
+			readOnly:   len(options) > 0 && options[0] == "ro",
+		})
+	}
+	return mounts, scanner.Err()
+}
+
+func findMount(mounts []mountEntry, path string) mountEntry {
+	best := mountEntry{mountPoint: "/"}
+	bestLen := -1
+	for _, mount := range mounts {
+		point := mount.mountPoint
+		if path != point && !strings.HasPrefix(path, strings.TrimSuffix(point, "/")+"/") {
+			continue
+		}
+		if len(point) >= bestLen {
+			best, bestLen = mount, len(point)
+		}
+	}
+	return best
+}
+
+// unescapeMount decodes the octal escapes (\040 for spaces) used in mountinfo
+func unescapeMount(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) {
+			if n, err := strconv.ParseUint(value[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+func readLoad() (domain.CPUStats, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return domain.CPUStats{}, fmt.Errorf("failed to read load average: %w", err)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return domain.CPUStats{}, fmt.Errorf("unexpected /proc/loadavg format")
+	}
+
+	var stats domain.CPUStats
+	stats.Load1, _ = strconv.ParseFloat(fields[0], 64)
+	stats.Load5, _ = strconv.ParseFloat(fields[1], 64)
+	stats.Load15, _ = strconv.ParseFloat(fields[2], 64)
+	return stats, nil
+}
+
+// readCPUSample reads the aggregate "cpu" line of /proc/stat
+func readCPUSample() (cpuSample, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return cpuSample{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+
+		var sample cpuSample
+		for i, field := range fields[1:] {
+			value, _ := strconv.ParseUint(field, 10, 64)
+			// guest y guest_nice ya están incluidos en user y nice
+			if i >= 8 {
+				break
+			}
+			sample.total += value
+			// idle + iowait
+			if i == 3 || i == 4 {
+				sample.idle += value
+			}
+		}
+		return sample, nil
+	}
+	return cpuSample{}, fmt.Errorf("cpu line not found in /proc/stat")
+}
+
+func readMemory() (domain.MemoryStats, error) {
+	values, err := readKeyValueKB("/proc/meminfo")
+	if err != nil {
+		return domain.MemoryStats{}, fmt.Errorf("failed to read memory info: %w", err)
+	}
+
+	available, ok := values["MemAvailable"]
+	if !ok {
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+
+	stats := domain.MemoryStats{
+		TotalBytes:     values["MemTotal"],
+		AvailableBytes: available,
+		SwapTotalBytes: values["SwapTotal"],
+		SwapUsedBytes:  values["SwapTotal"] - values["SwapFree"],
+	}
+	if stats.TotalBytes > available {
+		stats.UsedBytes = stats.TotalBytes - available
+	}
+	stats.UsedPercent = percent(stats.UsedBytes, stats.TotalBytes)
+
+	if status, err := readKeyValueKB("/proc/self/status"); err == nil {
+		stats.ProcessBytes = status["VmRSS"]
+	}
+	return stats, nil
+}
+
+// readKeyValueKB parses "Key:   1234 kB" files and returns the values in bytes
+func readKeyValueKB(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			value *= 1024
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+func readSystemUptime() (time.Duration, error) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read uptime: %w", err)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected /proc/uptime format")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected /proc/uptime format: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func deviceOf(info os.FileInfo) (uint64, bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev), true
+	}
+	return 0, false
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+
+package services
+
+import (
+	"os"
+	"time"
+
+	"github.com/infortech07/cubert/internal/system/domain"
+)
+
+func readFilesystems(roots []string) ([]domain.FilesystemStats, error) {
+	return nil, domain.ErrUnsupportedPlatform
+}
+
+func readLoad() (domain.CPUStats, error) {
+	return domain.CPUStats{}, domain.ErrUnsupportedPlatform
+}
+
+func readCPUSample() (cpuSample, error) {
+	return cpuSample{}, domain.ErrUnsupportedPlatform
+}
+
+func readMemory() (domain.MemoryStats, error) {
+	return domain.MemoryStats{}, domain.ErrUnsupportedPlatform
+}
+
+func readSystemUptime() (time.Duration, error) {
+	return 0, domain.ErrUnsupportedPlatform
+}
+
+// deviceOf is not used to bound walks outside Linux
+func deviceOf(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	jobdomain "github.com/infortech07/cubert/internal/jobs/domain"
+	jobservices "github.com/infortech07/cubert/internal/jobs/services"
+	"github.com/infortech07/cubert/internal/system/domain"
+)
+
+// StatsService gathers host statistics for the dashboard from /proc and statfs
+type StatsService struct {
+	roots     []string
+	indexer   *LibraryIndexer
+	jobs      *jobservices.JobService
+	startedAt time.Time
+
+	// La utilización de CPU se calcula entre dos muestras consecutivas de /proc/stat
+	mu      sync.Mutex
+	lastCPU cpuSample
+}
+
+func NewStatsService(roots []string, indexer *LibraryIndexer, jobs *jobservices.JobService) *StatsService {
+	cleaned := make([]string, 0, len(roots))
+	for _, root := range roots {
+		cleaned = append(cleaned, filepath.Clean(root))
+	}
+
+	s := &StatsService{
+		roots:     cleaned,
+		indexer:   indexer,
+		jobs:      jobs,
+		startedAt: time.Now(),
+	}
+	s.lastCPU, _ = readCPUSample()
+	jobs.Register(domain.ReindexJobType, s.runReindex, jobservices.RunnerOptions{Resumable: true})
+	return s
+}
+
+func (s *StatsService) Stats(ctx context.Context) (*domain.SystemStats, error) {
+	filesystems, err := readFilesystems(s.roots)
+	if err != nil {
+		return nil, err
+	}
+
+	cpu, err := readLoad()
+	if err != nil {
+		return nil, err
+	}
+	cpu.Cores = runtime.NumCPU()
+	cpu.UsagePercent = s.cpuUsage()
+
+	memory, err := readMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	systemUptime, err := readSystemUptime()
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.SystemStats{
+		Filesystems: filesystems,
+		CPU:         cpu,
+		Memory:      memory,
+		Uptime: domain.UptimeStats{
+			SystemSeconds: int64(systemUptime.Seconds()),
+			ServerSeconds: int64(time.Since(s.startedAt).Seconds()),
+			StartedAt:     s.startedAt,
+		},
+		Index:       s.indexer.Stats(),
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// Reindex queues a job that walks the library roots again
+func (s *StatsService) Reindex(ctx context.Context, userID string) (*jobdomain.Job, error) {
+	return s.jobs.Submit(ctx, domain.ReindexJobType, userID, nil)
+}
+
+func (s *StatsService) runReindex(ctx context.Context, job *jobdomain.Job, progress *jobservices.Progress) (interface{}, error) {
+	progress.SetTotal(0, "files")
+	stats, err := s.indexer.Index(ctx, func(files int64) {
+		progress.SetCurrent(files)
+	})
+	if err != nil {
+		return nil, err
+	}
+	progress.Flush()
+	return stats, nil
+}
+
+// cpuUsage returns the busy percentage since the previous call
+func (s *StatsService) cpuUsage() float64 {
+	sample, err := readCPUSample()
+	if err != nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := sample.total - s.lastCPU.total
+	idle := sample.idle - s.lastCPU.idle
+	s.lastCPU = sample
+
+	if total == 0 {
+		return 0
+	}
+	return percent(total-idle, total)
+}
+
+type cpuSample struct {
+	total uint64
+	idle  uint64
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(part)/float64(total)*1000+0.5)) / 10
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Limits on what a single file or folder can carry
+const (
+	MaxTags        = 64
+	MaxTagLength   = 64
+	MaxAttributes  = 64
+	MaxKeyLength   = 64
+	MaxValueLength = 1024
+)
+
+// Entry holds the tags and key/value attributes of one file or folder. FileID is the
+// device and inode the entry was last seen with; it finds the file again after a rename
+// made outside Cubert.
+type Entry struct {
+	Path       string            `json:"path"`
+	FileID     string            `json:"file_id,omitempty"`
+	Tags       []string          `json:"tags"`
+	Attributes map[string]string `json:"attributes"`
+	UpdatedAt  *time.Time        `json:"updated_at,omitempty"`
+	UpdatedBy  string            `json:"updated_by,omitempty"`
+}
+
+// Update changes some tags and attributes, leaving the rest as they are
+type Update struct {
+	AddTags          []string          `json:"add_tags"`
+	RemoveTags       []string          `json:"remove_tags"`
+	SetAttributes    map[string]string `json:"set_attributes"`
+	RemoveAttributes []string          `json:"remove_attributes"`
+}
+
+// Filter selects entries: below Path, carrying every tag in Tags and every attribute in
+// Attributes (an empty value only requires the key), with Query in the name
+type Filter struct {
+	Path       string
+	Tags       []string
+	Attributes map[string]string
+	Query      string
+	Limit      int
+}
+
+// TagCount is how many visible files and folders carry a tag
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+var (
+	ErrInvalidTag       = errors.New("invalid tag")
+	ErrInvalidAttribute = errors.New("invalid attribute")
+	ErrTooManyTags      = errors.New("too many tags or attributes")
+)
+
+// ParseAttributeFilters reads "key=value" query values into a filter; a bare "key" only
+// requires the attribute to be set
+func ParseAttributeFilters(values []string) map[string]string {
+	attributes := make(map[string]string, len(values))
+	for _, value := range values {
+		key, v, _ := strings.Cut(value, "=")
+		if key = strings.TrimSpace(key); key != "" {
+			attributes[key] = v
+		}
+	}
+	return attributes
+}
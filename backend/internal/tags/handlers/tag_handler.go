@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	"github.com/infortech07/cubert/internal/tags/domain"
+	"github.com/infortech07/cubert/internal/tags/services"
+)
+
+type TagHandler struct {
+	tagService *services.TagService
+	aclService *authservices.ACLService
+}
+
+func NewTagHandler(tagService *services.TagService, aclService *authservices.ACLService) *TagHandler {
+	return &TagHandler{
+		tagService: tagService,
+		aclService: aclService,
+	}
+}
+
+// Get returns the tags and attributes of a file or folder
+func (h *TagHandler) Get(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+	if !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
+	entry, err := h.tagService.Get(path)
+	if err != nil {
+		writeTagError(w, "Failed to read tags", err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, entry)
+}
+
+// Set replaces the tags and attributes of a file or folder
+func (h *TagHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path       string            `json:"path"`
+		Tags       []string          `json:"tags"`
+		Attributes map[string]string `json:"attributes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), request.Path)
+	if !h.authorize(w, r, request.Path, authdomain.AccessWrite) {
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	entry, err := h.tagService.Set(request.Path, request.Tags, request.Attributes, user.ID)
+	if err != nil {
+		writeTagError(w, "Failed to update tags", err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, entry)
+}
+
+// Update adds and removes some tags and attributes of a file or folder
+func (h *TagHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string `json:"path"`
+		domain.Update
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), request.Path)
+	if !h.authorize(w, r, request.Path, authdomain.AccessWrite) {
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	entry, err := h.tagService.Apply(request.Path, request.Update, user.ID)
+	if err != nil {
+		writeTagError(w, "Failed to update tags", err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, entry)
+}
+
+// Delete removes every tag and attribute of a file or folder
+func (h *TagHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Path parameter is required", nil)
+		return
+	}
+	if !h.authorize(w, r, path, authdomain.AccessWrite) {
+		return
+	}
+
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.tagService.Delete(path, user.ID); err != nil {
+		writeTagError(w, "Failed to delete tags", err)
+		return
+	}
+	utils.WriteMessageResponse(w, http.StatusOK, "Tags deleted")
+}
+
+// List counts the tags in use below path, or everywhere the user can read
+func (h *TagHandler) List(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path != "" && !h.authorize(w, r, path, authdomain.AccessRead) {
+		return
+	}
+
+	tags := h.tagService.Tags(path, h.visible(r))
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"path":  path,
+		"tags":  tags,
+		"count": len(tags),
+	})
+}
+
+// Search finds the files and folders carrying every tag and attribute asked for
+func (h *TagHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.Filter{
+		Path:       query.Get("path"),
+		Tags:       query["tag"],
+		Attributes: domain.ParseAttributeFilters(query["attr"]),
+		Query:      query.Get("q"),
+	}
+	if len(filter.Tags) == 0 && len(filter.Attributes) == 0 {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "At least one tag or attr parameter is required", nil)
+		return
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		filter.Limit = limit
+	}
+	if filter.Path != "" && !h.authorize(w, r, filter.Path, authdomain.AccessRead) {
+		return
+	}
+
+	results, truncated := h.tagService.Search(filter, h.visible(r))
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"path":      filter.Path,
+		"results":   results,
+		"count":     len(results),
+		"truncated": truncated,
+	})
+}
+
+// visible accepts the paths the current user can read
+func (h *TagHandler) visible(r *http.Request) func(path string) bool {
+	user, _ := authdomain.UserFromContext(r.Context())
+	return func(path string) bool {
+		return h.aclService.Authorize(r.Context(), user, path, authdomain.AccessRead) == nil
+	}
+}
+
+func (h *TagHandler) authorize(w http.ResponseWriter, r *http.Request, path string, access authdomain.Access) bool {
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, path, access); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return false
+	}
+	return true
+}
+
+func writeTagError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "File not found", err)
+	case errors.Is(err, domain.ErrInvalidTag), errors.Is(err, domain.ErrInvalidAttribute), errors.Is(err, domain.ErrTooManyTags):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, message, err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, message, err)
+	}
+}
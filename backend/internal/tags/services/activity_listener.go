@@ -0,0 +1,30 @@
+package services
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+)
+
+// HandleAuditEvent keeps the tags attached to files renamed, moved or deleted through
+// Cubert. It is registered as an audit listener so file handlers don't need to know about tags.
+func (s *TagService) HandleAuditEvent(event auditdomain.Event) {
+	if event.Result != auditdomain.ResultSuccess || len(event.Paths) == 0 {
+		return
+	}
+
+	switch {
+	case event.Method == http.MethodDelete || strings.HasSuffix(event.Action, ".delete") || strings.HasSuffix(event.Action, ".trash"):
+		for _, path := range event.Paths {
+			// Una ruta que sigue en disco no se ha borrado (p. ej. DELETE /tags?path=)
+			if _, err := os.Lstat(path); err == nil {
+				continue
+			}
+			s.PathDeleted(path)
+		}
+	case (strings.HasSuffix(event.Action, ".move") || strings.HasSuffix(event.Action, ".rename")) && len(event.Paths) == 2:
+		s.PathMoved(event.Paths[0], event.Paths[1])
+	}
+}
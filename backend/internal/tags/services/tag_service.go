@@ -0,0 +1,510 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/infortech07/cubert/internal/shared/utils"
+	"github.com/infortech07/cubert/internal/tags/domain"
+)
+
+const defaultSearchLimit = 500
+
+// TagService stores the tags and attributes of files and folders in a metadata database
+// keyed by file identity (device and inode), so they survive renames. Files without an
+// identity are keyed by path. When xattrs are enabled the values are also written to the
+// file itself and read back for files the database does not know.
+type TagService struct {
+	file  string
+	xattr bool
+
+	mu      sync.Mutex
+	entries map[string]*domain.Entry
+	byPath  map[string]string
+}
+
+func NewTagService(dataDir string, useXattr bool) (*TagService, error) {
+	s := &TagService{
+		file:    filepath.Join(dataDir, "metadata", "tags.json"),
+		xattr:   useXattr,
+		entries: make(map[string]*domain.Entry),
+		byPath:  make(map[string]string),
+	}
+
+	var stored []domain.Entry
+	if err := utils.LoadJSONFile(s.file, &stored); err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	for i := range stored {
+		entry := stored[i]
+		s.put(&entry)
+	}
+	return s, nil
+}
+
+// Get returns the tags and attributes of path, empty if it has none
+func (s *TagService) Get(path string) (*domain.Entry, error) {
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry := s.lookupLocked(path, info); entry != nil {
+		return cloneEntry(entry), nil
+	}
+	return &domain.Entry{Path: path, Tags: []string{}, Attributes: map[string]string{}}, nil
+}
+
+// Set replaces the tags and attributes of path. Empty values remove the entry.
+func (s *TagService) Set(path string, tags []string, attributes map[string]string, userID string) (*domain.Entry, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	attributes, err = normalizeAttributes(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.change(path, userID, func(entry *domain.Entry) error {
+		entry.Tags, entry.Attributes = tags, attributes
+		return nil
+	})
+}
+
+// Apply adds and removes some tags and attributes of path
+func (s *TagService) Apply(path string, update domain.Update, userID string) (*domain.Entry, error) {
+	add, err := normalizeTags(update.AddTags)
+	if err != nil {
+		return nil, err
+	}
+	set, err := normalizeAttributes(update.SetAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.change(path, userID, func(entry *domain.Entry) error {
+		tags := make([]string, 0, len(entry.Tags)+len(add))
+		for _, tag := range entry.Tags {
+			if !containsFold(update.RemoveTags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		tags, err := normalizeTags(append(tags, add...))
+		if err != nil {
+			return err
+		}
+
+		attributes := make(map[string]string, len(entry.Attributes)+len(set))
+		for key, value := range entry.Attributes {
+			if !containsFold(update.RemoveAttributes, key) {
+				attributes[key] = value
+			}
+		}
+		for key, value := range set {
+			attributes[key] = value
+		}
+		if len(attributes) > domain.MaxAttributes {
+			return fmt.Errorf("%w: at most %d attributes", domain.ErrTooManyTags, domain.MaxAttributes)
+		}
+
+		entry.Tags, entry.Attributes = tags, attributes
+		return nil
+	})
+}
+
+// Delete removes every tag and attribute of path
+func (s *TagService) Delete(path, userID string) error {
+	_, err := s.change(path, userID, func(entry *domain.Entry) error {
+		entry.Tags, entry.Attributes = []string{}, map[string]string{}
+		return nil
+	})
+	return err
+}
+
+// Matches reports whether path carries every tag and attribute of filter
+func (s *TagService) Matches(path string, filter domain.Filter) bool {
+	entry, err := s.Get(path)
+	if err != nil {
+		return false
+	}
+	return matches(entry, normalizeFilter(filter))
+}
+
+// Search returns the entries below filter.Path that match it and that visible accepts,
+// sorted by path. The second value is true when the limit cut the results short.
+func (s *TagService) Search(filter domain.Filter, visible func(path string) bool) ([]domain.Entry, bool) {
+	filter = normalizeFilter(filter)
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	results := make([]domain.Entry, 0)
+	for _, entry := range s.healed(filter.Path) {
+		if matches(&entry, filter) && visible(entry.Path) {
+			results = append(results, entry)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+
+	if len(results) > limit {
+		return results[:limit], true
+	}
+	return results, false
+}
+
+// Tags counts how many entries below path that visible accepts carry each tag
+func (s *TagService) Tags(path string, visible func(path string) bool) []domain.TagCount {
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+	counts := make(map[string]int)
+	for _, entry := range s.healed(path) {
+		if len(entry.Tags) == 0 || !visible(entry.Path) {
+			continue
+		}
+		for _, tag := range entry.Tags {
+			counts[tag]++
+		}
+	}
+
+	result := make([]domain.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		result = append(result, domain.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	return result
+}
+
+// PathMoved rewrites the entries at or under oldPath after a rename or move. A move to
+// another disk gives the files new identities; lookups by path pick them up later.
+func (s *TagService) PathMoved(oldPath, newPath string) {
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved := make([]*domain.Entry, 0)
+	for _, entry := range s.entries {
+		if within(entry.Path, oldPath) {
+			moved = append(moved, entry)
+		}
+	}
+	if len(moved) == 0 {
+		return
+	}
+
+	for _, entry := range moved {
+		s.remove(entry)
+	}
+	for _, entry := range moved {
+		entry.Path = newPath + strings.TrimPrefix(entry.Path, oldPath)
+		// Lo que hubiera en el destino queda sustituido por lo movido
+		if key, ok := s.byPath[entry.Path]; ok {
+			s.remove(s.entries[key])
+		}
+		s.put(entry)
+	}
+	s.saveLocked()
+}
+
+// PathDeleted drops the entries at or under path
+func (s *TagService) PathDeleted(path string) {
+	path = filepath.Clean(path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for _, entry := range s.entries {
+		if within(entry.Path, path) {
+			s.remove(entry)
+			changed = true
+		}
+	}
+	if changed {
+		s.saveLocked()
+	}
+}
+
+// change applies fn to the entry of path, creating it if needed, and persists the result
+func (s *TagService) change(path, userID string, fn func(entry *domain.Entry) error) (*domain.Entry, error) {
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.lookupLocked(path, info)
+	updated := &domain.Entry{Path: path, FileID: utils.FileIdentity(info)}
+	if entry != nil {
+		updated = cloneEntry(entry)
+	}
+	if err := fn(updated); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	updated.UpdatedAt = &now
+	updated.UpdatedBy = userID
+
+	if entry != nil {
+		s.remove(entry)
+	}
+	if len(updated.Tags) > 0 || len(updated.Attributes) > 0 {
+		s.put(updated)
+	}
+	if err := s.saveLocked(); err != nil {
+		return nil, err
+	}
+
+	if s.xattr {
+		if err := writeXattrs(path, updated); err != nil && err != errXattrUnsupported {
+			log.Printf("tags: failed to write xattrs of %s: %v", path, err)
+		}
+	}
+	return cloneEntry(updated), nil
+}
+
+// lookupLocked finds the entry of a file: by identity first, healing the stored path if
+// the file was renamed outside Cubert, then by path, taking the new identity of files
+// that were replaced by a save. Callers hold s.mu.
+func (s *TagService) lookupLocked(path string, info os.FileInfo) *domain.Entry {
+	id := utils.FileIdentity(info)
+
+	if entry, ok := s.entries[key(id, path)]; ok {
+		if entry.Path != path {
+			s.remove(entry)
+			if other, ok := s.byPath[path]; ok {
+				s.remove(s.entries[other])
+			}
+			entry.Path = path
+			s.put(entry)
+			s.saveLocked()
+		}
+		return entry
+	}
+
+	if k, ok := s.byPath[path]; ok {
+		entry := s.entries[k]
+		if id != "" && entry.FileID != id {
+			s.remove(entry)
+			entry.FileID = id
+			s.put(entry)
+			s.saveLocked()
+		}
+		return entry
+	}
+
+	if s.xattr {
+		if entry, ok := readXattrs(path); ok {
+			entry.Path, entry.FileID = path, id
+			s.put(entry)
+			s.saveLocked()
+			return entry
+		}
+	}
+	return nil
+}
+
+// healed returns a copy of the entries below path whose files still exist, following
+// the ones renamed in place outside Cubert and dropping the ones that disappeared
+func (s *TagService) healed(path string) []domain.Entry {
+	s.mu.Lock()
+	candidates := make([]domain.Entry, 0)
+	for _, entry := range s.entries {
+		if path == "" || within(entry.Path, path) {
+			candidates = append(candidates, *cloneEntry(entry))
+		}
+	}
+	s.mu.Unlock()
+
+	// Los stat se hacen sin el cerrojo: puede haber muchas entradas
+	type move struct {
+		entry domain.Entry
+		path  string
+	}
+	moves := make([]move, 0)
+	found := candidates[:0]
+	for _, entry := range candidates {
+		current, info, ok := utils.LocateFile(entry.Path, entry.FileID)
+		if !ok {
+			continue
+		}
+		if current != entry.Path || utils.FileIdentity(info) != entry.FileID {
+			moves = append(moves, move{entry: entry, path: current})
+			entry.Path = current
+		}
+		found = append(found, entry)
+	}
+
+	if len(moves) > 0 {
+		s.mu.Lock()
+		for _, m := range moves {
+			if info, err := os.Stat(m.path); err == nil {
+				s.lookupLocked(m.path, info)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return found
+}
+
+func (s *TagService) put(entry *domain.Entry) {
+	k := key(entry.FileID, entry.Path)
+	s.entries[k] = entry
+	s.byPath[entry.Path] = k
+}
+
+func (s *TagService) remove(entry *domain.Entry) {
+	k := key(entry.FileID, entry.Path)
+	delete(s.entries, k)
+	if s.byPath[entry.Path] == k {
+		delete(s.byPath, entry.Path)
+	}
+}
+
+func (s *TagService) saveLocked() error {
+	stored := make([]domain.Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		stored = append(stored, *entry)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Path < stored[j].Path })
+	return utils.SaveJSONFile(s.file, stored)
+}
+
+// key is the database key of a file: its identity, or its path where there is none
+func key(fileID, path string) string {
+	if fileID != "" {
+		return fileID
+	}
+	return "path:" + path
+}
+
+func within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func matches(entry *domain.Entry, filter domain.Filter) bool {
+	for _, tag := range filter.Tags {
+		if !containsFold(entry.Tags, tag) {
+			return false
+		}
+	}
+	for key, value := range filter.Attributes {
+		current, ok := entry.Attributes[key]
+		if !ok || (value != "" && !strings.EqualFold(current, value)) {
+			return false
+		}
+	}
+	if filter.Query != "" && !strings.Contains(strings.ToLower(filepath.Base(entry.Path)), filter.Query) {
+		return false
+	}
+	return true
+}
+
+func normalizeFilter(filter domain.Filter) domain.Filter {
+	if filter.Path != "" {
+		filter.Path = filepath.Clean(filter.Path)
+	}
+	tags := make([]string, 0, len(filter.Tags))
+	for _, tag := range filter.Tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	filter.Tags = tags
+	attributes := make(map[string]string, len(filter.Attributes))
+	for key, value := range filter.Attributes {
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			attributes[key] = strings.TrimSpace(value)
+		}
+	}
+	filter.Attributes = attributes
+	filter.Query = strings.ToLower(strings.TrimSpace(filter.Query))
+	return filter
+}
+
+// normalizeTags trims, lowercases, deduplicates and sorts tags
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > domain.MaxTagLength || strings.ContainsAny(tag, ",=") || strings.IndexFunc(tag, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("%w: %q", domain.ErrInvalidTag, tag)
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	if len(result) > domain.MaxTags {
+		return nil, fmt.Errorf("%w: at most %d tags", domain.ErrTooManyTags, domain.MaxTags)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// normalizeAttributes lowercases the keys and checks keys and values
+func normalizeAttributes(attributes map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" || len(key) > domain.MaxKeyLength || strings.IndexFunc(key, invalidKeyRune) >= 0 {
+			return nil, fmt.Errorf("%w: key %q", domain.ErrInvalidAttribute, key)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) > domain.MaxValueLength || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("%w: value of %q", domain.ErrInvalidAttribute, key)
+		}
+		result[key] = value
+	}
+	if len(result) > domain.MaxAttributes {
+		return nil, fmt.Errorf("%w: at most %d attributes", domain.ErrTooManyTags, domain.MaxAttributes)
+	}
+	return result, nil
+}
+
+func invalidKeyRune(r rune) bool {
+	return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.')
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneEntry(entry *domain.Entry) *domain.Entry {
+	clone := *entry
+	clone.Tags = append([]string{}, entry.Tags...)
+	clone.Attributes = make(map[string]string, len(entry.Attributes))
+	for key, value := range entry.Attributes {
+		clone.Attributes[key] = value
+	}
+	return &clone
+}
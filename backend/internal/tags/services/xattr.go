@@ -0,0 +1,12 @@
+package services
+
+import "errors"
+
+// Nombres de los atributos extendidos donde se copian las etiquetas
+const (
+	xattrTags       = "user.cubert.tags"
+	xattrAttributes = "user.cubert.attributes"
+)
+
+// errXattrUnsupported is returned where the platform or filesystem has no user xattrs
+var errXattrUnsupported = errors.New("extended attributes not supported")
@@ -0,0 +1,89 @@
+//go:build linux
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"syscall"
+
+	"github.com/infortech07/cubert/internal/tags/domain"
+)
+
+// writeXattrs copies the tags and attributes of entry onto the file, removing them
+// when the entry is empty
+func writeXattrs(path string, entry *domain.Entry) error {
+	if len(entry.Tags) == 0 {
+		if err := removeXattr(path, xattrTags); err != nil {
+			return err
+		}
+	} else if err := setXattr(path, xattrTags, []byte(strings.Join(entry.Tags, ","))); err != nil {
+		return err
+	}
+
+	if len(entry.Attributes) == 0 {
+		return removeXattr(path, xattrAttributes)
+	}
+	data, err := json.Marshal(entry.Attributes)
+	if err != nil {
+		return err
+	}
+	return setXattr(path, xattrAttributes, data)
+}
+
+// readXattrs builds an entry from the xattrs of a file tagged elsewhere
+func readXattrs(path string) (*domain.Entry, bool) {
+	tags, err := normalizeTags(strings.Split(string(getXattr(path, xattrTags)), ","))
+	if err != nil {
+		tags = []string{}
+	}
+	attributes := map[string]string{}
+	if data := getXattr(path, xattrAttributes); len(data) > 0 {
+		var stored map[string]string
+		if json.Unmarshal(data, &stored) == nil {
+			if normalized, err := normalizeAttributes(stored); err == nil {
+				attributes = normalized
+			}
+		}
+	}
+
+	if len(tags) == 0 && len(attributes) == 0 {
+		return nil, false
+	}
+	return &domain.Entry{Tags: tags, Attributes: attributes}, true
+}
+
+func getXattr(path, name string) []byte {
+	buf := make([]byte, 4096)
+	for {
+		n, err := syscall.Getxattr(path, name, buf)
+		if errors.Is(err, syscall.ERANGE) && len(buf) < 1<<20 {
+			buf = make([]byte, len(buf)*4)
+			continue
+		}
+		if err != nil {
+			return nil
+		}
+		return buf[:n]
+	}
+}
+
+func setXattr(path, name string, value []byte) error {
+	err := syscall.Setxattr(path, name, value, 0)
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EPERM) {
+		return errXattrUnsupported
+	}
+	return err
+}
+
+func removeXattr(path, name string) error {
+	err := syscall.Removexattr(path, name)
+	switch {
+	case err == nil, errors.Is(err, syscall.ENODATA):
+		return nil
+	case errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EPERM):
+		return errXattrUnsupported
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package services
+
+import "github.com/infortech07/cubert/internal/tags/domain"
+
+func writeXattrs(path string, entry *domain.Entry) error {
+	return errXattrUnsupported
+}
+
+func readXattrs(path string) (*domain.Entry, bool) {
+	return nil, false
+}
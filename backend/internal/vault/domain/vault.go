@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// MetadataFile lives in the vault folder and holds what is needed to unlock it, so
+// the folder can be backed up and restored on its own
+const MetadataFile = ".cubert-vault.json"
+
+const (
+	DefaultUnlockTTL = 15 * time.Minute
+	MaxUnlockTTL     = 12 * time.Hour
+	// MinPassphraseLength aplica solo al crear la bóveda
+	MinPassphraseLength = 8
+)
+
+// KDF identifies how the key-encryption key is derived from the passphrase
+const KDFArgon2id = "argon2id"
+
+type KDFParams struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt"`
+	Time      uint32 `json:"time"`
+	MemoryKiB uint32 `json:"memory_kib"`
+	Threads   uint8  `json:"threads"`
+}
+
+// Metadata is the content of MetadataFile. The random master key is stored wrapped
+// (AES-GCM) with the key derived from the passphrase.
+type Metadata struct {
+	Version    int       `json:"version"`
+	KDF        KDFParams `json:"kdf"`
+	WrappedKey []byte    `json:"wrapped_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+}
+
+// Status describes a vault as seen by the current session
+type Status struct {
+	Path      string     `json:"path"`
+	Locked    bool       `json:"locked"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+var (
+	ErrVaultLocked      = errors.New("vault is locked")
+	ErrWrongPassphrase  = errors.New("wrong vault passphrase")
+	ErrWeakPassphrase   = errors.New("vault passphrase is too short")
+	ErrNotVault         = errors.New("folder is not a vault")
+	ErrVaultNotEmpty    = errors.New("only new or empty folders can become vaults")
+	ErrNestedVault      = errors.New("vaults cannot contain other vaults")
+	ErrCrossVault       = errors.New("cannot move files into or out of a vault")
+	ErrNameTooLong      = errors.New("name is too long for an encrypted folder")
+	ErrCorruptVaultFile = errors.New("encrypted file is damaged")
+	ErrSessionRequired  = errors.New("vaults can only be unlocked from a login session")
+)
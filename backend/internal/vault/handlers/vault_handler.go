@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	"github.com/infortech07/cubert/internal/vault/domain"
+	"github.com/infortech07/cubert/internal/vault/services"
+)
+
+type VaultHandler struct {
+	vaultService *services.VaultService
+	aclService   *authservices.ACLService
+}
+
+func NewVaultHandler(vaultService *services.VaultService, aclService *authservices.ACLService) *VaultHandler {
+	return &VaultHandler{
+		vaultService: vaultService,
+		aclService:   aclService,
+	}
+}
+
+// List returns the vaults the user can read, locked or unlocked for this session
+func (h *VaultHandler) List(w http.ResponseWriter, r *http.Request) {
+	user, _ := authdomain.UserFromContext(r.Context())
+	vaults := make([]domain.Status, 0)
+	for _, vault := range h.vaultService.List(r.Context()) {
+		if h.aclService.Authorize(r.Context(), user, vault.Path, authdomain.AccessRead) == nil {
+			vaults = append(vaults, vault)
+		}
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"vaults": vaults,
+		"count":  len(vaults),
+	})
+}
+
+// Create turns a new or empty folder into a vault
+func (h *VaultHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path       string `json:"path"`
+		Passphrase string `json:"passphrase"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), request.Path)
+	if !h.authorize(w, r, request.Path, authdomain.AccessWrite) {
+		return
+	}
+
+	status, err := h.vaultService.Create(r.Context(), request.Path, request.Passphrase)
+	if err != nil {
+		writeVaultError(w, "Failed to create vault", err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusCreated, status)
+}
+
+// Unlock makes the vault readable for the current session for ttl_seconds
+func (h *VaultHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path       string `json:"path"`
+		Passphrase string `json:"passphrase"`
+		TTLSeconds int    `json:"ttl_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Path == "" || request.TTLSeconds < 0 {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), request.Path)
+	if !h.authorize(w, r, request.Path, authdomain.AccessRead) {
+		return
+	}
+
+	ttl := time.Duration(request.TTLSeconds) * time.Second
+	status, err := h.vaultService.Unlock(r.Context(), request.Path, request.Passphrase, ttl)
+	if err != nil {
+		writeVaultError(w, "Failed to unlock vault", err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, status)
+}
+
+// Lock forgets the vault keys of the current session
+func (h *VaultHandler) Lock(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Path == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	auditdomain.AddPaths(r.Context(), request.Path)
+	if err := h.vaultService.Lock(r.Context(), request.Path); err != nil {
+		writeVaultError(w, "Failed to lock vault", err)
+		return
+	}
+	utils.WriteMessageResponse(w, http.StatusOK, "Vault locked")
+}
+
+func (h *VaultHandler) authorize(w http.ResponseWriter, r *http.Request, path string, access authdomain.Access) bool {
+	user, _ := authdomain.UserFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), user, path, access); err != nil {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+		return false
+	}
+	return true
+}
+
+func writeVaultError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, domain.ErrWrongPassphrase):
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Wrong passphrase", err)
+	case errors.Is(err, domain.ErrNotVault), errors.Is(err, os.ErrNotExist):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "Vault not found", err)
+	case errors.Is(err, domain.ErrVaultNotEmpty), errors.Is(err, domain.ErrNestedVault):
+		utils.WriteErrorResponse(w, http.StatusConflict, message, err)
+	case errors.Is(err, domain.ErrWeakPassphrase), errors.Is(err, domain.ErrSessionRequired),
+		errors.Is(err, storagedomain.ErrNotDir):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, message, err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, message, err)
+	}
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/infortech07/cubert/internal/vault/domain"
+)
+
+const (
+	keySize  = 32
+	saltSize = 16
+	// Parámetros de Argon2id recomendados por la RFC 9106 para equipos con poca memoria
+	kdfTime      = 3
+	kdfMemoryKiB = 64 * 1024
+	kdfThreads   = 4
+
+	// Formato de los archivos: cabecera y bloques de chunkSize cifrados por separado
+	contentMagic = "CBV1"
+	fileIDSize   = 16
+	headerSize   = len(contentMagic) + fileIDSize
+	chunkSize    = 64 << 10
+	tagSize      = 16
+	nonceSize    = 12
+
+	// maxEncodedName es el límite de nombre de la mayoría de sistemas de archivos
+	maxEncodedName = 255
+)
+
+// vaultKeys are the subkeys derived from the master key of an unlocked vault
+type vaultKeys struct {
+	name    cipher.AEAD
+	nameMAC []byte
+	content []byte
+}
+
+func newKDFParams() (domain.KDFParams, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return domain.KDFParams{}, err
+	}
+	return domain.KDFParams{
+		Algorithm: domain.KDFArgon2id,
+		Salt:      salt,
+		Time:      kdfTime,
+		MemoryKiB: kdfMemoryKiB,
+		Threads:   kdfThreads,
+	}, nil
+}
+
+// deriveKEK turns the passphrase into the key that wraps the master key
+func deriveKEK(passphrase string, params domain.KDFParams) ([]byte, error) {
+	if params.Algorithm != domain.KDFArgon2id {
+		return nil, fmt.Errorf("unsupported vault key derivation %q", params.Algorithm)
+	}
+	return argon2.IDKey([]byte(passphrase), params.Salt, params.Time, params.MemoryKiB, params.Threads, keySize), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// wrapKey encrypts the master key with the KEK; the nonce goes in front
+func wrapKey(kek, master []byte) ([]byte, error) {
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, master, []byte(contentMagic)), nil
+}
+
+func unwrapKey(kek, wrapped []byte) ([]byte, error) {
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < nonceSize {
+		return nil, domain.ErrWrongPassphrase
+	}
+	master, err := aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], []byte(contentMagic))
+	if err != nil {
+		// GCM no distingue una clave incorrecta de unos metadatos alterados
+		return nil, domain.ErrWrongPassphrase
+	}
+	return master, nil
+}
+
+func deriveKeys(master []byte) (*vaultKeys, error) {
+	nameKey, err := hkdf.Key(sha256.New, master, nil, "cubert-vault name", keySize)
+	if err != nil {
+		return nil, err
+	}
+	nameMAC, err := hkdf.Key(sha256.New, master, nil, "cubert-vault name nonce", keySize)
+	if err != nil {
+		return nil, err
+	}
+	content, err := hkdf.Key(sha256.New, master, nil, "cubert-vault content", keySize)
+	if err != nil {
+		return nil, err
+	}
+	name, err := newAEAD(nameKey)
+	if err != nil {
+		return nil, err
+	}
+	return &vaultKeys{name: name, nameMAC: nameMAC, content: content}, nil
+}
+
+// encryptName is deterministic so a path can be found on disk without listing its
+// folders. The nonce is a MAC of the name: equal names give equal ciphertexts, and
+// nothing else is revealed.
+func (k *vaultKeys) encryptName(name string) (string, error) {
+	mac := hmac.New(sha256.New, k.nameMAC)
+	mac.Write([]byte(name))
+	nonce := mac.Sum(nil)[:nonceSize:nonceSize]
+
+	sealed := k.name.Seal(nonce, nonce, []byte(name), nil)
+	encoded := base64.RawURLEncoding.EncodeToString(sealed)
+	if len(encoded) > maxEncodedName {
+		return "", domain.ErrNameTooLong
+	}
+	return encoded, nil
+}
+
+func (k *vaultKeys) decryptName(encoded string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < nonceSize+tagSize {
+		return "", domain.ErrCorruptVaultFile
+	}
+	name, err := k.name.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", domain.ErrCorruptVaultFile
+	}
+	return string(name), nil
+}
+
+// fileAEAD returns the cipher of one file; every file has its own key
+func (k *vaultKeys) fileAEAD(fileID []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, k.content, fileID, "cubert-vault file", keySize)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+// plainSize computes the size of the content from the size on disk
+func plainSize(size int64) int64 {
+	body := size - int64(headerSize)
+	if body < tagSize {
+		return 0
+	}
+	chunks := (body + chunkSize + tagSize - 1) / (chunkSize + tagSize)
+	return body - chunks*tagSize
+}
+
+func chunkNonce(index int64) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce[4:], uint64(index))
+	return nonce
+}
+
+// chunkAAD marks the last chunk, so a file cut at a chunk boundary is detected
+func chunkAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// encryptedWriter encrypts a file as it is written. The last chunk is sealed on
+// Close, because only then is it known to be the last one.
+type encryptedWriter struct {
+	w     io.WriteCloser
+	aead  cipher.AEAD
+	buf   []byte
+	index int64
+}
+
+func newEncryptedWriter(w io.WriteCloser, keys *vaultKeys) (*encryptedWriter, error) {
+	fileID := make([]byte, fileIDSize)
+	if _, err := rand.Read(fileID); err != nil {
+		return nil, err
+	}
+	aead, err := keys.fileAEAD(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(contentMagic), fileID...)); err != nil {
+		return nil, err
+	}
+	return &encryptedWriter{w: w, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (e *encryptedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// Un bloque lleno solo se cifra cuando llegan más datos detrás
+		if len(e.buf) == chunkSize {
+			if err := e.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(chunkSize-len(e.buf), len(p))
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptedWriter) flush(final bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.index), e.buf, chunkAAD(final))
+	e.index++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+func (e *encryptedWriter) Close() error {
+	err := e.flush(true)
+	if closeErr := e.w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// encryptedReader decrypts a file chunk by chunk and supports seeking, so range
+// requests and previews only decrypt what they read
+type encryptedReader struct {
+	file   io.ReadSeekCloser
+	aead   cipher.AEAD
+	info   fs.FileInfo
+	size   int64
+	chunks int64
+	pos    int64
+
+	cached int64
+	chunk  []byte
+}
+
+func newEncryptedReader(file io.ReadSeekCloser, keys *vaultKeys, diskSize int64, info fs.FileInfo) (*encryptedReader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header[:len(contentMagic)], []byte(contentMagic)) {
+		return nil, domain.ErrCorruptVaultFile
+	}
+	if diskSize < int64(headerSize+tagSize) {
+		return nil, domain.ErrCorruptVaultFile
+	}
+	aead, err := keys.fileAEAD(header[len(contentMagic):])
+	if err != nil {
+		return nil, err
+	}
+	body := diskSize - int64(headerSize)
+	return &encryptedReader{
+		file:   file,
+		aead:   aead,
+		info:   info,
+		size:   plainSize(diskSize),
+		chunks: (body + chunkSize + tagSize - 1) / (chunkSize + tagSize),
+		cached: -1,
+	}, nil
+}
+
+func (r *encryptedReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		// Un archivo vacío también tiene su bloque final, que se comprueba al leerlo
+		if r.size == 0 && r.cached < 0 {
+			if err := r.load(0); err != nil {
+				return 0, err
+			}
+		}
+		return 0, io.EOF
+	}
+	index := r.pos / chunkSize
+	if err := r.load(index); err != nil {
+		return 0, err
+	}
+	n := copy(p, r.chunk[r.pos-index*chunkSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *encryptedReader) load(index int64) error {
+	if r.cached == index {
+		return nil
+	}
+	if _, err := r.file.Seek(int64(headerSize)+index*(chunkSize+tagSize), io.SeekStart); err != nil {
+		return err
+	}
+	sealed := make([]byte, chunkSize+tagSize)
+	n, err := io.ReadFull(r.file, sealed)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	chunk, err := r.aead.Open(sealed[:0], chunkNonce(index), sealed[:n], chunkAAD(index == r.chunks-1))
+	if err != nil {
+		return domain.ErrCorruptVaultFile
+	}
+	r.cached, r.chunk = index, chunk
+	return nil
+}
+
+func (r *encryptedReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fs.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *encryptedReader) Stat() (fs.FileInfo, error) { return r.info, nil }
+func (r *encryptedReader) Close() error               { return r.file.Close() }
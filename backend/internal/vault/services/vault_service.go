@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	"github.com/infortech07/cubert/internal/vault/domain"
+)
+
+const metadataVersion = 1
+
+// VaultService keeps the list of vault folders and the keys unlocked by each session.
+// Keys only live in memory: a restart locks every vault.
+type VaultService struct {
+	file    string
+	storage storagedomain.Storage
+
+	mu     sync.Mutex
+	vaults map[string]*domain.Metadata
+	// Claves desbloqueadas por token de sesión y carpeta de la bóveda
+	unlocks map[string]map[string]*unlock
+}
+
+type unlock struct {
+	keys      *vaultKeys
+	expiresAt time.Time
+}
+
+// NewVaultService loads the registry from dataDir. storage is the backend the vault
+// folders live on, without decryption.
+func NewVaultService(dataDir string, storage storagedomain.Storage) (*VaultService, error) {
+	s := &VaultService{
+		file:    filepath.Join(dataDir, "vaults.json"),
+		storage: storage,
+		vaults:  make(map[string]*domain.Metadata),
+		unlocks: make(map[string]map[string]*unlock),
+	}
+
+	var paths []string
+	if err := utils.LoadJSONFile(s.file, &paths); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		metadata, err := s.readMetadata(context.Background(), path)
+		if err != nil {
+			// Se conserva en el registro: puede estar en la papelera o en un montaje caído
+			log.Printf("vault %s: %v", path, err)
+		}
+		s.vaults[path] = metadata
+	}
+	return s, nil
+}
+
+// Create turns a new or empty folder into a vault and unlocks it for the session
+func (s *VaultService) Create(ctx context.Context, path, passphrase string) (*domain.Status, error) {
+	path = filepath.Clean(path)
+	if len(passphrase) < domain.MinPassphraseLength {
+		return nil, domain.ErrWeakPassphrase
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for root, metadata := range s.vaults {
+		if metadata != nil && (within(path, root) || within(root, path)) && s.present(ctx, root) {
+			return nil, domain.ErrNestedVault
+		}
+	}
+
+	info, err := s.storage.Stat(ctx, path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if err := s.storage.Mkdir(ctx, path); err != nil {
+			return nil, fmt.Errorf("failed to create vault folder: %w", err)
+		}
+	case err != nil:
+		return nil, err
+	case !info.IsDir():
+		return nil, fmt.Errorf("%s: %w", path, storagedomain.ErrNotDir)
+	default:
+		entries, err := s.storage.ReadDir(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			return nil, domain.ErrVaultNotEmpty
+		}
+	}
+
+	params, err := newKDFParams()
+	if err != nil {
+		return nil, err
+	}
+	kek, err := deriveKEK(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	master := make([]byte, keySize)
+	if _, err := rand.Read(master); err != nil {
+		return nil, err
+	}
+	wrapped, err := wrapKey(kek, master)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := deriveKeys(master)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &domain.Metadata{
+		Version:    metadataVersion,
+		KDF:        params,
+		WrappedKey: wrapped,
+		CreatedAt:  time.Now(),
+	}
+	if user, ok := authdomain.UserFromContext(ctx); ok {
+		metadata.CreatedBy = user.Username
+	}
+	if err := s.writeMetadata(ctx, path, metadata); err != nil {
+		return nil, err
+	}
+	s.vaults[path] = metadata
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+
+	expiresAt := s.setUnlock(ctx, path, keys, domain.DefaultUnlockTTL)
+	return s.status(path, metadata, expiresAt), nil
+}
+
+// Unlock checks the passphrase and keeps the vault keys for the session in ctx until
+// ttl passes or the session ends
+func (s *VaultService) Unlock(ctx context.Context, path, passphrase string, ttl time.Duration) (*domain.Status, error) {
+	path = filepath.Clean(path)
+	if _, ok := authdomain.SessionFromContext(ctx); !ok {
+		return nil, domain.ErrSessionRequired
+	}
+
+	root, metadata := s.lookup(ctx, path)
+	if metadata == nil || root != path {
+		return nil, domain.ErrNotVault
+	}
+
+	// La derivación es lenta a propósito: se hace fuera del bloqueo
+	kek, err := deriveKEK(passphrase, metadata.KDF)
+	if err != nil {
+		return nil, err
+	}
+	master, err := unwrapKey(kek, metadata.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := deriveKeys(master)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		ttl = domain.DefaultUnlockTTL
+	}
+	ttl = min(ttl, domain.MaxUnlockTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiresAt := s.setUnlock(ctx, path, keys, ttl)
+	return s.status(path, metadata, expiresAt), nil
+}
+
+// Lock forgets the keys of the vault for the session in ctx
+func (s *VaultService) Lock(ctx context.Context, path string) error {
+	path = filepath.Clean(path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vaults[path] == nil {
+		return domain.ErrNotVault
+	}
+	if session, ok := authdomain.SessionFromContext(ctx); ok {
+		delete(s.unlocks[session.Token], path)
+		if len(s.unlocks[session.Token]) == 0 {
+			delete(s.unlocks, session.Token)
+		}
+	}
+	return nil
+}
+
+// List returns every vault with its state for the session in ctx
+func (s *VaultService) List(ctx context.Context) []domain.Status {
+	s.mu.Lock()
+	paths := make([]string, 0, len(s.vaults))
+	for path := range s.vaults {
+		paths = append(paths, path)
+	}
+	s.mu.Unlock()
+	sort.Strings(paths)
+
+	statuses := make([]domain.Status, 0, len(paths))
+	for _, path := range paths {
+		root, metadata := s.lookup(ctx, path)
+		if metadata == nil || root != path {
+			continue
+		}
+		s.mu.Lock()
+		var expiresAt *time.Time
+		if unlock := s.unlockFor(ctx, path); unlock != nil {
+			expiresAt = &unlock.expiresAt
+		}
+		s.mu.Unlock()
+		statuses = append(statuses, *s.status(path, metadata, expiresAt))
+	}
+	return statuses
+}
+
+// root returns the vault folder that contains path, or path itself
+func (s *VaultService) root(ctx context.Context, path string) (string, bool) {
+	root, metadata := s.lookup(ctx, path)
+	return root, metadata != nil
+}
+
+// lookup finds the registered vault that contains path. Vaults are never nested, so
+// there is at most one; its metadata is read again if it was missing at startup.
+func (s *VaultService) lookup(ctx context.Context, path string) (string, *domain.Metadata) {
+	s.mu.Lock()
+	candidates := make(map[string]*domain.Metadata)
+	for root, metadata := range s.vaults {
+		if within(path, root) {
+			candidates[root] = metadata
+		}
+	}
+	s.mu.Unlock()
+
+	for root, metadata := range candidates {
+		// Puede quedar en el registro una bóveda borrada en la misma ruta
+		if !s.present(ctx, root) {
+			continue
+		}
+		if metadata == nil {
+			var err error
+			if metadata, err = s.readMetadata(ctx, root); err != nil {
+				continue
+			}
+			s.mu.Lock()
+			if _, ok := s.vaults[root]; ok {
+				s.vaults[root] = metadata
+			}
+			s.mu.Unlock()
+		}
+		return root, metadata
+	}
+	return "", nil
+}
+
+// contains reports whether there is a vault strictly below dir
+func (s *VaultService) contains(dir string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for root, metadata := range s.vaults {
+		if metadata != nil && root != dir && within(root, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// keys returns the unlocked keys of a vault for the session in ctx
+func (s *VaultService) keys(ctx context.Context, root string) *vaultKeys {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if unlock := s.unlockFor(ctx, root); unlock != nil {
+		return unlock.keys
+	}
+	return nil
+}
+
+// moved follows a rename of vault folders or of one of their parents
+func (s *VaultService) moved(oldPath, newPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	renamed := func(path string) string {
+		return filepath.Join(newPath, strings.TrimPrefix(path, oldPath))
+	}
+	for root, metadata := range s.vaults {
+		if within(root, oldPath) {
+			delete(s.vaults, root)
+			s.vaults[renamed(root)] = metadata
+		}
+	}
+	for _, unlocks := range s.unlocks {
+		for root, unlock := range unlocks {
+			if within(root, oldPath) {
+				delete(unlocks, root)
+				unlocks[renamed(root)] = unlock
+			}
+		}
+	}
+	return s.save()
+}
+
+// removed unregisters a vault whose folder was deleted
+func (s *VaultService) removed(root string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.vaults, root)
+	for _, unlocks := range s.unlocks {
+		delete(unlocks, root)
+	}
+	return s.save()
+}
+
+// unlockFor devuelve el desbloqueo vigente de la sesión; debe llamarse con s.mu tomado
+func (s *VaultService) unlockFor(ctx context.Context, root string) *unlock {
+	session, ok := authdomain.SessionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	unlock := s.unlocks[session.Token][root]
+	if unlock == nil {
+		return nil
+	}
+	if time.Now().After(unlock.expiresAt) {
+		delete(s.unlocks[session.Token], root)
+		return nil
+	}
+	return unlock
+}
+
+// setUnlock guarda las claves para la sesión; debe llamarse con s.mu tomado
+func (s *VaultService) setUnlock(ctx context.Context, root string, keys *vaultKeys, ttl time.Duration) *time.Time {
+	session, ok := authdomain.SessionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	expiresAt := time.Now().Add(ttl)
+	if !session.ExpiresAt.IsZero() && session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+
+	// Se aprovecha para olvidar las claves caducadas de otras sesiones
+	now := time.Now()
+	for token, unlocks := range s.unlocks {
+		for path, unlock := range unlocks {
+			if now.After(unlock.expiresAt) {
+				delete(unlocks, path)
+			}
+		}
+		if len(unlocks) == 0 {
+			delete(s.unlocks, token)
+		}
+	}
+
+	if s.unlocks[session.Token] == nil {
+		s.unlocks[session.Token] = make(map[string]*unlock)
+	}
+	s.unlocks[session.Token][root] = &unlock{keys: keys, expiresAt: expiresAt}
+	return &expiresAt
+}
+
+func (s *VaultService) status(path string, metadata *domain.Metadata, expiresAt *time.Time) *domain.Status {
+	return &domain.Status{
+		Path:      path,
+		Locked:    expiresAt == nil,
+		ExpiresAt: expiresAt,
+		CreatedAt: metadata.CreatedAt,
+	}
+}
+
+// present reports whether the metadata file is still in the folder. A vault sent to
+// the trash keeps its registry entry and works again once restored.
+func (s *VaultService) present(ctx context.Context, root string) bool {
+	_, err := s.storage.Stat(ctx, filepath.Join(root, domain.MetadataFile))
+	return err == nil
+}
+
+func (s *VaultService) readMetadata(ctx context.Context, root string) (*domain.Metadata, error) {
+	file, err := s.storage.Open(ctx, filepath.Join(root, domain.MetadataFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var metadata domain.Metadata
+	if err := json.NewDecoder(file).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("invalid vault metadata: %w", err)
+	}
+	if metadata.Version != metadataVersion {
+		return nil, fmt.Errorf("unsupported vault version %d", metadata.Version)
+	}
+	return &metadata, nil
+}
+
+func (s *VaultService) writeMetadata(ctx context.Context, root string, metadata *domain.Metadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	w, err := s.storage.Create(ctx, filepath.Join(root, domain.MetadataFile))
+	if err != nil {
+		return fmt.Errorf("failed to write vault metadata: %w", err)
+	}
+	_, err = w.Write(data)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// save escribe el registro; debe llamarse con s.mu tomado
+func (s *VaultService) save() error {
+	paths := make([]string, 0, len(s.vaults))
+	for path := range s.vaults {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return utils.SaveJSONFile(s.file, paths)
+}
+
+// within reports whether path is dir or lies below it
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
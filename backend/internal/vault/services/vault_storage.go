@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	storageservices "github.com/infortech07/cubert/internal/storage/services"
+	"github.com/infortech07/cubert/internal/vault/domain"
+)
+
+// VaultStorage encrypts the names and contents of the files below vault folders and
+// passes every other path through to the wrapped storage. Inside a vault the session
+// in the request context must have unlocked it; a locked vault only lists its
+// encrypted entries.
+type VaultStorage struct {
+	storage storagedomain.Storage
+	vaults  *VaultService
+}
+
+func NewVaultStorage(storage storagedomain.Storage, vaults *VaultService) *VaultStorage {
+	return &VaultStorage{storage: storage, vaults: vaults}
+}
+
+func (s *VaultStorage) Stat(ctx context.Context, path string) (fs.FileInfo, error) {
+	root, _, disk, err := s.resolve(ctx, "stat", path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.storage.Stat(ctx, disk)
+	if err != nil {
+		return nil, plainError(err, path)
+	}
+	if root == "" || disk == root {
+		return info, nil
+	}
+	return newVaultInfo(info, filepath.Base(path)), nil
+}
+
+func (s *VaultStorage) ReadDir(ctx context.Context, path string) ([]fs.DirEntry, error) {
+	root, keys, disk, err := s.resolve(ctx, "readdir", path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.storage.ReadDir(ctx, disk)
+	if err != nil || root == "" {
+		return entries, plainError(err, path)
+	}
+
+	result := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if disk == root && entry.Name() == domain.MetadataFile {
+			continue
+		}
+		// Sin la clave solo se ven los nombres cifrados
+		if keys == nil {
+			result = append(result, entry)
+			continue
+		}
+		name, err := keys.decryptName(entry.Name())
+		if err != nil {
+			// Lo que no se cifró con esta bóveda no se muestra
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		result = append(result, fs.FileInfoToDirEntry(newVaultInfo(info, name)))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+	return result, nil
+}
+
+func (s *VaultStorage) Open(ctx context.Context, path string) (storagedomain.File, error) {
+	root, keys, disk, err := s.resolve(ctx, "open", path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := s.storage.Open(ctx, disk)
+	if err != nil || root == "" || disk == root {
+		return file, plainError(err, path)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dirFile{File: file, info: newVaultInfo(info, filepath.Base(path))}, nil
+	}
+	reader, err := newEncryptedReader(file, keys, info.Size(), newVaultInfo(info, filepath.Base(path)))
+	if err != nil {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+	return reader, nil
+}
+
+func (s *VaultStorage) Create(ctx context.Context, path string) (io.WriteCloser, error) {
+	root, keys, disk, err := s.resolve(ctx, "create", path)
+	if err != nil {
+		return nil, err
+	}
+	if root == "" {
+		return s.storage.Create(ctx, path)
+	}
+	if disk == root {
+		return nil, &fs.PathError{Op: "create", Path: path, Err: storagedomain.ErrIsDir}
+	}
+
+	w, err := s.storage.Create(ctx, disk)
+	if err != nil {
+		return nil, plainError(err, path)
+	}
+	writer, err := newEncryptedWriter(w, keys)
+	if err != nil {
+		w.Close()
+		s.storage.Remove(ctx, disk)
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (s *VaultStorage) Mkdir(ctx context.Context, path string) error {
+	_, _, disk, err := s.resolve(ctx, "mkdir", path)
+	if err != nil {
+		return err
+	}
+	return plainError(s.storage.Mkdir(ctx, disk), path)
+}
+
+// Rename works inside a vault and for whole vault folders or their parents. Moving
+// entries into or out of a vault would need to re-encrypt them and is refused.
+func (s *VaultStorage) Rename(ctx context.Context, oldPath, newPath string) error {
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+	oldRoot, oldInVault := s.vaults.root(ctx, oldPath)
+	newRoot, newInVault := s.vaults.root(ctx, newPath)
+
+	switch {
+	case (oldInVault && oldPath == oldRoot || !oldInVault && s.vaults.contains(oldPath)) && !newInVault:
+		if err := s.storage.Rename(ctx, oldPath, newPath); err != nil {
+			return err
+		}
+		return s.vaults.moved(oldPath, newPath)
+	case !oldInVault && !newInVault:
+		return s.storage.Rename(ctx, oldPath, newPath)
+	case oldInVault && newInVault && oldRoot == newRoot && oldPath != oldRoot && newPath != newRoot:
+		_, _, oldDisk, err := s.resolve(ctx, "rename", oldPath)
+		if err != nil {
+			return err
+		}
+		_, _, newDisk, err := s.resolve(ctx, "rename", newPath)
+		if err != nil {
+			return err
+		}
+		return plainError(s.storage.Rename(ctx, oldDisk, newDisk), oldPath)
+	default:
+		return &fs.PathError{Op: "rename", Path: newPath, Err: domain.ErrCrossVault}
+	}
+}
+
+func (s *VaultStorage) Remove(ctx context.Context, path string) error {
+	root, _, disk, err := s.resolve(ctx, "remove", path)
+	if err != nil {
+		return err
+	}
+	if root == "" || disk != root {
+		return plainError(s.storage.Remove(ctx, disk), path)
+	}
+
+	// Los metadatos solo se borran con la carpeta ya vacía, o la bóveda quedaría ilegible
+	entries, err := s.storage.ReadDir(ctx, root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() != domain.MetadataFile {
+			return &fs.PathError{Op: "remove", Path: path, Err: storagedomain.ErrNotEmpty}
+		}
+	}
+	if err := s.storage.Remove(ctx, filepath.Join(root, domain.MetadataFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := s.storage.Remove(ctx, root); err != nil {
+		return err
+	}
+	return s.vaults.removed(root)
+}
+
+func (s *VaultStorage) Walk(ctx context.Context, root string, fn filepath.WalkFunc) error {
+	if _, ok := s.vaults.root(ctx, root); ok || s.vaults.contains(filepath.Clean(root)) {
+		// Se recorre a través de la bóveda para ver los nombres descifrados
+		return storageservices.WalkStorage(ctx, s, root, fn)
+	}
+	return s.storage.Walk(ctx, root, fn)
+}
+
+// DiskPath returns the encrypted path of an entry inside an unlocked vault, and path
+// itself elsewhere
+func (s *VaultStorage) DiskPath(ctx context.Context, path string) (string, error) {
+	_, _, disk, err := s.resolve(ctx, "stat", path)
+	return disk, err
+}
+
+// Locked reports whether path is a vault, or lies inside one, that the session has not unlocked
+func (s *VaultStorage) Locked(ctx context.Context, path string) bool {
+	root, ok := s.vaults.root(ctx, filepath.Clean(path))
+	return ok && s.vaults.keys(ctx, root) == nil
+}
+
+// CheckDiskPath fails with ErrVaultLocked when path is not kept on disk under its own
+// name: it lies in a vault the session has not unlocked, or in an unlocked one whose
+// entries are encrypted. Services that use the os package on library paths call it so
+// they never write plaintext into a vault or serve its encrypted files.
+func CheckDiskPath(ctx context.Context, storage storagedomain.Storage, path string) error {
+	if locks, ok := storage.(storagedomain.LockChecker); ok && locks.Locked(ctx, path) {
+		return &fs.PathError{Op: "open", Path: path, Err: domain.ErrVaultLocked}
+	}
+	resolver, ok := storage.(storagedomain.PathResolver)
+	if !ok {
+		return nil
+	}
+	disk, err := resolver.DiskPath(ctx, path)
+	if err != nil {
+		return err
+	}
+	if filepath.Clean(disk) != filepath.Clean(path) {
+		return &fs.PathError{Op: "open", Path: path, Err: domain.ErrVaultLocked}
+	}
+	return nil
+}
+
+func (s *VaultStorage) Mounts() []string {
+	if lister, ok := s.storage.(storagedomain.MountLister); ok {
+		return lister.Mounts()
+	}
+	return nil
+}
+
+func (s *VaultStorage) Mounted(path string) bool {
+	lister, ok := s.storage.(storagedomain.MountLister)
+	return ok && lister.Mounted(path)
+}
+
+// resolve devuelve la bóveda que contiene path, sus claves si la sesión la desbloqueó
+// y la ruta cifrada en disco. Fuera de las bóvedas root es "" y disk es path.
+func (s *VaultStorage) resolve(ctx context.Context, op, path string) (root string, keys *vaultKeys, disk string, err error) {
+	clean := filepath.Clean(path)
+	root, ok := s.vaults.root(ctx, clean)
+	if !ok {
+		return "", nil, path, nil
+	}
+	keys = s.vaults.keys(ctx, root)
+	if clean == root {
+		return root, keys, root, nil
+	}
+	if keys == nil {
+		return "", nil, "", &fs.PathError{Op: op, Path: path, Err: domain.ErrVaultLocked}
+	}
+
+	rel, err := filepath.Rel(root, clean)
+	if err != nil {
+		return "", nil, "", err
+	}
+	names := strings.Split(rel, string(filepath.Separator))
+	for i, name := range names {
+		if names[i], err = keys.encryptName(name); err != nil {
+			return "", nil, "", &fs.PathError{Op: op, Path: path, Err: err}
+		}
+	}
+	return root, keys, filepath.Join(append([]string{root}, names...)...), nil
+}
+
+// plainError replaces the encrypted path in err with the path the client asked for
+func plainError(err error, path string) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: path, Err: pathErr.Err}
+	}
+	return err
+}
+
+// vaultInfo shows the decrypted name and the size of the content of an entry
+type vaultInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func newVaultInfo(info fs.FileInfo, name string) vaultInfo {
+	return vaultInfo{FileInfo: info, name: name}
+}
+
+func (i vaultInfo) Name() string { return i.name }
+
+func (i vaultInfo) Size() int64 {
+	if i.IsDir() {
+		return i.FileInfo.Size()
+	}
+	return plainSize(i.FileInfo.Size())
+}
+
+// dirFile is a directory opened inside a vault
+type dirFile struct {
+	storagedomain.File
+	info fs.FileInfo
+}
+
+func (f *dirFile) Stat() (fs.FileInfo, error) { return f.info, nil }
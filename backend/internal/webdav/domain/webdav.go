@@ -0,0 +1,12 @@
+package domain
+
+import "errors"
+
+// Prefix is the URL path where the library roots are mounted
+const Prefix = "/dav"
+
+// AuditSource identifies WebDAV requests in the audit log
+const AuditSource = "webdav"
+
+// ErrRootEntry is returned when a client tries to delete, rename or write a library root itself
+var ErrRootEntry = errors.New("library roots cannot be modified")
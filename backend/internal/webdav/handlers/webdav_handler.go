@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/webdav"
+
+	auditdomain "github.com/infortech07/cubert/internal/audit/domain"
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	quotadomain "github.com/infortech07/cubert/internal/quota/domain"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	vaultdomain "github.com/infortech07/cubert/internal/vault/domain"
+	"github.com/infortech07/cubert/internal/webdav/domain"
+	"github.com/infortech07/cubert/internal/webdav/services"
+)
+
+// Methods are the WebDAV verbs that must be known to the router besides the standard ones
+var Methods = []string{"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK"}
+
+type WebDAVHandler struct {
+	fileSystem *services.FileSystem
+	dav        *webdav.Handler
+}
+
+func NewWebDAVHandler(fileSystem *services.FileSystem) *WebDAVHandler {
+	return &WebDAVHandler{
+		fileSystem: fileSystem,
+		dav: &webdav.Handler{
+			Prefix:     domain.Prefix,
+			FileSystem: fileSystem,
+			// Los bloqueos viven en memoria: bastan para la clase 2 y caducan solos
+			LockSystem: webdav.NewMemLS(),
+			Logger: func(r *http.Request, err error) {
+				auditdomain.SetError(r.Context(), err)
+			},
+		},
+	}
+}
+
+// ServeHTTP checks the ACL of the request and its destination before handing it to the
+// WebDAV server, so denials are reported as 403 instead of the generic WebDAV errors
+func (h *WebDAVHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auditdomain.SetSource(ctx, domain.AuditSource)
+	auditdomain.SetAction(ctx, "dav."+strings.ToLower(r.Method))
+
+	access := authdomain.AccessWrite
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND", "COPY":
+		access = authdomain.AccessRead
+	}
+	if !h.authorize(w, r, r.URL.Path, access) {
+		return
+	}
+
+	if r.Method == "COPY" || r.Method == "MOVE" {
+		if destination, err := url.Parse(r.Header.Get("Destination")); err == nil && destination.Path != "" {
+			if !h.authorize(w, r, destination.Path, authdomain.AccessWrite) {
+				return
+			}
+		}
+	}
+
+	// Las subidas que no caben se rechazan antes de leer el cuerpo
+	if r.Method == http.MethodPut && r.ContentLength > 0 {
+		if name, ok := strings.CutPrefix(r.URL.Path, domain.Prefix); ok {
+			if err := h.fileSystem.CheckQuota(ctx, name, r.ContentLength); errors.Is(err, quotadomain.ErrQuotaExceeded) {
+				utils.WriteErrorResponse(w, http.StatusInsufficientStorage, "Not enough quota for the upload", err)
+				return
+			}
+		}
+	}
+
+	h.dav.ServeHTTP(w, r)
+}
+
+func (h *WebDAVHandler) authorize(w http.ResponseWriter, r *http.Request, urlPath string, access authdomain.Access) bool {
+	name, ok := strings.CutPrefix(urlPath, domain.Prefix)
+	if !ok {
+		// El servidor WebDAV responde por sí mismo a los destinos fuera del prefijo
+		return true
+	}
+
+	local, err := h.fileSystem.Authorize(r.Context(), name, access)
+	auditdomain.AddPaths(r.Context(), local)
+	switch {
+	case err == nil, errors.Is(err, os.ErrNotExist):
+		return true
+	case errors.Is(err, domain.ErrRootEntry):
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Library roots cannot be modified", err)
+	case errors.Is(err, vaultdomain.ErrVaultLocked):
+		utils.WriteErrorResponse(w, http.StatusLocked, "Vault is locked", err)
+	default:
+		utils.WriteErrorResponse(w, http.StatusForbidden, "Access denied", err)
+	}
+	return false
+}
@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	authdomain "github.com/infortech07/cubert/internal/auth/domain"
+	authservices "github.com/infortech07/cubert/internal/auth/services"
+	fsdomain "github.com/infortech07/cubert/internal/filesystem/domain"
+	fsservices "github.com/infortech07/cubert/internal/filesystem/services"
+	quotaservices "github.com/infortech07/cubert/internal/quota/services"
+	"github.com/infortech07/cubert/internal/shared/utils"
+	storagedomain "github.com/infortech07/cubert/internal/storage/domain"
+	vaultservices "github.com/infortech07/cubert/internal/vault/services"
+	"github.com/infortech07/cubert/internal/webdav/domain"
+)
+
+// mount is a library root exposed as a top-level folder
+type mount struct {
+	name string
+	path string
+}
+
+// FileSystem exposes the library roots through webdav.FileSystem, enforcing the ACL of the
+// user in the request context. With a single root its content is served directly; with
+// several roots each one appears as a top-level folder named after it. Files are read and
+// written on the local disk, so the encrypted vaults of storage are refused.
+type FileSystem struct {
+	mounts   []mount
+	storage  storagedomain.Storage
+	acl      *authservices.ACLService
+	trash    *fsservices.TrashService
+	versions *fsservices.VersionService
+	quotas   *quotaservices.QuotaService
+	started  time.Time
+}
+
+func NewFileSystem(
+	roots []string,
+	acl *authservices.ACLService,
+	trash *fsservices.TrashService,
+	versions *fsservices.VersionService,
+	quotas *quotaservices.QuotaService,
+	storage storagedomain.Storage,
+) *FileSystem {
+	fsys := &FileSystem{
+		storage:  storage,
+		acl:      acl,
+		trash:    trash,
+		versions: versions,
+		quotas:   quotas,
+		started:  time.Now(),
+	}
+
+	names := utils.RootNames(roots, nil)
+	for i, root := range roots {
+		fsys.mounts = append(fsys.mounts, mount{name: names[i], path: filepath.Clean(root)})
+	}
+	return fsys
+}
+
+// Resolve maps a WebDAV name to a local path. virtual is set for the folder listing the
+// roots, which has no local path.
+func (f *FileSystem) Resolve(name string) (local string, virtual bool, err error) {
+	name = path.Clean("/" + name)
+	if len(f.mounts) == 1 {
+		return filepath.Join(f.mounts[0].path, filepath.FromSlash(name)), false, nil
+	}
+	if name == "/" {
+		return "", true, nil
+	}
+
+	first, rest, _ := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	for _, m := range f.mounts {
+		if m.name == first {
+			return filepath.Join(m.path, filepath.FromSlash(rest)), false, nil
+		}
+	}
+	return "", false, os.ErrNotExist
+}
+
+// Authorize resolves name and checks the access of the user in ctx. Read access is also
+// granted on folders leading to a path the user may read, so restricted users can browse
+// down to it; write access is never granted on the roots themselves. Paths inside vaults
+// fail with ErrVaultLocked.
+func (f *FileSystem) Authorize(ctx context.Context, name string, access authdomain.Access) (string, error) {
+	local, virtual, err := f.Resolve(name)
+	if err != nil {
+		return "", err
+	}
+	if virtual {
+		if access == authdomain.AccessWrite {
+			return "", fmt.Errorf("%w: %w", os.ErrPermission, domain.ErrRootEntry)
+		}
+		return "", nil
+	}
+
+	if access == authdomain.AccessWrite {
+		for _, m := range f.mounts {
+			if local == m.path {
+				return local, fmt.Errorf("%w: %w", os.ErrPermission, domain.ErrRootEntry)
+			}
+		}
+	}
+
+	user, _ := authdomain.UserFromContext(ctx)
+	aclErr := f.acl.Authorize(ctx, user, local, access)
+	if aclErr != nil && (access != authdomain.AccessRead || !f.leadsToAllowed(user, local)) {
+		return local, fmt.Errorf("%w: %w", os.ErrPermission, aclErr)
+	}
+	return local, vaultservices.CheckDiskPath(ctx, f.storage, local)
+}
+
+func (f *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	local, err := f.Authorize(ctx, name, authdomain.AccessWrite)
+	if err != nil {
+		return err
+	}
+	return f.storage.Mkdir(ctx, local)
+}
+
+func (f *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		local, err := f.Authorize(ctx, name, authdomain.AccessWrite)
+		if err != nil {
+			return nil, err
+		}
+		// Un fichero nuevo ocupa una entrada de la cuota; los bytes se cuentan al escribir
+		allowance := f.quotas.Allowance(userID(ctx), local)
+		var size int64
+		info, statErr := os.Stat(local)
+		if statErr == nil && flag&os.O_TRUNC == 0 {
+			size = info.Size()
+		}
+		if os.IsNotExist(statErr) && flag&os.O_CREATE != 0 {
+			if err := allowance.Take(0, 1); err != nil {
+				return nil, err
+			}
+		}
+		// Guardar la versión anterior antes de sobrescribir, como en el editor
+		if flag&os.O_TRUNC != 0 && f.versions != nil {
+			if _, err := f.versions.Snapshot(ctx, local, userID(ctx), fsdomain.VersionReasonEdit); err != nil {
+				log.Printf("webdav: failed to snapshot %s: %v", local, err)
+			}
+		}
+		file, err := os.OpenFile(local, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &localFile{File: file, fsys: f, ctx: ctx, path: local, readable: true, allowance: allowance, size: size}, nil
+	}
+
+	local, err := f.Authorize(ctx, name, authdomain.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	if local == "" {
+		return &rootsDir{fsys: f, ctx: ctx}, nil
+	}
+
+	file, err := os.Open(local)
+	if err != nil {
+		return nil, err
+	}
+	// Las carpetas intermedias solo listan lo que conduce a rutas permitidas
+	user, _ := authdomain.UserFromContext(ctx)
+	readable := f.acl.Authorize(ctx, user, local, authdomain.AccessRead) == nil
+	if !readable {
+		if info, err := file.Stat(); err != nil || !info.IsDir() {
+			file.Close()
+			return nil, fmt.Errorf("%w: %s", os.ErrPermission, name)
+		}
+	}
+	return &localFile{File: file, fsys: f, ctx: ctx, path: local, readable: readable}, nil
+}
+
+// RemoveAll moves the entry to the trash instead of deleting it
+func (f *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	local, err := f.Authorize(ctx, name, authdomain.AccessWrite)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(local); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	_, err = f.trash.Move(ctx, local, userID(ctx))
+	return err
+}
+
+func (f *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldLocal, err := f.Authorize(ctx, oldName, authdomain.AccessWrite)
+	if err != nil {
+		return err
+	}
+	newLocal, err := f.Authorize(ctx, newName, authdomain.AccessWrite)
+	if err != nil {
+		return err
+	}
+	// A través del almacenamiento, para que las bóvedas sigan a las carpetas que las contienen
+	return f.storage.Rename(ctx, oldLocal, newLocal)
+}
+
+func (f *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	local, err := f.Authorize(ctx, name, authdomain.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	if local == "" {
+		return virtualDirInfo{modTime: f.started}, nil
+	}
+	return os.Stat(local)
+}
+
+// leadsToAllowed reports whether one of the paths granted to the user is below local
+func (f *FileSystem) leadsToAllowed(user *authdomain.User, local string) bool {
+	if user == nil {
+		return false
+	}
+	for _, allowed := range f.acl.AllowedRoots(user) {
+		if rel, err := filepath.Rel(local, allowed); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// visible reports whether a directory entry may be listed to the user in ctx
+func (f *FileSystem) visible(ctx context.Context, local string) bool {
+	user, _ := authdomain.UserFromContext(ctx)
+	return f.acl.Authorize(ctx, user, local, authdomain.AccessRead) == nil || f.leadsToAllowed(user, local)
+}
+
+func userID(ctx context.Context) string {
+	if user, ok := authdomain.UserFromContext(ctx); ok {
+		return user.ID
+	}
+	return ""
+}
+
+// localFile filters the listing of folders the user can only traverse
+type localFile struct {
+	*os.File
+	fsys     *FileSystem
+	ctx      context.Context
+	path     string
+	readable bool
+
+	// Solo en ficheros abiertos para escritura: lo que el fichero puede crecer
+	allowance *quotaservices.Allowance
+	size      int64
+}
+
+func (l *localFile) Write(p []byte) (int, error) {
+	if err := l.grow(int64(len(p))); err != nil {
+		return 0, err
+	}
+	return l.File.Write(p)
+}
+
+func (l *localFile) WriteAt(p []byte, off int64) (int, error) {
+	if end := off + int64(len(p)); end > l.size {
+		if err := l.grow(end - l.size); err != nil {
+			return 0, err
+		}
+	}
+	return l.File.WriteAt(p, off)
+}
+
+func (l *localFile) grow(bytes int64) error {
+	if l.allowance == nil {
+		return nil
+	}
+	if err := l.allowance.Take(bytes, 0); err != nil {
+		return err
+	}
+	l.size += bytes
+	return nil
+}
+
+// Truncate changes the size of a file like truncate(2). The growth is checked against the
+// quotas and the previous content is kept as a version, as when the file is overwritten.
+func (f *FileSystem) Truncate(ctx context.Context, name string, size int64) error {
+	local, err := f.Authorize(ctx, name, authdomain.AccessWrite)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%w: %s is a directory", fs.ErrInvalid, name)
+	}
+	if size == info.Size() {
+		return nil
+	}
+
+	if size > info.Size() {
+		if err := f.quotas.Check(userID(ctx), local, size-info.Size(), 0); err != nil {
+			return err
+		}
+	}
+	if f.versions != nil {
+		if _, err := f.versions.Snapshot(ctx, local, userID(ctx), fsdomain.VersionReasonEdit); err != nil {
+			log.Printf("webdav: failed to snapshot %s: %v", local, err)
+		}
+	}
+	return os.Truncate(local, size)
+}
+
+// CheckQuota fails with ErrQuotaExceeded if a file of the given size does not fit at name
+func (f *FileSystem) CheckQuota(ctx context.Context, name string, bytes int64) error {
+	local, err := f.Authorize(ctx, name, authdomain.AccessWrite)
+	if err != nil || local == "" {
+		return nil
+	}
+	var files int64
+	if _, err := os.Stat(local); os.IsNotExist(err) {
+		files = 1
+	}
+	return f.quotas.Check(userID(ctx), local, bytes, files)
+}
+
+func (l *localFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if l.readable {
+		return l.File.Readdir(count)
+	}
+	for {
+		infos, err := l.File.Readdir(count)
+		visible := infos[:0]
+		for _, info := range infos {
+			if l.fsys.visible(l.ctx, filepath.Join(l.path, info.Name())) {
+				visible = append(visible, info)
+			}
+		}
+		if len(visible) > 0 || err != nil || count <= 0 {
+			return visible, err
+		}
+	}
+}
+
+// rootsDir is the virtual folder listing the roots when several are configured
+type rootsDir struct {
+	fsys *FileSystem
+	ctx  context.Context
+	read bool
+}
+
+func (d *rootsDir) Close() error { return nil }
+
+func (d *rootsDir) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("%w: is a directory", os.ErrInvalid)
+}
+
+func (d *rootsDir) Seek(int64, int) (int64, error) { return 0, nil }
+
+func (d *rootsDir) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("%w: %w", os.ErrPermission, domain.ErrRootEntry)
+}
+
+func (d *rootsDir) Stat() (fs.FileInfo, error) {
+	return virtualDirInfo{modTime: d.fsys.started}, nil
+}
+
+func (d *rootsDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if d.read {
+		if count > 0 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	}
+	d.read = true
+
+	var infos []fs.FileInfo
+	for _, m := range d.fsys.mounts {
+		if !d.fsys.visible(d.ctx, m.path) {
+			continue
+		}
+		info, err := os.Stat(m.path)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, namedInfo{FileInfo: info, name: m.name})
+	}
+	return infos, nil
+}
+
+// namedInfo reports a root under its mount name
+type namedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (n namedInfo) Name() string { return n.name }
+
+type virtualDirInfo struct {
+	modTime time.Time
+}
+
+func (v virtualDirInfo) Name() string       { return "/" }
+func (v virtualDirInfo) Size() int64        { return 0 }
+func (v virtualDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (v virtualDirInfo) ModTime() time.Time { return v.modTime }
+func (v virtualDirInfo) IsDir() bool        { return true }
+func (v virtualDirInfo) Sys() interface{}   { return nil }
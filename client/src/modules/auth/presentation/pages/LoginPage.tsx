@@ -1,12 +1,31 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
 import AuthLayout from '../../../../shared/components/layout/AuthLayout';
-import { Lock, User, ArrowRight } from 'lucide-react';
+import { Lock, User, ArrowRight, KeyRound } from 'lucide-react';
 import { motion } from 'motion/react';
 import { TextShimmer } from '../../../../shared/components/ui/motion-primitives/text-shimmer-basic';
 
+interface SSOProvider {
+  enabled: boolean;
+  name: string;
+  url: string;
+}
+
 const LoginPage: React.FC = () => {
   const [username, setUsername] = useState('');
   const [password, setPassword] = useState('');
+  const [sso, setSso] = useState<SSOProvider | null>(null);
+
+  useEffect(() => {
+    // Consultar si el servidor tiene SSO (OpenID Connect) configurado
+    fetch('/api/v1/auth/providers')
+      .then((res) => (res.ok ? res.json() : null))
+      .then((body) => {
+        if (body?.data?.oidc?.enabled) {
+          setSso(body.data.oidc);
+        }
+      })
+      .catch(() => setSso(null));
+  }, []);
 
   const handleSubmit = (e: React.FormEvent) => {
     e.preventDefault();
@@ -75,6 +94,19 @@ const LoginPage: React.FC = () => {
                       <ArrowRight className="w-5 h-5" />
                     </motion.button>
                   </form>
+
+                  {/* Acceso mediante SSO */}
+                  {sso && (
+                    <motion.a
+                      href={sso.url}
+                      whileHover={{ scale: 1.02 }}
+                      whileTap={{ scale: 0.98 }}
+                      className="mt-4 w-full py-2 border border-white/20 rounded-xl futuristic-text font-medium text-lg transition-all duration-300 flex items-center justify-center gap-3 hover:border-blue-400/60"
+                    >
+                      <KeyRound className="w-5 h-5" />
+                      Acceder con {sso.name}
+                    </motion.a>
+                  )}
                 </div>
               </div>
             </motion.div>